@@ -0,0 +1,128 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PRLabel records the pull request number on the resources created for a
+	// pull request preview
+	PRLabel = "preview.previewd.io/pr"
+
+	// BranchLabel records the branch (see PreviewEnvironmentSpec.PreviewID) on
+	// the resources created for a branch preview
+	BranchLabel = "preview.previewd.io/branch"
+
+	// RepositoryLabel records the repository hash (see RepositoryHash) on the
+	// resources created for a preview, telling apart previews of different
+	// repositories that share a pull request number or branch name
+	RepositoryLabel = "preview.previewd.io/repository"
+
+	// maxBranchNameLength bounds the part of a branch name used in resource names
+	maxBranchNameLength = 20
+)
+
+// IsBranchPreview reports whether the preview environment was created for a
+// branch rather than a pull request
+func (s *PreviewEnvironmentSpec) IsBranchPreview() bool {
+	return s.Branch != ""
+}
+
+// PreviewID identifies the pull request or branch in the names of the
+// resources created for the preview environment: "pr-<number>" for a pull
+// request, and "br-<branch>-<hash>" for a branch, where <branch> is the branch
+// name reduced to at most 20 DNS label characters and <hash> keeps branches
+// that reduce to the same name apart.
+func (s *PreviewEnvironmentSpec) PreviewID() string {
+	if !s.IsBranchPreview() {
+		return fmt.Sprintf("pr-%d", s.PRNumber)
+	}
+
+	hash := sha256.Sum256([]byte(s.Branch))
+	if name := dnsLabelPrefix(s.Branch, maxBranchNameLength); name != "" {
+		return fmt.Sprintf("br-%s-%x", name, hash[:3])
+	}
+	return fmt.Sprintf("br-%x", hash[:3])
+}
+
+// RepositoryHash returns the short hash of a repository that resource names
+// and the RepositoryLabel include, so pull requests and branches of different
+// repositories never collide. Repository names are case-insensitive, like
+// everywhere else repositories are compared.
+func RepositoryHash(repository string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(repository)))
+	return fmt.Sprintf("%x", hash[:4])
+}
+
+// InstanceID identifies the preview environment across repositories: its
+// PreviewID followed by the repository hash, e.g. "pr-42-1a2b3c4d". It names
+// the resources previews share a scope for, such as ArgoCD ApplicationSets and
+// ingress hosts.
+func (s *PreviewEnvironmentSpec) InstanceID() string {
+	return s.PreviewID() + "-" + RepositoryHash(s.Repository)
+}
+
+// IdentityLabels returns the labels recording the pull request or branch and
+// the repository on the resources created for the preview environment
+func (s *PreviewEnvironmentSpec) IdentityLabels() map[string]string {
+	key, value := s.IdentityLabel()
+	return map[string]string{
+		key:             value,
+		RepositoryLabel: RepositoryHash(s.Repository),
+	}
+}
+
+// IdentityLabel returns the label key and value recording the pull request or
+// branch on the resources created for the preview environment
+func (s *PreviewEnvironmentSpec) IdentityLabel() (string, string) {
+	if s.IsBranchPreview() {
+		return BranchLabel, s.PreviewID()
+	}
+	return PRLabel, strconv.Itoa(s.PRNumber)
+}
+
+// dnsLabelPrefix lower-cases name, replaces runs of characters not allowed in
+// a DNS label with a dash and truncates the result to maxLength characters
+// without leading or trailing dashes
+func dnsLabelPrefix(name string, maxLength int) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+	}
+
+	label := b.String()
+	if len(label) > maxLength {
+		label = label[:maxLength]
+	}
+	return strings.Trim(label, "-")
+}
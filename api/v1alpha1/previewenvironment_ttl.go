@@ -0,0 +1,66 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTTL is how long a preview environment lives when Spec.TTL is empty
+const DefaultTTL = 4 * time.Hour
+
+// ParseTTL parses a TTL in the format of Spec.TTL: a Go duration (e.g. "4h",
+// "30m") or a whole number of days (e.g. "2d"). An empty TTL is DefaultTTL.
+// It accepts the same values as the CRD validation pattern of Spec.TTL, so a
+// TTL it parses is never rejected by the API server.
+func ParseTTL(ttl string) (time.Duration, error) {
+	if ttl == "" {
+		return DefaultTTL, nil
+	}
+
+	// Handle days specially (e.g., "2d" -> 48h)
+	if daysStr, ok := strings.CutSuffix(ttl, "d"); ok {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || strings.TrimLeft(daysStr, "0123456789") != "" {
+			return 0, fmt.Errorf("invalid TTL format: %s", ttl)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	// Go durations also allow signs, fractions, "µs" and a bare "0", none of
+	// which the CRD pattern admits: only digits and ASCII units are accepted,
+	// and the TTL must end in a unit.
+	if strings.Trim(ttl, "0123456789nsumh") != "" || strings.TrimRight(ttl, "nsumh") == ttl {
+		return 0, fmt.Errorf("invalid TTL format: %s", ttl)
+	}
+
+	// Parse standard Go duration formats
+	duration, err := time.ParseDuration(ttl)
+	if err != nil {
+		return 0, fmt.Errorf("invalid TTL format: %s", ttl)
+	}
+	return duration, nil
+}
@@ -26,23 +26,81 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// ProviderGitHub is the Provider value for repositories hosted on GitHub
+	ProviderGitHub = "github"
+
+	// ProviderGitLab is the Provider value for projects hosted on GitLab
+	ProviderGitLab = "gitlab"
+
+	// ProviderGitea is the Provider value for repositories hosted on Gitea or Forgejo
+	ProviderGitea = "gitea"
+
+	// ProviderBitbucket is the Provider value for repositories hosted on Bitbucket Cloud
+	ProviderBitbucket = "bitbucket"
+)
+
+const (
+	// SleepAnnotation, when set to "true", puts the preview environment to sleep:
+	// its services are undeployed while the namespace and ingress are kept.
+	SleepAnnotation = "previewd.io/sleep"
+
+	// RedeployAnnotation records when a redeploy was last requested (RFC 3339).
+	// When it changes, the controller refreshes the preview's ArgoCD
+	// Applications and restarts its workloads, then records the value in
+	// Status.ObservedRedeployRequest.
+	RedeployAnnotation = "previewd.io/redeploy-requested-at"
+
+	// HeadUpdatedAtAnnotation records when the pull request was last updated
+	// according to the webhook event that set Spec.HeadSHA (RFC 3339). Events
+	// older than this are stale and must not roll the preview back.
+	HeadUpdatedAtAnnotation = "previewd.io/head-updated-at"
+
+	// TTLOverrideAnnotation holds the TTL set with the /preview extend command.
+	// It takes precedence over the TTL from .previewd.yaml when the webhook
+	// server updates Spec.TTL.
+	TTLOverrideAnnotation = "previewd.io/ttl-override"
+
+	// ServicesOverrideAnnotation holds the comma-separated services chosen with
+	// the /preview services command. It takes precedence over detected and
+	// configured services when the webhook server updates Spec.Services.
+	ServicesOverrideAnnotation = "previewd.io/services-override"
+
+	// ConfigServicesAnnotation, when set to "true", records that Spec.Services
+	// came from .previewd.yaml rather than service detection, so the webhook
+	// server replaces them when the file's services change or are removed.
+	ConfigServicesAnnotation = "previewd.io/services-from-config"
+)
+
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 // PreviewEnvironmentSpec defines the desired state of PreviewEnvironment
+// +kubebuilder:validation:XValidation:rule="has(self.prNumber) != has(self.branch)",message="exactly one of prNumber and branch must be set"
 type PreviewEnvironmentSpec struct {
 	// Services is the list of services to deploy in the preview environment
 	// +optional
 	Services []string `json:"services,omitempty"`
 
-	// Repository is the GitHub repository (format: "owner/repo" or "https://github.com/owner/repo")
+	// Repository is the repository path on the Git provider. GitHub repositories use
+	// "owner/repo" or "https://github.com/owner/repo"; GitLab projects use their full
+	// path, which may include subgroups (e.g. "group/subgroup/project").
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Pattern=`^([a-zA-Z0-9_-]+/[a-zA-Z0-9_-]+|https://github\.com/[a-zA-Z0-9_-]+/[a-zA-Z0-9_-]+)$`
+	// +kubebuilder:validation:Pattern=`^([a-zA-Z0-9._-]+(/[a-zA-Z0-9._-]+)+|https://github\.com/[a-zA-Z0-9_-]+/[a-zA-Z0-9_-]+)$`
 	Repository string `json:"repository"`
 
-	// HeadSHA is the git commit SHA of the PR head (40-character hex string)
+	// Provider is the Git provider hosting the repository. It selects the API used
+	// to report commit statuses and pull/merge request comments; previewd does not
+	// report to gitea or bitbucket. Defaults to github.
+	// +kubebuilder:validation:Enum=github;gitlab;gitea;bitbucket
+	// +kubebuilder:default=github
+	// +optional
+	Provider string `json:"provider,omitempty"`
+
+	// HeadSHA is the git commit SHA of the PR or branch head. It is a full 40-character hex
+	// string, except for Bitbucket Cloud, whose webhooks carry abbreviated hashes.
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Pattern=`^[0-9a-f]{40}$`
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{7,40}$`
 	HeadSHA string `json:"headSHA"`
 
 	// ResourceQuota defines resource limits for the preview environment namespace
@@ -53,10 +111,33 @@ type PreviewEnvironmentSpec struct {
 	// +optional
 	IngressPort *int32 `json:"ingressPort,omitempty"`
 
-	// PRNumber is the pull request number
-	// +kubebuilder:validation:Required
+	// TTL is how long the preview environment lives before it is automatically deleted.
+	// Accepts Go durations (e.g. "4h", "90m", "1h30m") or whole days (e.g. "2d"). Defaults to 4h.
+	// +kubebuilder:validation:Pattern=`^([0-9]+d|([0-9]+(ns|us|ms|s|m|h))+)$`
+	// +optional
+	TTL string `json:"ttl,omitempty"`
+
+	// SourcePath is the repository path ArgoCD deploys each service from.
+	// "{{service}}" is replaced with the service name. Defaults to "services/{{service}}".
+	// +optional
+	SourcePath string `json:"sourcePath,omitempty"`
+
+	// IngressPaths overrides the ingress path prefix per service.
+	// By default "frontend" is served at "/" and other services at "/<service>".
+	// +optional
+	IngressPaths map[string]string `json:"ingressPaths,omitempty"`
+
+	// PRNumber is the pull request number. Exactly one of PRNumber and Branch is set.
 	// +kubebuilder:validation:Minimum=1
-	PRNumber int `json:"prNumber"`
+	// +optional
+	PRNumber int `json:"prNumber,omitempty"`
+
+	// Branch is the branch previewed by a branch preview, which is created from
+	// pushes to a branch rather than from a pull request.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=255
+	// +optional
+	Branch string `json:"branch,omitempty"`
 }
 
 // ResourceQuotaSpec defines resource quota limits for a preview environment
@@ -103,8 +184,8 @@ type PreviewEnvironmentStatus struct {
 	LastSyncedAt *metav1.Time `json:"lastSyncedAt,omitempty"`
 
 	// Phase represents the current phase of the preview environment
-	// Valid values: Pending, Creating, Ready, Updating, Deleting, Failed
-	// +kubebuilder:validation:Enum=Pending;Creating;Ready;Updating;Deleting;Failed
+	// Valid values: Pending, Creating, Ready, Updating, Sleeping, Deleting, Failed
+	// +kubebuilder:validation:Enum=Pending;Creating;Ready;Updating;Sleeping;Deleting;Failed
 	// +optional
 	Phase string `json:"phase,omitempty"`
 
@@ -116,6 +197,21 @@ type PreviewEnvironmentStatus struct {
 	// +optional
 	Namespace string `json:"namespace,omitempty"`
 
+	// DeployedSHA is the head SHA the services were last fully rolled out at: every
+	// service's Application reported it synced and healthy. While it differs from
+	// Spec.HeadSHA, a rollout is in progress.
+	// +optional
+	DeployedSHA string `json:"deployedSHA,omitempty"`
+
+	// ObservedRedeployRequest is the RedeployAnnotation value the services were
+	// last redeployed for
+	// +optional
+	ObservedRedeployRequest string `json:"observedRedeployRequest,omitempty"`
+
+	// DeploymentID is the GitHub deployment ID tracking this preview environment
+	// +optional
+	DeploymentID int64 `json:"deploymentID,omitempty"`
+
 	// conditions represent the current state of the PreviewEnvironment resource.
 	// Each condition has a unique type and reflects the status of a specific aspect of the resource.
 	//
@@ -148,7 +244,26 @@ type ServiceStatus struct {
 	// +optional
 	URL string `json:"url,omitempty"`
 
-	// Ready indicates if the service is ready
+	// Health is the ArgoCD health status of the service's Application: Healthy,
+	// Progressing, Degraded, Suspended, Missing or Unknown
+	// +optional
+	Health string `json:"health,omitempty"`
+
+	// Sync is the ArgoCD sync status of the service's Application: Synced,
+	// OutOfSync or Unknown
+	// +optional
+	Sync string `json:"sync,omitempty"`
+
+	// Revision is the git revision the service's Application last synced
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// Message explains the health status, if ArgoCD reports a reason
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Ready indicates if the service is ready: its Application is Healthy and Synced
+	// at the preview's head SHA
 	Ready bool `json:"ready"`
 }
 
@@ -168,6 +283,7 @@ type CostEstimate struct {
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="PR",type="integer",JSONPath=".spec.prNumber",description="Pull Request Number"
+// +kubebuilder:printcolumn:name="Branch",type="string",JSONPath=".spec.branch",description="Previewed Branch"
 // +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Current Phase"
 // +kubebuilder:printcolumn:name="URL",type="string",JSONPath=".status.url",description="Preview URL"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Creation Time"
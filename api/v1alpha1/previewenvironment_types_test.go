@@ -78,7 +78,7 @@ var _ = Describe("PreviewEnvironment", func() {
 			Expect(preview.Spec.PRNumber).To(Equal(123))
 		})
 
-		It("rejects PR number less than 1", func() {
+		It("rejects a spec with neither PR number nor branch", func() {
 			preview := &PreviewEnvironment{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "invalid-pr-test",
@@ -92,7 +92,55 @@ var _ = Describe("PreviewEnvironment", func() {
 
 			err := k8sClient.Create(ctx, preview)
 			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(ContainSubstring("spec.prNumber"))
+			Expect(err.Error()).To(ContainSubstring("exactly one of prNumber and branch must be set"))
+		})
+	})
+
+	Context("Branch field", func() {
+		It("rejects a spec with both PR number and branch", func() {
+			preview := &PreviewEnvironment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pr-and-branch-test",
+					Namespace: "default",
+				},
+				Spec: PreviewEnvironmentSpec{
+					Repository: "owner/repo",
+					HeadSHA:    "1234567890abcdef1234567890abcdef12345678",
+					PRNumber:   123,
+					Branch:     "release/1.2",
+				},
+			}
+
+			err := k8sClient.Create(ctx, preview)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("exactly one of prNumber and branch must be set"))
+		})
+
+		It("identifies pull request previews by number", func() {
+			spec := PreviewEnvironmentSpec{Repository: "owner/repo", PRNumber: 123}
+
+			Expect(spec.IsBranchPreview()).To(BeFalse())
+			Expect(spec.PreviewID()).To(Equal("pr-123"))
+			key, value := spec.IdentityLabel()
+			Expect(key).To(Equal(PRLabel))
+			Expect(value).To(Equal("123"))
+		})
+
+		It("identifies branch previews by a DNS-safe branch name and hash", func() {
+			spec := PreviewEnvironmentSpec{Repository: "owner/repo", Branch: "Release/2025.10_Hotfix-Candidate"}
+
+			Expect(spec.IsBranchPreview()).To(BeTrue())
+			Expect(spec.PreviewID()).To(MatchRegexp(`^br-release-2025-10-hotf-[0-9a-f]{6}$`))
+			key, value := spec.IdentityLabel()
+			Expect(key).To(Equal(BranchLabel))
+			Expect(value).To(Equal(spec.PreviewID()))
+		})
+
+		It("keeps branches that reduce to the same name apart", func() {
+			a := PreviewEnvironmentSpec{Branch: "feature/a_b"}
+			b := PreviewEnvironmentSpec{Branch: "feature/a-b"}
+
+			Expect(a.PreviewID()).NotTo(Equal(b.PreviewID()))
 		})
 	})
 
@@ -132,6 +180,45 @@ var _ = Describe("PreviewEnvironment", func() {
 		})
 	})
 
+	Context("TTL field", func() {
+		It("accepts day-suffixed TTL", func() {
+			preview := &PreviewEnvironment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ttl-days-test",
+					Namespace: "default",
+				},
+				Spec: PreviewEnvironmentSpec{
+					Repository: "owner/repo",
+					PRNumber:   123,
+					HeadSHA:    "1234567890abcdef1234567890abcdef12345678",
+					TTL:        "2d",
+				},
+			}
+
+			Expect(k8sClient.Create(ctx, preview)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, preview)).To(Succeed())
+		})
+
+		It("rejects invalid TTL format", func() {
+			preview := &PreviewEnvironment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "invalid-ttl-test",
+					Namespace: "default",
+				},
+				Spec: PreviewEnvironmentSpec{
+					Repository: "owner/repo",
+					PRNumber:   123,
+					HeadSHA:    "1234567890abcdef1234567890abcdef12345678",
+					TTL:        "forever",
+				},
+			}
+
+			err := k8sClient.Create(ctx, preview)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.ttl"))
+		})
+	})
+
 	Context("Optional fields", func() {
 		It("accepts Services slice when provided", func() {
 			preview := &PreviewEnvironment{
@@ -123,6 +123,13 @@ func (in *PreviewEnvironmentSpec) DeepCopyInto(out *PreviewEnvironmentSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.IngressPaths != nil {
+		in, out := &in.IngressPaths, &out.IngressPaths
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreviewEnvironmentSpec.
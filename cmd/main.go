@@ -23,18 +23,31 @@ SOFTWARE.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
+	"net"
 	"os"
+	"strings"
+	"time"
 
 	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/argocd"
+	"github.com/mikelane/previewd/internal/cleanup"
 	"github.com/mikelane/previewd/internal/controller"
 	"github.com/mikelane/previewd/internal/cost"
+	"github.com/mikelane/previewd/internal/github"
+	"github.com/mikelane/previewd/internal/gitlab"
+	"github.com/mikelane/previewd/internal/ingress"
+	"github.com/mikelane/previewd/internal/namespace"
+	"github.com/mikelane/previewd/internal/services"
+	githubwebhook "github.com/mikelane/previewd/internal/webhook"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -51,6 +64,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(previewv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(argocd.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -63,6 +77,19 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var argocdNamespace, argocdProject, argocdRepoURL string
+	var previewBaseDomain, certIssuer string
+	var cleanupInterval time.Duration
+	var githubWebhookDeliveryConfigMap, githubWebhookEventConfigMap string
+	var githubWebhookAddr, githubWebhookSecretName, githubWebhookSecretKey, githubWebhookSecretNamespace string
+	var githubWebhookPort, githubWebhookWorkers int
+	var webhookRepoRateLimit, webhookGlobalRateLimit int
+	var githubWebhookLeaderOnly, skipDraftPRs bool
+	var previewLabel, previewNamespace, previewNamespaceMap, branchPreviewPatterns string
+	var githubAppSecretName string
+	var gitlabURL, gitlabWebhookSecretKey string
+	var giteaWebhookSecretKey, bitbucketWebhookSecretKey string
+	var serviceDependencies string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -81,6 +108,91 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&argocdNamespace, "argocd-namespace", "argocd",
+		"The namespace where ArgoCD ApplicationSets are created.")
+	flag.StringVar(&argocdProject, "argocd-project", "default", "The ArgoCD project used for preview Applications.")
+	flag.StringVar(&argocdRepoURL, "argocd-repo-url", "",
+		"The Git repository URL ArgoCD deploys preview services from. Leave empty to disable ApplicationSet creation.")
+	flag.StringVar(&previewBaseDomain, "preview-base-domain", "",
+		"The base domain for preview environment hosts: pr-<number>-<repo hash>.<domain> for pull requests and "+
+			"br-<branch>-<hash>-<repo hash>.<domain> for branches. Leave empty to disable ingress creation.")
+	flag.StringVar(&certIssuer, "cert-issuer", "letsencrypt-prod",
+		"The cert-manager ClusterIssuer used for preview environment TLS certificates.")
+	flag.DurationVar(&cleanupInterval, "cleanup-interval", 5*time.Minute,
+		"How often expired preview environments are checked for deletion.")
+	flag.StringVar(&githubWebhookAddr, "github-webhook-bind-address", os.Getenv("GITHUB_WEBHOOK_BIND_ADDRESS"),
+		"The address the GitHub webhook server binds to (defaults to all interfaces).")
+	flag.IntVar(&githubWebhookPort, "github-webhook-port", 0,
+		"The port the GitHub webhook server listens on. Leave as 0 to disable the webhook server.")
+	flag.StringVar(&githubWebhookSecretName, "github-webhook-secret-name", "",
+		"The name of the Secret holding the webhook secrets of every provider, each under its own data keys. "+
+			"The Secret is watched, so keys can be rotated without a restart. If empty, the "+
+			"GITHUB_WEBHOOK_SECRET environment variable is used for GitHub. "+
+			"The default RBAC only grants access to a Secret named previewd-github-webhook.")
+	flag.StringVar(&githubWebhookSecretKey, "github-webhook-secret-key", "webhook-secret,previous-webhook-secret",
+		"Comma-separated keys within the webhook Secret holding accepted HMAC keys, current first. "+
+			"Missing keys are skipped; signatures matching any present key are accepted.")
+	flag.StringVar(&githubWebhookSecretNamespace, "github-webhook-secret-namespace", os.Getenv("POD_NAMESPACE"),
+		"The namespace of the webhook and GitHub App Secrets and the webhook ConfigMaps "+
+			"(defaults to the POD_NAMESPACE environment variable, which the manager Deployment sets).")
+	flag.StringVar(&githubAppSecretName, "github-app-secret-name", "",
+		"The name of the Secret holding GitHub App credentials (app-id and private-key keys) "+
+			"in the --github-webhook-secret-namespace namespace. Takes precedence over the GITHUB_TOKEN "+
+			"environment variable, which otherwise holds the token used to report commit statuses and PR comments. "+
+			"The default RBAC only grants access to a Secret named previewd-github-app.")
+	flag.StringVar(&gitlabURL, "gitlab-url", gitlab.DefaultBaseURL,
+		"The base URL of the GitLab instance hosting merge requests.")
+	flag.StringVar(&gitlabWebhookSecretKey, "gitlab-webhook-secret-key",
+		"gitlab-webhook-token,previous-gitlab-webhook-token",
+		"Comma-separated keys within the webhook Secret holding the secret tokens GitLab sends in X-Gitlab-Token, "+
+			"current first. If --github-webhook-secret-name is empty, the GITLAB_WEBHOOK_TOKEN environment "+
+			"variable is used. GitLab webhooks are rejected unless a token is set at startup.")
+	flag.StringVar(&giteaWebhookSecretKey, "gitea-webhook-secret-key",
+		"gitea-webhook-secret,previous-gitea-webhook-secret",
+		"Comma-separated keys within the webhook Secret holding the secrets signing Gitea and Forgejo webhooks, "+
+			"current first. If --github-webhook-secret-name is empty, the GITEA_WEBHOOK_SECRET environment "+
+			"variable is used. Gitea webhooks are rejected unless a secret is set at startup.")
+	flag.StringVar(&bitbucketWebhookSecretKey, "bitbucket-webhook-secret-key",
+		"bitbucket-webhook-secret,previous-bitbucket-webhook-secret",
+		"Comma-separated keys within the webhook Secret holding the secrets signing Bitbucket Cloud webhooks, "+
+			"current first. If --github-webhook-secret-name is empty, the BITBUCKET_WEBHOOK_SECRET environment "+
+			"variable is used. Bitbucket webhooks are rejected unless a secret is set at startup.")
+	flag.StringVar(&serviceDependencies, "service-dependencies", "",
+		"The service dependency graph used to detect affected services, as service=dep1,dep2;other=dep3. "+
+			"Services depending on a changed service are deployed too.")
+	flag.StringVar(&previewLabel, "preview-label", "",
+		"If set, only pull requests with this label get a preview environment; "+
+			"adding the label creates it and removing the label tears it down.")
+	flag.StringVar(&previewNamespace, "preview-namespace", githubwebhook.DefaultPreviewNamespace,
+		"The namespace the GitHub webhook server creates PreviewEnvironments in.")
+	flag.StringVar(&previewNamespaceMap, "preview-namespace-map", "",
+		"Per-repository PreviewEnvironment namespaces, as owner/repo=namespace;other/repo=namespace. "+
+			"Repositories without an entry use --preview-namespace.")
+	flag.StringVar(&branchPreviewPatterns, "branch-preview-patterns", "",
+		"Comma-separated branch name patterns (e.g. main,release/*) whose pushes create a preview environment "+
+			"for the branch; deleting the branch deletes it. Not available for Bitbucket Cloud. "+
+			"Leave empty to preview pull requests only.")
+	flag.BoolVar(&skipDraftPRs, "skip-draft-prs", false,
+		"If set, draft pull requests get no preview environment until they are marked ready for review.")
+	flag.IntVar(&githubWebhookWorkers, "github-webhook-workers", 4,
+		"The number of workers processing accepted GitHub webhook events. "+
+			"Events for the same pull request are always processed one at a time.")
+	flag.IntVar(&webhookRepoRateLimit, "github-webhook-repo-rate-limit", 10,
+		"The number of webhook requests per second accepted for each repository, and the burst size.")
+	flag.IntVar(&webhookGlobalRateLimit, "github-webhook-global-rate-limit", 0,
+		"The number of webhook requests per second accepted across all repositories, and the burst size. "+
+			"Leave as 0 for no global limit.")
+	flag.StringVar(&githubWebhookDeliveryConfigMap, "github-webhook-delivery-configmap", "",
+		"The name of a ConfigMap in the --github-webhook-secret-namespace namespace used to share "+
+			"processed webhook delivery IDs between replicas. If empty, deliveries are deduplicated in memory. "+
+			"The default RBAC only grants access to a ConfigMap named previewd-webhook-deliveries.")
+	flag.StringVar(&githubWebhookEventConfigMap, "github-webhook-event-configmap", "",
+		"The name of a ConfigMap in the --github-webhook-secret-namespace namespace where accepted webhook "+
+			"events are kept until processed, so they are replayed after a restart. If empty, events still "+
+			"queued when the webhook server stops are lost. "+
+			"The default RBAC only grants access to a ConfigMap named previewd-webhook-events.")
+	flag.BoolVar(&githubWebhookLeaderOnly, "github-webhook-leader-only", true,
+		"If set, only the elected leader runs the GitHub webhook server and writes PreviewEnvironments.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -89,6 +201,23 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	// Credentials are only read from the environment, never from flags, so they
+	// do not show up in usage output or the process list
+	githubToken := os.Getenv("GITHUB_TOKEN")
+	// The GitLab token reads .previewd.yaml and reports commit statuses and MR
+	// notes; without it GitLab reporting is disabled
+	gitlabToken := os.Getenv("GITLAB_TOKEN")
+
+	// The webhook and GitHub App Secrets and the webhook ConfigMaps live in this
+	// namespace; an empty one would only fail once they are first read
+	usesManagerNamespace := githubWebhookSecretName != "" || githubAppSecretName != "" ||
+		githubWebhookDeliveryConfigMap != "" || githubWebhookEventConfigMap != ""
+	if usesManagerNamespace && githubWebhookSecretNamespace == "" {
+		setupLog.Error(nil, "no namespace for the webhook Secrets and ConfigMaps; "+
+			"set --github-webhook-secret-namespace or the POD_NAMESPACE environment variable")
+		os.Exit(1)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
@@ -175,14 +304,171 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := (&controller.PreviewEnvironmentReconciler{
-		Client:        mgr.GetClient(),
-		Scheme:        mgr.GetScheme(),
-		CostEstimator: cost.NewEstimator(nil),
-	}).SetupWithManager(mgr); err != nil {
+	// Lifecycle transitions and errors are recorded as Events on each PreviewEnvironment
+	recorder := mgr.GetEventRecorderFor("previewd-controller")
+	reconciler := &controller.PreviewEnvironmentReconciler{
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		CostEstimator:    cost.NewEstimator(nil),
+		NamespaceManager: namespace.NewManager(mgr.GetClient(), mgr.GetScheme()).WithRecorder(recorder),
+		Recorder:         recorder,
+	}
+	if argocdRepoURL != "" {
+		reconciler.ArgoCDManager = argocd.NewManager(mgr.GetClient(), mgr.GetScheme(),
+			argocdRepoURL, argocdNamespace, argocdProject).WithRecorder(recorder)
+	}
+	if previewBaseDomain != "" {
+		// Resolving preview hosts shows when external-dns has published their records
+		reconciler.IngressManager = ingress.NewManager(mgr.GetClient(), mgr.GetScheme(), previewBaseDomain, certIssuer).
+			WithResolver(net.DefaultResolver).
+			WithRecorder(recorder)
+	}
+	var githubClient github.Client
+	switch {
+	case githubAppSecretName != "":
+		// The manager cache is not started yet, so read the Secret directly from the API server
+		loadCtx, cancelLoad := context.WithTimeout(context.Background(), 30*time.Second)
+		creds, err := github.LoadAppCredentials(loadCtx, mgr.GetAPIReader(),
+			githubWebhookSecretNamespace, githubAppSecretName)
+		cancelLoad()
+		if err != nil {
+			setupLog.Error(err, "unable to load GitHub App credentials")
+			os.Exit(1)
+		}
+		githubClient, err = github.NewAppClient(creds)
+		if err != nil {
+			setupLog.Error(err, "unable to create GitHub App client")
+			os.Exit(1)
+		}
+	case githubToken != "":
+		githubClient, err = github.NewClient(githubToken)
+		if err != nil {
+			setupLog.Error(err, "unable to create GitHub client")
+			os.Exit(1)
+		}
+	}
+	reconciler.GitHubClient = githubClient
+	var gitlabClient gitlab.Client
+	if gitlabToken != "" {
+		gitlabClient, err = gitlab.NewClient(gitlabURL, gitlabToken)
+		if err != nil {
+			setupLog.Error(err, "unable to create GitLab client")
+			os.Exit(1)
+		}
+		reconciler.GitLabClient = gitlabClient
+	}
+	if err := reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PreviewEnvironment")
 		os.Exit(1)
 	}
+	// The cleanup scheduler deletes PreviewEnvironments past their status.expiresAt.
+	// It runs only on the elected leader.
+	if err := mgr.Add(cleanup.NewScheduler(mgr.GetClient(), cleanupInterval).WithRecorder(recorder)); err != nil {
+		setupLog.Error(err, "unable to add cleanup scheduler")
+		os.Exit(1)
+	}
+	if githubWebhookPort > 0 {
+		// Webhook secrets come from the watched Secret, or from the environment
+		// when no Secret is configured
+		githubKeys := environmentKeys("GITHUB_WEBHOOK_SECRET")
+		gitlabKeys := environmentKeys("GITLAB_WEBHOOK_TOKEN")
+		giteaKeys := environmentKeys("GITEA_WEBHOOK_SECRET")
+		bitbucketKeys := environmentKeys("BITBUCKET_WEBHOOK_SECRET")
+		if githubWebhookSecretName != "" {
+			// Watch the Secret directly rather than caching every Secret in the cluster
+			secretClient, err := client.NewWithWatch(mgr.GetConfig(), client.Options{Scheme: scheme})
+			if err != nil {
+				setupLog.Error(err, "unable to create webhook secret client")
+				os.Exit(1)
+			}
+			secretWatcher := githubwebhook.NewSecretWatcher(secretClient, githubWebhookSecretNamespace,
+				githubWebhookSecretName, strings.Split(githubWebhookSecretKey, ","), githubKeys).
+				WithKeyRing(strings.Split(gitlabWebhookSecretKey, ","), gitlabKeys).
+				WithKeyRing(strings.Split(giteaWebhookSecretKey, ","), giteaKeys).
+				WithKeyRing(strings.Split(bitbucketWebhookSecretKey, ","), bitbucketKeys)
+			loadCtx, cancelLoad := context.WithTimeout(context.Background(), 30*time.Second)
+			err = secretWatcher.Load(loadCtx)
+			cancelLoad()
+			if err != nil {
+				setupLog.Error(err, "unable to load GitHub webhook secret")
+				os.Exit(1)
+			}
+			if err := mgr.Add(secretWatcher); err != nil {
+				setupLog.Error(err, "unable to add GitHub webhook secret watcher")
+				os.Exit(1)
+			}
+		}
+		if githubKeys.Len() == 0 && gitlabKeys.Len() == 0 && giteaKeys.Len() == 0 && bitbucketKeys.Len() == 0 {
+			setupLog.Error(nil, "GitHub webhook server enabled without a secret; set --github-webhook-secret-name, "+
+				"GITHUB_WEBHOOK_SECRET, GITLAB_WEBHOOK_TOKEN, GITEA_WEBHOOK_SECRET or BITBUCKET_WEBHOOK_SECRET")
+			os.Exit(1)
+		}
+
+		namespaceMapping, err := githubwebhook.ParseNamespaceMapping(previewNamespaceMap)
+		if err != nil {
+			setupLog.Error(err, "invalid --preview-namespace-map")
+			os.Exit(1)
+		}
+
+		branchPatterns, err := githubwebhook.ParseBranchPatterns(branchPreviewPatterns)
+		if err != nil {
+			setupLog.Error(err, "invalid --branch-preview-patterns")
+			os.Exit(1)
+		}
+
+		githubWebhookServer := githubwebhook.NewServer(githubWebhookAddr, githubWebhookPort, mgr.GetClient(), "").
+			WithGitHubKeys(githubKeys).
+			WithLeaderElection(githubWebhookLeaderOnly).
+			WithPreviewLabel(previewLabel).
+			WithSkipDrafts(skipDraftPRs).
+			WithWorkers(githubWebhookWorkers).
+			WithRateLimiter(githubwebhook.NewRateLimiter(webhookRepoRateLimit, time.Second).
+				WithGlobalLimit(webhookGlobalRateLimit, time.Second)).
+			WithNamespace(previewNamespace).
+			WithRepositoryNamespaces(namespaceMapping).
+			WithBranchPreviews(branchPatterns)
+		if githubWebhookDeliveryConfigMap != "" || githubWebhookEventConfigMap != "" {
+			// Replicas must see each other's writes immediately, so bypass the cache
+			storeClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: scheme})
+			if err != nil {
+				setupLog.Error(err, "unable to create webhook store client")
+				os.Exit(1)
+			}
+			if githubWebhookDeliveryConfigMap != "" {
+				githubWebhookServer.WithDeliveryStore(githubwebhook.NewConfigMapDeliveryStore(storeClient,
+					githubWebhookSecretNamespace, githubWebhookDeliveryConfigMap, githubwebhook.DefaultDeliveryTTL))
+			}
+			if githubWebhookEventConfigMap != "" {
+				githubWebhookServer.WithEventStore(githubwebhook.NewConfigMapEventStore(storeClient,
+					githubWebhookSecretNamespace, githubWebhookEventConfigMap))
+			}
+		}
+		// Pull requests list their changed files through the provider's API
+		// client; branch pushes carry them in the payload
+		dependencies, err := services.ParseDependencies(serviceDependencies)
+		if err != nil {
+			setupLog.Error(err, "invalid --service-dependencies")
+			os.Exit(1)
+		}
+		githubWebhookServer.WithServiceDetection(services.NewDetector(dependencies))
+		if githubClient != nil {
+			// The GitHub client also enables per-repository .previewd.yaml configuration
+			githubWebhookServer.WithGitHubClient(githubClient)
+		}
+		if gitlabKeys.Len() > 0 {
+			githubWebhookServer.WithGitLab(gitlabKeys, gitlabClient)
+		}
+		if giteaKeys.Len() > 0 {
+			githubWebhookServer.WithProvider(githubwebhook.NewGiteaProvider(giteaKeys))
+		}
+		if bitbucketKeys.Len() > 0 {
+			githubWebhookServer.WithProvider(githubwebhook.NewBitbucketProvider(bitbucketKeys))
+		}
+		if err := mgr.Add(githubWebhookServer); err != nil {
+			setupLog.Error(err, "unable to add GitHub webhook server")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -200,3 +486,9 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// environmentKeys returns a webhook KeyRing holding the secret in the
+// environment variable name, or an empty ring if it is unset
+func environmentKeys(name string) *githubwebhook.KeyRing {
+	return githubwebhook.NewKeyRing(githubwebhook.Key{Name: githubwebhook.DefaultKeyName, Value: os.Getenv(name)})
+}
@@ -29,6 +29,8 @@
 //   - Kustomize integration for namespace isolation
 //   - Owner reference tracking via annotations (cross-namespace)
 //   - Idempotent operations
+//   - Events on the PreviewEnvironment when its ApplicationSet is created or
+//     updated (see WithRecorder)
 //
 // # Usage
 //
@@ -68,6 +70,14 @@
 //	}
 //	fmt.Printf("Health: %s, Sync: %s\n", status.Health, status.Sync)
 //
+// Get the status of every Application generated for a preview, keyed by the
+// service named in its preview.previewd.io/service label:
+//
+//	statuses, err := mgr.GetServiceStatuses(ctx, previewEnv)
+//	if err != nil {
+//	    // Handle error
+//	}
+//
 // # ApplicationSet Structure
 //
 // The generated ApplicationSet uses the following structure:
@@ -109,6 +119,11 @@
 //	        syncOptions:
 //	        - CreateNamespace=false
 //
+// Branch previews replace preview-{prNumber} and pr-{prNumber} with
+// preview-br-{branch}-{hash} and br-{branch}-{hash}, and the
+// preview.previewd.io/pr label with preview.previewd.io/branch (see
+// PreviewEnvironmentSpec.PreviewID).
+//
 // # Sync Policy
 //
 // The ApplicationSet configures automated sync with:
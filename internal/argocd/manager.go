@@ -22,11 +22,13 @@ import (
 	"fmt"
 
 	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -34,8 +36,25 @@ import (
 const (
 	managedByLabel = "previewd"
 
+	// ManagedByLabel marks the ApplicationSets and Applications previewd generates
+	ManagedByLabel = "preview.previewd.io/managed-by"
+
+	// ServiceLabel records the service an Application deploys
+	ServiceLabel = "preview.previewd.io/service"
+
 	// InClusterServer is the default in-cluster Kubernetes API server URL
 	InClusterServer = "https://kubernetes.default.svc"
+
+	// DefaultSourcePath is the repository path each service is deployed from
+	DefaultSourcePath = "services/{{service}}"
+
+	// RefreshAnnotation asks ArgoCD to refresh an Application; ArgoCD removes
+	// it once the refresh is done
+	RefreshAnnotation = "argocd.argoproj.io/refresh"
+
+	// refreshHard makes ArgoCD regenerate the manifests instead of using its
+	// cache, so the automated sync policy re-applies them
+	refreshHard = "hard"
 )
 
 // ApplicationStatusInfo contains the health and sync status of an ArgoCD Application
@@ -44,6 +63,8 @@ type ApplicationStatusInfo struct {
 	Health string
 	// Sync is the sync status (e.g., "Synced", "OutOfSync")
 	Sync string
+	// Revision is the git revision the Application last synced
+	Revision string
 	// Message is an optional message with more details
 	Message string
 }
@@ -52,6 +73,7 @@ type ApplicationStatusInfo struct {
 type Manager struct {
 	client          client.Client
 	scheme          *runtime.Scheme
+	recorder        record.EventRecorder
 	repoURL         string
 	argocdNamespace string
 	project         string
@@ -68,11 +90,20 @@ func NewManager(c client.Client, scheme *runtime.Scheme, repoURL, argocdNamespac
 	}
 }
 
+// WithRecorder sets the recorder used to record ApplicationSet changes as
+// Events on the PreviewEnvironment
+func (m *Manager) WithRecorder(recorder record.EventRecorder) *Manager {
+	m.recorder = recorder
+	return m
+}
+
 // BuildApplicationSet creates an ApplicationSet resource for a preview environment.
 // It generates one Application per service using a list generator.
 func (m *Manager) BuildApplicationSet(preview *previewv1alpha1.PreviewEnvironment, namespace string) *ApplicationSet {
-	prNumber := preview.Spec.PRNumber
-	appSetName := m.GetApplicationSetName(prNumber)
+	previewID := preview.Spec.PreviewID()
+	identityKey, identityValue := preview.Spec.IdentityLabel()
+	repositoryHash := previewv1alpha1.RepositoryHash(preview.Spec.Repository)
+	appSetName := m.GetApplicationSetName(preview)
 
 	// Build list generator elements - one per service
 	elements := make([]apiextensionsv1.JSON, len(preview.Spec.Services))
@@ -94,8 +125,9 @@ func (m *Manager) BuildApplicationSet(preview *previewv1alpha1.PreviewEnvironmen
 			Name:      appSetName,
 			Namespace: m.argocdNamespace,
 			Labels: map[string]string{
-				"preview.previewd.io/pr":         fmt.Sprintf("%d", prNumber),
-				"preview.previewd.io/managed-by": managedByLabel,
+				identityKey:                     identityValue,
+				previewv1alpha1.RepositoryLabel: repositoryHash,
+				ManagedByLabel:                  managedByLabel,
 			},
 			Annotations: map[string]string{
 				"preview.previewd.io/owner-name":      preview.Name,
@@ -117,26 +149,27 @@ func (m *Manager) BuildApplicationSet(preview *previewv1alpha1.PreviewEnvironmen
 			},
 			Template: ApplicationSetTemplate{
 				ApplicationSetTemplateMeta: ApplicationSetTemplateMeta{
-					Name: fmt.Sprintf("preview-%d-{{service}}", prNumber),
+					Name: appSetName + "-{{service}}",
 					Labels: map[string]string{
-						"preview.previewd.io/pr":         fmt.Sprintf("%d", prNumber),
-						"preview.previewd.io/service":    "{{service}}",
-						"preview.previewd.io/managed-by": managedByLabel,
+						identityKey:                     identityValue,
+						previewv1alpha1.RepositoryLabel: repositoryHash,
+						ServiceLabel:                    "{{service}}",
+						ManagedByLabel:                  managedByLabel,
 					},
 				},
 				Spec: ApplicationSpec{
 					Project: m.project,
 					Source: &ApplicationSource{
 						RepoURL:        m.repoURL,
-						Path:           "services/{{service}}",
+						Path:           sourcePath(preview),
 						TargetRevision: preview.Spec.HeadSHA,
 						Kustomize: &ApplicationSourceKustomize{
-							NamePrefix: fmt.Sprintf("pr-%d-", prNumber),
+							NamePrefix: previewID + "-",
 							Namespace:  namespace,
 							CommonLabels: map[string]string{
-								"preview.previewd.io/pr":         fmt.Sprintf("%d", prNumber),
-								"preview.previewd.io/service":    "{{service}}",
-								"preview.previewd.io/managed-by": managedByLabel,
+								identityKey:    identityValue,
+								ServiceLabel:   "{{service}}",
+								ManagedByLabel: managedByLabel,
 							},
 						},
 					},
@@ -179,7 +212,7 @@ func (m *Manager) EnsureApplicationSet(ctx context.Context, preview *previewv1al
 		return fmt.Errorf("namespace cannot be empty")
 	}
 
-	appSetName := m.GetApplicationSetName(preview.Spec.PRNumber)
+	appSetName := m.GetApplicationSetName(preview)
 
 	appSet := &ApplicationSet{
 		ObjectMeta: metav1.ObjectMeta{
@@ -188,7 +221,7 @@ func (m *Manager) EnsureApplicationSet(ctx context.Context, preview *previewv1al
 		},
 	}
 
-	_, err := controllerutil.CreateOrUpdate(ctx, m.client, appSet, func() error {
+	result, err := controllerutil.CreateOrUpdate(ctx, m.client, appSet, func() error {
 		// Build the desired state
 		desired := m.BuildApplicationSet(preview, namespace)
 
@@ -215,8 +248,19 @@ func (m *Manager) EnsureApplicationSet(ctx context.Context, preview *previewv1al
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to ensure ApplicationSet for preview %s/%s (PR #%d): %w",
-			preview.Namespace, preview.Name, preview.Spec.PRNumber, err)
+		return fmt.Errorf("failed to ensure ApplicationSet for preview %s/%s (%s): %w",
+			preview.Namespace, preview.Name, preview.Spec.PreviewID(), err)
+	}
+
+	if m.recorder != nil {
+		switch result {
+		case controllerutil.OperationResultCreated:
+			m.recorder.Eventf(preview, corev1.EventTypeNormal, "ApplicationSetCreated",
+				"Created ApplicationSet %s/%s at revision %s", m.argocdNamespace, appSetName, preview.Spec.HeadSHA)
+		case controllerutil.OperationResultUpdated:
+			m.recorder.Eventf(preview, corev1.EventTypeNormal, "ApplicationSetUpdated",
+				"Updated ApplicationSet %s/%s to revision %s", m.argocdNamespace, appSetName, preview.Spec.HeadSHA)
+		}
 	}
 
 	return nil
@@ -252,18 +296,96 @@ func (m *Manager) GetApplicationStatus(ctx context.Context, name, namespace stri
 	}
 
 	return &ApplicationStatusInfo{
-		Health:  app.Status.Health.Status,
-		Sync:    app.Status.Sync.Status,
-		Message: app.Status.Health.Message,
+		Health:   app.Status.Health.Status,
+		Sync:     app.Status.Sync.Status,
+		Revision: app.Status.Sync.Revision,
+		Message:  app.Status.Health.Message,
 	}, nil
 }
 
-// GetApplicationSetName generates the ApplicationSet name for a PR number.
-func (m *Manager) GetApplicationSetName(prNumber int) string {
-	return fmt.Sprintf("preview-%d", prNumber)
+// GetServiceStatuses returns the health and sync status of the Applications
+// generated for a preview environment, keyed by service. Services whose
+// Application has not been generated yet are missing from the map.
+// Applications are selected by the preview's pull request or branch and
+// repository labels, so previews of other repositories are never included.
+func (m *Manager) GetServiceStatuses(ctx context.Context, preview *previewv1alpha1.PreviewEnvironment) (map[string]*ApplicationStatusInfo, error) {
+	apps, err := m.listApplications(ctx, preview)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[string]*ApplicationStatusInfo, len(apps.Items))
+	for _, app := range apps.Items {
+		statuses[app.Labels[ServiceLabel]] = &ApplicationStatusInfo{
+			Health:   app.Status.Health.Status,
+			Sync:     app.Status.Sync.Status,
+			Revision: app.Status.Sync.Revision,
+			Message:  app.Status.Health.Message,
+		}
+	}
+	return statuses, nil
+}
+
+// RefreshApplications requests a hard refresh of every Application generated
+// for a preview environment. ArgoCD then regenerates their manifests from the
+// repository and, with the automated sync policy, syncs any drift. It returns
+// the number of Applications refreshed.
+func (m *Manager) RefreshApplications(ctx context.Context, preview *previewv1alpha1.PreviewEnvironment) (int, error) {
+	apps, err := m.listApplications(ctx, preview)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range apps.Items {
+		app := &apps.Items[i]
+		base := app.DeepCopy()
+		if app.Annotations == nil {
+			app.Annotations = make(map[string]string)
+		}
+		app.Annotations[RefreshAnnotation] = refreshHard
+		if err := m.client.Patch(ctx, app, client.MergeFrom(base)); err != nil {
+			return 0, fmt.Errorf("failed to refresh Application %s/%s: %w", app.Namespace, app.Name, err)
+		}
+	}
+	return len(apps.Items), nil
+}
+
+// listApplications returns the Applications generated for a preview
+// environment, selected by its pull request or branch and repository labels
+func (m *Manager) listApplications(ctx context.Context, preview *previewv1alpha1.PreviewEnvironment) (*ApplicationList, error) {
+	selector := client.MatchingLabels(preview.Spec.IdentityLabels())
+	selector[ManagedByLabel] = managedByLabel
+
+	apps := &ApplicationList{}
+	if err := m.client.List(ctx, apps, client.InNamespace(m.argocdNamespace), selector); err != nil {
+		return nil, fmt.Errorf("failed to list Applications for preview %s/%s: %w", preview.Namespace, preview.Name, err)
+	}
+	return apps, nil
+}
+
+// IsPreviewApplication reports whether obj is an Application generated by previewd
+func IsPreviewApplication(obj client.Object) bool {
+	return obj.GetLabels()[ManagedByLabel] == managedByLabel
+}
+
+// GetApplicationSetName generates the ApplicationSet name for a preview
+// environment: "preview-pr-<number>-<repo hash>" for a pull request and
+// "preview-br-<branch>-<hash>-<repo hash>" for a branch (see
+// PreviewEnvironmentSpec.InstanceID). All previews share the ArgoCD namespace,
+// so the name includes the repository hash.
+func (m *Manager) GetApplicationSetName(preview *previewv1alpha1.PreviewEnvironment) string {
+	return "preview-" + preview.Spec.InstanceID()
 }
 
 // GetArgocdNamespace returns the ArgoCD namespace configured for this manager.
 func (m *Manager) GetArgocdNamespace() string {
 	return m.argocdNamespace
 }
+
+// sourcePath returns the ArgoCD source path template for the preview
+func sourcePath(preview *previewv1alpha1.PreviewEnvironment) string {
+	if preview.Spec.SourcePath != "" {
+		return preview.Spec.SourcePath
+	}
+	return DefaultSourcePath
+}
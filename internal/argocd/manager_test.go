@@ -25,6 +25,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
@@ -76,7 +77,7 @@ func TestNewManager(t *testing.T) {
 	}
 }
 
-// TestBuildApplicationSet_Name verifies the ApplicationSet name follows the pattern "preview-{prNumber}"
+// TestBuildApplicationSet_Name verifies the ApplicationSet name follows the pattern "preview-pr-{prNumber}-{repo hash}"
 func TestBuildApplicationSet_Name(t *testing.T) {
 	c := setupTestClient(t)
 	m := NewManager(c, c.Scheme(), "https://github.com/example/app", "argocd", "default")
@@ -97,7 +98,7 @@ func TestBuildApplicationSet_Name(t *testing.T) {
 
 	appSet := m.BuildApplicationSet(preview, "preview-pr-123-abc12345")
 
-	expectedName := "preview-123"
+	expectedName := "preview-pr-123-1c0e2a33"
 	if appSet.Name != expectedName {
 		t.Errorf("ApplicationSet name = %v, want %v", appSet.Name, expectedName)
 	}
@@ -438,8 +439,8 @@ func TestBuildApplicationSet_ApplicationNamePattern(t *testing.T) {
 
 	appSet := m.BuildApplicationSet(preview, "preview-pr-123-abc12345")
 
-	// The template metadata name should follow the pattern "preview-{prNumber}-{{service}}"
-	expectedNamePattern := "preview-123-{{service}}"
+	// The template metadata name should follow the pattern "preview-pr-{prNumber}-{repo hash}-{{service}}"
+	expectedNamePattern := "preview-pr-123-1c0e2a33-{{service}}"
 	if appSet.Spec.Template.Name != expectedNamePattern {
 		t.Errorf("Template name pattern = %v, want %v", appSet.Spec.Template.Name, expectedNamePattern)
 	}
@@ -542,6 +543,33 @@ func TestBuildApplicationSet_SourcePath(t *testing.T) {
 	}
 }
 
+// TestBuildApplicationSet_CustomSourcePath verifies Spec.SourcePath overrides the default path
+func TestBuildApplicationSet_CustomSourcePath(t *testing.T) {
+	c := setupTestClient(t)
+	m := NewManager(c, c.Scheme(), "https://github.com/example/app", "argocd", "default")
+
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-123",
+			Namespace: "previewd-system",
+			UID:       "abc-123",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			PRNumber:   123,
+			Repository: "example/app",
+			HeadSHA:    "abc123def456789012345678901234567890abcd",
+			Services:   []string{"auth"},
+			SourcePath: "deploy/{{service}}/overlays/preview",
+		},
+	}
+
+	appSet := m.BuildApplicationSet(preview, "preview-pr-123-abc12345")
+
+	if got := appSet.Spec.Template.Spec.Source.Path; got != "deploy/{{service}}/overlays/preview" {
+		t.Errorf("Source path = %v, want deploy/{{service}}/overlays/preview", got)
+	}
+}
+
 // TestBuildApplicationSet_GoTemplate verifies Go templating is enabled
 func TestBuildApplicationSet_GoTemplate(t *testing.T) {
 	c := setupTestClient(t)
@@ -595,15 +623,15 @@ func TestEnsureApplicationSet_Creates(t *testing.T) {
 	// Verify the ApplicationSet was created
 	appSet := &ApplicationSet{}
 	err = c.Get(context.Background(), types.NamespacedName{
-		Name:      "preview-123",
+		Name:      "preview-pr-123-1c0e2a33",
 		Namespace: "argocd",
 	}, appSet)
 	if err != nil {
 		t.Fatalf("failed to get created ApplicationSet: %v", err)
 	}
 
-	if appSet.Name != "preview-123" {
-		t.Errorf("ApplicationSet name = %v, want %v", appSet.Name, "preview-123")
+	if appSet.Name != "preview-pr-123-1c0e2a33" {
+		t.Errorf("ApplicationSet name = %v, want %v", appSet.Name, "preview-pr-123-1c0e2a33")
 	}
 }
 
@@ -639,6 +667,47 @@ func TestEnsureApplicationSet_Idempotent(t *testing.T) {
 	}
 }
 
+// TestEnsureApplicationSet_RecordsEvents verifies creation and updates are recorded as Events
+func TestEnsureApplicationSet_RecordsEvents(t *testing.T) {
+	c := setupTestClient(t)
+	recorder := record.NewFakeRecorder(10)
+	m := NewManager(c, c.Scheme(), "https://github.com/example/app", "argocd", "default").WithRecorder(recorder)
+
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-123",
+			Namespace: "previewd-system",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			PRNumber:   123,
+			Repository: "example/app",
+			HeadSHA:    "abc123def456789012345678901234567890abcd",
+			Services:   []string{"auth"},
+		},
+	}
+
+	ensure := func(want string) {
+		t.Helper()
+		if err := m.EnsureApplicationSet(context.Background(), preview, "preview-pr-123-abc12345"); err != nil {
+			t.Fatalf("EnsureApplicationSet() error = %v", err)
+		}
+		var got string
+		select {
+		case got = <-recorder.Events:
+		default:
+		}
+		if got != want {
+			t.Errorf("recorded event = %q, want %q", got, want)
+		}
+	}
+
+	ensure("Normal ApplicationSetCreated Created ApplicationSet argocd/preview-pr-123-1c0e2a33 at revision abc123def456789012345678901234567890abcd")
+	ensure("")
+
+	preview.Spec.HeadSHA = "fedcba9876543210fedcba9876543210fedcba98"
+	ensure("Normal ApplicationSetUpdated Updated ApplicationSet argocd/preview-pr-123-1c0e2a33 to revision fedcba9876543210fedcba9876543210fedcba98")
+}
+
 // TestDeleteApplicationSet_Deletes verifies deletion works
 func TestDeleteApplicationSet_Deletes(t *testing.T) {
 	c := setupTestClient(t)
@@ -769,6 +838,130 @@ func TestGetApplicationStatus_NotFound(t *testing.T) {
 	}
 }
 
+// TestGetServiceStatuses verifies Application statuses are collected per service
+// for the preview's own Applications only
+func TestGetServiceStatuses(t *testing.T) {
+	c := setupTestClient(t)
+	m := NewManager(c, c.Scheme(), "https://github.com/example/app", "argocd", "default")
+
+	newApp := func(name, pr, repository, service, health string) *Application {
+		return &Application{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "argocd",
+				Labels: map[string]string{
+					previewv1alpha1.PRLabel:         pr,
+					previewv1alpha1.RepositoryLabel: previewv1alpha1.RepositoryHash(repository),
+					ServiceLabel:                    service,
+					ManagedByLabel:                  managedByLabel,
+				},
+			},
+			Status: ApplicationStatus{
+				Health: HealthStatus{Status: health, Message: health + " message"},
+				Sync:   SyncStatus{Status: "Synced", Revision: "1234567890123456789012345678901234567890"},
+			},
+		}
+	}
+	for _, app := range []*Application{
+		newApp("preview-pr-123-1c0e2a33-auth", "123", "example/app", "auth", "Healthy"),
+		newApp("preview-pr-123-1c0e2a33-api", "123", "example/app", "api", "Degraded"),
+		newApp("preview-pr-124-1c0e2a33-api", "124", "example/app", "api", "Healthy"),
+		// Another repository's pull request with the same number
+		newApp("preview-pr-123-65e817ee-web", "123", "owner/repo", "web", "Healthy"),
+	} {
+		if err := c.Create(context.Background(), app); err != nil {
+			t.Fatalf("failed to create Application: %v", err)
+		}
+	}
+
+	preview := &previewv1alpha1.PreviewEnvironment{
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{Repository: "example/app", PRNumber: 123},
+	}
+	statuses, err := m.GetServiceStatuses(context.Background(), preview)
+	if err != nil {
+		t.Fatalf("GetServiceStatuses() error = %v", err)
+	}
+
+	if len(statuses) != 2 {
+		t.Fatalf("GetServiceStatuses() returned %d services, want 2", len(statuses))
+	}
+	if statuses["auth"].Health != "Healthy" || statuses["auth"].Sync != "Synced" ||
+		statuses["auth"].Revision != "1234567890123456789012345678901234567890" {
+		t.Errorf("auth status = %+v, want Healthy/Synced with its revision", statuses["auth"])
+	}
+	if statuses["api"].Health != "Degraded" || statuses["api"].Message != "Degraded message" {
+		t.Errorf("api status = %+v, want Degraded with message", statuses["api"])
+	}
+}
+
+// TestRefreshApplications verifies a hard refresh is requested for the
+// preview's own Applications only
+func TestRefreshApplications(t *testing.T) {
+	c := setupTestClient(t)
+	m := NewManager(c, c.Scheme(), "https://github.com/example/app", "argocd", "default")
+
+	newApp := func(name, pr string) *Application {
+		return &Application{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "argocd",
+				Labels: map[string]string{
+					previewv1alpha1.PRLabel:         pr,
+					previewv1alpha1.RepositoryLabel: previewv1alpha1.RepositoryHash("example/app"),
+					ServiceLabel:                    "api",
+					ManagedByLabel:                  managedByLabel,
+				},
+			},
+		}
+	}
+	for _, app := range []*Application{
+		newApp("preview-pr-123-1c0e2a33-api", "123"),
+		newApp("preview-pr-124-1c0e2a33-api", "124"),
+	} {
+		if err := c.Create(context.Background(), app); err != nil {
+			t.Fatalf("failed to create Application: %v", err)
+		}
+	}
+
+	preview := &previewv1alpha1.PreviewEnvironment{
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{Repository: "example/app", PRNumber: 123},
+	}
+	refreshed, err := m.RefreshApplications(context.Background(), preview)
+	if err != nil {
+		t.Fatalf("RefreshApplications() error = %v", err)
+	}
+	if refreshed != 1 {
+		t.Errorf("RefreshApplications() refreshed %d Applications, want 1", refreshed)
+	}
+
+	for name, want := range map[string]string{
+		"preview-pr-123-1c0e2a33-api": "hard",
+		"preview-pr-124-1c0e2a33-api": "",
+	} {
+		app := &Application{}
+		if err := c.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "argocd"}, app); err != nil {
+			t.Fatalf("failed to get Application: %v", err)
+		}
+		if got := app.Annotations[RefreshAnnotation]; got != want {
+			t.Errorf("%s refresh annotation = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// TestIsPreviewApplication verifies only Applications labeled as managed by previewd match
+func TestIsPreviewApplication(t *testing.T) {
+	managed := &Application{ObjectMeta: metav1.ObjectMeta{
+		Labels: map[string]string{ManagedByLabel: managedByLabel},
+	}}
+	if !IsPreviewApplication(managed) {
+		t.Error("IsPreviewApplication() = false for a previewd Application, want true")
+	}
+
+	if IsPreviewApplication(&Application{}) {
+		t.Error("IsPreviewApplication() = true for an unlabeled Application, want false")
+	}
+}
+
 // TestBuildApplicationSet_Project verifies the project is set correctly
 func TestBuildApplicationSet_Project(t *testing.T) {
 	c := setupTestClient(t)
@@ -897,12 +1090,14 @@ func TestEnsureApplicationSet_EmptyNamespace(t *testing.T) {
 // TestGetApplicationSetName verifies the ApplicationSet name generation
 func TestGetApplicationSetName(t *testing.T) {
 	tests := []struct {
-		prNumber int
-		want     string
+		want   string
+		branch string
+		number int
 	}{
-		{prNumber: 1, want: "preview-1"},
-		{prNumber: 123, want: "preview-123"},
-		{prNumber: 9999, want: "preview-9999"},
+		{number: 1, want: "preview-pr-1-e3b0c442"},
+		{number: 123, want: "preview-pr-123-e3b0c442"},
+		{number: 9999, want: "preview-pr-9999-e3b0c442"},
+		{branch: "main", want: "preview-br-main-0d6e40-e3b0c442"},
 	}
 
 	c := setupTestClient(t)
@@ -910,14 +1105,55 @@ func TestGetApplicationSetName(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.want, func(t *testing.T) {
-			got := m.GetApplicationSetName(tt.prNumber)
+			preview := &previewv1alpha1.PreviewEnvironment{
+				Spec: previewv1alpha1.PreviewEnvironmentSpec{PRNumber: tt.number, Branch: tt.branch},
+			}
+			got := m.GetApplicationSetName(preview)
 			if got != tt.want {
-				t.Errorf("GetApplicationSetName(%d) = %v, want %v", tt.prNumber, got, tt.want)
+				t.Errorf("GetApplicationSetName() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+// TestBuildApplicationSet_BranchPreview verifies branch previews are named and
+// labeled after their branch
+func TestBuildApplicationSet_BranchPreview(t *testing.T) {
+	c := setupTestClient(t)
+	m := NewManager(c, c.Scheme(), "https://github.com/example/app", "argocd", "default")
+
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "br-main-0d6e40-12345678",
+			Namespace: "default",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "owner/repo",
+			Branch:     "main",
+			HeadSHA:    "abc123def456789012345678901234567890abcd",
+			Services:   []string{"api"},
+		},
+	}
+
+	appSet := m.BuildApplicationSet(preview, "preview-br-main-0d6e40-65e817ee")
+
+	if appSet.Name != "preview-br-main-0d6e40-65e817ee" {
+		t.Errorf("ApplicationSet name = %v, want preview-br-main-0d6e40-65e817ee", appSet.Name)
+	}
+	if appSet.Labels[previewv1alpha1.BranchLabel] != "br-main-0d6e40" {
+		t.Errorf("label %s = %v, want br-main-0d6e40", previewv1alpha1.BranchLabel, appSet.Labels[previewv1alpha1.BranchLabel])
+	}
+	if _, ok := appSet.Labels[previewv1alpha1.PRLabel]; ok {
+		t.Errorf("branch preview should not have label %s", previewv1alpha1.PRLabel)
+	}
+	if name := appSet.Spec.Template.Name; name != "preview-br-main-0d6e40-65e817ee-{{service}}" {
+		t.Errorf("template name = %v, want preview-br-main-0d6e40-65e817ee-{{service}}", name)
+	}
+	if prefix := appSet.Spec.Template.Spec.Source.Kustomize.NamePrefix; prefix != "br-main-0d6e40-" {
+		t.Errorf("Kustomize namePrefix = %v, want br-main-0d6e40-", prefix)
+	}
+}
+
 // TestBuildApplicationSet_DestinationServer verifies the destination server is set
 func TestBuildApplicationSet_DestinationServer(t *testing.T) {
 	c := setupTestClient(t)
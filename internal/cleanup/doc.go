@@ -30,7 +30,7 @@ SOFTWARE.
 //   - Respects TTL (time-to-live) configured in PreviewEnvironment spec
 //   - Honors "do-not-expire" label override for long-running environments
 //   - Graceful shutdown via context cancellation
-//   - Emits Kubernetes events when environments are deleted
+//   - Emits an Expired event on environments it deletes (see WithRecorder)
 //
 // TTL Calculation:
 //
@@ -27,7 +27,9 @@ import (
 	"time"
 
 	previewdv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -37,6 +39,7 @@ import (
 // and deletes them to prevent resource waste.
 type Scheduler struct {
 	client   client.Client
+	recorder record.EventRecorder
 	interval time.Duration
 }
 
@@ -55,6 +58,13 @@ func NewScheduler(k8sClient client.Client, interval time.Duration) *Scheduler {
 	}
 }
 
+// WithRecorder sets the recorder used to record the expiry of a PreviewEnvironment
+// as an Event on it before it is deleted.
+func (s *Scheduler) WithRecorder(recorder record.EventRecorder) *Scheduler {
+	s.recorder = recorder
+	return s
+}
+
 // Start begins the cleanup scheduler, running periodically until the context is canceled.
 // It uses a ticker to trigger cleanup at the configured interval and respects graceful
 // shutdown via context cancellation.
@@ -122,6 +132,10 @@ func (s *Scheduler) cleanup(ctx context.Context) error {
 			if err := s.client.Delete(ctx, env); err != nil {
 				return err
 			}
+			if s.recorder != nil {
+				s.recorder.Eventf(env, corev1.EventTypeNormal, "Expired",
+					"TTL expired at %s, deleting preview environment", env.Status.ExpiresAt.UTC().Format(time.RFC3339))
+			}
 		}
 	}
 
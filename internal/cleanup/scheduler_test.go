@@ -31,6 +31,7 @@ import (
 	previewdv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
@@ -119,6 +120,51 @@ func TestScheduler_cleanup_deletes_expired_environment(t *testing.T) {
 	}
 }
 
+func TestScheduler_cleanup_records_expired_event(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := previewdv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add scheme: %v", err)
+	}
+
+	expiredTime := metav1.NewTime(time.Date(2025, 11, 9, 12, 0, 0, 0, time.UTC))
+	expiredEnv := &previewdv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-123",
+			Namespace: "default",
+		},
+		Spec: previewdv1alpha1.PreviewEnvironmentSpec{
+			Repository: "owner/repo",
+			HeadSHA:    "0123456789abcdef0123456789abcdef01234567",
+			PRNumber:   123,
+		},
+		Status: previewdv1alpha1.PreviewEnvironmentStatus{
+			ExpiresAt: &expiredTime,
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(expiredEnv).
+		Build()
+
+	recorder := record.NewFakeRecorder(1)
+	scheduler := NewScheduler(fakeClient, 50*time.Millisecond).WithRecorder(recorder)
+
+	if err := scheduler.cleanup(context.Background()); err != nil {
+		t.Fatalf("cleanup() returned error: %v", err)
+	}
+
+	select {
+	case event := <-recorder.Events:
+		want := "Normal Expired TTL expired at 2025-11-09T12:00:00Z, deleting preview environment"
+		if event != want {
+			t.Errorf("recorded event = %q, want %q", event, want)
+		}
+	default:
+		t.Error("Expected an Expired event to be recorded")
+	}
+}
+
 func TestScheduler_cleanup_does_not_delete_non_expired_environment(t *testing.T) {
 	// Setup scheme with PreviewEnvironment CRD
 	scheme := runtime.NewScheme()
@@ -0,0 +1,185 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/ingress"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Condition types of a PreviewEnvironment. Each provisioning step owns one
+// condition; Ready rolls them up.
+const (
+	// conditionReady is the rollup condition type for a PreviewEnvironment
+	conditionReady = "Ready"
+
+	// conditionProgressing is True while the preview is being created or updated
+	conditionProgressing = "Progressing"
+
+	// conditionAvailable is True when every service of the preview is ready
+	conditionAvailable = "Available"
+
+	// conditionDegraded is True when a service or the provisioning itself failed
+	conditionDegraded = "Degraded"
+
+	conditionNamespaceReady         = "NamespaceReady"
+	conditionQuotaApplied           = "QuotaApplied"
+	conditionNetworkPoliciesApplied = "NetworkPoliciesApplied"
+	conditionApplicationSetSynced   = "ApplicationSetSynced"
+	conditionIngressReady           = "IngressReady"
+	conditionCertificateIssued      = "CertificateIssued"
+	conditionDNSPublished           = "DNSPublished"
+	conditionCostEstimated          = "CostEstimated"
+
+	// reasonProvisioningFailed is the reason of a step condition whose step failed
+	reasonProvisioningFailed = "ProvisioningFailed"
+)
+
+// readyConditions are the conditions that must all be True for the preview to
+// be Ready, in provisioning order. Conditions of steps that do not apply (for
+// example without ArgoCD or cert-manager) are absent and ignored.
+var readyConditions = []string{
+	conditionNamespaceReady,
+	conditionQuotaApplied,
+	conditionNetworkPoliciesApplied,
+	conditionApplicationSetSynced,
+	conditionIngressReady,
+	conditionCertificateIssued,
+	conditionDNSPublished,
+	conditionAvailable,
+}
+
+// setCondition sets a condition for the current generation of the preview
+func setCondition(previewEnv *previewv1alpha1.PreviewEnvironment, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&previewEnv.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: previewEnv.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// stepFailed marks the condition owned by a provisioning step as failed and
+// returns the step's error
+func stepFailed(previewEnv *previewv1alpha1.PreviewEnvironment, conditionType string, err error) error {
+	setCondition(previewEnv, conditionType, metav1.ConditionFalse, reasonProvisioningFailed, err.Error())
+	return err
+}
+
+// updateCertificateCondition sets CertificateIssued from the cert-manager
+// Certificate of the preview's ingress. The condition is removed when
+// cert-manager is not installed.
+func (r *PreviewEnvironmentReconciler) updateCertificateCondition(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment, nsName string) {
+	certificate, err := r.IngressManager.GetCertificateStatus(ctx, previewEnv, nsName)
+	switch {
+	case errors.Is(err, ingress.ErrCertManagerNotInstalled):
+		meta.RemoveStatusCondition(&previewEnv.Status.Conditions, conditionCertificateIssued)
+	case err != nil:
+		logf.FromContext(ctx).Error(err, "Failed to check the TLS certificate")
+		setCondition(previewEnv, conditionCertificateIssued, metav1.ConditionUnknown, "CheckFailed", err.Error())
+	case certificate.Issued:
+		setCondition(previewEnv, conditionCertificateIssued, metav1.ConditionTrue, "Issued", certificate.Message)
+	default:
+		setCondition(previewEnv, conditionCertificateIssued, metav1.ConditionFalse, certificate.Reason, certificate.Message)
+	}
+}
+
+// updateDNSCondition sets DNSPublished by resolving the preview host name. The
+// condition is removed when the ingress manager has no resolver.
+func (r *PreviewEnvironmentReconciler) updateDNSCondition(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment) {
+	host := r.IngressManager.GetIngressHost(previewEnv)
+	addrs, err := r.IngressManager.ResolveHost(ctx, previewEnv)
+	switch {
+	case errors.Is(err, ingress.ErrNoResolver):
+		meta.RemoveStatusCondition(&previewEnv.Status.Conditions, conditionDNSPublished)
+	case err != nil:
+		setCondition(previewEnv, conditionDNSPublished, metav1.ConditionFalse, "NotResolvable", err.Error())
+	default:
+		setCondition(previewEnv, conditionDNSPublished, metav1.ConditionTrue, "Published",
+			fmt.Sprintf("%s resolves to %s", host, strings.Join(addrs, ", ")))
+	}
+}
+
+// updateReadyCondition rolls the step conditions up into Ready and derives the
+// phase and the Progressing condition from them. Ready carries the reason of
+// the first condition that is not True and names it in its message, so the
+// stuck step shows in kubectl describe.
+func updateReadyCondition(previewEnv *previewv1alpha1.PreviewEnvironment) {
+	ready := metav1.ConditionTrue
+	reason, message := "Ready", "Preview environment is ready"
+	for _, conditionType := range readyConditions {
+		condition := meta.FindStatusCondition(previewEnv.Status.Conditions, conditionType)
+		if condition == nil {
+			continue
+		}
+		if condition.Status != metav1.ConditionTrue {
+			ready, reason, message = metav1.ConditionFalse, condition.Reason, conditionType+": "+condition.Message
+			break
+		}
+	}
+	setCondition(previewEnv, conditionReady, ready, reason, message)
+
+	switch {
+	case meta.IsStatusConditionTrue(previewEnv.Status.Conditions, conditionDegraded):
+		previewEnv.Status.Phase = phaseFailed
+	case isSleeping(previewEnv):
+		// A sleeping preview has no services running, so it is not Ready
+		previewEnv.Status.Phase = phaseSleeping
+	case ready == metav1.ConditionTrue:
+		previewEnv.Status.Phase = phaseReady
+		previewEnv.Status.DeployedSHA = previewEnv.Spec.HeadSHA
+	case previewEnv.Status.Phase == phaseReady || previewEnv.Status.Phase == phaseUpdating ||
+		previewEnv.Status.Phase == phaseSleeping:
+		// Waking up redeploys the services of a preview that was Ready before
+		previewEnv.Status.Phase = phaseUpdating
+	default:
+		previewEnv.Status.Phase = phaseCreating
+	}
+
+	if previewEnv.Status.Phase == phaseCreating || previewEnv.Status.Phase == phaseUpdating {
+		setCondition(previewEnv, conditionProgressing, metav1.ConditionTrue, reason, message)
+	} else {
+		setCondition(previewEnv, conditionProgressing, metav1.ConditionFalse, previewEnv.Status.Phase, message)
+	}
+}
+
+// awaitingIngress reports whether the preview waits on cert-manager or
+// external-dns, which are not watched and are re-checked on a short interval
+func awaitingIngress(previewEnv *previewv1alpha1.PreviewEnvironment) bool {
+	for _, conditionType := range []string{conditionCertificateIssued, conditionDNSPublished} {
+		condition := meta.FindStatusCondition(previewEnv.Status.Conditions, conditionType)
+		if condition != nil && condition.Status != metav1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
@@ -107,6 +107,51 @@ func TestReconciler_UpdatesCostEstimate(t *testing.T) {
 				TotalCost:  "0.2000",
 			},
 		},
+		{
+			preview: &previewv1alpha1.PreviewEnvironment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-preview-ttl",
+					Namespace: "default",
+				},
+				Spec: previewv1alpha1.PreviewEnvironmentSpec{
+					Repository: "org/repo",
+					PRNumber:   124,
+					HeadSHA:    "1234567890123456789012345678901234567890",
+					TTL:        "2d",
+				},
+				Status: previewv1alpha1.PreviewEnvironmentStatus{
+					Phase:     "Ready",
+					Namespace: "preview-pr-124",
+				},
+			},
+			pods: []corev1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "app-pod",
+						Namespace: "preview-pr-124",
+					},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Name: "app",
+								Resources: corev1.ResourceRequirements{
+									Requests: corev1.ResourceList{
+										corev1.ResourceCPU:    resource.MustParse("1"),
+										corev1.ResourceMemory: resource.MustParse("2Gi"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			name: "uses spec TTL for total cost",
+			wantCost: &previewv1alpha1.CostEstimate{
+				Currency:   "USD",
+				HourlyCost: "0.0500",
+				TotalCost:  "2.4000",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -166,6 +211,80 @@ func TestReconciler_UpdatesCostEstimate(t *testing.T) {
 	}
 }
 
+func TestReconciler_SetsExpiresAtFromTTL(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  string
+		want time.Duration
+	}{
+		{
+			name: "defaults to 4 hours",
+			ttl:  "",
+			want: 4 * time.Hour,
+		},
+		{
+			name: "uses day-suffixed TTL",
+			ttl:  "2d",
+			want: 48 * time.Hour,
+		},
+		{
+			name: "uses Go duration TTL",
+			ttl:  "90m",
+			want: 90 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preview := &previewv1alpha1.PreviewEnvironment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-preview",
+					Namespace: "default",
+				},
+				Spec: previewv1alpha1.PreviewEnvironmentSpec{
+					Repository: "org/repo",
+					PRNumber:   123,
+					HeadSHA:    "1234567890123456789012345678901234567890",
+					TTL:        tt.ttl,
+				},
+			}
+
+			fakeClient := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithObjects(preview).
+				WithStatusSubresource(preview).
+				Build()
+
+			reconciler := &PreviewEnvironmentReconciler{
+				Client: fakeClient,
+				Scheme: testScheme,
+			}
+
+			req := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      preview.Name,
+					Namespace: preview.Namespace,
+				},
+			}
+			if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+
+			var updated previewv1alpha1.PreviewEnvironment
+			if err := fakeClient.Get(context.TODO(), req.NamespacedName, &updated); err != nil {
+				t.Fatalf("Failed to get updated preview environment: %v", err)
+			}
+
+			if updated.Status.CreatedAt == nil || updated.Status.ExpiresAt == nil {
+				t.Fatalf("expected CreatedAt and ExpiresAt to be set, got %+v", updated.Status)
+			}
+			if got := updated.Status.ExpiresAt.Sub(updated.Status.CreatedAt.Time); got != tt.want {
+				t.Errorf("ExpiresAt - CreatedAt = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestParseTTL(t *testing.T) {
 	tests := []struct {
 		ttl     string
@@ -208,17 +327,37 @@ func TestParseTTL(t *testing.T) {
 			name:    "handles invalid format",
 			wantErr: true,
 		},
+		{
+			ttl:     "1.5h",
+			name:    "rejects fractional durations",
+			wantErr: true,
+		},
+		{
+			ttl:     "-1h",
+			name:    "rejects negative durations",
+			wantErr: true,
+		},
+		{
+			ttl:     "2xd",
+			name:    "rejects non-numeric days",
+			wantErr: true,
+		},
+		{
+			ttl:     "0",
+			name:    "rejects durations without a unit",
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseTTL(tt.ttl)
+			got, err := previewv1alpha1.ParseTTL(tt.ttl)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("parseTTL() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("ParseTTL() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !tt.wantErr && got != tt.want {
-				t.Errorf("parseTTL() = %v, want %v", got, tt.want)
+				t.Errorf("ParseTTL() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -0,0 +1,159 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/argocd"
+	"github.com/mikelane/previewd/internal/github"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// newDeletingPreview returns a PreviewEnvironment that is marked for deletion
+func newDeletingPreview() *previewv1alpha1.PreviewEnvironment {
+	now := metav1.NewTime(time.Now())
+	return &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "pr-55",
+			Namespace:         "default",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{finalizerName},
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			PRNumber:   55,
+			HeadSHA:    "1234567890123456789012345678901234567890",
+			Services:   []string{"api"},
+		},
+		Status: previewv1alpha1.PreviewEnvironmentStatus{
+			Phase: phaseReady,
+		},
+	}
+}
+
+func TestReconciler_DeletionRemovesApplicationSetAndNamespace(t *testing.T) {
+	preview := newDeletingPreview()
+	appSet := &argocd.ApplicationSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "preview-pr-55-57b681c5",
+			Namespace: "argocd",
+		},
+	}
+
+	reconciler, fakeClient := newProvisioningReconciler(preview, appSet)
+	nsName, err := reconciler.NamespaceManager.GetNamespaceName(preview)
+	if err != nil {
+		t.Fatalf("GetNamespaceName() error = %v", err)
+	}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: nsName}}
+	if err := fakeClient.Create(context.TODO(), ns); err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-55", Namespace: "default"}}
+	result, err := reconciler.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.RequeueAfter != 0 {
+		t.Errorf("RequeueAfter = %v, want 0", result.RequeueAfter)
+	}
+
+	if err := fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(appSet), &argocd.ApplicationSet{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected ApplicationSet to be deleted, got err = %v", err)
+	}
+	if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: nsName}, &corev1.Namespace{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected namespace to be deleted, got err = %v", err)
+	}
+	if err := fakeClient.Get(context.TODO(), req.NamespacedName, &previewv1alpha1.PreviewEnvironment{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected PreviewEnvironment to be released, got err = %v", err)
+	}
+}
+
+func TestReconciler_DeletionWaitsForTerminatingNamespace(t *testing.T) {
+	preview := newDeletingPreview()
+
+	reconciler, fakeClient := newProvisioningReconciler(preview)
+	nsName, err := reconciler.NamespaceManager.GetNamespaceName(preview)
+	if err != nil {
+		t.Fatalf("GetNamespaceName() error = %v", err)
+	}
+	// A finalizer on the namespace keeps it around in Terminating state
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:       nsName,
+		Finalizers: []string{"example.com/hold"},
+	}}
+	if err := fakeClient.Create(context.TODO(), ns); err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-55", Namespace: "default"}}
+	result, err := reconciler.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.RequeueAfter != namespaceDeletionRequeueAfter {
+		t.Errorf("RequeueAfter = %v, want %v", result.RequeueAfter, namespaceDeletionRequeueAfter)
+	}
+
+	var updated previewv1alpha1.PreviewEnvironment
+	if err := fakeClient.Get(context.TODO(), req.NamespacedName, &updated); err != nil {
+		t.Fatalf("Failed to get preview environment: %v", err)
+	}
+	if updated.Status.Phase != phaseDeleting {
+		t.Errorf("Phase = %q, want %q", updated.Status.Phase, phaseDeleting)
+	}
+	if len(updated.Finalizers) == 0 {
+		t.Error("expected finalizer to be kept while namespace is terminating")
+	}
+}
+
+func TestReconciler_DeletionMarksDeploymentInactive(t *testing.T) {
+	preview := newDeletingPreview()
+	preview.Status.DeploymentID = 99
+
+	reconciler, _ := newProvisioningReconciler(preview)
+	gh := &fakeGitHubClient{}
+	reconciler.GitHubClient = gh
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-55", Namespace: "default"}}
+	if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	if len(gh.deploymentStatuses) != 1 {
+		t.Fatalf("deployment statuses = %d, want 1", len(gh.deploymentStatuses))
+	}
+	if gh.deploymentStatuses[0].State != github.DeploymentStateInactive {
+		t.Errorf("state = %s, want inactive", gh.deploymentStatuses[0].State)
+	}
+}
@@ -0,0 +1,251 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/github"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// commitStatusContext identifies previewd's commit status on GitHub
+	commitStatusContext = "previewd/preview"
+
+	// previewCommentMarker is the hidden marker used to find and edit the summary comment
+	previewCommentMarker = "<!-- previewd:preview-environment -->"
+
+	// maxStatusDescriptionLength is GitHub's limit for commit and deployment status descriptions
+	maxStatusDescriptionLength = 140
+
+	// reportTimeout bounds each best-effort reporting call so a slow or
+	// rate-limited provider cannot stall the reconcile
+	reportTimeout = 15 * time.Second
+)
+
+// reportCommitStatus posts a commit status for the preview's head SHA.
+// Previews of GitLab merge requests report to GitLab instead.
+// Reporting is best-effort: failures are logged and never fail the reconcile.
+func (r *PreviewEnvironmentReconciler) reportCommitStatus(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment, state github.StatusState, description string) {
+	if isGitLabPreview(previewEnv) {
+		r.reportGitLabCommitStatus(ctx, previewEnv, state, description)
+		return
+	}
+	if r.GitHubClient == nil || !isGitHubPreview(previewEnv) {
+		return
+	}
+	logger := logf.FromContext(ctx)
+
+	owner, repo, err := github.ParseRepository(previewEnv.Spec.Repository)
+	if err != nil {
+		logger.Error(err, "Failed to parse repository for commit status")
+		return
+	}
+
+	status := &github.Status{
+		State:       state,
+		TargetURL:   previewEnv.Status.URL,
+		Description: truncateDescription(description),
+		Context:     commitStatusContext,
+	}
+	reportCtx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+	if err := r.GitHubClient.UpdateCommitStatus(reportCtx, owner, repo, previewEnv.Spec.HeadSHA, status); err != nil {
+		logger.Error(err, "Failed to update commit status", "state", state)
+		return
+	}
+
+	logger.Info("Updated commit status", "state", state, "sha", previewEnv.Spec.HeadSHA)
+}
+
+// reportPreviewComment upserts the pull request comment summarizing the preview environment.
+// Previews of GitLab merge requests get a merge request note instead, and
+// branch previews, having no pull request, get neither.
+// Reporting is best-effort: failures are logged and never fail the reconcile.
+func (r *PreviewEnvironmentReconciler) reportPreviewComment(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment) {
+	if previewEnv.Spec.IsBranchPreview() {
+		return
+	}
+	if isGitLabPreview(previewEnv) {
+		r.reportGitLabPreviewNote(ctx, previewEnv)
+		return
+	}
+	if r.GitHubClient == nil || !isGitHubPreview(previewEnv) {
+		return
+	}
+	logger := logf.FromContext(ctx)
+
+	owner, repo, err := github.ParseRepository(previewEnv.Spec.Repository)
+	if err != nil {
+		logger.Error(err, "Failed to parse repository for PR comment")
+		return
+	}
+
+	body := buildPreviewComment(previewEnv)
+	reportCtx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+	if err := r.GitHubClient.CreateOrUpdateComment(reportCtx, owner, repo, previewEnv.Spec.PRNumber, previewCommentMarker, body); err != nil {
+		logger.Error(err, "Failed to update preview comment")
+		return
+	}
+
+	logger.Info("Updated preview comment", "pr", previewEnv.Spec.PRNumber)
+}
+
+// startDeployment creates a GitHub deployment for the preview's head SHA, records
+// its ID on the status and marks it in progress with the given description. The
+// caller is responsible for persisting the status.
+// Reporting is best-effort: failures are logged and never fail the reconcile.
+func (r *PreviewEnvironmentReconciler) startDeployment(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment, description string) {
+	if r.GitHubClient == nil || !isGitHubPreview(previewEnv) {
+		return
+	}
+	logger := logf.FromContext(ctx)
+
+	owner, repo, err := github.ParseRepository(previewEnv.Spec.Repository)
+	if err != nil {
+		logger.Error(err, "Failed to parse repository for deployment")
+		return
+	}
+
+	reportCtx, cancel := context.WithTimeout(ctx, reportTimeout)
+	id, err := r.GitHubClient.CreateDeployment(reportCtx, owner, repo, &github.Deployment{
+		Ref:                  previewEnv.Spec.HeadSHA,
+		Environment:          deploymentEnvironmentName(previewEnv),
+		Description:          deploymentDescription(previewEnv),
+		TransientEnvironment: true,
+	})
+	cancel()
+	if err != nil {
+		logger.Error(err, "Failed to create deployment")
+		return
+	}
+
+	previewEnv.Status.DeploymentID = id
+	logger.Info("Created deployment", "deploymentID", id, "environment", deploymentEnvironmentName(previewEnv))
+
+	r.reportDeploymentStatus(ctx, previewEnv, github.DeploymentStateInProgress, description)
+}
+
+// reportDeploymentStatus records a status on the preview's GitHub deployment, if one exists.
+// Reporting is best-effort: failures are logged and never fail the reconcile.
+func (r *PreviewEnvironmentReconciler) reportDeploymentStatus(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment, state github.DeploymentState, description string) {
+	if r.GitHubClient == nil || !isGitHubPreview(previewEnv) || previewEnv.Status.DeploymentID == 0 {
+		return
+	}
+	logger := logf.FromContext(ctx)
+
+	owner, repo, err := github.ParseRepository(previewEnv.Spec.Repository)
+	if err != nil {
+		logger.Error(err, "Failed to parse repository for deployment status")
+		return
+	}
+
+	status := &github.DeploymentStatus{
+		State:       state,
+		Description: truncateDescription(description),
+	}
+	if state != github.DeploymentStateInactive {
+		status.EnvironmentURL = previewEnv.Status.URL
+	}
+
+	reportCtx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+	if err := r.GitHubClient.CreateDeploymentStatus(reportCtx, owner, repo, previewEnv.Status.DeploymentID, status); err != nil {
+		logger.Error(err, "Failed to update deployment status", "state", state)
+		return
+	}
+
+	logger.Info("Updated deployment status", "state", state, "deploymentID", previewEnv.Status.DeploymentID)
+}
+
+// isGitHubPreview reports whether the preview was created for a GitHub pull request.
+// Previews from providers previewd cannot report to are not reported anywhere.
+func isGitHubPreview(previewEnv *previewv1alpha1.PreviewEnvironment) bool {
+	return previewEnv.Spec.Provider == "" || previewEnv.Spec.Provider == previewv1alpha1.ProviderGitHub
+}
+
+// deploymentEnvironmentName returns the GitHub environment name for a preview
+func deploymentEnvironmentName(previewEnv *previewv1alpha1.PreviewEnvironment) string {
+	return "preview-" + previewEnv.Spec.PreviewID()
+}
+
+// deploymentDescription describes the pull request or branch a GitHub deployment previews
+func deploymentDescription(previewEnv *previewv1alpha1.PreviewEnvironment) string {
+	if previewEnv.Spec.IsBranchPreview() {
+		return truncateDescription(fmt.Sprintf("Preview environment for branch %s", previewEnv.Spec.Branch))
+	}
+	return fmt.Sprintf("Preview environment for PR #%d", previewEnv.Spec.PRNumber)
+}
+
+// buildPreviewComment renders the markdown summary posted on the pull request
+func buildPreviewComment(previewEnv *previewv1alpha1.PreviewEnvironment) string {
+	var b strings.Builder
+
+	b.WriteString("### Preview environment ready\n\n")
+	b.WriteString("| | |\n|---|---|\n")
+
+	url := previewEnv.Status.URL
+	if url == "" {
+		url = "n/a"
+	}
+	fmt.Fprintf(&b, "| **URL** | %s |\n", url)
+
+	services := "n/a"
+	if len(previewEnv.Spec.Services) > 0 {
+		services = "`" + strings.Join(previewEnv.Spec.Services, "`, `") + "`"
+	}
+	fmt.Fprintf(&b, "| **Services** | %s |\n", services)
+
+	estimate := "n/a"
+	if c := previewEnv.Status.CostEstimate; c != nil {
+		estimate = fmt.Sprintf("%s %s/hour", c.HourlyCost, c.Currency)
+		if c.TotalCost != "" {
+			estimate += fmt.Sprintf(" (%s %s total)", c.TotalCost, c.Currency)
+		}
+	}
+	fmt.Fprintf(&b, "| **Estimated cost** | %s |\n", estimate)
+
+	expires := "n/a"
+	if previewEnv.Status.ExpiresAt != nil {
+		expires = previewEnv.Status.ExpiresAt.UTC().Format(time.RFC1123)
+	}
+	fmt.Fprintf(&b, "| **Expires** | %s |\n", expires)
+
+	fmt.Fprintf(&b, "\nDeployed commit: `%s`\n", previewEnv.Spec.HeadSHA)
+
+	return b.String()
+}
+
+// truncateDescription shortens a description to GitHub's status description limit
+func truncateDescription(description string) string {
+	if len(description) > maxStatusDescriptionLength {
+		return description[:maxStatusDescriptionLength-3] + "..."
+	}
+	return description
+}
@@ -0,0 +1,92 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/github"
+	"github.com/mikelane/previewd/internal/gitlab"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// isGitLabPreview reports whether the preview was created for a GitLab merge request
+func isGitLabPreview(previewEnv *previewv1alpha1.PreviewEnvironment) bool {
+	return previewEnv.Spec.Provider == previewv1alpha1.ProviderGitLab
+}
+
+// gitlabStatusState maps a GitHub commit status state to its GitLab equivalent
+func gitlabStatusState(state github.StatusState) gitlab.StatusState {
+	switch state {
+	case github.StatusStateSuccess:
+		return gitlab.StatusStateSuccess
+	case github.StatusStateError, github.StatusStateFailure:
+		return gitlab.StatusStateFailed
+	default:
+		return gitlab.StatusStatePending
+	}
+}
+
+// reportGitLabCommitStatus posts a commit status for the preview's head SHA on GitLab.
+// Reporting is best-effort: failures are logged and never fail the reconcile.
+func (r *PreviewEnvironmentReconciler) reportGitLabCommitStatus(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment, state github.StatusState, description string) {
+	if r.GitLabClient == nil {
+		return
+	}
+	logger := logf.FromContext(ctx)
+
+	status := &gitlab.Status{
+		State:       gitlabStatusState(state),
+		TargetURL:   previewEnv.Status.URL,
+		Description: truncateDescription(description),
+		Name:        commitStatusContext,
+	}
+	reportCtx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+	if err := r.GitLabClient.UpdateCommitStatus(reportCtx, previewEnv.Spec.Repository, previewEnv.Spec.HeadSHA, status); err != nil {
+		logger.Error(err, "Failed to update GitLab commit status", "state", status.State)
+		return
+	}
+
+	logger.Info("Updated GitLab commit status", "state", status.State, "sha", previewEnv.Spec.HeadSHA)
+}
+
+// reportGitLabPreviewNote upserts the merge request note summarizing the preview environment.
+// Reporting is best-effort: failures are logged and never fail the reconcile.
+func (r *PreviewEnvironmentReconciler) reportGitLabPreviewNote(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment) {
+	if r.GitLabClient == nil {
+		return
+	}
+	logger := logf.FromContext(ctx)
+
+	body := buildPreviewComment(previewEnv)
+	reportCtx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+	if err := r.GitLabClient.CreateOrUpdateNote(reportCtx, previewEnv.Spec.Repository, previewEnv.Spec.PRNumber, previewCommentMarker, body); err != nil {
+		logger.Error(err, "Failed to update GitLab preview note")
+		return
+	}
+
+	logger.Info("Updated GitLab preview note", "mr", previewEnv.Spec.PRNumber)
+}
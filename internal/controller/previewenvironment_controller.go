@@ -25,32 +25,61 @@ package controller
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/argocd"
 	"github.com/mikelane/previewd/internal/cost"
+	"github.com/mikelane/previewd/internal/github"
+	"github.com/mikelane/previewd/internal/gitlab"
+	"github.com/mikelane/previewd/internal/ingress"
+	"github.com/mikelane/previewd/internal/namespace"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
 const (
 	// finalizerName is the finalizer used to prevent deletion until cleanup is complete
 	finalizerName = "preview.previewd.io/finalizer"
 
+	// fieldManager identifies the reconciler's patches in managedFields
+	fieldManager = "previewd-controller"
+
 	// defaultRequeueAfter is the default duration to requeue the reconciliation loop.
 	// This value controls how frequently the operator checks each PreviewEnvironment
 	// for state changes. Keep this reasonably large (minutes, not seconds) to avoid
 	// excessive API server load. Specific events (CR creation, deletion, etc.) will
 	// trigger immediate reconciliation via webhooks.
 	defaultRequeueAfter = 5 * time.Minute
+
+	// namespaceDeletionRequeueAfter is how often deletion is re-checked while the
+	// preview namespace is still terminating.
+	namespaceDeletionRequeueAfter = 5 * time.Second
+
+	// awaitingIngressRequeueAfter is how often a preview waiting for its TLS
+	// certificate or DNS record is re-checked.
+	awaitingIngressRequeueAfter = 30 * time.Second
+
+	// Phase values for PreviewEnvironment status
+	phasePending  = "Pending"
+	phaseCreating = "Creating"
+	phaseReady    = "Ready"
+	phaseUpdating = "Updating"
+	phaseSleeping = "Sleeping"
+	phaseDeleting = "Deleting"
+	phaseFailed   = "Failed"
 )
 
 // PreviewEnvironmentReconciler reconciles a PreviewEnvironment object
@@ -58,12 +87,36 @@ type PreviewEnvironmentReconciler struct {
 	client.Client
 	Scheme        *runtime.Scheme
 	CostEstimator *cost.Estimator
+
+	// NamespaceManager provisions the preview namespace, quota and network policies.
+	// When nil, provisioning is skipped and the environment stays Pending.
+	NamespaceManager *namespace.Manager
+	// ArgoCDManager deploys services through an ArgoCD ApplicationSet (optional)
+	ArgoCDManager *argocd.Manager
+	// IngressManager exposes the preview environment through an Ingress (optional)
+	IngressManager *ingress.Manager
+	// GitHubClient reports commit statuses and PR comments (optional)
+	GitHubClient github.Client
+	// GitLabClient reports commit statuses and MR notes for GitLab previews (optional)
+	GitLabClient gitlab.Client
+	// Recorder records lifecycle transitions and errors as Events on the
+	// PreviewEnvironment (optional)
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=preview.previewd.io,resources=previewenvironments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=preview.previewd.io,resources=previewenvironments/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=preview.previewd.io,resources=previewenvironments/finalizers,verbs=update
 // +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=resourcequotas,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=argoproj.io,resources=applications,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=argoproj.io,resources=applicationsets,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -86,6 +139,30 @@ func (r *PreviewEnvironmentReconciler) Reconcile(ctx context.Context, req ctrl.R
 		return ctrl.Result{}, err
 	}
 
+	// Status changes are collected in memory and written once, as a patch, when
+	// the reconcile ends, so they never conflict with concurrent spec updates
+	// such as a webhook setting a new head SHA.
+	status := previewEnv.Status.DeepCopy()
+	result, err := r.reconcilePreview(ctx, previewEnv)
+
+	// A PreviewEnvironment whose finalizer was released is about to disappear
+	if !previewEnv.DeletionTimestamp.IsZero() && !controllerutil.ContainsFinalizer(previewEnv, finalizerName) {
+		return result, err
+	}
+	if patchErr := r.patchStatus(ctx, previewEnv, status); patchErr != nil {
+		logger.Error(patchErr, "Failed to update status")
+		if err == nil {
+			return ctrl.Result{}, patchErr
+		}
+	}
+	return result, err
+}
+
+// reconcilePreview runs one reconcile of a PreviewEnvironment, updating its
+// status in memory only
+func (r *PreviewEnvironmentReconciler) reconcilePreview(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment) (ctrl.Result, error) {
+	logger := logf.FromContext(ctx)
+
 	// Handle deletion
 	if !previewEnv.DeletionTimestamp.IsZero() {
 		return r.handleDeletion(ctx, previewEnv)
@@ -93,65 +170,271 @@ func (r *PreviewEnvironmentReconciler) Reconcile(ctx context.Context, req ctrl.R
 
 	// Add finalizer if it doesn't exist
 	if !controllerutil.ContainsFinalizer(previewEnv, finalizerName) {
-		controllerutil.AddFinalizer(previewEnv, finalizerName)
-		if err := r.Update(ctx, previewEnv); err != nil {
+		if err := r.patchFinalizer(ctx, previewEnv, controllerutil.AddFinalizer); err != nil {
 			logger.Error(err, "Failed to add finalizer")
 			return ctrl.Result{}, err
 		}
 		logger.Info("Added finalizer to PreviewEnvironment")
-
-		// Re-fetch the resource after updating finalizer to ensure we have latest version
-		if err := r.Get(ctx, req.NamespacedName, previewEnv); err != nil {
-			logger.Error(err, "Failed to re-fetch PreviewEnvironment after finalizer update")
-			return ctrl.Result{}, err
-		}
 	}
 
 	// Initialize status if this is a new resource
-	if err := r.initializeStatus(ctx, previewEnv); err != nil {
-		logger.Error(err, "Failed to initialize status")
+	r.initializeStatus(ctx, previewEnv)
+
+	// A new head SHA rolls out over the deployed environment
+	r.startRollout(ctx, previewEnv)
+
+	wasReady := previewEnv.Status.Phase == phaseReady
+	wasFailed := previewEnv.Status.Phase == phaseFailed
+
+	// Provision namespace, ArgoCD ApplicationSet and ingress
+	if err := r.provision(ctx, previewEnv); err != nil {
+		logger.Error(err, "Failed to provision preview environment")
+		r.recordEvent(previewEnv, corev1.EventTypeWarning, "ProvisioningFailed", "%v", err)
 		return ctrl.Result{}, err
 	}
 
-	// TODO(#3): Create namespace using namespace manager
-	// This will create a dedicated namespace for the preview environment
-	// with appropriate RBAC and resource quotas.
+	// Act on a /preview redeploy request once the environment is provisioned
+	if err := r.redeploy(ctx, previewEnv); err != nil {
+		logger.Error(err, "Failed to redeploy preview environment")
+		r.recordEvent(previewEnv, corev1.EventTypeWarning, "RedeployFailed", "%v", err)
+		return ctrl.Result{}, err
+	}
 
-	// TODO(#4): Deploy services via ArgoCD ApplicationSet
-	// Parse the repository and create an ArgoCD ApplicationSet that deploys
-	// services to the preview namespace based on the repository structure.
+	// Compute expiry from the spec TTL; the cleanup scheduler deletes expired environments
+	if err := r.updateExpiration(ctx, previewEnv); err != nil {
+		logger.Error(err, "Failed to update expiration")
+		r.recordEvent(previewEnv, corev1.EventTypeWarning, "InvalidTTL", "%v", err)
+		return ctrl.Result{}, err
+	}
 
 	// Perform cost estimation after status is initialized
 	if err := r.estimateAndUpdateCosts(ctx, previewEnv); err != nil {
 		logger.Error(err, "Failed to estimate costs (non-fatal, will retry)")
+		r.recordEvent(previewEnv, corev1.EventTypeWarning, "CostEstimationFailed", "%v", err)
 		// Log the error but don't fail - cost estimation is best-effort
 	}
 
-	// TODO(#5): Implement TTL-based expiration check and cleanup
-	// Check if the environment has exceeded its TTL (time-to-live) and
-	// trigger cleanup if necessary.
+	// Report readiness to GitHub once, when the environment becomes Ready
+	if !wasReady && previewEnv.Status.Phase == phaseReady {
+		message := "Preview environment is ready"
+		if previewEnv.Status.DeployedSHA != "" {
+			message += " at " + shortSHA(previewEnv.Status.DeployedSHA)
+		}
+		r.recordEvent(previewEnv, corev1.EventTypeNormal, "Ready", "%s", message)
+		r.reportCommitStatus(ctx, previewEnv, github.StatusStateSuccess, "Preview environment is ready")
+		r.reportDeploymentStatus(ctx, previewEnv, github.DeploymentStateSuccess, "Preview environment is ready")
+		r.reportPreviewComment(ctx, previewEnv)
+	}
+
+	// Report a service failure once, when the environment becomes Failed after provisioning
+	if !wasFailed && previewEnv.Status.Phase == phaseFailed {
+		message := "Preview environment is degraded"
+		if degraded := meta.FindStatusCondition(previewEnv.Status.Conditions, conditionDegraded); degraded != nil {
+			message = degraded.Message
+		}
+		r.recordEvent(previewEnv, corev1.EventTypeWarning, "Degraded", "%s", message)
+		r.reportCommitStatus(ctx, previewEnv, github.StatusStateFailure, message)
+		r.reportDeploymentStatus(ctx, previewEnv, github.DeploymentStateFailure, message)
+	}
+
+	// cert-manager and external-dns are not watched, so check on them more often
+	if !isSleeping(previewEnv) && awaitingIngress(previewEnv) {
+		return ctrl.Result{RequeueAfter: awaitingIngressRequeueAfter}, nil
+	}
 
 	// Requeue after the default interval for periodic reconciliation
 	return ctrl.Result{RequeueAfter: defaultRequeueAfter}, nil
 }
 
-// handleDeletion performs cleanup when a PreviewEnvironment is being deleted
+// provision drives the provisioning sequence for a preview environment:
+// namespace, resource quota, network policies, ArgoCD ApplicationSet and ingress.
+// Each step is idempotent, so the full sequence is re-run on every reconcile.
+func (r *PreviewEnvironmentReconciler) provision(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment) error {
+	logger := logf.FromContext(ctx)
+
+	if r.NamespaceManager == nil {
+		logger.V(1).Info("Namespace manager not configured, skipping provisioning")
+		return nil
+	}
+
+	// Move to Creating before touching the cluster
+	if previewEnv.Status.Phase == phasePending {
+		previewEnv.Status.Phase = phaseCreating
+		r.recordEvent(previewEnv, corev1.EventTypeNormal, "Creating", "Creating preview environment at %s",
+			shortSHA(previewEnv.Spec.HeadSHA))
+		r.startDeployment(ctx, previewEnv, "Creating preview environment")
+		r.reportCommitStatus(ctx, previewEnv, github.StatusStatePending, "Creating preview environment")
+	}
+
+	if err := r.ensureResources(ctx, previewEnv); err != nil {
+		if previewEnv.Status.Phase != phaseFailed {
+			r.reportCommitStatus(ctx, previewEnv, github.StatusStateFailure, fmt.Sprintf("Provisioning failed: %v", err))
+			r.reportDeploymentStatus(ctx, previewEnv, github.DeploymentStateFailure, fmt.Sprintf("Provisioning failed: %v", err))
+		}
+		setCondition(previewEnv, conditionAvailable, metav1.ConditionFalse, reasonProvisioningFailed, err.Error())
+		setCondition(previewEnv, conditionDegraded, metav1.ConditionTrue, reasonProvisioningFailed, err.Error())
+		updateReadyCondition(previewEnv)
+		return err
+	}
+
+	// Derive the phase from the health of the deployed services and the step conditions
+	if err := r.updateServiceHealth(ctx, previewEnv); err != nil {
+		return fmt.Errorf("failed to read service health: %w", err)
+	}
+	updateReadyCondition(previewEnv)
+
+	now := metav1.Now()
+	previewEnv.Status.LastSyncedAt = &now
+	previewEnv.Status.ObservedGeneration = previewEnv.Generation
+
+	logger.Info("Provisioned preview environment",
+		"namespace", previewEnv.Status.Namespace,
+		"url", previewEnv.Status.URL,
+		"phase", previewEnv.Status.Phase)
+	return nil
+}
+
+// ensureResources creates or updates every resource backing the preview environment
+// and records the namespace, URL and the condition of each step on the status
+// (without persisting it).
+func (r *PreviewEnvironmentReconciler) ensureResources(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment) error {
+	nsName, err := r.NamespaceManager.GetNamespaceName(previewEnv)
+	if err != nil {
+		return stepFailed(previewEnv, conditionNamespaceReady, err)
+	}
+
+	if err := r.NamespaceManager.EnsureNamespace(ctx, previewEnv); err != nil {
+		return stepFailed(previewEnv, conditionNamespaceReady, err)
+	}
+	previewEnv.Status.Namespace = nsName
+	setCondition(previewEnv, conditionNamespaceReady, metav1.ConditionTrue, "Created",
+		fmt.Sprintf("Namespace %s exists", nsName))
+
+	if err := r.NamespaceManager.EnsureResourceQuota(ctx, previewEnv, nsName); err != nil {
+		return stepFailed(previewEnv, conditionQuotaApplied, err)
+	}
+	setCondition(previewEnv, conditionQuotaApplied, metav1.ConditionTrue, "Applied",
+		"Resource quota is applied to the preview namespace")
+
+	if err := r.NamespaceManager.EnsureNetworkPolicies(ctx, previewEnv, nsName); err != nil {
+		return stepFailed(previewEnv, conditionNetworkPoliciesApplied, fmt.Errorf("failed to ensure network policies: %w", err))
+	}
+	setCondition(previewEnv, conditionNetworkPoliciesApplied, metav1.ConditionTrue, "Applied",
+		"Network policies are applied to the preview namespace")
+
+	if r.ArgoCDManager == nil {
+		meta.RemoveStatusCondition(&previewEnv.Status.Conditions, conditionApplicationSetSynced)
+	} else {
+		appSetName := r.ArgoCDManager.GetApplicationSetName(previewEnv)
+		if isSleeping(previewEnv) {
+			// Removing the ApplicationSet prunes the services; namespace and ingress stay in place
+			if err := r.ArgoCDManager.DeleteApplicationSet(ctx, appSetName, r.ArgoCDManager.GetArgocdNamespace()); err != nil {
+				return stepFailed(previewEnv, conditionApplicationSetSynced, err)
+			}
+			setCondition(previewEnv, conditionApplicationSetSynced, metav1.ConditionFalse, "Sleeping",
+				fmt.Sprintf("ApplicationSet %s is removed until the preview environment is redeployed", appSetName))
+		} else {
+			if err := r.ArgoCDManager.EnsureApplicationSet(ctx, previewEnv, nsName); err != nil {
+				return stepFailed(previewEnv, conditionApplicationSetSynced, err)
+			}
+			setCondition(previewEnv, conditionApplicationSetSynced, metav1.ConditionTrue, "Synced",
+				fmt.Sprintf("ApplicationSet %s is up to date", appSetName))
+		}
+	}
+
+	if r.IngressManager == nil {
+		for _, conditionType := range []string{conditionIngressReady, conditionCertificateIssued, conditionDNSPublished} {
+			meta.RemoveStatusCondition(&previewEnv.Status.Conditions, conditionType)
+		}
+	} else {
+		if err := r.IngressManager.EnsureIngress(ctx, previewEnv, nsName); err != nil {
+			return stepFailed(previewEnv, conditionIngressReady, err)
+		}
+		host := r.IngressManager.GetIngressHost(previewEnv)
+		previewEnv.Status.URL = fmt.Sprintf("https://%s", host)
+		setCondition(previewEnv, conditionIngressReady, metav1.ConditionTrue, "Applied",
+			fmt.Sprintf("Ingress %s routes %s", ingress.IngressName, host))
+
+		r.updateCertificateCondition(ctx, previewEnv, nsName)
+		r.updateDNSCondition(ctx, previewEnv)
+	}
+
+	return nil
+}
+
+// handleDeletion performs cleanup when a PreviewEnvironment is being deleted.
+// It removes the ApplicationSet from the ArgoCD namespace and the preview namespace,
+// and only releases the finalizer once the namespace has been fully removed.
 func (r *PreviewEnvironmentReconciler) handleDeletion(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment) (ctrl.Result, error) {
 	logger := logf.FromContext(ctx)
 
-	if controllerutil.ContainsFinalizer(previewEnv, finalizerName) {
-		// Perform cleanup here (will be implemented in future PRs)
-		// For now, just remove the finalizer to allow deletion
-		logger.Info("Performing cleanup for PreviewEnvironment deletion")
+	if !controllerutil.ContainsFinalizer(previewEnv, finalizerName) {
+		return ctrl.Result{}, nil
+	}
+
+	logger.Info("Performing cleanup for PreviewEnvironment deletion")
+
+	// Record that teardown is in progress
+	if previewEnv.Status.Phase != phaseDeleting {
+		previewEnv.Status.Phase = phaseDeleting
+		r.recordEvent(previewEnv, corev1.EventTypeNormal, "Deleting", "Removing preview environment resources")
+		meta.SetStatusCondition(&previewEnv.Status.Conditions, metav1.Condition{
+			Type:               conditionReady,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: previewEnv.Generation,
+			Reason:             "Deleting",
+			Message:            "Preview environment resources are being removed",
+		})
+	}
 
-		controllerutil.RemoveFinalizer(previewEnv, finalizerName)
-		if err := r.Update(ctx, previewEnv); err != nil {
-			logger.Error(err, "Failed to remove finalizer")
+	// ApplicationSets live in the ArgoCD namespace and cannot be garbage collected
+	// through owner references, so they are removed explicitly.
+	if r.ArgoCDManager != nil {
+		appSetName := r.ArgoCDManager.GetApplicationSetName(previewEnv)
+		if err := r.ArgoCDManager.DeleteApplicationSet(ctx, appSetName, r.ArgoCDManager.GetArgocdNamespace()); err != nil {
+			logger.Error(err, "Failed to delete ApplicationSet")
+			r.recordEvent(previewEnv, corev1.EventTypeWarning, "CleanupFailed", "%v", err)
 			return ctrl.Result{}, err
 		}
-		logger.Info("Removed finalizer, PreviewEnvironment can now be deleted")
 	}
 
+	// Deleting the namespace removes everything inside it (quota, policies, ingress, workloads)
+	if r.NamespaceManager != nil {
+		if err := r.NamespaceManager.Cleanup(ctx, previewEnv); err != nil {
+			logger.Error(err, "Failed to delete preview namespace")
+			r.recordEvent(previewEnv, corev1.EventTypeWarning, "CleanupFailed", "%v", err)
+			return ctrl.Result{}, err
+		}
+
+		deleted, err := r.NamespaceManager.IsNamespaceDeleted(ctx, previewEnv)
+		if err != nil {
+			logger.Error(err, "Failed to check preview namespace deletion")
+			r.recordEvent(previewEnv, corev1.EventTypeWarning, "CleanupFailed", "%v", err)
+			return ctrl.Result{}, err
+		}
+		if !deleted {
+			logger.Info("Waiting for preview namespace to terminate", "namespace", previewEnv.Status.Namespace)
+			meta.SetStatusCondition(&previewEnv.Status.Conditions, metav1.Condition{
+				Type:               conditionReady,
+				Status:             metav1.ConditionFalse,
+				ObservedGeneration: previewEnv.Generation,
+				Reason:             "NamespaceTerminating",
+				Message:            "Waiting for the preview namespace to finish terminating",
+			})
+			return ctrl.Result{RequeueAfter: namespaceDeletionRequeueAfter}, nil
+		}
+	}
+
+	// Teardown is complete, so the GitHub deployment no longer points at a live environment
+	r.reportDeploymentStatus(ctx, previewEnv, github.DeploymentStateInactive, "Preview environment deleted")
+
+	if err := r.patchFinalizer(ctx, previewEnv, controllerutil.RemoveFinalizer); err != nil {
+		logger.Error(err, "Failed to remove finalizer")
+		return ctrl.Result{}, err
+	}
+	logger.Info("Removed finalizer, PreviewEnvironment can now be deleted")
+	r.recordEvent(previewEnv, corev1.EventTypeNormal, "CleanupCompleted", "Removed preview environment resources")
+
 	return ctrl.Result{}, nil
 }
 
@@ -172,12 +455,14 @@ func (r *PreviewEnvironmentReconciler) estimateAndUpdateCosts(ctx context.Contex
 	// List all pods in the preview environment namespace
 	var podList corev1.PodList
 	if err := r.List(ctx, &podList, client.InNamespace(previewEnv.Status.Namespace)); err != nil {
-		return fmt.Errorf("failed to list pods in namespace %s: %w", previewEnv.Status.Namespace, err)
+		return costEstimationFailed(previewEnv,
+			fmt.Errorf("failed to list pods in namespace %s: %w", previewEnv.Status.Namespace, err))
 	}
 
-	// Use default TTL since TTL field is not yet implemented in the spec
-	// TODO(#5): Add TTL field to PreviewEnvironmentSpec
-	ttl := 4 * time.Hour
+	ttl, err := previewv1alpha1.ParseTTL(previewEnv.Spec.TTL)
+	if err != nil {
+		return costEstimationFailed(previewEnv, err)
+	}
 
 	// Check if spot instances should be used
 	useSpot := checkSpotInstance(previewEnv)
@@ -186,12 +471,13 @@ func (r *PreviewEnvironmentReconciler) estimateAndUpdateCosts(ctx context.Contex
 	costEstimate := r.CostEstimator.EstimateEnvironmentCost(podList.Items, ttl, useSpot)
 
 	// Update status with cost estimate
-	previewEnv.Status.CostEstimate = costEstimate
-
-	// Update the status
-	if err := r.Status().Update(ctx, previewEnv); err != nil {
-		return fmt.Errorf("failed to update preview environment status: %w", err)
+	message := fmt.Sprintf("Estimated at %s %s per hour, %s %s over the TTL",
+		costEstimate.HourlyCost, costEstimate.Currency, costEstimate.TotalCost, costEstimate.Currency)
+	if !equality.Semantic.DeepEqual(previewEnv.Status.CostEstimate, costEstimate) {
+		r.recordEvent(previewEnv, corev1.EventTypeNormal, "CostEstimated", "%s", message)
 	}
+	previewEnv.Status.CostEstimate = costEstimate
+	setCondition(previewEnv, conditionCostEstimated, metav1.ConditionTrue, "Estimated", message)
 
 	logger.Info("Updated cost estimate",
 		"namespace", previewEnv.Status.Namespace,
@@ -202,18 +488,50 @@ func (r *PreviewEnvironmentReconciler) estimateAndUpdateCosts(ctx context.Contex
 	return nil
 }
 
+// costEstimationFailed records a failed cost estimation on the CostEstimated
+// condition and returns err
+func costEstimationFailed(previewEnv *previewv1alpha1.PreviewEnvironment, err error) error {
+	setCondition(previewEnv, conditionCostEstimated, metav1.ConditionFalse, "EstimationFailed", err.Error())
+	return err
+}
+
+// updateExpiration sets Status.ExpiresAt to CreatedAt + spec.ttl, logging when
+// the expiry actually changes (e.g. the TTL was edited).
+func (r *PreviewEnvironmentReconciler) updateExpiration(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment) error {
+	if previewEnv.Status.CreatedAt == nil {
+		return nil
+	}
+
+	ttl, err := previewv1alpha1.ParseTTL(previewEnv.Spec.TTL)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := metav1.NewTime(previewEnv.Status.CreatedAt.Add(ttl))
+	if previewEnv.Status.ExpiresAt != nil && previewEnv.Status.ExpiresAt.Equal(&expiresAt) {
+		return nil
+	}
+
+	previewEnv.Status.ExpiresAt = &expiresAt
+
+	logf.FromContext(ctx).Info("Updated expiration", "ttl", ttl, "expiresAt", expiresAt)
+	r.recordEvent(previewEnv, corev1.EventTypeNormal, "ExpirationUpdated", "Preview environment expires at %s (TTL %s)",
+		expiresAt.UTC().Format(time.RFC3339), ttl)
+	return nil
+}
+
 // initializeStatus sets up initial status fields for a new PreviewEnvironment
-func (r *PreviewEnvironmentReconciler) initializeStatus(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment) error {
+func (r *PreviewEnvironmentReconciler) initializeStatus(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment) {
 	logger := logf.FromContext(ctx)
 
 	// Check if status has already been initialized
 	if previewEnv.Status.Phase != "" && previewEnv.Status.CreatedAt != nil {
 		// Status already initialized, skip
-		return nil
+		return
 	}
 
 	// Set initial phase
-	previewEnv.Status.Phase = "Pending"
+	previewEnv.Status.Phase = phasePending
 
 	// Set creation timestamp if not already set
 	if previewEnv.Status.CreatedAt == nil {
@@ -226,7 +544,7 @@ func (r *PreviewEnvironmentReconciler) initializeStatus(ctx context.Context, pre
 
 	// Set Ready condition to False
 	meta.SetStatusCondition(&previewEnv.Status.Conditions, metav1.Condition{
-		Type:               "Ready",
+		Type:               conditionReady,
 		Status:             metav1.ConditionFalse,
 		ObservedGeneration: previewEnv.Generation,
 		Reason:             "Reconciling",
@@ -234,49 +552,60 @@ func (r *PreviewEnvironmentReconciler) initializeStatus(ctx context.Context, pre
 		LastTransitionTime: metav1.Now(),
 	})
 
-	// Update status subresource
-	if err := r.Status().Update(ctx, previewEnv); err != nil {
-		return fmt.Errorf("failed to update status: %w", err)
+	logger.Info("Initialized PreviewEnvironment status", "phase", previewEnv.Status.Phase)
+}
+
+// patchStatus writes the status of the preview environment if it differs from
+// status, the status read at the start of the reconcile. It sends a merge patch
+// without a resourceVersion precondition, so concurrent spec and metadata
+// updates never make it conflict.
+func (r *PreviewEnvironmentReconciler) patchStatus(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment, status *previewv1alpha1.PreviewEnvironmentStatus) error {
+	if equality.Semantic.DeepEqual(*status, previewEnv.Status) {
+		return nil
 	}
 
-	logger.Info("Initialized PreviewEnvironment status", "phase", previewEnv.Status.Phase)
+	base := previewEnv.DeepCopy()
+	base.Status = *status
+	if err := r.Status().Patch(ctx, previewEnv, client.MergeFrom(base), client.FieldOwner(fieldManager)); err != nil {
+		return fmt.Errorf("failed to patch status: %w", err)
+	}
 	return nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *PreviewEnvironmentReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&previewv1alpha1.PreviewEnvironment{}).
-		Named("previewenvironment").
-		Complete(r)
+// patchFinalizer adds or removes the previewd finalizer with a merge patch
+func (r *PreviewEnvironmentReconciler) patchFinalizer(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment, mutate func(client.Object, string) bool) error {
+	base := previewEnv.DeepCopy()
+	mutate(previewEnv, finalizerName)
+	return r.Patch(ctx, previewEnv, client.MergeFrom(base), client.FieldOwner(fieldManager))
 }
 
-// parseTTL parses a TTL string and returns a time.Duration.
-// Supports formats like "4h", "30m", "2d" (days).
-// Returns default of 4 hours if empty or invalid.
-func parseTTL(ttl string) (time.Duration, error) {
-	if ttl == "" {
-		return 4 * time.Hour, nil
+// recordEvent records an Event on the preview environment, if a recorder is set
+func (r *PreviewEnvironmentReconciler) recordEvent(previewEnv *previewv1alpha1.PreviewEnvironment, eventType, reason, messageFmt string, args ...any) {
+	if r.Recorder != nil {
+		r.Recorder.Eventf(previewEnv, eventType, reason, messageFmt, args...)
 	}
+}
 
-	// Handle days specially (e.g., "2d" -> 48h)
-	if strings.HasSuffix(ttl, "d") {
-		daysStr := strings.TrimSuffix(ttl, "d")
-		var days int
-		_, err := fmt.Sscanf(daysStr, "%d", &days)
-		if err != nil {
-			return 0, fmt.Errorf("invalid TTL format: %s", ttl)
-		}
-		return time.Duration(days) * 24 * time.Hour, nil
+// SetupWithManager sets up the controller with the Manager.
+func (r *PreviewEnvironmentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&previewv1alpha1.PreviewEnvironment{}).
+		Named("previewenvironment")
+
+	// Reconcile as soon as ArgoCD reports a health or sync change of a preview's
+	// Applications instead of waiting for the periodic requeue
+	if r.ArgoCDManager != nil {
+		b = b.Watches(&argocd.Application{},
+			handler.EnqueueRequestsFromMapFunc(r.previewsForApplication),
+			builder.WithPredicates(predicate.NewPredicateFuncs(argocd.IsPreviewApplication)))
 	}
 
-	// Parse standard Go duration formats
-	duration, err := time.ParseDuration(ttl)
-	if err != nil {
-		return 0, fmt.Errorf("invalid TTL format: %s", ttl)
-	}
+	return b.Complete(r)
+}
 
-	return duration, nil
+// isSleeping reports whether the preview environment has been put to sleep
+func isSleeping(preview *previewv1alpha1.PreviewEnvironment) bool {
+	return preview.Annotations[previewv1alpha1.SleepAnnotation] == "true"
 }
 
 // checkSpotInstance checks if the preview environment should use spot instances
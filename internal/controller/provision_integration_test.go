@@ -0,0 +1,1328 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/argocd"
+	"github.com/mikelane/previewd/internal/cost"
+	"github.com/mikelane/previewd/internal/github"
+	"github.com/mikelane/previewd/internal/gitlab"
+	"github.com/mikelane/previewd/internal/ingress"
+	"github.com/mikelane/previewd/internal/namespace"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func init() {
+	if err := argocd.AddToScheme(testScheme); err != nil {
+		panic(err)
+	}
+}
+
+// fakeGitHubClient records calls made by the reconciler
+type fakeGitHubClient struct {
+	statuses           []github.Status
+	statusSHAs         []string
+	comments           []string
+	deployments        []github.Deployment
+	deploymentStatuses []github.DeploymentStatus
+}
+
+func (f *fakeGitHubClient) GetPullRequest(_ context.Context, _, _ string, _ int) (*github.PullRequest, error) {
+	return &github.PullRequest{}, nil
+}
+
+func (f *fakeGitHubClient) GetPRFiles(_ context.Context, _, _ string, _ int) ([]*github.File, error) {
+	return []*github.File{}, nil
+}
+
+func (f *fakeGitHubClient) UpdateCommitStatus(_ context.Context, _, _, sha string, status *github.Status) error {
+	f.statuses = append(f.statuses, *status)
+	f.statusSHAs = append(f.statusSHAs, sha)
+	return nil
+}
+
+func (f *fakeGitHubClient) CreateOrUpdateComment(_ context.Context, _, _ string, _ int, marker, body string) error {
+	f.comments = append(f.comments, marker+"\n"+body)
+	return nil
+}
+
+func (f *fakeGitHubClient) CreateDeployment(_ context.Context, _, _ string, deployment *github.Deployment) (int64, error) {
+	f.deployments = append(f.deployments, *deployment)
+	return int64(len(f.deployments)), nil
+}
+
+func (f *fakeGitHubClient) CreateDeploymentStatus(_ context.Context, _, _ string, _ int64, status *github.DeploymentStatus) error {
+	f.deploymentStatuses = append(f.deploymentStatuses, *status)
+	return nil
+}
+
+func (f *fakeGitHubClient) GetFileContents(_ context.Context, _, _, _, _ string) ([]byte, error) {
+	return nil, github.ErrNotFound
+}
+
+func (f *fakeGitHubClient) GetPermissionLevel(_ context.Context, _, _, _ string) (string, error) {
+	return "write", nil
+}
+
+func (f *fakeGitHubClient) CreateComment(_ context.Context, _, _ string, _ int, _ string) error {
+	return nil
+}
+
+func (f *fakeGitHubClient) AddCommentReaction(_ context.Context, _, _ string, _ int64, _ string) error {
+	return nil
+}
+
+// fakeGitLabClient records calls made by the reconciler for GitLab previews
+type fakeGitLabClient struct {
+	gitlab.Client
+	statuses []gitlab.Status
+	notes    []string
+}
+
+func (f *fakeGitLabClient) UpdateCommitStatus(_ context.Context, _, _ string, status *gitlab.Status) error {
+	f.statuses = append(f.statuses, *status)
+	return nil
+}
+
+func (f *fakeGitLabClient) CreateOrUpdateNote(_ context.Context, _ string, _ int, marker, body string) error {
+	f.notes = append(f.notes, marker+"\n"+body)
+	return nil
+}
+
+// newProvisioningReconciler returns a reconciler wired with all managers against a fake client
+func newProvisioningReconciler(objs ...client.Object) (*PreviewEnvironmentReconciler, client.Client) {
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(testScheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&previewv1alpha1.PreviewEnvironment{}).
+		Build()
+
+	return &PreviewEnvironmentReconciler{
+		Client:           fakeClient,
+		Scheme:           testScheme,
+		CostEstimator:    cost.NewEstimator(nil),
+		NamespaceManager: namespace.NewManager(fakeClient, testScheme),
+		ArgoCDManager:    argocd.NewManager(fakeClient, testScheme, "https://github.com/org/repo", "argocd", "default"),
+		IngressManager:   ingress.NewManager(fakeClient, testScheme, "preview.example.com", "letsencrypt-prod"),
+	}, fakeClient
+}
+
+// recordedEvents returns the events recorded so far, as "<type> <reason> <message>"
+func recordedEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// serviceApplication returns the ArgoCD Application generated for one service
+// of a preview, reporting the given health and sync status
+func serviceApplication(preview *previewv1alpha1.PreviewEnvironment, service, health, sync string) *argocd.Application {
+	labels := preview.Spec.IdentityLabels()
+	labels[argocd.ServiceLabel] = service
+	labels[argocd.ManagedByLabel] = "previewd"
+	return &argocd.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "preview-" + preview.Spec.InstanceID() + "-" + service,
+			Namespace: "argocd",
+			Labels:    labels,
+		},
+		Status: argocd.ApplicationStatus{
+			Health: argocd.HealthStatus{Status: health},
+			Sync:   argocd.SyncStatus{Status: sync, Revision: preview.Spec.HeadSHA},
+		},
+	}
+}
+
+// issuedCertificate returns the cert-manager Certificate of the preview's ingress,
+// reporting it as issued
+func issuedCertificate(preview *previewv1alpha1.PreviewEnvironment) *unstructured.Unstructured {
+	nsName, err := namespace.NewManager(nil, testScheme).GetNamespaceName(preview)
+	if err != nil {
+		panic(err)
+	}
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "True", "message": "Certificate is up to date"},
+			},
+		},
+	}}
+	certificate.SetGroupVersionKind(ingress.CertificateGVK)
+	certificate.SetName(preview.Spec.InstanceID() + "-tls")
+	certificate.SetNamespace(nsName)
+	return certificate
+}
+
+// withReadyResources returns the preview followed by its issued certificate and
+// a Healthy and Synced Application for each of its services
+func withReadyResources(preview *previewv1alpha1.PreviewEnvironment) []client.Object {
+	objs := []client.Object{preview, issuedCertificate(preview)}
+	for _, service := range preview.Spec.Services {
+		objs = append(objs, serviceApplication(preview, service, healthHealthy, syncSynced))
+	}
+	return objs
+}
+
+func TestReconciler_ProvisionsPreviewEnvironment(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-42",
+			Namespace: "default",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			PRNumber:   42,
+			HeadSHA:    "1234567890123456789012345678901234567890",
+			Services:   []string{"api", "frontend"},
+		},
+	}
+
+	reconciler, fakeClient := newProvisioningReconciler(withReadyResources(preview)...)
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "default"}}
+
+	if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	var updated previewv1alpha1.PreviewEnvironment
+	if err := fakeClient.Get(context.TODO(), req.NamespacedName, &updated); err != nil {
+		t.Fatalf("Failed to get preview environment: %v", err)
+	}
+
+	nsName, err := reconciler.NamespaceManager.GetNamespaceName(&updated)
+	if err != nil {
+		t.Fatalf("GetNamespaceName() error = %v", err)
+	}
+
+	if updated.Status.Phase != phaseReady {
+		t.Errorf("Phase = %q, want %q", updated.Status.Phase, phaseReady)
+	}
+	if updated.Status.Namespace != nsName {
+		t.Errorf("Namespace = %q, want %q", updated.Status.Namespace, nsName)
+	}
+	if updated.Status.URL != "https://pr-42-57b681c5.preview.example.com" {
+		t.Errorf("URL = %q, want %q", updated.Status.URL, "https://pr-42-57b681c5.preview.example.com")
+	}
+	if !meta.IsStatusConditionTrue(updated.Status.Conditions, conditionReady) {
+		t.Errorf("Ready condition = %+v, want True", meta.FindStatusCondition(updated.Status.Conditions, conditionReady))
+	}
+
+	var ns corev1.Namespace
+	if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: nsName}, &ns); err != nil {
+		t.Errorf("expected namespace %s to exist: %v", nsName, err)
+	}
+
+	var quota corev1.ResourceQuota
+	if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: "preview-quota", Namespace: nsName}, &quota); err != nil {
+		t.Errorf("expected resource quota to exist: %v", err)
+	}
+
+	var policies networkingv1.NetworkPolicyList
+	if err := fakeClient.List(context.TODO(), &policies, client.InNamespace(nsName)); err != nil {
+		t.Fatalf("Failed to list network policies: %v", err)
+	}
+	if len(policies.Items) != 3 {
+		t.Errorf("network policies = %d, want 3", len(policies.Items))
+	}
+
+	var appSet argocd.ApplicationSet
+	if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: "preview-pr-42-57b681c5", Namespace: "argocd"}, &appSet); err != nil {
+		t.Errorf("expected ApplicationSet to exist: %v", err)
+	}
+
+	var ing networkingv1.Ingress
+	if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: ingress.IngressName, Namespace: nsName}, &ing); err != nil {
+		t.Errorf("expected ingress to exist: %v", err)
+	}
+}
+
+func TestReconciler_SleepingPreviewRemovesApplicationSet(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pr-42",
+			Namespace:   "default",
+			Annotations: map[string]string{previewv1alpha1.SleepAnnotation: "true"},
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			PRNumber:   42,
+			HeadSHA:    "1234567890123456789012345678901234567890",
+			Services:   []string{"api"},
+		},
+	}
+	appSet := &argocd.ApplicationSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "preview-pr-42-57b681c5", Namespace: "argocd"},
+	}
+
+	reconciler, fakeClient := newProvisioningReconciler(preview, appSet)
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "default"}}
+
+	if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: "preview-pr-42-57b681c5", Namespace: "argocd"}, &argocd.ApplicationSet{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected ApplicationSet to be deleted while sleeping, got err = %v", err)
+	}
+
+	var updated previewv1alpha1.PreviewEnvironment
+	if err := fakeClient.Get(context.TODO(), req.NamespacedName, &updated); err != nil {
+		t.Fatalf("Failed to get preview environment: %v", err)
+	}
+	ready := meta.FindStatusCondition(updated.Status.Conditions, conditionReady)
+	if ready == nil || ready.Status != metav1.ConditionFalse || ready.Reason != "Sleeping" {
+		t.Errorf("Ready condition = %+v, want False/Sleeping", ready)
+	}
+	if updated.Status.Phase != phaseSleeping {
+		t.Errorf("Phase = %q, want %q", updated.Status.Phase, phaseSleeping)
+	}
+}
+
+func TestReconciler_SleepingPreviewIsNotReportedReady(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pr-42",
+			Namespace:   "default",
+			Annotations: map[string]string{previewv1alpha1.SleepAnnotation: "true"},
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			PRNumber:   42,
+			HeadSHA:    "1234567890123456789012345678901234567890",
+			Services:   []string{"api"},
+		},
+	}
+
+	reconciler, fakeClient := newProvisioningReconciler(preview,
+		serviceApplication(preview, "api", healthHealthy, syncSynced), issuedCertificate(preview))
+	gh := &fakeGitHubClient{}
+	reconciler.GitHubClient = gh
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "default"}}
+
+	reconcileAndGet := func() *previewv1alpha1.PreviewEnvironment {
+		t.Helper()
+		if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		updated := &previewv1alpha1.PreviewEnvironment{}
+		if err := fakeClient.Get(context.TODO(), req.NamespacedName, updated); err != nil {
+			t.Fatalf("Failed to get preview environment: %v", err)
+		}
+		return updated
+	}
+
+	for range 2 {
+		if updated := reconcileAndGet(); updated.Status.Phase != phaseSleeping {
+			t.Errorf("Phase = %q, want %q", updated.Status.Phase, phaseSleeping)
+		}
+	}
+	for _, status := range gh.statuses {
+		if status.State == github.StatusStateSuccess {
+			t.Errorf("commit statuses = %+v, want no success while sleeping", gh.statuses)
+		}
+	}
+	for _, status := range gh.deploymentStatuses {
+		if status.State == github.DeploymentStateSuccess {
+			t.Errorf("deployment statuses = %+v, want no success while sleeping", gh.deploymentStatuses)
+		}
+	}
+	if len(gh.comments) != 0 {
+		t.Errorf("got %d preview comments, want none while sleeping", len(gh.comments))
+	}
+
+	// Waking the preview redeploys it and reports it ready
+	updated := reconcileAndGet()
+	delete(updated.Annotations, previewv1alpha1.SleepAnnotation)
+	if err := fakeClient.Update(context.TODO(), updated); err != nil {
+		t.Fatalf("Failed to wake preview environment: %v", err)
+	}
+	if updated = reconcileAndGet(); updated.Status.Phase != phaseReady {
+		t.Errorf("Phase = %q after waking, want %q", updated.Status.Phase, phaseReady)
+	}
+	if last := gh.statuses[len(gh.statuses)-1]; last.State != github.StatusStateSuccess || len(gh.comments) != 1 {
+		t.Errorf("commit statuses = %+v, comments = %d; want success and a preview comment after waking", gh.statuses, len(gh.comments))
+	}
+}
+
+func TestReconciler_RedeploysOnRequest(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-42",
+			Namespace: "default",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			PRNumber:   42,
+			HeadSHA:    "1234567890123456789012345678901234567890",
+			Services:   []string{"api"},
+		},
+	}
+	nsName, err := namespace.NewManager(nil, testScheme).GetNamespaceName(preview)
+	if err != nil {
+		t.Fatalf("GetNamespaceName() error = %v", err)
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "pr-42-api", Namespace: nsName},
+	}
+
+	reconciler, fakeClient := newProvisioningReconciler(preview, deployment,
+		serviceApplication(preview, "api", healthHealthy, syncSynced), issuedCertificate(preview))
+	recorder := record.NewFakeRecorder(100)
+	reconciler.Recorder = recorder
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "default"}}
+
+	reconcileAndGet := func() *previewv1alpha1.PreviewEnvironment {
+		t.Helper()
+		if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		updated := &previewv1alpha1.PreviewEnvironment{}
+		if err := fakeClient.Get(context.TODO(), req.NamespacedName, updated); err != nil {
+			t.Fatalf("Failed to get preview environment: %v", err)
+		}
+		return updated
+	}
+	restartedAt := func() string {
+		t.Helper()
+		current := &appsv1.Deployment{}
+		if err := fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(deployment), current); err != nil {
+			t.Fatalf("Failed to get Deployment: %v", err)
+		}
+		return current.Spec.Template.Annotations[restartedAtAnnotation]
+	}
+	refreshRequested := func() bool {
+		t.Helper()
+		app := &argocd.Application{}
+		key := types.NamespacedName{Name: "preview-" + preview.Spec.InstanceID() + "-api", Namespace: "argocd"}
+		if err := fakeClient.Get(context.TODO(), key, app); err != nil {
+			t.Fatalf("Failed to get Application: %v", err)
+		}
+		return app.Annotations[argocd.RefreshAnnotation] == "hard"
+	}
+
+	updated := reconcileAndGet()
+	if restartedAt() != "" || refreshRequested() {
+		t.Fatal("preview was redeployed without a request")
+	}
+
+	// /preview redeploy sets the annotation
+	const requested = "2025-01-02T03:04:05Z"
+	updated.Annotations = map[string]string{previewv1alpha1.RedeployAnnotation: requested}
+	if err := fakeClient.Update(context.TODO(), updated); err != nil {
+		t.Fatalf("Failed to request redeploy: %v", err)
+	}
+	recordedEvents(recorder)
+
+	updated = reconcileAndGet()
+	if got := restartedAt(); got != requested {
+		t.Errorf("Deployment restartedAt = %q, want %q", got, requested)
+	}
+	if !refreshRequested() {
+		t.Error("Application was not hard refreshed")
+	}
+	if updated.Status.ObservedRedeployRequest != requested {
+		t.Errorf("ObservedRedeployRequest = %q, want %q", updated.Status.ObservedRedeployRequest, requested)
+	}
+	events := recordedEvents(recorder)
+	if !slices.ContainsFunc(events, func(event string) bool { return strings.Contains(event, "Redeployed") }) {
+		t.Errorf("events = %v, want a Redeployed event", events)
+	}
+
+	// A handled request is not acted on again
+	reconcileAndGet()
+	events = recordedEvents(recorder)
+	if slices.ContainsFunc(events, func(event string) bool { return strings.Contains(event, "Redeployed") }) {
+		t.Errorf("events = %v, want no second redeploy", events)
+	}
+}
+
+func TestReconciler_MarksFailedWhenProvisioningFails(t *testing.T) {
+	// No services means the ingress manager rejects the preview
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-7",
+			Namespace: "default",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			PRNumber:   7,
+			HeadSHA:    "1234567890123456789012345678901234567890",
+		},
+	}
+
+	reconciler, fakeClient := newProvisioningReconciler(preview)
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-7", Namespace: "default"}}
+
+	if _, err := reconciler.Reconcile(context.TODO(), req); err == nil {
+		t.Fatal("Reconcile() expected error, got nil")
+	}
+
+	var updated previewv1alpha1.PreviewEnvironment
+	if err := fakeClient.Get(context.TODO(), req.NamespacedName, &updated); err != nil {
+		t.Fatalf("Failed to get preview environment: %v", err)
+	}
+
+	if updated.Status.Phase != phaseFailed {
+		t.Errorf("Phase = %q, want %q", updated.Status.Phase, phaseFailed)
+	}
+	cond := meta.FindStatusCondition(updated.Status.Conditions, conditionReady)
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != "ProvisioningFailed" {
+		t.Errorf("Ready condition = %+v, want False/ProvisioningFailed", cond)
+	}
+	if !meta.IsStatusConditionTrue(updated.Status.Conditions, conditionDegraded) {
+		t.Errorf("Degraded condition = %+v, want True", meta.FindStatusCondition(updated.Status.Conditions, conditionDegraded))
+	}
+	if !strings.HasPrefix(cond.Message, conditionIngressReady+": ") {
+		t.Errorf("Ready message = %q, want it to name the failed %s step", cond.Message, conditionIngressReady)
+	}
+	ingressReady := meta.FindStatusCondition(updated.Status.Conditions, conditionIngressReady)
+	if ingressReady == nil || ingressReady.Status != metav1.ConditionFalse || ingressReady.Reason != reasonProvisioningFailed {
+		t.Errorf("IngressReady condition = %+v, want False/%s", ingressReady, reasonProvisioningFailed)
+	}
+	if !meta.IsStatusConditionTrue(updated.Status.Conditions, conditionNamespaceReady) {
+		t.Errorf("NamespaceReady condition = %+v, want True", meta.FindStatusCondition(updated.Status.Conditions, conditionNamespaceReady))
+	}
+}
+
+func TestReconciler_AggregatesApplicationHealth(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-42",
+			Namespace: "default",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			PRNumber:   42,
+			HeadSHA:    "1234567890123456789012345678901234567890",
+			Services:   []string{"api", "frontend"},
+		},
+	}
+	api := serviceApplication(preview, "api", "Progressing", "OutOfSync")
+
+	reconciler, fakeClient := newProvisioningReconciler(preview, api, issuedCertificate(preview))
+	gh := &fakeGitHubClient{}
+	reconciler.GitHubClient = gh
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "default"}}
+
+	reconcileAndGet := func() *previewv1alpha1.PreviewEnvironment {
+		t.Helper()
+		if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		updated := &previewv1alpha1.PreviewEnvironment{}
+		if err := fakeClient.Get(context.TODO(), req.NamespacedName, updated); err != nil {
+			t.Fatalf("Failed to get preview environment: %v", err)
+		}
+		return updated
+	}
+	setHealth := func(app *argocd.Application, health, sync string) {
+		t.Helper()
+		app.Status.Health.Status = health
+		app.Status.Sync.Status = sync
+		if err := fakeClient.Update(context.TODO(), app); err != nil {
+			t.Fatalf("Failed to update Application: %v", err)
+		}
+	}
+
+	// One service is progressing and the other has no Application yet
+	updated := reconcileAndGet()
+	if updated.Status.Phase != phaseCreating {
+		t.Errorf("Phase = %q, want %q", updated.Status.Phase, phaseCreating)
+	}
+	if len(updated.Status.Services) != 2 {
+		t.Fatalf("Services = %+v, want api and frontend", updated.Status.Services)
+	}
+	if s := updated.Status.Services[0]; s.Name != "api" || s.Health != "Progressing" || s.Sync != "OutOfSync" || s.Ready ||
+		s.URL != "https://pr-42-57b681c5.preview.example.com/api" {
+		t.Errorf("api status = %+v, want Progressing/OutOfSync at /api", s)
+	}
+	if s := updated.Status.Services[1]; s.Health != healthMissing || s.Ready || s.URL != "https://pr-42-57b681c5.preview.example.com/" {
+		t.Errorf("frontend status = %+v, want Missing at /", s)
+	}
+	available := meta.FindStatusCondition(updated.Status.Conditions, conditionAvailable)
+	if available == nil || available.Status != metav1.ConditionFalse || available.Reason != "ServicesProgressing" {
+		t.Errorf("Available condition = %+v, want False/ServicesProgressing", available)
+	}
+
+	// Every service becomes Healthy and Synced
+	frontend := serviceApplication(preview, "frontend", healthHealthy, syncSynced)
+	if err := fakeClient.Create(context.TODO(), frontend); err != nil {
+		t.Fatalf("Failed to create Application: %v", err)
+	}
+	setHealth(api, healthHealthy, syncSynced)
+	updated = reconcileAndGet()
+	if updated.Status.Phase != phaseReady {
+		t.Errorf("Phase = %q, want %q", updated.Status.Phase, phaseReady)
+	}
+	for _, conditionType := range []string{conditionAvailable, conditionReady} {
+		if !meta.IsStatusConditionTrue(updated.Status.Conditions, conditionType) {
+			t.Errorf("%s condition = %+v, want True", conditionType, meta.FindStatusCondition(updated.Status.Conditions, conditionType))
+		}
+	}
+	if len(gh.statuses) != 2 || gh.statuses[1].State != github.StatusStateSuccess {
+		t.Errorf("commit statuses = %+v, want pending and success", gh.statuses)
+	}
+
+	// A new rollout is progressing again
+	setHealth(api, "Progressing", "OutOfSync")
+	if updated = reconcileAndGet(); updated.Status.Phase != phaseUpdating {
+		t.Errorf("Phase = %q, want %q", updated.Status.Phase, phaseUpdating)
+	}
+
+	// The rollout fails
+	api.Status.Health.Message = "Back-off restarting failed container"
+	setHealth(api, healthDegraded, syncSynced)
+	updated = reconcileAndGet()
+	if updated.Status.Phase != phaseFailed {
+		t.Errorf("Phase = %q, want %q", updated.Status.Phase, phaseFailed)
+	}
+	degraded := meta.FindStatusCondition(updated.Status.Conditions, conditionDegraded)
+	if degraded == nil || degraded.Status != metav1.ConditionTrue || !strings.Contains(degraded.Message, "Back-off") {
+		t.Errorf("Degraded condition = %+v, want True with the Application's message", degraded)
+	}
+	if len(gh.statuses) != 3 || gh.statuses[2].State != github.StatusStateFailure {
+		t.Errorf("commit statuses = %+v, want a failure after success", gh.statuses)
+	}
+
+	// The failure is only reported once
+	reconcileAndGet()
+	if len(gh.statuses) != 3 {
+		t.Errorf("commit statuses = %d, want 3", len(gh.statuses))
+	}
+}
+
+// fakeResolver resolves every host name to addrs, or fails with err
+type fakeResolver struct {
+	err   error
+	addrs []string
+}
+
+func (f *fakeResolver) LookupHost(_ context.Context, _ string) ([]string, error) {
+	return f.addrs, f.err
+}
+
+func TestReconciler_SetsStepConditions(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-42",
+			Namespace: "default",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			PRNumber:   42,
+			HeadSHA:    "1234567890123456789012345678901234567890",
+			Services:   []string{"api"},
+		},
+	}
+
+	reconciler, fakeClient := newProvisioningReconciler(preview, serviceApplication(preview, "api", healthHealthy, syncSynced))
+	resolver := &fakeResolver{err: errors.New("lookup pr-42-57b681c5.preview.example.com: no such host")}
+	reconciler.IngressManager.WithResolver(resolver)
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "default"}}
+
+	reconcileAndGet := func() (ctrl.Result, *previewv1alpha1.PreviewEnvironment) {
+		t.Helper()
+		result, err := reconciler.Reconcile(context.TODO(), req)
+		if err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		updated := &previewv1alpha1.PreviewEnvironment{}
+		if err := fakeClient.Get(context.TODO(), req.NamespacedName, updated); err != nil {
+			t.Fatalf("Failed to get preview environment: %v", err)
+		}
+		return result, updated
+	}
+	wantCondition := func(updated *previewv1alpha1.PreviewEnvironment, conditionType string, status metav1.ConditionStatus, reason string) {
+		t.Helper()
+		condition := meta.FindStatusCondition(updated.Status.Conditions, conditionType)
+		if condition == nil || condition.Status != status || condition.Reason != reason {
+			t.Errorf("%s condition = %+v, want %s/%s", conditionType, condition, status, reason)
+		}
+	}
+
+	// The certificate has not been issued and the host does not resolve yet
+	result, updated := reconcileAndGet()
+	wantCondition(updated, conditionNamespaceReady, metav1.ConditionTrue, "Created")
+	wantCondition(updated, conditionQuotaApplied, metav1.ConditionTrue, "Applied")
+	wantCondition(updated, conditionNetworkPoliciesApplied, metav1.ConditionTrue, "Applied")
+	wantCondition(updated, conditionApplicationSetSynced, metav1.ConditionTrue, "Synced")
+	wantCondition(updated, conditionIngressReady, metav1.ConditionTrue, "Applied")
+	wantCondition(updated, conditionCertificateIssued, metav1.ConditionFalse, "CertificateNotCreated")
+	wantCondition(updated, conditionDNSPublished, metav1.ConditionFalse, "NotResolvable")
+	wantCondition(updated, conditionAvailable, metav1.ConditionTrue, "ServicesReady")
+	wantCondition(updated, conditionCostEstimated, metav1.ConditionTrue, "Estimated")
+	wantCondition(updated, conditionProgressing, metav1.ConditionTrue, "CertificateNotCreated")
+	wantCondition(updated, conditionReady, metav1.ConditionFalse, "CertificateNotCreated")
+	if updated.Status.Phase != phaseCreating {
+		t.Errorf("Phase = %q, want %q", updated.Status.Phase, phaseCreating)
+	}
+	if result.RequeueAfter != awaitingIngressRequeueAfter {
+		t.Errorf("RequeueAfter = %v, want %v", result.RequeueAfter, awaitingIngressRequeueAfter)
+	}
+
+	// cert-manager issues the certificate and external-dns publishes the record
+	if err := fakeClient.Create(context.TODO(), issuedCertificate(preview)); err != nil {
+		t.Fatalf("Failed to create Certificate: %v", err)
+	}
+	resolver.err, resolver.addrs = nil, []string{"203.0.113.10"}
+
+	result, updated = reconcileAndGet()
+	wantCondition(updated, conditionCertificateIssued, metav1.ConditionTrue, "Issued")
+	wantCondition(updated, conditionDNSPublished, metav1.ConditionTrue, "Published")
+	wantCondition(updated, conditionProgressing, metav1.ConditionFalse, phaseReady)
+	wantCondition(updated, conditionReady, metav1.ConditionTrue, "Ready")
+	if updated.Status.Phase != phaseReady {
+		t.Errorf("Phase = %q, want %q", updated.Status.Phase, phaseReady)
+	}
+	if result.RequeueAfter != defaultRequeueAfter {
+		t.Errorf("RequeueAfter = %v, want %v", result.RequeueAfter, defaultRequeueAfter)
+	}
+}
+
+func TestReconciler_PatchesStatusOncePerReconcile(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-42",
+			Namespace: "default",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			PRNumber:   42,
+			HeadSHA:    "1234567890123456789012345678901234567890",
+			Services:   []string{"api"},
+		},
+	}
+
+	reconciler, fakeClient := newProvisioningReconciler(withReadyResources(preview)...)
+	var updates, statusPatches int
+	var fieldManagers []string
+	reconciler.Client = interceptor.NewClient(fakeClient.(client.WithWatch), interceptor.Funcs{
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			updates++
+			return c.Update(ctx, obj, opts...)
+		},
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			updates++
+			return c.SubResource(subResource).Update(ctx, obj, opts...)
+		},
+		SubResourcePatch: func(ctx context.Context, c client.Client, subResource string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+			statusPatches++
+			patchOpts := &client.SubResourcePatchOptions{}
+			patchOpts.ApplyOptions(opts)
+			fieldManagers = append(fieldManagers, patchOpts.FieldManager)
+
+			// The webhook moves the head SHA while the reconcile is running
+			current := &previewv1alpha1.PreviewEnvironment{}
+			if err := c.Get(ctx, client.ObjectKeyFromObject(obj), current); err != nil {
+				return err
+			}
+			current.Spec.HeadSHA = "abcdefabcdefabcdefabcdefabcdefabcdefabcd"
+			if err := c.Update(ctx, current); err != nil {
+				return err
+			}
+			return c.SubResource(subResource).Patch(ctx, obj, patch, opts...)
+		},
+	})
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "default"}}
+
+	if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	if updates != 0 {
+		t.Errorf("updates = %d, want 0", updates)
+	}
+	if statusPatches != 1 {
+		t.Errorf("status patches = %d, want 1", statusPatches)
+	}
+	if len(fieldManagers) != 1 || fieldManagers[0] != fieldManager {
+		t.Errorf("status patch field managers = %v, want [%s]", fieldManagers, fieldManager)
+	}
+
+	var updated previewv1alpha1.PreviewEnvironment
+	if err := fakeClient.Get(context.TODO(), req.NamespacedName, &updated); err != nil {
+		t.Fatalf("Failed to get preview environment: %v", err)
+	}
+	if updated.Status.Phase != phaseReady {
+		t.Errorf("Phase = %q, want %q", updated.Status.Phase, phaseReady)
+	}
+	if updated.Spec.HeadSHA != "abcdefabcdefabcdefabcdefabcdefabcdefabcd" {
+		t.Errorf("HeadSHA = %q, want the concurrently updated head SHA", updated.Spec.HeadSHA)
+	}
+
+	// The concurrent update triggers another reconcile, which patches on top of it
+	statusPatches = 0
+	if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("second Reconcile() error = %v", err)
+	}
+	if statusPatches != 1 {
+		t.Errorf("status patches = %d on the second reconcile, want 1", statusPatches)
+	}
+}
+
+func TestReconciler_RollsOutNewHeadSHA(t *testing.T) {
+	const oldSHA, newSHA = "1234567890123456789012345678901234567890", "abcdefabcdefabcdefabcdefabcdefabcdefabcd"
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "pr-42",
+			Namespace:  "default",
+			Generation: 1,
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			PRNumber:   42,
+			HeadSHA:    oldSHA,
+			Services:   []string{"api"},
+		},
+	}
+	api := serviceApplication(preview, "api", healthHealthy, syncSynced)
+
+	reconciler, fakeClient := newProvisioningReconciler(preview, api, issuedCertificate(preview))
+	gh := &fakeGitHubClient{}
+	reconciler.GitHubClient = gh
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "default"}}
+
+	reconcileAndGet := func() *previewv1alpha1.PreviewEnvironment {
+		t.Helper()
+		if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		updated := &previewv1alpha1.PreviewEnvironment{}
+		if err := fakeClient.Get(context.TODO(), req.NamespacedName, updated); err != nil {
+			t.Fatalf("Failed to get preview environment: %v", err)
+		}
+		return updated
+	}
+
+	updated := reconcileAndGet()
+	if updated.Status.Phase != phaseReady || updated.Status.DeployedSHA != oldSHA {
+		t.Fatalf("Phase = %q, DeployedSHA = %q; want Ready at %s", updated.Status.Phase, updated.Status.DeployedSHA, oldSHA)
+	}
+
+	// The webhook sets a new head SHA
+	updated.Spec.HeadSHA = newSHA
+	updated.Generation++
+	if err := fakeClient.Update(context.TODO(), updated); err != nil {
+		t.Fatalf("Failed to update head SHA: %v", err)
+	}
+
+	updated = reconcileAndGet()
+	if updated.Status.Phase != phaseUpdating {
+		t.Errorf("Phase = %q, want %q while the Applications are at the old SHA", updated.Status.Phase, phaseUpdating)
+	}
+	if updated.Status.DeployedSHA != oldSHA {
+		t.Errorf("DeployedSHA = %q, want %q until the rollout completes", updated.Status.DeployedSHA, oldSHA)
+	}
+	if s := updated.Status.Services[0]; s.Ready || s.Revision != oldSHA {
+		t.Errorf("api status = %+v, want not ready at the old revision", s)
+	}
+	available := meta.FindStatusCondition(updated.Status.Conditions, conditionAvailable)
+	if available == nil || !strings.Contains(available.Message, "abcdefa") || !strings.Contains(available.Message, "at 1234567") {
+		t.Errorf("Available condition = %+v, want it to name the new SHA and the service's revision", available)
+	}
+
+	var appSet argocd.ApplicationSet
+	if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: "preview-pr-42-57b681c5", Namespace: "argocd"}, &appSet); err != nil {
+		t.Fatalf("Failed to get ApplicationSet: %v", err)
+	}
+	if revision := appSet.Spec.Template.Spec.Source.TargetRevision; revision != newSHA {
+		t.Errorf("ApplicationSet TargetRevision = %q, want %q", revision, newSHA)
+	}
+
+	if len(gh.statuses) != 3 || gh.statuses[2].State != github.StatusStatePending || gh.statusSHAs[2] != newSHA {
+		t.Errorf("commit statuses = %+v for %v, want a pending status for the new SHA", gh.statuses, gh.statusSHAs)
+	}
+	if len(gh.deployments) != 2 || gh.deployments[1].Ref != newSHA {
+		t.Errorf("deployments = %+v, want a second deployment for the new SHA", gh.deployments)
+	}
+
+	// Reconciling again while the rollout is in progress reports nothing new
+	reconcileAndGet()
+	if len(gh.statuses) != 3 || len(gh.deployments) != 2 {
+		t.Errorf("got %d statuses and %d deployments, want 3 and 2", len(gh.statuses), len(gh.deployments))
+	}
+
+	// ArgoCD syncs the new SHA
+	api.Status.Sync.Revision = newSHA
+	if err := fakeClient.Update(context.TODO(), api); err != nil {
+		t.Fatalf("Failed to update Application: %v", err)
+	}
+	updated = reconcileAndGet()
+	if updated.Status.Phase != phaseReady || updated.Status.DeployedSHA != newSHA {
+		t.Errorf("Phase = %q, DeployedSHA = %q; want Ready at %s", updated.Status.Phase, updated.Status.DeployedSHA, newSHA)
+	}
+	if len(gh.statuses) != 4 || gh.statuses[3].State != github.StatusStateSuccess || gh.statusSHAs[3] != newSHA {
+		t.Errorf("commit statuses = %+v for %v, want a success status for the new SHA", gh.statuses, gh.statusSHAs)
+	}
+}
+
+func TestReconciler_RecordsLifecycleEvents(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-42",
+			Namespace: "default",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			PRNumber:   42,
+			HeadSHA:    "1234567890123456789012345678901234567890",
+			Services:   []string{"api"},
+		},
+	}
+
+	reconciler, fakeClient := newProvisioningReconciler(preview,
+		serviceApplication(preview, "api", healthHealthy, syncSynced), issuedCertificate(preview))
+	recorder := record.NewFakeRecorder(20)
+	reconciler.Recorder = recorder
+	reconciler.NamespaceManager.WithRecorder(recorder)
+	reconciler.ArgoCDManager.WithRecorder(recorder)
+	reconciler.IngressManager.WithRecorder(recorder)
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "default"}}
+
+	wantEvents := func(want ...string) {
+		t.Helper()
+		events := recordedEvents(recorder)
+		if len(events) != len(want) {
+			t.Fatalf("recorded events = %q, want %d events", events, len(want))
+		}
+		for i, prefix := range want {
+			if !strings.HasPrefix(events[i], prefix) {
+				t.Errorf("event %d = %q, want prefix %q", i, events[i], prefix)
+			}
+		}
+	}
+
+	if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	wantEvents(
+		"Normal Creating Creating preview environment at 1234567",
+		"Normal NamespaceCreated Created namespace ",
+		"Normal ApplicationSetCreated Created ApplicationSet argocd/preview-pr-42-57b681c5 at revision 1234567890",
+		"Normal IngressCreated Created ingress ",
+		"Normal ExpirationUpdated Preview environment expires at ",
+		"Normal CostEstimated Estimated at ",
+		"Normal Ready Preview environment is ready at 1234567",
+	)
+
+	// Nothing changes, so nothing is recorded
+	if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	wantEvents()
+
+	if err := fakeClient.Delete(context.TODO(), preview); err != nil {
+		t.Fatalf("Failed to delete preview environment: %v", err)
+	}
+	if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	wantEvents(
+		"Normal Deleting Removing preview environment resources",
+		"Normal DeletingNamespace Deleting namespace ",
+		"Normal CleanupCompleted Removed preview environment resources",
+	)
+}
+
+func TestReconciler_RecordsProvisioningFailure(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-42",
+			Namespace: "default",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			PRNumber:   42,
+			HeadSHA:    "1234567890123456789012345678901234567890",
+			Services:   []string{"api"},
+		},
+	}
+
+	reconciler, _ := newProvisioningReconciler(preview)
+	reconciler.Client = interceptor.NewClient(reconciler.Client.(client.WithWatch), interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*corev1.Namespace); ok {
+				return errors.New("namespaces is forbidden")
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	})
+	reconciler.NamespaceManager = namespace.NewManager(reconciler.Client, testScheme)
+	recorder := record.NewFakeRecorder(10)
+	reconciler.Recorder = recorder
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "default"}}
+	if _, err := reconciler.Reconcile(context.TODO(), req); err == nil {
+		t.Fatal("Reconcile() error = nil, want the namespace creation error")
+	}
+
+	events := recordedEvents(recorder)
+	if len(events) != 2 || !strings.HasPrefix(events[1], "Warning ProvisioningFailed ") ||
+		!strings.Contains(events[1], "namespaces is forbidden") {
+		t.Errorf("recorded events = %q, want a ProvisioningFailed warning with the error", events)
+	}
+}
+
+func TestReconciler_KeepsRepositoriesWithTheSamePRNumberApart(t *testing.T) {
+	newPreview := func(repository string) *previewv1alpha1.PreviewEnvironment {
+		return &previewv1alpha1.PreviewEnvironment{
+			ObjectMeta: metav1.ObjectMeta{Name: "pr-42-" + previewv1alpha1.RepositoryHash(repository), Namespace: "default"},
+			Spec: previewv1alpha1.PreviewEnvironmentSpec{
+				Repository: repository,
+				PRNumber:   42,
+				HeadSHA:    "1234567890123456789012345678901234567890",
+				Services:   []string{"api"},
+			},
+		}
+	}
+	first := newPreview("org/repo")
+	second := newPreview("org/other")
+
+	reconciler, fakeClient := newProvisioningReconciler(first, second)
+	for _, preview := range []*previewv1alpha1.PreviewEnvironment{first, second} {
+		req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(preview)}
+		if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+			t.Fatalf("Reconcile(%s) error = %v", preview.Name, err)
+		}
+	}
+
+	var appSets argocd.ApplicationSetList
+	if err := fakeClient.List(context.TODO(), &appSets, client.InNamespace("argocd")); err != nil {
+		t.Fatalf("Failed to list ApplicationSets: %v", err)
+	}
+	if len(appSets.Items) != 2 {
+		t.Fatalf("got %d ApplicationSets, want one per repository", len(appSets.Items))
+	}
+	for _, appSet := range appSets.Items {
+		owner := appSet.Annotations["preview.previewd.io/owner-name"]
+		repository := first.Spec.Repository
+		if owner == second.Name {
+			repository = second.Spec.Repository
+		}
+		hash := previewv1alpha1.RepositoryHash(repository)
+		if appSet.Labels[previewv1alpha1.RepositoryLabel] != hash ||
+			appSet.Spec.Template.Labels[previewv1alpha1.RepositoryLabel] != hash {
+			t.Errorf("ApplicationSet %s of %s is not labeled with repository hash %s", appSet.Name, owner, hash)
+		}
+	}
+
+	urls := map[string]bool{}
+	for _, preview := range []*previewv1alpha1.PreviewEnvironment{first, second} {
+		updated := &previewv1alpha1.PreviewEnvironment{}
+		if err := fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(preview), updated); err != nil {
+			t.Fatalf("Failed to get preview environment: %v", err)
+		}
+		urls[updated.Status.URL] = true
+
+		var ing networkingv1.Ingress
+		if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: ingress.IngressName, Namespace: updated.Status.Namespace}, &ing); err != nil {
+			t.Fatalf("Failed to get ingress of %s: %v", preview.Name, err)
+		}
+		if secret := ing.Spec.TLS[0].SecretName; secret != preview.Spec.InstanceID()+"-tls" {
+			t.Errorf("TLS secret of %s = %q, want %q", preview.Name, secret, preview.Spec.InstanceID()+"-tls")
+		}
+	}
+	if len(urls) != 2 {
+		t.Errorf("previews share a URL: %v", urls)
+	}
+
+	// Deleting one preview leaves the other's ApplicationSet alone
+	if err := fakeClient.Delete(context.TODO(), first); err != nil {
+		t.Fatalf("Failed to delete preview environment: %v", err)
+	}
+	if _, err := reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(first)}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	appSetKey := types.NamespacedName{Name: "preview-" + second.Spec.InstanceID(), Namespace: "argocd"}
+	if err := fakeClient.Get(context.TODO(), appSetKey, &argocd.ApplicationSet{}); err != nil {
+		t.Errorf("ApplicationSet of the other repository was removed: %v", err)
+	}
+}
+
+func TestReconciler_PreviewsForApplication(t *testing.T) {
+	pr := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{Name: "pr-42", Namespace: "default"},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			PRNumber:   42,
+			HeadSHA:    "1234567890123456789012345678901234567890",
+		},
+	}
+	branch := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{Name: "br-main", Namespace: "previews"},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			Branch:     "main",
+			HeadSHA:    "1234567890123456789012345678901234567890",
+		},
+	}
+	// The same pull request number in another repository
+	otherRepository := pr.DeepCopy()
+	otherRepository.Spec.Repository = "org/other"
+	reconciler, _ := newProvisioningReconciler(pr, branch)
+
+	tests := []struct {
+		app  *argocd.Application
+		want string
+	}{
+		{app: serviceApplication(pr, "api", healthHealthy, syncSynced), want: "default/pr-42"},
+		{app: serviceApplication(branch, "api", healthHealthy, syncSynced), want: "previews/br-main"},
+		{app: serviceApplication(otherRepository, "api", healthHealthy, syncSynced)},
+		{app: &argocd.Application{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "argocd"}}},
+	}
+	for _, tt := range tests {
+		requests := reconciler.previewsForApplication(context.TODO(), tt.app)
+		var got string
+		if len(requests) == 1 {
+			got = requests[0].String()
+		}
+		if len(requests) > 1 || got != tt.want {
+			t.Errorf("previewsForApplication(%s) = %v, want %q", tt.app.Name, requests, tt.want)
+		}
+	}
+}
+
+func TestReconciler_ReportsProvisioningToGitHub(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-42",
+			Namespace: "default",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			PRNumber:   42,
+			HeadSHA:    "1234567890123456789012345678901234567890",
+			Services:   []string{"api", "frontend"},
+		},
+	}
+
+	reconciler, _ := newProvisioningReconciler(withReadyResources(preview)...)
+	gh := &fakeGitHubClient{}
+	reconciler.GitHubClient = gh
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "default"}}
+
+	if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	if len(gh.statuses) != 2 {
+		t.Fatalf("commit statuses = %d, want 2 (pending, success)", len(gh.statuses))
+	}
+	if gh.statuses[0].State != github.StatusStatePending {
+		t.Errorf("first status = %s, want pending", gh.statuses[0].State)
+	}
+	if gh.statuses[1].State != github.StatusStateSuccess {
+		t.Errorf("second status = %s, want success", gh.statuses[1].State)
+	}
+	if gh.statuses[1].TargetURL != "https://pr-42-57b681c5.preview.example.com" {
+		t.Errorf("TargetURL = %q, want preview URL", gh.statuses[1].TargetURL)
+	}
+
+	if len(gh.deployments) != 1 {
+		t.Fatalf("deployments = %d, want 1", len(gh.deployments))
+	}
+	if gh.deployments[0].Environment != "preview-pr-42" || !gh.deployments[0].TransientEnvironment {
+		t.Errorf("deployment = %+v, want transient preview-pr-42", gh.deployments[0])
+	}
+	if len(gh.deploymentStatuses) != 2 {
+		t.Fatalf("deployment statuses = %d, want 2 (in_progress, success)", len(gh.deploymentStatuses))
+	}
+	if gh.deploymentStatuses[1].State != github.DeploymentStateSuccess ||
+		gh.deploymentStatuses[1].EnvironmentURL != "https://pr-42-57b681c5.preview.example.com" {
+		t.Errorf("final deployment status = %+v, want success with environment URL", gh.deploymentStatuses[1])
+	}
+
+	if len(gh.comments) != 1 {
+		t.Fatalf("comments = %d, want 1", len(gh.comments))
+	}
+	for _, want := range []string{previewCommentMarker, "https://pr-42-57b681c5.preview.example.com", "`api`, `frontend`", "Expires"} {
+		if !strings.Contains(gh.comments[0], want) {
+			t.Errorf("comment missing %q:\n%s", want, gh.comments[0])
+		}
+	}
+
+	// A second reconcile of a Ready environment must not report again
+	if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("second Reconcile() error = %v", err)
+	}
+	if len(gh.statuses) != 2 || len(gh.comments) != 1 {
+		t.Errorf("expected no additional reports, got %d statuses and %d comments", len(gh.statuses), len(gh.comments))
+	}
+}
+
+func TestReconciler_ProvisionsBranchPreview(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "br-main",
+			Namespace: "default",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			Branch:     "main",
+			HeadSHA:    "1234567890123456789012345678901234567890",
+			Services:   []string{"api"},
+		},
+	}
+
+	reconciler, k8sClient := newProvisioningReconciler(withReadyResources(preview)...)
+	gh := &fakeGitHubClient{}
+	reconciler.GitHubClient = gh
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "br-main", Namespace: "default"}}
+
+	if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	updated := &previewv1alpha1.PreviewEnvironment{}
+	if err := k8sClient.Get(context.TODO(), req.NamespacedName, updated); err != nil {
+		t.Fatalf("failed to get PreviewEnvironment: %v", err)
+	}
+	previewID := preview.Spec.PreviewID()
+	if want := "https://" + preview.Spec.InstanceID() + ".preview.example.com"; updated.Status.URL != want {
+		t.Errorf("URL = %q, want %q", updated.Status.URL, want)
+	}
+
+	appSet := &argocd.ApplicationSet{}
+	appSetKey := types.NamespacedName{Name: "preview-" + preview.Spec.InstanceID(), Namespace: "argocd"}
+	if err := k8sClient.Get(context.TODO(), appSetKey, appSet); err != nil {
+		t.Fatalf("ApplicationSet %s not created: %v", appSetKey.Name, err)
+	}
+
+	if len(gh.statuses) != 2 || gh.statuses[1].State != github.StatusStateSuccess {
+		t.Errorf("commit statuses = %+v, want pending and success", gh.statuses)
+	}
+	if len(gh.deployments) != 1 || gh.deployments[0].Environment != "preview-"+previewID {
+		t.Errorf("deployments = %+v, want one for environment preview-%s", gh.deployments, previewID)
+	}
+	if len(gh.comments) != 0 {
+		t.Errorf("comments = %d, want 0 for a branch without a pull request", len(gh.comments))
+	}
+}
+
+func TestReconciler_ReportsProvisioningFailureToGitHub(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-7",
+			Namespace: "default",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "org/repo",
+			PRNumber:   7,
+			HeadSHA:    "1234567890123456789012345678901234567890",
+		},
+	}
+
+	reconciler, _ := newProvisioningReconciler(preview)
+	gh := &fakeGitHubClient{}
+	reconciler.GitHubClient = gh
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-7", Namespace: "default"}}
+
+	if _, err := reconciler.Reconcile(context.TODO(), req); err == nil {
+		t.Fatal("Reconcile() expected error, got nil")
+	}
+
+	if len(gh.statuses) != 2 {
+		t.Fatalf("commit statuses = %d, want 2 (pending, failure)", len(gh.statuses))
+	}
+	if gh.statuses[1].State != github.StatusStateFailure {
+		t.Errorf("second status = %s, want failure", gh.statuses[1].State)
+	}
+	if !strings.Contains(gh.statuses[1].Description, "at least one service") {
+		t.Errorf("Description = %q, want failure reason", gh.statuses[1].Description)
+	}
+	if len(gh.comments) != 0 {
+		t.Errorf("comments = %d, want 0", len(gh.comments))
+	}
+}
+
+func TestReconciler_ReportsGitLabPreviewsToGitLab(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-42",
+			Namespace: "default",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: "group/sub/project",
+			Provider:   previewv1alpha1.ProviderGitLab,
+			PRNumber:   42,
+			HeadSHA:    "1234567890123456789012345678901234567890",
+			Services:   []string{"api"},
+		},
+	}
+
+	reconciler, _ := newProvisioningReconciler(withReadyResources(preview)...)
+	gh := &fakeGitHubClient{}
+	gl := &fakeGitLabClient{}
+	reconciler.GitHubClient = gh
+	reconciler.GitLabClient = gl
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "default"}}
+
+	if _, err := reconciler.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	if len(gl.statuses) != 2 {
+		t.Fatalf("GitLab commit statuses = %d, want 2 (pending, success)", len(gl.statuses))
+	}
+	if gl.statuses[0].State != gitlab.StatusStatePending || gl.statuses[1].State != gitlab.StatusStateSuccess {
+		t.Errorf("GitLab statuses = %s, %s; want pending, success", gl.statuses[0].State, gl.statuses[1].State)
+	}
+	if gl.statuses[1].Name != commitStatusContext || gl.statuses[1].TargetURL != "https://"+preview.Spec.InstanceID()+".preview.example.com" {
+		t.Errorf("final GitLab status = %+v, want %s with preview URL", gl.statuses[1], commitStatusContext)
+	}
+	if len(gl.notes) != 1 || !strings.Contains(gl.notes[0], previewCommentMarker) {
+		t.Errorf("GitLab notes = %v, want one preview note", gl.notes)
+	}
+
+	if len(gh.statuses) != 0 || len(gh.comments) != 0 || len(gh.deployments) != 0 {
+		t.Errorf("GitHub received %d statuses, %d comments and %d deployments, want none",
+			len(gh.statuses), len(gh.comments), len(gh.deployments))
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// restartedAtAnnotation is the pod template annotation `kubectl rollout restart`
+// sets. Changing it rolls every pod of the workload.
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// redeploy acts on a new RedeployAnnotation value: it asks ArgoCD to hard
+// refresh the preview's Applications, so their manifests are regenerated and
+// re-synced, and restarts every Deployment and StatefulSet in the preview
+// namespace. The handled value is recorded in Status.ObservedRedeployRequest,
+// so each request redeploys once. Sleeping previews keep the request until
+// they are woken up.
+func (r *PreviewEnvironmentReconciler) redeploy(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment) error {
+	requested := previewEnv.Annotations[previewv1alpha1.RedeployAnnotation]
+	if requested == "" || requested == previewEnv.Status.ObservedRedeployRequest ||
+		isSleeping(previewEnv) || previewEnv.Status.Namespace == "" {
+		return nil
+	}
+
+	refreshed := 0
+	if r.ArgoCDManager != nil {
+		var err error
+		if refreshed, err = r.ArgoCDManager.RefreshApplications(ctx, previewEnv); err != nil {
+			return err
+		}
+	}
+
+	restarted, err := r.restartWorkloads(ctx, previewEnv.Status.Namespace, requested)
+	if err != nil {
+		return err
+	}
+
+	previewEnv.Status.ObservedRedeployRequest = requested
+	logf.FromContext(ctx).Info("Redeployed preview environment",
+		"requestedAt", requested, "applications", refreshed, "workloads", restarted)
+	r.recordEvent(previewEnv, corev1.EventTypeNormal, "Redeployed",
+		"Refreshed %d Applications and restarted %d workloads", refreshed, restarted)
+	return nil
+}
+
+// restartWorkloads restarts the Deployments and StatefulSets in namespace the
+// way `kubectl rollout restart` does, stamping their pod templates with
+// restartedAt. It returns the number of workloads restarted.
+func (r *PreviewEnvironmentReconciler) restartWorkloads(ctx context.Context, namespace, restartedAt string) (int, error) {
+	var deployments appsv1.DeploymentList
+	if err := r.List(ctx, &deployments, client.InNamespace(namespace)); err != nil {
+		return 0, fmt.Errorf("failed to list Deployments in namespace %s: %w", namespace, err)
+	}
+	var statefulSets appsv1.StatefulSetList
+	if err := r.List(ctx, &statefulSets, client.InNamespace(namespace)); err != nil {
+		return 0, fmt.Errorf("failed to list StatefulSets in namespace %s: %w", namespace, err)
+	}
+
+	workloads := make([]client.Object, 0, len(deployments.Items)+len(statefulSets.Items))
+	for i := range deployments.Items {
+		workloads = append(workloads, &deployments.Items[i])
+	}
+	for i := range statefulSets.Items {
+		workloads = append(workloads, &statefulSets.Items[i])
+	}
+
+	for _, workload := range workloads {
+		var template *corev1.PodTemplateSpec
+		switch w := workload.(type) {
+		case *appsv1.Deployment:
+			template = &w.Spec.Template
+		case *appsv1.StatefulSet:
+			template = &w.Spec.Template
+		}
+		if template.Annotations[restartedAtAnnotation] == restartedAt {
+			continue
+		}
+
+		base := workload.DeepCopyObject().(client.Object)
+		if template.Annotations == nil {
+			template.Annotations = make(map[string]string)
+		}
+		template.Annotations[restartedAtAnnotation] = restartedAt
+		if err := r.Patch(ctx, workload, client.MergeFrom(base), client.FieldOwner(fieldManager)); err != nil {
+			return 0, fmt.Errorf("failed to restart %s/%s: %w", namespace, workload.GetName(), err)
+		}
+	}
+	return len(workloads), nil
+}
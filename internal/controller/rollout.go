@@ -0,0 +1,74 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/github"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// shortSHALength is the length commit SHAs are abbreviated to in messages
+const shortSHALength = 7
+
+// startRollout moves a deployed preview to Updating when the webhook sets a new
+// head SHA, and reports the rollout of that SHA as a new GitHub deployment and a
+// pending commit status. The ApplicationSet then targets the new SHA, and the
+// preview returns to Ready once every Application is synced and healthy at it
+// (see updateServiceHealth), which records it as Status.DeployedSHA.
+func (r *PreviewEnvironmentReconciler) startRollout(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment) {
+	if previewEnv.Status.DeployedSHA == "" || previewEnv.Status.DeployedSHA == previewEnv.Spec.HeadSHA ||
+		previewEnv.Status.ObservedGeneration == previewEnv.Generation || isSleeping(previewEnv) {
+		return
+	}
+
+	logf.FromContext(ctx).Info("Rolling out new head SHA",
+		"deployedSHA", previewEnv.Status.DeployedSHA, "headSHA", previewEnv.Spec.HeadSHA)
+
+	description := fmt.Sprintf("Deploying %s", shortSHA(previewEnv.Spec.HeadSHA))
+	previewEnv.Status.Phase = phaseUpdating
+	previewEnv.Status.ObservedGeneration = previewEnv.Generation
+	r.recordEvent(previewEnv, corev1.EventTypeNormal, "RolloutStarted", "%s, replacing %s",
+		description, shortSHA(previewEnv.Status.DeployedSHA))
+	r.startDeployment(ctx, previewEnv, description)
+	r.reportCommitStatus(ctx, previewEnv, github.StatusStatePending, description)
+}
+
+// atRevision reports whether an Application synced at revision deploys headSHA.
+// Bitbucket head SHAs are abbreviated, so headSHA may be a prefix of revision.
+func atRevision(revision, headSHA string) bool {
+	return revision != "" && headSHA != "" && strings.HasPrefix(revision, headSHA)
+}
+
+// shortSHA abbreviates a commit SHA for messages
+func shortSHA(sha string) string {
+	if len(sha) > shortSHALength {
+		return sha[:shortSHALength]
+	}
+	return sha
+}
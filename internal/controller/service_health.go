@@ -0,0 +1,156 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// ArgoCD health and sync statuses (see ServiceStatus)
+	healthHealthy  = "Healthy"
+	healthDegraded = "Degraded"
+	healthMissing  = "Missing"
+	syncSynced     = "Synced"
+)
+
+// updateServiceHealth fills Status.Services from the preview's ArgoCD
+// Applications and derives the Available and Degraded conditions from them.
+// It does not persist the status.
+//
+// Without an ArgoCD manager, or while the preview sleeps, there are no
+// Applications to watch and the preview is Available once it is provisioned.
+func (r *PreviewEnvironmentReconciler) updateServiceHealth(ctx context.Context, previewEnv *previewv1alpha1.PreviewEnvironment) error {
+	if r.ArgoCDManager == nil || isSleeping(previewEnv) {
+		previewEnv.Status.Services = nil
+		if isSleeping(previewEnv) {
+			setCondition(previewEnv, conditionAvailable, metav1.ConditionFalse, "Sleeping",
+				"Services are undeployed until the preview environment is redeployed")
+		} else {
+			setCondition(previewEnv, conditionAvailable, metav1.ConditionTrue, "Provisioned",
+				"Preview environment resources are provisioned")
+		}
+		setCondition(previewEnv, conditionDegraded, metav1.ConditionFalse, "Provisioned", "Preview environment resources are provisioned")
+		return nil
+	}
+
+	apps, err := r.ArgoCDManager.GetServiceStatuses(ctx, previewEnv)
+	if err != nil {
+		return err
+	}
+
+	services := make([]previewv1alpha1.ServiceStatus, 0, len(previewEnv.Spec.Services))
+	var degraded, progressing []string
+	for _, name := range previewEnv.Spec.Services {
+		service := previewv1alpha1.ServiceStatus{Name: name}
+		if app, ok := apps[name]; ok {
+			service.Health = app.Health
+			service.Sync = app.Sync
+			service.Revision = app.Revision
+			service.Message = app.Message
+		} else {
+			service.Health = healthMissing
+			service.Message = "Application not generated yet"
+		}
+		service.Ready = service.Health == healthHealthy && service.Sync == syncSynced &&
+			atRevision(service.Revision, previewEnv.Spec.HeadSHA)
+		if r.IngressManager != nil {
+			service.URL = r.IngressManager.GetServiceURL(previewEnv, name)
+		}
+
+		switch {
+		case service.Health == healthDegraded:
+			degraded = append(degraded, describeService(service, previewEnv.Spec.HeadSHA))
+		case !service.Ready:
+			progressing = append(progressing, describeService(service, previewEnv.Spec.HeadSHA))
+		}
+		services = append(services, service)
+	}
+	previewEnv.Status.Services = services
+
+	switch {
+	case len(degraded) > 0:
+		message := "Degraded services: " + strings.Join(degraded, ", ")
+		setCondition(previewEnv, conditionAvailable, metav1.ConditionFalse, "ServicesDegraded", message)
+		setCondition(previewEnv, conditionDegraded, metav1.ConditionTrue, "ServicesDegraded", message)
+	case len(progressing) > 0:
+		message := fmt.Sprintf("Waiting for services to be healthy and synced at %s: %s",
+			shortSHA(previewEnv.Spec.HeadSHA), strings.Join(progressing, ", "))
+		setCondition(previewEnv, conditionAvailable, metav1.ConditionFalse, "ServicesProgressing", message)
+		setCondition(previewEnv, conditionDegraded, metav1.ConditionFalse, "ServicesProgressing", message)
+	default:
+		message := fmt.Sprintf("All %d services are healthy and synced at %s", len(services), shortSHA(previewEnv.Spec.HeadSHA))
+		setCondition(previewEnv, conditionAvailable, metav1.ConditionTrue, "ServicesReady", message)
+		setCondition(previewEnv, conditionDegraded, metav1.ConditionFalse, "ServicesReady", message)
+	}
+	return nil
+}
+
+// describeService summarizes a service that is not ready for a condition message
+func describeService(service previewv1alpha1.ServiceStatus, headSHA string) string {
+	description := fmt.Sprintf("%s (%s", service.Name, service.Health)
+	if service.Sync != "" && service.Sync != syncSynced {
+		description += ", " + service.Sync
+	}
+	if service.Revision != "" && !atRevision(service.Revision, headSHA) {
+		description += ", at " + shortSHA(service.Revision)
+	}
+	description += ")"
+	if service.Message != "" {
+		description += ": " + service.Message
+	}
+	return description
+}
+
+// previewsForApplication maps an ArgoCD Application to the PreviewEnvironment
+// it was generated for, matching the preview's pull request or branch and
+// repository labels, so health changes are reconciled as soon as ArgoCD reports them.
+func (r *PreviewEnvironmentReconciler) previewsForApplication(ctx context.Context, obj client.Object) []reconcile.Request {
+	previews := &previewv1alpha1.PreviewEnvironmentList{}
+	if err := r.List(ctx, previews); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list PreviewEnvironments for Application", "application", obj.GetName())
+		return nil
+	}
+
+	appLabels := labels.Set(obj.GetLabels())
+	var requests []reconcile.Request
+	for _, preview := range previews.Items {
+		if !labels.SelectorFromSet(preview.Spec.IdentityLabels()).Matches(appLabels) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: preview.Name, Namespace: preview.Namespace},
+		})
+	}
+	return requests
+}
@@ -0,0 +1,332 @@
+// MIT License
+//
+// Copyright (c) 2025 Mike Lane
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v66/github"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AppIDSecretKey is the Secret key holding the GitHub App ID
+	AppIDSecretKey = "app-id"
+	// PrivateKeySecretKey is the Secret key holding the PEM-encoded GitHub App private key
+	PrivateKeySecretKey = "private-key"
+
+	// jwtLifetime is how long a GitHub App JWT is valid (GitHub allows at most 10 minutes)
+	jwtLifetime = 9 * time.Minute
+	// jwtClockSkew backdates the JWT issued-at time to tolerate clock drift
+	jwtClockSkew = 60 * time.Second
+	// tokenRefreshWindow is how long before expiry an installation token is replaced
+	tokenRefreshWindow = 5 * time.Minute
+)
+
+// The GitHub App Secret is read from the manager's namespace only, and must be
+// named previewd-github-app to match the default RBAC.
+// +kubebuilder:rbac:groups="",namespace=system,resources=secrets,resourceNames=previewd-github-app,verbs=get
+
+// AppCredentials identifies a GitHub App
+type AppCredentials struct {
+	PrivateKey []byte // PEM-encoded RSA private key
+	AppID      int64
+}
+
+// installationToken is a cached installation access token
+type installationToken struct {
+	expiresAt time.Time
+	token     string
+}
+
+// appAuth authenticates as a GitHub App and hands out per-installation clients.
+// Installations are resolved per repository and their tokens are cached until
+// shortly before they expire. A cached installation is forgotten once GitHub
+// rejects it, so an uninstalled and reinstalled App is resolved again.
+type appAuth struct {
+	now           func() time.Time
+	key           *rsa.PrivateKey
+	baseURL       *url.URL
+	installations map[string]int64
+	tokens        map[int64]*installationToken
+	appID         int64
+	mu            sync.Mutex
+}
+
+// NewAppClient creates a GitHub client that authenticates as a GitHub App,
+// using a per-installation access token for each repository.
+func NewAppClient(creds *AppCredentials) (Client, error) {
+	key, err := parsePrivateKey(creds.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if creds.AppID <= 0 {
+		return nil, fmt.Errorf("invalid GitHub App ID: %d", creds.AppID)
+	}
+
+	return &githubClient{
+		client: github.NewClient(nil),
+		app: &appAuth{
+			appID:         creds.AppID,
+			key:           key,
+			baseURL:       github.NewClient(nil).BaseURL,
+			installations: make(map[string]int64),
+			tokens:        make(map[int64]*installationToken),
+			now:           time.Now,
+		},
+		retryConfig: defaultRetryConfig(),
+	}, nil
+}
+
+// LoadAppCredentials reads GitHub App credentials from a Kubernetes Secret
+// containing the app-id and private-key keys.
+func LoadAppCredentials(ctx context.Context, reader client.Reader, namespace, name string) (*AppCredentials, error) {
+	secret := &corev1.Secret{}
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get GitHub App secret %s/%s: %w", namespace, name, err)
+	}
+
+	appID, err := strconv.ParseInt(string(secret.Data[AppIDSecretKey]), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %q in GitHub App secret %s/%s: %w", AppIDSecretKey, namespace, name, err)
+	}
+
+	privateKey := secret.Data[PrivateKeySecretKey]
+	if len(privateKey) == 0 {
+		return nil, fmt.Errorf("GitHub App secret %s/%s has no value for key %q", namespace, name, PrivateKeySecretKey)
+	}
+
+	return &AppCredentials{AppID: appID, PrivateKey: privateKey}, nil
+}
+
+// clientFor returns a client authenticated for the installation that owns owner/repo
+func (a *appAuth) clientFor(ctx context.Context, owner, repo string) (*github.Client, error) {
+	installationID, err := a.installationFor(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := a.tokenFor(ctx, installationID)
+	if err != nil {
+		// The installation no longer exists, e.g. the App was reinstalled
+		if isStatus(err, http.StatusNotFound, http.StatusUnauthorized) {
+			a.forgetInstallation(owner+"/"+repo, installationID)
+		}
+		return nil, err
+	}
+
+	transport := &installationTransport{
+		auth:           a,
+		base:           newRateLimitTransport(nil, installationTokenLabel(installationID)),
+		repository:     owner + "/" + repo,
+		installationID: installationID,
+	}
+	gh := github.NewClient(&http.Client{Transport: transport}).WithAuthToken(token)
+	gh.BaseURL = a.baseURL
+	return gh, nil
+}
+
+// installationFor resolves (and caches) the installation ID for a repository
+func (a *appAuth) installationFor(ctx context.Context, owner, repo string) (int64, error) {
+	key := owner + "/" + repo
+
+	a.mu.Lock()
+	id, ok := a.installations[key]
+	a.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	installation, _, err := a.appClient().Apps.FindRepositoryInstallation(ctx, owner, repo)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find GitHub App installation for %s: %w", key, err)
+	}
+
+	a.mu.Lock()
+	a.installations[key] = installation.GetID()
+	a.mu.Unlock()
+
+	return installation.GetID(), nil
+}
+
+// forgetInstallation drops the cached installation of a repository and its
+// token, so the next call resolves the installation again
+func (a *appAuth) forgetInstallation(repository string, installationID int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.installations[repository] == installationID {
+		delete(a.installations, repository)
+	}
+	delete(a.tokens, installationID)
+}
+
+// tokenFor returns a cached installation token, exchanging a new JWT when the
+// cached token is missing or about to expire.
+func (a *appAuth) tokenFor(ctx context.Context, installationID int64) (string, error) {
+	a.mu.Lock()
+	cached, ok := a.tokens[installationID]
+	a.mu.Unlock()
+	if ok && a.now().Add(tokenRefreshWindow).Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+
+	token, _, err := a.appClient().Apps.CreateInstallationToken(ctx, installationID, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create installation token for installation %d: %w", installationID, err)
+	}
+
+	a.mu.Lock()
+	a.tokens[installationID] = &installationToken{
+		token:     token.GetToken(),
+		expiresAt: token.GetExpiresAt().Time,
+	}
+	a.mu.Unlock()
+
+	return token.GetToken(), nil
+}
+
+// appClient returns a client authenticated as the App itself (JWT bearer auth)
+func (a *appAuth) appClient() *github.Client {
+	transport := &jwtTransport{auth: a, base: newRateLimitTransport(nil, tokenLabelApp)}
+	gh := github.NewClient(&http.Client{Transport: transport})
+	gh.BaseURL = a.baseURL
+	return gh
+}
+
+// jwtTransport signs each request with a freshly minted App JWT
+type jwtTransport struct {
+	base http.RoundTripper
+	auth *appAuth
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *jwtTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.auth.signJWT()
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
+
+// installationTransport forgets the cached installation of a repository when
+// GitHub rejects its installation token, e.g. after the App was uninstalled
+type installationTransport struct {
+	base           http.RoundTripper
+	auth           *appAuth
+	repository     string
+	installationID int64
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *installationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		t.auth.forgetInstallation(t.repository, t.installationID)
+	}
+	return resp, err
+}
+
+// isStatus reports whether err is a GitHub API error with one of the given
+// HTTP status codes
+func isStatus(err error, codes ...int) bool {
+	var ghErr *github.ErrorResponse
+	if !errors.As(err, &ghErr) || ghErr.Response == nil {
+		return false
+	}
+	for _, code := range codes {
+		if ghErr.Response.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// signJWT mints an RS256 JWT identifying the GitHub App
+func (a *appAuth) signJWT() (string, error) {
+	now := a.now()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-jwtClockSkew).Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+		"iss": strconv.FormatInt(a.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey decodes a PEM-encoded PKCS#1 or PKCS#8 RSA private key
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("GitHub App private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub App private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("GitHub App private key is not an RSA key")
+	}
+
+	return key, nil
+}
@@ -0,0 +1,319 @@
+// MIT License
+//
+// Copyright (c) 2025 Mike Lane
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v66/github"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeGitHubAppAPI simulates the GitHub App installation endpoints
+type fakeGitHubAppAPI struct {
+	t              *testing.T
+	key            *rsa.PrivateKey
+	tokenExpiresAt time.Time
+	revokedToken   string // rejected with 401 Unauthorized
+	lookups        int
+	tokenRequests  int
+	installationID int64 // defaults to 555
+	authHeaders    []string
+	mu             sync.Mutex
+}
+
+func (f *fakeGitHubAppAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	installationID := f.installationID
+	if installationID == 0 {
+		installationID = 555
+	}
+
+	switch {
+	case r.URL.Path == "/repos/mikelane/previewd/installation":
+		f.lookups++
+		f.verifyJWT(r)
+		//nolint:errcheck,gosec // Test helper - write error is acceptable (G104)
+		fmt.Fprintf(w, `{"id":%d}`, installationID)
+	case strings.HasPrefix(r.URL.Path, "/app/installations/"):
+		f.tokenRequests++
+		f.verifyJWT(r)
+		if r.URL.Path != fmt.Sprintf("/app/installations/%d/access_tokens", installationID) {
+			w.WriteHeader(http.StatusNotFound)
+			//nolint:errcheck,gosec // Test helper - write error is acceptable (G104)
+			w.Write([]byte(`{"message":"Not Found"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		//nolint:errcheck,gosec // Test helper - write error is acceptable (G104)
+		fmt.Fprintf(w, `{"token":"ghs_token%d","expires_at":%q}`, f.tokenRequests, f.tokenExpiresAt.Format(time.RFC3339))
+	case f.revokedToken != "" && r.Header.Get("Authorization") == "Bearer "+f.revokedToken:
+		w.WriteHeader(http.StatusUnauthorized)
+		//nolint:errcheck,gosec // Test helper - write error is acceptable (G104)
+		w.Write([]byte(`{"message":"Bad credentials"}`))
+	default:
+		f.authHeaders = append(f.authHeaders, r.Header.Get("Authorization"))
+		//nolint:errcheck,gosec // Test helper - write error is acceptable (G104)
+		w.Write([]byte(`{"number":1}`))
+	}
+}
+
+// verifyJWT checks that the request carries a valid RS256 JWT issued by app 42
+func (f *fakeGitHubAppAPI) verifyJWT(r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		f.t.Errorf("expected JWT bearer token, got %q", r.Header.Get("Authorization"))
+		return
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		f.t.Errorf("failed to decode JWT signature: %v", err)
+		return
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&f.key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		f.t.Errorf("invalid JWT signature: %v", err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		f.t.Errorf("failed to decode JWT claims: %v", err)
+		return
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		f.t.Errorf("failed to parse JWT claims: %v", err)
+		return
+	}
+	if claims["iss"] != "42" {
+		f.t.Errorf("JWT iss = %v, want 42", claims["iss"])
+	}
+}
+
+// newTestAppClient creates an App-authenticated client pointed at the fake API
+func newTestAppClient(t *testing.T, api *fakeGitHubAppAPI, serverURL string, now func() time.Time) *githubClient {
+	t.Helper()
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(api.key)})
+	c, err := NewAppClient(&AppCredentials{AppID: 42, PrivateKey: keyPEM})
+	if err != nil {
+		t.Fatalf("NewAppClient() unexpected error: %v", err)
+	}
+
+	gc := c.(*githubClient)
+	baseURL, err := github.NewClient(nil).BaseURL.Parse(serverURL + "/")
+	if err != nil {
+		t.Fatalf("failed to parse test server URL: %v", err)
+	}
+	gc.app.baseURL = baseURL
+	gc.app.now = now
+	return gc
+}
+
+// TestAppClient_UsesCachedInstallationToken tests that installation lookups and
+// tokens are cached across calls
+func TestAppClient_UsesCachedInstallationToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	api := &fakeGitHubAppAPI{t: t, key: key, tokenExpiresAt: now.Add(time.Hour)}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client := newTestAppClient(t, api, server.URL, func() time.Time { return now })
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetPullRequest(context.Background(), "mikelane", "previewd", 1); err != nil {
+			t.Fatalf("GetPullRequest() unexpected error: %v", err)
+		}
+	}
+
+	if api.lookups != 1 {
+		t.Errorf("installation lookups = %d, want 1", api.lookups)
+	}
+	if api.tokenRequests != 1 {
+		t.Errorf("token requests = %d, want 1", api.tokenRequests)
+	}
+	for _, header := range api.authHeaders {
+		if header != "Bearer ghs_token1" {
+			t.Errorf("Authorization = %q, want installation token", header)
+		}
+	}
+}
+
+// TestAppClient_RefreshesTokenNearExpiry tests that a token is replaced before it expires
+func TestAppClient_RefreshesTokenNearExpiry(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	api := &fakeGitHubAppAPI{t: t, key: key, tokenExpiresAt: now.Add(time.Hour)}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	current := now
+	client := newTestAppClient(t, api, server.URL, func() time.Time { return current })
+
+	if _, err := client.GetPullRequest(context.Background(), "mikelane", "previewd", 1); err != nil {
+		t.Fatalf("GetPullRequest() unexpected error: %v", err)
+	}
+
+	// Move within the refresh window of the cached token
+	current = now.Add(57 * time.Minute)
+	if _, err := client.GetPullRequest(context.Background(), "mikelane", "previewd", 1); err != nil {
+		t.Fatalf("GetPullRequest() unexpected error: %v", err)
+	}
+
+	if api.tokenRequests != 2 {
+		t.Errorf("token requests = %d, want 2", api.tokenRequests)
+	}
+	if got := api.authHeaders[len(api.authHeaders)-1]; got != "Bearer ghs_token2" {
+		t.Errorf("Authorization = %q, want refreshed token", got)
+	}
+}
+
+// TestAppClient_ForgetsRejectedInstallation tests that a cached installation is
+// resolved again once GitHub rejects it with 404 or 401
+func TestAppClient_ForgetsRejectedInstallation(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		reject func(api *fakeGitHubAppAPI, advance func())
+		name   string
+	}{
+		{
+			// The App is reinstalled: the old installation's token exchange returns 404
+			name: "installation not found",
+			reject: func(api *fakeGitHubAppAPI, advance func()) {
+				api.installationID = 777
+				advance()
+			},
+		},
+		{
+			// The installation token is revoked: API calls return 401
+			name: "token rejected",
+			reject: func(api *fakeGitHubAppAPI, _ func()) {
+				api.revokedToken = "ghs_token1"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+			api := &fakeGitHubAppAPI{t: t, key: key, tokenExpiresAt: now.Add(time.Hour)}
+			server := httptest.NewServer(api)
+			defer server.Close()
+
+			current := now
+			client := newTestAppClient(t, api, server.URL, func() time.Time { return current })
+			client.retryConfig.MaxRetries = 0
+
+			if _, err := client.GetPullRequest(context.Background(), "mikelane", "previewd", 1); err != nil {
+				t.Fatalf("GetPullRequest() unexpected error: %v", err)
+			}
+
+			api.mu.Lock()
+			tt.reject(api, func() { current = now.Add(2 * time.Hour) })
+			api.tokenExpiresAt = current.Add(time.Hour)
+			api.mu.Unlock()
+
+			if _, err := client.GetPullRequest(context.Background(), "mikelane", "previewd", 1); err == nil {
+				t.Fatal("GetPullRequest() with a rejected installation expected error, got nil")
+			}
+			if _, err := client.GetPullRequest(context.Background(), "mikelane", "previewd", 1); err != nil {
+				t.Fatalf("GetPullRequest() after the rejection unexpected error: %v", err)
+			}
+
+			if api.lookups != 2 {
+				t.Errorf("installation lookups = %d, want 2", api.lookups)
+			}
+		})
+	}
+}
+
+// TestNewAppClient_InvalidKey tests rejection of malformed private keys
+func TestNewAppClient_InvalidKey(t *testing.T) {
+	if _, err := NewAppClient(&AppCredentials{AppID: 42, PrivateKey: []byte("not a key")}); err == nil {
+		t.Error("NewAppClient() expected error for invalid key, got nil")
+	}
+}
+
+// TestLoadAppCredentials tests reading App credentials from a Secret
+func TestLoadAppCredentials(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add scheme: %v", err)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github-app", Namespace: "previewd-system"},
+		Data: map[string][]byte{
+			AppIDSecretKey:      []byte("42"),
+			PrivateKeySecretKey: []byte("pem"),
+		},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()
+
+	creds, err := LoadAppCredentials(context.Background(), reader, "previewd-system", "github-app")
+	if err != nil {
+		t.Fatalf("LoadAppCredentials() unexpected error: %v", err)
+	}
+	if creds.AppID != 42 || string(creds.PrivateKey) != "pem" {
+		t.Errorf("LoadAppCredentials() = %+v", creds)
+	}
+
+	if _, err := LoadAppCredentials(context.Background(), reader, "previewd-system", "missing"); err == nil {
+		t.Error("LoadAppCredentials() expected error for missing secret, got nil")
+	}
+}
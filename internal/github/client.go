@@ -29,6 +29,7 @@ import (
 	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/v66/github"
@@ -40,43 +41,66 @@ type RetryConfig struct {
 	InitialBackoff time.Duration
 	MaxBackoff     time.Duration
 	BackoffFactor  float64
+	// MaxRateLimitWait is the longest a rate-limited call waits before retrying;
+	// longer waits fail fast instead. Zero means no limit.
+	MaxRateLimitWait time.Duration
 }
 
 // githubClient implements the Client interface using go-github
 type githubClient struct {
 	client      *github.Client
 	retryConfig *RetryConfig
+	app         *appAuth // set when authenticating as a GitHub App
 }
 
 // NewClient creates a new GitHub client with the provided token
 func NewClient(token string) (Client, error) {
-	var httpClient *http.Client
+	httpClient := &http.Client{Transport: newRateLimitTransport(nil, tokenLabelAnonymous)}
 	if token != "" {
-		httpClient = github.NewClient(nil).Client()
 		httpClient.Transport = &github.BasicAuthTransport{
-			Username: "token",
-			Password: token,
+			Username:  "token",
+			Password:  token,
+			Transport: newRateLimitTransport(nil, tokenLabelPersonalAccessToken),
 		}
 	}
 
 	return &githubClient{
-		client: github.NewClient(httpClient),
-		retryConfig: &RetryConfig{
-			MaxRetries:     3,
-			InitialBackoff: 100 * time.Millisecond,
-			MaxBackoff:     30 * time.Second,
-			BackoffFactor:  2.0,
-		},
+		client:      github.NewClient(httpClient),
+		retryConfig: defaultRetryConfig(),
 	}, nil
 }
 
+// defaultRetryConfig returns the retry behavior used by new clients
+func defaultRetryConfig() *RetryConfig {
+	return &RetryConfig{
+		MaxRetries:       3,
+		InitialBackoff:   100 * time.Millisecond,
+		MaxBackoff:       30 * time.Second,
+		BackoffFactor:    2.0,
+		MaxRateLimitWait: time.Minute,
+	}
+}
+
+// clientFor returns the go-github client to use for a repository.
+// With GitHub App auth, this is a client for the repository's installation.
+func (c *githubClient) clientFor(ctx context.Context, owner, repo string) (*github.Client, error) {
+	if c.app == nil {
+		return c.client, nil
+	}
+	return c.app.clientFor(ctx, owner, repo)
+}
+
 // GetPullRequest retrieves metadata about a pull request
 func (c *githubClient) GetPullRequest(ctx context.Context, owner, repo string, number int) (*PullRequest, error) {
+	gh, err := c.clientFor(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
 	var pr *github.PullRequest
-	var err error
 
 	err = c.executeWithRetry(ctx, func() error {
-		pr, _, err = c.client.PullRequests.Get(ctx, owner, repo, number)
+		pr, _, err = gh.PullRequests.Get(ctx, owner, repo, number)
 		return err
 	})
 
@@ -89,6 +113,11 @@ func (c *githubClient) GetPullRequest(ctx context.Context, owner, repo string, n
 
 // GetPRFiles retrieves the list of files changed in a pull request
 func (c *githubClient) GetPRFiles(ctx context.Context, owner, repo string, number int) ([]*File, error) {
+	gh, err := c.clientFor(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
 	allFiles := []*File{} // Initialize as empty slice, not nil
 	opts := &github.ListOptions{
 		PerPage: 100,
@@ -100,7 +129,7 @@ func (c *githubClient) GetPRFiles(ctx context.Context, owner, repo string, numbe
 		var err error
 
 		err = c.executeWithRetry(ctx, func() error {
-			files, resp, err = c.client.PullRequests.ListFiles(ctx, owner, repo, number, opts)
+			files, resp, err = gh.PullRequests.ListFiles(ctx, owner, repo, number, opts)
 			return err
 		})
 
@@ -123,6 +152,11 @@ func (c *githubClient) GetPRFiles(ctx context.Context, owner, repo string, numbe
 
 // UpdateCommitStatus updates the status of a commit
 func (c *githubClient) UpdateCommitStatus(ctx context.Context, owner, repo, sha string, status *Status) error {
+	gh, err := c.clientFor(ctx, owner, repo)
+	if err != nil {
+		return err
+	}
+
 	repoStatus := &github.RepoStatus{
 		State:       github.String(string(status.State)),
 		TargetURL:   github.String(status.TargetURL),
@@ -130,8 +164,8 @@ func (c *githubClient) UpdateCommitStatus(ctx context.Context, owner, repo, sha
 		Context:     github.String(status.Context),
 	}
 
-	err := c.executeWithRetry(ctx, func() error {
-		_, _, err := c.client.Repositories.CreateStatus(ctx, owner, repo, sha, repoStatus)
+	err = c.executeWithRetry(ctx, func() error {
+		_, _, err := gh.Repositories.CreateStatus(ctx, owner, repo, sha, repoStatus)
 		return err
 	})
 
@@ -142,7 +176,259 @@ func (c *githubClient) UpdateCommitStatus(ctx context.Context, owner, repo, sha
 	return nil
 }
 
-// executeWithRetry executes an operation with exponential backoff retry
+// CreateOrUpdateComment edits the pull request comment containing marker in place,
+// or creates a new comment if none exists. The marker (typically a hidden HTML
+// comment such as "<!-- previewd -->") is prepended to the body so the comment
+// can be found again on later calls.
+func (c *githubClient) CreateOrUpdateComment(ctx context.Context, owner, repo string, number int, marker, body string) error {
+	if marker == "" {
+		return fmt.Errorf("comment marker cannot be empty")
+	}
+	fullBody := marker + "\n" + body
+
+	gh, err := c.clientFor(ctx, owner, repo)
+	if err != nil {
+		return err
+	}
+
+	existingID, err := c.findCommentByMarker(ctx, gh, owner, repo, number, marker)
+	if err != nil {
+		return err
+	}
+
+	comment := &github.IssueComment{Body: github.String(fullBody)}
+
+	if existingID != 0 {
+		err = c.executeWithRetry(ctx, func() error {
+			_, _, err := gh.Issues.EditComment(ctx, owner, repo, existingID, comment)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update comment: %w", err)
+		}
+		return nil
+	}
+
+	err = c.executeWithRetry(ctx, func() error {
+		_, _, err := gh.Issues.CreateComment(ctx, owner, repo, number, comment)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create comment: %w", err)
+	}
+
+	return nil
+}
+
+// CreateDeployment creates a deployment and returns its ID.
+// Required status contexts are disabled so the deployment is not blocked by
+// the commit statuses previewd itself posts.
+func (c *githubClient) CreateDeployment(ctx context.Context, owner, repo string, deployment *Deployment) (int64, error) {
+	gh, err := c.clientFor(ctx, owner, repo)
+	if err != nil {
+		return 0, err
+	}
+
+	request := &github.DeploymentRequest{
+		Ref:                   github.String(deployment.Ref),
+		Environment:           github.String(deployment.Environment),
+		Description:           github.String(deployment.Description),
+		AutoMerge:             github.Bool(false),
+		RequiredContexts:      &[]string{},
+		TransientEnvironment:  github.Bool(deployment.TransientEnvironment),
+		ProductionEnvironment: github.Bool(false),
+	}
+
+	var created *github.Deployment
+	err = c.executeWithRetry(ctx, func() error {
+		var err error
+		created, _, err = gh.Repositories.CreateDeployment(ctx, owner, repo, request)
+		return err
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to create deployment: %w", err)
+	}
+
+	return created.GetID(), nil
+}
+
+// CreateDeploymentStatus records a new status for an existing deployment
+func (c *githubClient) CreateDeploymentStatus(ctx context.Context, owner, repo string, deploymentID int64, status *DeploymentStatus) error {
+	gh, err := c.clientFor(ctx, owner, repo)
+	if err != nil {
+		return err
+	}
+
+	request := &github.DeploymentStatusRequest{
+		State:       github.String(string(status.State)),
+		Description: github.String(status.Description),
+	}
+	if status.EnvironmentURL != "" {
+		request.EnvironmentURL = github.String(status.EnvironmentURL)
+	}
+
+	err = c.executeWithRetry(ctx, func() error {
+		_, _, err := gh.Repositories.CreateDeploymentStatus(ctx, owner, repo, deploymentID, request)
+		return err
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to create deployment status: %w", err)
+	}
+
+	return nil
+}
+
+// GetFileContents returns the contents of a file at the given ref
+func (c *githubClient) GetFileContents(ctx context.Context, owner, repo, path, ref string) ([]byte, error) {
+	gh, err := c.clientFor(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var file *github.RepositoryContent
+	opts := &github.RepositoryContentGetOptions{Ref: ref}
+
+	err = c.executeWithRetry(ctx, func() error {
+		file, _, _, err = gh.Repositories.GetContents(ctx, owner, repo, path, opts)
+		return err
+	})
+
+	if err != nil {
+		var ghErr *github.ErrorResponse
+		if errors.As(err, &ghErr) && ghErr.Response != nil && ghErr.Response.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("file %s at %s: %w", path, ref, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get file contents: %w", err)
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%s at %s is a directory, not a file", path, ref)
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode file contents: %w", err)
+	}
+
+	return []byte(content), nil
+}
+
+// GetPermissionLevel returns a user's permission on the repository
+func (c *githubClient) GetPermissionLevel(ctx context.Context, owner, repo, user string) (string, error) {
+	gh, err := c.clientFor(ctx, owner, repo)
+	if err != nil {
+		return "", err
+	}
+
+	var level *github.RepositoryPermissionLevel
+
+	err = c.executeWithRetry(ctx, func() error {
+		level, _, err = gh.Repositories.GetPermissionLevel(ctx, owner, repo, user)
+		return err
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("failed to get permission level for %s: %w", user, err)
+	}
+
+	// role_name distinguishes maintain and triage, which the legacy permission field folds away
+	if role := level.GetRoleName(); role != "" {
+		return role, nil
+	}
+	return level.GetPermission(), nil
+}
+
+// CreateComment adds a new comment to a pull request
+func (c *githubClient) CreateComment(ctx context.Context, owner, repo string, number int, body string) error {
+	gh, err := c.clientFor(ctx, owner, repo)
+	if err != nil {
+		return err
+	}
+
+	comment := &github.IssueComment{Body: github.String(body)}
+
+	err = c.executeWithRetry(ctx, func() error {
+		_, _, err := gh.Issues.CreateComment(ctx, owner, repo, number, comment)
+		return err
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to create comment: %w", err)
+	}
+
+	return nil
+}
+
+// AddCommentReaction reacts to a pull request comment
+func (c *githubClient) AddCommentReaction(ctx context.Context, owner, repo string, commentID int64, reaction string) error {
+	gh, err := c.clientFor(ctx, owner, repo)
+	if err != nil {
+		return err
+	}
+
+	err = c.executeWithRetry(ctx, func() error {
+		_, _, err := gh.Reactions.CreateIssueCommentReaction(ctx, owner, repo, commentID, reaction)
+		return err
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to add reaction: %w", err)
+	}
+
+	return nil
+}
+
+// findCommentByMarker returns the ID of the first comment containing marker, or 0 if none exists
+func (c *githubClient) findCommentByMarker(ctx context.Context, gh *github.Client, owner, repo string, number int, marker string) (int64, error) {
+	opts := &github.IssueListCommentsOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for {
+		var comments []*github.IssueComment
+		var resp *github.Response
+		var err error
+
+		err = c.executeWithRetry(ctx, func() error {
+			comments, resp, err = gh.Issues.ListComments(ctx, owner, repo, number, opts)
+			return err
+		})
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to list comments: %w", err)
+		}
+
+		for _, comment := range comments {
+			if strings.Contains(comment.GetBody(), marker) {
+				return comment.GetID(), nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return 0, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// ParseRepository splits a repository reference into owner and name.
+// Accepts "owner/repo" and "https://github.com/owner/repo" formats.
+func ParseRepository(repository string) (owner, repo string, err error) {
+	trimmed := strings.TrimPrefix(repository, "https://github.com/")
+	trimmed = strings.TrimSuffix(trimmed, ".git")
+
+	parts := strings.Split(trimmed, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid repository format: %s", repository)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// executeWithRetry executes an operation with retry. Rate-limited requests wait
+// for the duration GitHub asks for (Retry-After or X-RateLimit-Reset); other
+// retryable errors use exponential backoff. Waits never outlive ctx.
 func (c *githubClient) executeWithRetry(ctx context.Context, operation func() error) error {
 	var lastErr error
 
@@ -171,14 +457,26 @@ func (c *githubClient) executeWithRetry(ctx context.Context, operation func() er
 			break
 		}
 
-		// Calculate backoff with jitter
-		backoff := c.calculateBackoff(attempt)
+		// Prefer the wait GitHub asked for; fall back to backoff with jitter
+		wait := c.calculateBackoff(attempt)
+		if rateLimited, rateLimitWait := c.rateLimitWait(lastErr); rateLimited {
+			// A primary rate limit can take up to an hour to reset
+			if limit := c.retryConfig.MaxRateLimitWait; limit > 0 && rateLimitWait > limit {
+				return fmt.Errorf("rate limit wait of %s exceeds maximum of %s: %w", rateLimitWait, limit, lastErr)
+			}
+			wait = rateLimitWait
+		}
+
+		// Give up early rather than sleeping past the caller's deadline
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return fmt.Errorf("rate limit wait of %s exceeds context deadline: %w", wait, lastErr)
+		}
 
 		// Wait with context cancellation support
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-time.After(backoff):
+		case <-time.After(wait):
 			// Continue to next retry
 		}
 	}
@@ -192,9 +490,16 @@ func (c *githubClient) isRetryableError(err error) bool {
 		return false
 	}
 
+	// Primary and secondary rate limits detected by go-github
+	var rateLimitErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &rateLimitErr) || errors.As(err, &abuseErr) {
+		return true
+	}
+
 	// Check for GitHub API errors using errors.As to handle wrapped errors
 	var ghErr *github.ErrorResponse
-	if errors.As(err, &ghErr) {
+	if errors.As(err, &ghErr) && ghErr.Response != nil {
 		switch ghErr.Response.StatusCode {
 		case http.StatusTooManyRequests,
 			http.StatusBadGateway,
@@ -212,6 +517,35 @@ func (c *githubClient) isRetryableError(err error) bool {
 	return false
 }
 
+// rateLimitWait returns how long GitHub asked us to wait before retrying err.
+// It reports false when err carries no rate-limit information.
+func (c *githubClient) rateLimitWait(err error) (bool, time.Duration) {
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		if abuseErr.RetryAfter != nil {
+			return true, *abuseErr.RetryAfter
+		}
+		return c.checkRateLimit(abuseErr.Response)
+	}
+
+	var rateLimitErr *github.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		if wait := time.Until(rateLimitErr.Rate.Reset.Time); wait > 0 {
+			return true, wait
+		}
+		return c.checkRateLimit(rateLimitErr.Response)
+	}
+
+	// Plain error responses only count as rate limited when GitHub sent headers
+	// saying so; otherwise the regular backoff applies.
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) {
+		return rateLimitHeaderWait(ghErr.Response)
+	}
+
+	return false, 0
+}
+
 // calculateBackoff calculates the backoff duration for a retry attempt
 func (c *githubClient) calculateBackoff(attempt int) time.Duration {
 	// Exponential backoff with jitter
@@ -237,6 +571,36 @@ func (c *githubClient) checkRateLimit(resp *http.Response) (bool, time.Duration)
 		return false, 0
 	}
 
+	if rateLimited, waitTime := rateLimitHeaderWait(resp); rateLimited {
+		return true, waitTime
+	}
+
+	// Check for secondary rate limit (403 without rate limit headers)
+	if resp.StatusCode == http.StatusForbidden {
+		// Default wait for secondary rate limit
+		return true, 60 * time.Second
+	}
+
+	return false, 0
+}
+
+// rateLimitHeaderWait reads the wait time from Retry-After or, when the primary
+// quota is exhausted, X-RateLimit-Reset
+func rateLimitHeaderWait(resp *http.Response) (bool, time.Duration) {
+	if resp == nil {
+		return false, 0
+	}
+
+	// Retry-After takes precedence (sent with 429s and secondary rate limits)
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+			return true, time.Duration(seconds) * time.Second
+		}
+		if at, err := http.ParseTime(retryAfter); err == nil {
+			return true, max(time.Until(at), 0)
+		}
+	}
+
 	// Check primary rate limit
 	remaining := resp.Header.Get("X-RateLimit-Remaining")
 	if remaining != "" {
@@ -254,12 +618,6 @@ func (c *githubClient) checkRateLimit(resp *http.Response) (bool, time.Duration)
 		}
 	}
 
-	// Check for secondary rate limit (403 without rate limit headers)
-	if resp.StatusCode == http.StatusForbidden {
-		// Default wait for secondary rate limit
-		return true, 60 * time.Second
-	}
-
 	return false, 0
 }
 
@@ -25,6 +25,7 @@ package github
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -637,3 +638,366 @@ func TestGetPRFilesPagination(t *testing.T) {
 		}
 	}
 }
+
+// newTestClient returns a githubClient pointed at the given test server
+func newTestClient(t *testing.T, serverURL string) *githubClient {
+	t.Helper()
+
+	client := &githubClient{
+		client: github.NewClient(nil),
+		retryConfig: &RetryConfig{
+			MaxRetries:     3,
+			InitialBackoff: 10 * time.Millisecond,
+			MaxBackoff:     100 * time.Millisecond,
+			BackoffFactor:  2.0,
+		},
+	}
+	baseURL, err := client.client.BaseURL.Parse(serverURL + "/")
+	if err != nil {
+		t.Fatalf("failed to parse test server URL: %v", err)
+	}
+	client.client.BaseURL = baseURL
+	return client
+}
+
+// TestCreateOrUpdateComment tests upserting a PR comment keyed on a hidden marker
+func TestCreateOrUpdateComment(t *testing.T) {
+	const marker = "<!-- previewd -->"
+
+	tests := []struct {
+		name           string
+		existing       string
+		wantMethod     string
+		wantPath       string
+		wantBodyPrefix string
+	}{
+		{
+			name:           "Creates comment when marker is absent",
+			existing:       `[{"id":1,"body":"LGTM"}]`,
+			wantMethod:     http.MethodPost,
+			wantPath:       "/repos/mikelane/previewd/issues/42/comments",
+			wantBodyPrefix: marker + "\n",
+		},
+		{
+			name:           "Edits comment containing marker",
+			existing:       `[{"id":1,"body":"LGTM"},{"id":7,"body":"` + marker + `\nold"}]`,
+			wantMethod:     http.MethodPatch,
+			wantPath:       "/repos/mikelane/previewd/issues/comments/7",
+			wantBodyPrefix: marker + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotMethod, gotPath string
+			var gotComment github.IssueComment
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					//nolint:errcheck,gosec // Test helper - write error is acceptable (G104)
+					w.Write([]byte(tt.existing))
+					return
+				}
+
+				gotMethod = r.Method
+				gotPath = r.URL.Path
+				if err := json.NewDecoder(r.Body).Decode(&gotComment); err != nil {
+					t.Errorf("Failed to decode request body: %v", err)
+				}
+				w.WriteHeader(http.StatusOK)
+				//nolint:errcheck,gosec // Test helper - write error is acceptable (G104)
+				w.Write([]byte(`{"id":7}`))
+			}))
+			defer server.Close()
+
+			client := newTestClient(t, server.URL)
+			err := client.CreateOrUpdateComment(context.Background(), "mikelane", "previewd", 42, marker, "new body")
+			if err != nil {
+				t.Fatalf("CreateOrUpdateComment() unexpected error: %v", err)
+			}
+
+			if gotMethod != tt.wantMethod {
+				t.Errorf("method = %s, want %s", gotMethod, tt.wantMethod)
+			}
+			if gotPath != tt.wantPath {
+				t.Errorf("path = %s, want %s", gotPath, tt.wantPath)
+			}
+			if gotComment.GetBody() != tt.wantBodyPrefix+"new body" {
+				t.Errorf("body = %q, want %q", gotComment.GetBody(), tt.wantBodyPrefix+"new body")
+			}
+		})
+	}
+}
+
+// TestParseRepository tests splitting repository references into owner and name
+func TestParseRepository(t *testing.T) {
+	tests := []struct {
+		name       string
+		repository string
+		wantOwner  string
+		wantRepo   string
+		wantError  bool
+	}{
+		{name: "owner/repo format", repository: "mikelane/previewd", wantOwner: "mikelane", wantRepo: "previewd"},
+		{name: "GitHub URL format", repository: "https://github.com/mikelane/previewd", wantOwner: "mikelane", wantRepo: "previewd"},
+		{name: "rejects missing repo", repository: "mikelane", wantError: true},
+		{name: "rejects empty owner", repository: "/previewd", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, repo, err := ParseRepository(tt.repository)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("ParseRepository() error = %v, wantError %v", err, tt.wantError)
+			}
+			if owner != tt.wantOwner || repo != tt.wantRepo {
+				t.Errorf("ParseRepository() = (%q, %q), want (%q, %q)", owner, repo, tt.wantOwner, tt.wantRepo)
+			}
+		})
+	}
+}
+
+// TestCreateDeployment tests creating a transient preview deployment
+func TestCreateDeployment(t *testing.T) {
+	var got github.DeploymentRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/mikelane/previewd/deployments" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		//nolint:errcheck,gosec // Test helper - write error is acceptable (G104)
+		w.Write([]byte(`{"id":1234}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL)
+	id, err := client.CreateDeployment(context.Background(), "mikelane", "previewd", &Deployment{
+		Ref:                  "abc123",
+		Environment:          "preview-pr-42",
+		Description:          "Preview environment for PR #42",
+		TransientEnvironment: true,
+	})
+	if err != nil {
+		t.Fatalf("CreateDeployment() unexpected error: %v", err)
+	}
+
+	if id != 1234 {
+		t.Errorf("CreateDeployment() id = %d, want 1234", id)
+	}
+	if got.GetEnvironment() != "preview-pr-42" || got.GetRef() != "abc123" {
+		t.Errorf("request environment/ref = %q/%q", got.GetEnvironment(), got.GetRef())
+	}
+	if !got.GetTransientEnvironment() || got.GetProductionEnvironment() {
+		t.Errorf("expected transient, non-production deployment, got %+v", got)
+	}
+	if got.RequiredContexts == nil || len(*got.RequiredContexts) != 0 {
+		t.Errorf("expected empty required contexts, got %v", got.RequiredContexts)
+	}
+}
+
+// TestCreateDeploymentStatus tests recording deployment statuses
+func TestCreateDeploymentStatus(t *testing.T) {
+	tests := []struct {
+		status     *DeploymentStatus
+		name       string
+		statusCode int
+		wantError  bool
+	}{
+		{
+			name:       "Records success with environment URL",
+			statusCode: http.StatusCreated,
+			status: &DeploymentStatus{
+				State:          DeploymentStateSuccess,
+				EnvironmentURL: "https://pr-42.preview.example.com",
+				Description:    "Preview environment ready",
+			},
+		},
+		{
+			name:       "Records inactive without URL",
+			statusCode: http.StatusCreated,
+			status: &DeploymentStatus{
+				State:       DeploymentStateInactive,
+				Description: "Preview environment deleted",
+			},
+		},
+		{
+			name:       "Handles not found error",
+			statusCode: http.StatusNotFound,
+			status:     &DeploymentStatus{State: DeploymentStateFailure},
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got github.DeploymentStatusRequest
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/repos/mikelane/previewd/deployments/1234/statuses" {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Errorf("Failed to decode request body: %v", err)
+				}
+				w.WriteHeader(tt.statusCode)
+				//nolint:errcheck,gosec // Test helper - write error is acceptable (G104)
+				w.Write([]byte(`{}`))
+			}))
+			defer server.Close()
+
+			client := newTestClient(t, server.URL)
+			err := client.CreateDeploymentStatus(context.Background(), "mikelane", "previewd", 1234, tt.status)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("CreateDeploymentStatus() error = %v, wantError %v", err, tt.wantError)
+			}
+			if tt.wantError {
+				return
+			}
+
+			if got.GetState() != string(tt.status.State) {
+				t.Errorf("state = %q, want %q", got.GetState(), tt.status.State)
+			}
+			if got.GetEnvironmentURL() != tt.status.EnvironmentURL {
+				t.Errorf("environment_url = %q, want %q", got.GetEnvironmentURL(), tt.status.EnvironmentURL)
+			}
+		})
+	}
+}
+
+func TestGetFileContents(t *testing.T) {
+	tests := []struct {
+		name         string
+		statusCode   int
+		body         string
+		want         string
+		wantNotFound bool
+		wantError    bool
+	}{
+		{
+			name:       "Decodes base64 file content",
+			statusCode: http.StatusOK,
+			body:       `{"type":"file","encoding":"base64","content":"dHRsOiA4aAo="}`,
+			want:       "ttl: 8h\n",
+		},
+		{
+			name:         "Returns ErrNotFound for missing file",
+			statusCode:   http.StatusNotFound,
+			body:         `{"message":"Not Found"}`,
+			wantNotFound: true,
+			wantError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/repos/mikelane/previewd/contents/.previewd.yaml" {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				if ref := r.URL.Query().Get("ref"); ref != "abc123" {
+					t.Errorf("ref = %q, want abc123", ref)
+				}
+				w.WriteHeader(tt.statusCode)
+				//nolint:errcheck,gosec // Test helper - write error is acceptable (G104)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := newTestClient(t, server.URL)
+			got, err := client.GetFileContents(context.Background(), "mikelane", "previewd", ".previewd.yaml", "abc123")
+
+			if (err != nil) != tt.wantError {
+				t.Fatalf("GetFileContents() error = %v, wantError %v", err, tt.wantError)
+			}
+			if errors.Is(err, ErrNotFound) != tt.wantNotFound {
+				t.Errorf("GetFileContents() errors.Is(ErrNotFound) = %v, want %v", errors.Is(err, ErrNotFound), tt.wantNotFound)
+			}
+			if string(got) != tt.want {
+				t.Errorf("GetFileContents() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPermissionLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "Prefers role name",
+			body: `{"permission":"write","role_name":"maintain"}`,
+			want: "maintain",
+		},
+		{
+			name: "Falls back to legacy permission",
+			body: `{"permission":"read"}`,
+			want: "read",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/repos/mikelane/previewd/collaborators/octocat/permission" {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				//nolint:errcheck,gosec // Test helper - write error is acceptable (G104)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := newTestClient(t, server.URL)
+			got, err := client.GetPermissionLevel(context.Background(), "mikelane", "previewd", "octocat")
+			if err != nil {
+				t.Fatalf("GetPermissionLevel() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetPermissionLevel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateCommentAndReaction(t *testing.T) {
+	var commentBody, reaction string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		switch r.URL.Path {
+		case "/repos/mikelane/previewd/issues/42/comments":
+			commentBody = body["body"]
+		case "/repos/mikelane/previewd/issues/comments/99/reactions":
+			reaction = body["content"]
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+		//nolint:errcheck,gosec // Test helper - write error is acceptable (G104)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL)
+	if err := client.CreateComment(context.Background(), "mikelane", "previewd", 42, "hello"); err != nil {
+		t.Fatalf("CreateComment() unexpected error: %v", err)
+	}
+	if err := client.AddCommentReaction(context.Background(), "mikelane", "previewd", 99, "+1"); err != nil {
+		t.Fatalf("AddCommentReaction() unexpected error: %v", err)
+	}
+
+	if commentBody != "hello" {
+		t.Errorf("comment body = %q, want hello", commentBody)
+	}
+	if reaction != "+1" {
+		t.Errorf("reaction = %q, want +1", reaction)
+	}
+}
@@ -28,16 +28,28 @@
 // Key features:
 //   - Fetch pull request details (title, author, SHA, branches)
 //   - Update commit status with preview environment information
+//   - Upsert a pull request comment identified by a hidden marker
+//   - Reply to and react on pull request comments for ChatOps commands
+//   - Look up collaborator permission levels
 //   - Retry logic with exponential backoff
 //   - Rate limit handling
 //   - Error handling and logging
 //
 // Authentication:
 //
-// The client requires a GitHub personal access token with the following scopes:
+// The client authenticates either with a personal access token or as a GitHub App.
+//
+// A personal access token (NewClient) requires the following scopes:
 //   - repo (for accessing private repositories)
 //   - repo:status (for updating commit status)
 //
+// A GitHub App (NewAppClient) signs a short-lived JWT with the App private key,
+// looks up the installation for each repository and exchanges it for an
+// installation token. Installation tokens are cached per installation and
+// refreshed shortly before they expire, so a single client can serve every
+// repository the App is installed on. Credentials can be loaded from a Secret
+// with LoadAppCredentials.
+//
 // Example usage:
 //
 //	client := github.NewClient(token)
@@ -61,6 +73,12 @@
 //	    log.Fatal(err)
 //	}
 //
+//	// Create or edit the summary comment on the pull request
+//	err = client.CreateOrUpdateComment(ctx, "owner", "repo", 123, "<!-- previewd -->", body)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//
 // Rate Limiting:
 //
 // The GitHub API has rate limits:
@@ -68,6 +86,13 @@
 //   - 60 requests per hour for unauthenticated requests
 //
 // The client automatically handles rate limit errors by waiting and retrying.
+// When GitHub sends Retry-After or an exhausted X-RateLimit-Remaining with
+// X-RateLimit-Reset, the client waits exactly that long instead of backing off.
+// If the wait would outlive the request context, the call fails immediately.
+//
+// The remaining quota reported by every response is exported as the
+// previewd_github_rate_limit_remaining gauge, labelled by token (the personal
+// access token, the App JWT or an App installation) and rate-limit resource.
 //
 // Retry Logic:
 //
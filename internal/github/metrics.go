@@ -0,0 +1,99 @@
+// MIT License
+//
+// Copyright (c) 2025 Mike Lane
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package github
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// tokenLabelAnonymous identifies unauthenticated requests
+	tokenLabelAnonymous = "anonymous"
+
+	// tokenLabelPersonalAccessToken identifies requests made with a personal access token
+	tokenLabelPersonalAccessToken = "personal-access-token"
+
+	// tokenLabelApp identifies requests made with the GitHub App JWT
+	tokenLabelApp = "app"
+)
+
+// rateLimitRemaining tracks the remaining GitHub API quota reported by the
+// X-RateLimit-Remaining header, per token and rate-limit resource.
+var rateLimitRemaining = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "previewd_github_rate_limit_remaining",
+		Help: "Remaining GitHub API requests in the current rate-limit window, by token and resource",
+	},
+	[]string{"token", "resource"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(rateLimitRemaining)
+}
+
+// installationTokenLabel returns the metric label for an App installation token
+func installationTokenLabel(installationID int64) string {
+	return "installation-" + strconv.FormatInt(installationID, 10)
+}
+
+// rateLimitTransport records the rate-limit headers of every GitHub response
+type rateLimitTransport struct {
+	base  http.RoundTripper
+	token string
+}
+
+// newRateLimitTransport wraps base (or http.DefaultTransport when nil) so that
+// responses update the remaining-quota gauge for token.
+func newRateLimitTransport(base http.RoundTripper, token string) *rateLimitTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &rateLimitTransport{base: base, token: token}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		recordRateLimit(t.token, resp)
+	}
+	return resp, err
+}
+
+// recordRateLimit updates the remaining-quota gauge from response headers
+func recordRateLimit(token string, resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+
+	resource := resp.Header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = "core"
+	}
+	rateLimitRemaining.WithLabelValues(token, resource).Set(float64(remaining))
+}
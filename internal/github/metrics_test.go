@@ -0,0 +1,64 @@
+// MIT License
+//
+// Copyright (c) 2025 Mike Lane
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestRateLimitTransport_RecordsRemaining tests that responses update the
+// remaining-quota gauge for the client's token
+func TestRateLimitTransport_RecordsRemaining(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4321")
+		w.Header().Set("X-RateLimit-Resource", "core")
+		//nolint:errcheck,gosec // Test helper - write error is acceptable (G104)
+		w.Write([]byte(`{"number":1}`))
+	}))
+	defer server.Close()
+
+	c, err := NewClient("test-token")
+	if err != nil {
+		t.Fatalf("NewClient() unexpected error: %v", err)
+	}
+	gc := c.(*githubClient)
+	baseURL, err := gc.client.BaseURL.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatalf("failed to parse server URL: %v", err)
+	}
+	gc.client.BaseURL = baseURL
+
+	if _, err := c.GetPullRequest(context.Background(), "owner", "repo", 1); err != nil {
+		t.Fatalf("GetPullRequest() unexpected error: %v", err)
+	}
+
+	got := testutil.ToFloat64(rateLimitRemaining.WithLabelValues(tokenLabelPersonalAccessToken, "core"))
+	if got != 4321 {
+		t.Errorf("rate limit remaining gauge = %v, want 4321", got)
+	}
+}
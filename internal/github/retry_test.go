@@ -27,6 +27,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -205,6 +206,7 @@ func TestRateLimitHandling(t *testing.T) {
 		name            string
 		remainingHeader string
 		resetHeader     string
+		retryAfter      string
 		statusCode      int
 		wantWaitTime    time.Duration
 		wantRateLimited bool
@@ -233,6 +235,22 @@ func TestRateLimitHandling(t *testing.T) {
 			wantWaitTime:    60 * time.Second, // Default wait for secondary rate limit
 			wantRateLimited: true,
 		},
+		{
+			name:            "Honors Retry-After seconds",
+			retryAfter:      "30",
+			statusCode:      http.StatusTooManyRequests,
+			wantWaitTime:    30 * time.Second,
+			wantRateLimited: true,
+		},
+		{
+			name:            "Retry-After takes precedence over reset",
+			remainingHeader: "0",
+			resetHeader:     fmt.Sprintf("%d", time.Now().Add(1*time.Hour).Unix()),
+			retryAfter:      "10",
+			statusCode:      http.StatusForbidden,
+			wantWaitTime:    10 * time.Second,
+			wantRateLimited: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -245,6 +263,9 @@ func TestRateLimitHandling(t *testing.T) {
 				if tt.resetHeader != "" {
 					w.Header().Set("X-RateLimit-Reset", tt.resetHeader)
 				}
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
 				w.WriteHeader(tt.statusCode)
 				if tt.statusCode == http.StatusForbidden {
 					w.Write([]byte(`{"message":"API rate limit exceeded"}`)) //nolint:errcheck,gosec
@@ -289,6 +310,137 @@ func TestRateLimitHandling(t *testing.T) {
 	}
 }
 
+// TestRetryHonorsRetryAfter tests that the retry loop waits as long as GitHub asks
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &githubClient{
+		retryConfig: &RetryConfig{
+			MaxRetries:     3,
+			InitialBackoff: 10 * time.Millisecond,
+			MaxBackoff:     100 * time.Millisecond,
+			BackoffFactor:  2.0,
+		},
+	}
+
+	start := time.Now()
+	err := client.executeWithRetry(context.Background(), func() error {
+		resp, err := http.Get(server.URL) //nolint:noctx
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() //nolint:errcheck,gosec
+
+		if resp.StatusCode != http.StatusOK {
+			return &github.ErrorResponse{Response: resp, Message: "Too Many Requests"}
+		}
+		return nil
+	})
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Fatalf("executeWithRetry() unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 2 {
+		t.Errorf("executeWithRetry() made %d attempts, want 2", got)
+	}
+	if elapsed < 1*time.Second {
+		t.Errorf("executeWithRetry() took %v, want at least the 1s Retry-After", elapsed)
+	}
+}
+
+// TestRetryGivesUpWhenRateLimitExceedsDeadline tests that the retry loop does not
+// sleep past the context deadline waiting for a rate-limit reset
+func TestRetryGivesUpWhenRateLimitExceedsDeadline(t *testing.T) {
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(30*time.Second).Unix()))
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message":"API rate limit exceeded"}`)) //nolint:errcheck,gosec
+	}))
+	defer server.Close()
+
+	gh := github.NewClient(nil)
+	baseURL, err := gh.BaseURL.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatalf("failed to parse server URL: %v", err)
+	}
+	gh.BaseURL = baseURL
+
+	client := &githubClient{client: gh, retryConfig: defaultRetryConfig()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err = client.GetPullRequest(ctx, "owner", "repo", 1)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("GetPullRequest() expected rate limit error, got nil")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("GetPullRequest() made %d attempts, want 1", got)
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("GetPullRequest() took %v, expected to give up immediately", elapsed)
+	}
+}
+
+// TestRetryGivesUpWhenRateLimitExceedsMaxWait tests that a long rate limit reset
+// fails fast even when the context has no deadline
+func TestRetryGivesUpWhenRateLimitExceedsMaxWait(t *testing.T) {
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(1*time.Hour).Unix()))
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message":"API rate limit exceeded"}`)) //nolint:errcheck,gosec
+	}))
+	defer server.Close()
+
+	gh := github.NewClient(nil)
+	baseURL, err := gh.BaseURL.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatalf("failed to parse server URL: %v", err)
+	}
+	gh.BaseURL = baseURL
+
+	client := &githubClient{client: gh, retryConfig: defaultRetryConfig()}
+
+	start := time.Now()
+	_, err = client.GetPullRequest(context.Background(), "owner", "repo", 1)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("GetPullRequest() expected rate limit error, got nil")
+	}
+	if !strings.Contains(err.Error(), "exceeds maximum") {
+		t.Errorf("GetPullRequest() error = %v, want max wait error", err)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("GetPullRequest() made %d attempts, want 1", got)
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("GetPullRequest() took %v, expected to give up immediately", elapsed)
+	}
+}
+
 // TestContextCancellation tests that retries respect context cancellation
 func TestContextCancellation(t *testing.T) {
 	var attempts int32
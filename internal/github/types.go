@@ -24,9 +24,13 @@ package github
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrNotFound is returned when the requested GitHub resource does not exist
+var ErrNotFound = errors.New("not found")
+
 // Client interface defines the contract for interacting with GitHub API
 type Client interface {
 	// GetPullRequest retrieves metadata about a pull request
@@ -35,6 +39,23 @@ type Client interface {
 	GetPRFiles(ctx context.Context, owner, repo string, number int) ([]*File, error)
 	// UpdateCommitStatus updates the status of a commit
 	UpdateCommitStatus(ctx context.Context, owner, repo, sha string, status *Status) error
+	// CreateOrUpdateComment edits the pull request comment containing marker in place,
+	// or creates a new comment if none exists. The marker is embedded in the body.
+	CreateOrUpdateComment(ctx context.Context, owner, repo string, number int, marker, body string) error
+	// CreateDeployment creates a deployment and returns its ID
+	CreateDeployment(ctx context.Context, owner, repo string, deployment *Deployment) (int64, error)
+	// CreateDeploymentStatus records a new status for an existing deployment
+	CreateDeploymentStatus(ctx context.Context, owner, repo string, deploymentID int64, status *DeploymentStatus) error
+	// GetFileContents returns the contents of a file at the given ref.
+	// It returns ErrNotFound if the file does not exist.
+	GetFileContents(ctx context.Context, owner, repo, path, ref string) ([]byte, error)
+	// GetPermissionLevel returns a user's permission on the repository
+	// (admin, maintain, write, triage, read or none)
+	GetPermissionLevel(ctx context.Context, owner, repo, user string) (string, error)
+	// CreateComment adds a new comment to a pull request
+	CreateComment(ctx context.Context, owner, repo string, number int, body string) error
+	// AddCommentReaction reacts to a pull request comment (e.g. "+1", "eyes", "confused")
+	AddCommentReaction(ctx context.Context, owner, repo string, commentID int64, reaction string) error
 }
 
 // PullRequest represents GitHub pull request metadata
@@ -83,3 +104,36 @@ const (
 	// StatusStateFailure indicates that the status failed
 	StatusStateFailure StatusState = "failure"
 )
+
+// Deployment represents a GitHub deployment to be created for a preview environment
+type Deployment struct {
+	Ref                  string // The commit SHA or branch to deploy
+	Environment          string // Environment name (e.g., "preview-pr-123")
+	Description          string // Short description of the deployment
+	TransientEnvironment bool   // Whether the environment is destroyed when no longer needed
+}
+
+// DeploymentStatus represents a status update for a GitHub deployment
+type DeploymentStatus struct {
+	State          DeploymentState // pending, in_progress, success, failure, error, inactive
+	EnvironmentURL string          // URL for accessing the deployed environment
+	Description    string          // Short description of the status
+}
+
+// DeploymentState represents the state of a deployment status
+type DeploymentState string
+
+const (
+	// DeploymentStatePending indicates that the deployment is queued
+	DeploymentStatePending DeploymentState = "pending"
+	// DeploymentStateInProgress indicates that the deployment is in progress
+	DeploymentStateInProgress DeploymentState = "in_progress"
+	// DeploymentStateSuccess indicates that the deployment succeeded
+	DeploymentStateSuccess DeploymentState = "success"
+	// DeploymentStateFailure indicates that the deployment failed
+	DeploymentStateFailure DeploymentState = "failure"
+	// DeploymentStateError indicates that the deployment errored
+	DeploymentStateError DeploymentState = "error"
+	// DeploymentStateInactive indicates that the deployment is no longer active
+	DeploymentStateInactive DeploymentState = "inactive"
+)
@@ -0,0 +1,254 @@
+// MIT License
+//
+// Copyright (c) 2025 Mike Lane
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultBaseURL is the GitLab instance used when no base URL is configured
+	DefaultBaseURL = "https://gitlab.com"
+
+	// perPage is the page size requested from paginated endpoints (GitLab's maximum)
+	perPage = 100
+
+	// maxErrorBodyBytes bounds how much of an error response is kept in APIError
+	maxErrorBodyBytes = 1024
+)
+
+// gitlabClient implements the Client interface with the GitLab REST API
+type gitlabClient struct {
+	httpClient *http.Client
+	baseURL    *url.URL
+	token      string
+}
+
+// NewClient creates a new GitLab client for the instance at baseURL
+// (e.g. "https://gitlab.example.com") authenticating with token
+func NewClient(baseURL, token string) (Client, error) {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	parsed, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid GitLab URL %q", baseURL)
+	}
+
+	return &gitlabClient{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		baseURL:    parsed,
+		token:      token,
+	}, nil
+}
+
+// GetMRFiles returns the paths of the files changed in a merge request.
+// Renamed files are reported under their new path.
+func (c *gitlabClient) GetMRFiles(ctx context.Context, project string, iid int) ([]string, error) {
+	var paths []string
+	for page := 1; page != 0; {
+		var diffs []struct {
+			NewPath string `json:"new_path"`
+			OldPath string `json:"old_path"`
+		}
+		query := url.Values{"page": {strconv.Itoa(page)}, "per_page": {strconv.Itoa(perPage)}}
+		resp, err := c.do(ctx, http.MethodGet, projectPath(project, "merge_requests", strconv.Itoa(iid), "diffs"), query, nil, &diffs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list merge request files: %w", err)
+		}
+
+		for _, diff := range diffs {
+			path := diff.NewPath
+			if path == "" {
+				path = diff.OldPath
+			}
+			paths = append(paths, path)
+		}
+		page, _ = strconv.Atoi(resp.Header.Get("X-Next-Page"))
+	}
+	return paths, nil
+}
+
+// UpdateCommitStatus sets the status of a commit
+func (c *gitlabClient) UpdateCommitStatus(ctx context.Context, project, sha string, status *Status) error {
+	if sha == "" {
+		return fmt.Errorf("commit SHA cannot be empty")
+	}
+
+	body := map[string]string{
+		"state":       string(status.State),
+		"name":        status.Name,
+		"description": status.Description,
+	}
+	if status.TargetURL != "" {
+		body["target_url"] = status.TargetURL
+	}
+
+	if _, err := c.do(ctx, http.MethodPost, projectPath(project, "statuses", sha), nil, body, nil); err != nil {
+		return fmt.Errorf("failed to update commit status: %w", err)
+	}
+	return nil
+}
+
+// CreateOrUpdateNote edits the merge request note containing marker in place,
+// or creates a new note if none exists
+func (c *gitlabClient) CreateOrUpdateNote(ctx context.Context, project string, iid int, marker, body string) error {
+	if marker == "" {
+		return fmt.Errorf("note marker cannot be empty")
+	}
+	fullBody := map[string]string{"body": marker + "\n" + body}
+	notesPath := projectPath(project, "merge_requests", strconv.Itoa(iid), "notes")
+
+	existingID, err := c.findNoteByMarker(ctx, notesPath, marker)
+	if err != nil {
+		return err
+	}
+
+	if existingID != 0 {
+		if _, err := c.do(ctx, http.MethodPut, notesPath+"/"+strconv.FormatInt(existingID, 10), nil, fullBody, nil); err != nil {
+			return fmt.Errorf("failed to update note: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := c.do(ctx, http.MethodPost, notesPath, nil, fullBody, nil); err != nil {
+		return fmt.Errorf("failed to create note: %w", err)
+	}
+	return nil
+}
+
+// findNoteByMarker returns the ID of the first merge request note containing marker, or 0
+func (c *gitlabClient) findNoteByMarker(ctx context.Context, notesPath, marker string) (int64, error) {
+	for page := 1; page != 0; {
+		var notes []struct {
+			Body string `json:"body"`
+			ID   int64  `json:"id"`
+		}
+		query := url.Values{"page": {strconv.Itoa(page)}, "per_page": {strconv.Itoa(perPage)}}
+		resp, err := c.do(ctx, http.MethodGet, notesPath, query, nil, &notes)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list notes: %w", err)
+		}
+
+		for _, note := range notes {
+			if strings.Contains(note.Body, marker) {
+				return note.ID, nil
+			}
+		}
+		page, _ = strconv.Atoi(resp.Header.Get("X-Next-Page"))
+	}
+	return 0, nil
+}
+
+// GetFileContents returns the raw contents of a file at the given ref
+func (c *gitlabClient) GetFileContents(ctx context.Context, project, path, ref string) ([]byte, error) {
+	resp, err := c.request(ctx, http.MethodGet, projectPath(project, "repository", "files", path, "raw"), url.Values{"ref": {ref}}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// do sends a JSON request and decodes the JSON response into out (if non-nil)
+func (c *gitlabClient) do(ctx context.Context, method, path string, query url.Values, in, out any) (*http.Response, error) {
+	resp, err := c.request(ctx, method, path, query, in)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("failed to decode GitLab response: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// request sends an API request and returns the response of a successful call.
+// The caller must close the response body.
+func (c *gitlabClient) request(ctx context.Context, method, path string, query url.Values, in any) (*http.Response, error) {
+	endpoint := *c.baseURL
+	endpoint.RawPath = endpoint.Path + "/api/v4" + path
+	endpoint.Path, _ = url.PathUnescape(endpoint.RawPath)
+	endpoint.RawQuery = query.Encode()
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode GitLab request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("PRIVATE-TOKEN", c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	return nil, &APIError{Method: method, Path: path, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+}
+
+// projectPath builds an API path below /projects/:id, escaping the project
+// path and every segment as GitLab requires
+func projectPath(project string, segments ...string) string {
+	escaped := make([]string, 0, len(segments)+2)
+	escaped = append(escaped, "/projects", url.PathEscape(project))
+	for _, segment := range segments {
+		escaped = append(escaped, url.PathEscape(segment))
+	}
+	return strings.Join(escaped, "/")
+}
@@ -0,0 +1,199 @@
+// MIT License
+//
+// Copyright (c) 2025 Mike Lane
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestClient returns a client talking to handler
+func newTestClient(t *testing.T, handler http.HandlerFunc) Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL, "test-token")
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
+
+func TestNewClient_InvalidURL(t *testing.T) {
+	if _, err := NewClient("gitlab.example.com", "token"); err == nil {
+		t.Error("NewClient() accepted a URL without a scheme")
+	}
+}
+
+func TestGetMRFiles(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/group%2Fsub%2Fproject/merge_requests/7/diffs" {
+			t.Errorf("unexpected path %s", r.URL.EscapedPath())
+		}
+		if r.Header.Get("PRIVATE-TOKEN") != "test-token" {
+			t.Errorf("PRIVATE-TOKEN = %q, want test-token", r.Header.Get("PRIVATE-TOKEN"))
+		}
+
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Header().Set("X-Next-Page", "2")
+			_, _ = fmt.Fprint(w, `[{"old_path":"services/api/main.go","new_path":"services/api/main.go"}]`)
+		case "2":
+			w.Header().Set("X-Next-Page", "")
+			_, _ = fmt.Fprint(w, `[{"old_path":"services/web/old.ts","new_path":"services/web/new.ts"}]`)
+		default:
+			t.Errorf("unexpected page %q", r.URL.Query().Get("page"))
+		}
+	})
+
+	files, err := client.GetMRFiles(context.Background(), "group/sub/project", 7)
+	if err != nil {
+		t.Fatalf("GetMRFiles() error = %v", err)
+	}
+	want := []string{"services/api/main.go", "services/web/new.ts"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("GetMRFiles() = %v, want %v", files, want)
+	}
+}
+
+func TestUpdateCommitStatus(t *testing.T) {
+	var got map[string]string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.EscapedPath() != "/api/v4/projects/group%2Fproject/statuses/abc123" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.EscapedPath())
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode body: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprint(w, `{}`)
+	})
+
+	err := client.UpdateCommitStatus(context.Background(), "group/project", "abc123", &Status{
+		State:       StatusStateSuccess,
+		TargetURL:   "https://pr-7.preview.example.com",
+		Description: "Preview environment ready",
+		Name:        "previewd/preview",
+	})
+	if err != nil {
+		t.Fatalf("UpdateCommitStatus() error = %v", err)
+	}
+
+	want := map[string]string{
+		"state":       "success",
+		"name":        "previewd/preview",
+		"description": "Preview environment ready",
+		"target_url":  "https://pr-7.preview.example.com",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request body = %v, want %v", got, want)
+	}
+}
+
+func TestCreateOrUpdateNote(t *testing.T) {
+	tests := []struct {
+		name       string
+		notes      string
+		wantMethod string
+		wantPath   string
+	}{
+		{
+			name:       "creates a note when none carries the marker",
+			notes:      `[{"id":1,"body":"LGTM"}]`,
+			wantMethod: http.MethodPost,
+			wantPath:   "/api/v4/projects/group%2Fproject/merge_requests/7/notes",
+		},
+		{
+			name:       "updates the note carrying the marker",
+			notes:      `[{"id":1,"body":"LGTM"},{"id":42,"body":"<!-- marker -->\nold"}]`,
+			wantMethod: http.MethodPut,
+			wantPath:   "/api/v4/projects/group%2Fproject/merge_requests/7/notes/42",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, path, body string
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					_, _ = fmt.Fprint(w, tt.notes)
+					return
+				}
+				var note map[string]string
+				_ = json.NewDecoder(r.Body).Decode(&note)
+				method, path, body = r.Method, r.URL.EscapedPath(), note["body"]
+				_, _ = fmt.Fprint(w, `{}`)
+			})
+
+			if err := client.CreateOrUpdateNote(context.Background(), "group/project", 7, "<!-- marker -->", "new"); err != nil {
+				t.Fatalf("CreateOrUpdateNote() error = %v", err)
+			}
+			if method != tt.wantMethod || path != tt.wantPath {
+				t.Errorf("request = %s %s, want %s %s", method, path, tt.wantMethod, tt.wantPath)
+			}
+			if body != "<!-- marker -->\nnew" {
+				t.Errorf("note body = %q, want marker followed by body", body)
+			}
+		})
+	}
+}
+
+func TestGetFileContents(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ref") != "abc123" {
+			t.Errorf("ref = %q, want abc123", r.URL.Query().Get("ref"))
+		}
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Fproject/repository/files/.previewd.yaml/raw":
+			_, _ = fmt.Fprint(w, "ttl: 8h\n")
+		case "/api/v4/projects/group%2Fproject/repository/files/deploy%2Fvalues.yaml/raw":
+			http.Error(w, `{"message":"404 File Not Found"}`, http.StatusNotFound)
+		default:
+			t.Errorf("unexpected path %s", r.URL.EscapedPath())
+		}
+	})
+
+	data, err := client.GetFileContents(context.Background(), "group/project", ".previewd.yaml", "abc123")
+	if err != nil {
+		t.Fatalf("GetFileContents() error = %v", err)
+	}
+	if string(data) != "ttl: 8h\n" {
+		t.Errorf("GetFileContents() = %q, want file contents", data)
+	}
+
+	_, err = client.GetFileContents(context.Background(), "group/project", "deploy/values.yaml", "abc123")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetFileContents() error = %v, want ErrNotFound", err)
+	}
+	if !strings.Contains(err.Error(), "404") {
+		t.Errorf("GetFileContents() error = %q, want status code in message", err)
+	}
+}
@@ -0,0 +1,57 @@
+// MIT License
+//
+// Copyright (c) 2025 Mike Lane
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package gitlab provides GitLab API integration for Previewd.
+//
+// This package implements a client for the GitLab REST API (v4) with the
+// operations Previewd needs for merge request previews on gitlab.com or a
+// self-hosted GitLab instance.
+//
+// Key features:
+//   - List the files changed in a merge request
+//   - Set commit statuses (shown as external pipeline jobs on the merge request)
+//   - Upsert a merge request note identified by a hidden marker
+//   - Read repository files (e.g. .previewd.yaml) at a commit
+//
+// Projects are identified by their full path ("group/subgroup/project"), which
+// is what GitLab webhooks report as path_with_namespace.
+//
+// Authentication:
+//
+// The client authenticates with a personal, group or project access token sent
+// in the PRIVATE-TOKEN header. The token needs the api scope.
+//
+// Example usage:
+//
+//	client, err := gitlab.NewClient("https://gitlab.example.com", token)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//
+//	status := &gitlab.Status{
+//	    State:       gitlab.StatusStateSuccess,
+//	    TargetURL:   "https://pr-7.preview.example.com",
+//	    Description: "Preview environment ready",
+//	    Name:        "previewd",
+//	}
+//	err = client.UpdateCommitStatus(ctx, "group/project", sha, status)
+package gitlab
@@ -0,0 +1,91 @@
+// MIT License
+//
+// Copyright (c) 2025 Mike Lane
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrNotFound is returned when the requested GitLab resource does not exist
+var ErrNotFound = errors.New("not found")
+
+// Client interface defines the contract for interacting with the GitLab API
+type Client interface {
+	// GetMRFiles returns the paths of the files changed in a merge request
+	GetMRFiles(ctx context.Context, project string, iid int) ([]string, error)
+	// UpdateCommitStatus sets the status of a commit
+	UpdateCommitStatus(ctx context.Context, project, sha string, status *Status) error
+	// CreateOrUpdateNote edits the merge request note containing marker in place,
+	// or creates a new note if none exists. The marker is embedded in the body.
+	CreateOrUpdateNote(ctx context.Context, project string, iid int, marker, body string) error
+	// GetFileContents returns the contents of a file at the given ref.
+	// It returns ErrNotFound if the file does not exist.
+	GetFileContents(ctx context.Context, project, path, ref string) ([]byte, error)
+}
+
+// Status represents a commit status to be set on GitLab
+type Status struct {
+	State       StatusState // pending, running, success, failed, canceled
+	TargetURL   string      // URL for more details
+	Description string      // Short description of the status
+	Name        string      // A unique name for this status (GitLab's "context")
+}
+
+// StatusState represents the state of a commit status
+type StatusState string
+
+const (
+	// StatusStatePending indicates that the status is pending
+	StatusStatePending StatusState = "pending"
+	// StatusStateRunning indicates that the work is in progress
+	StatusStateRunning StatusState = "running"
+	// StatusStateSuccess indicates that the status succeeded
+	StatusStateSuccess StatusState = "success"
+	// StatusStateFailed indicates that the status failed
+	StatusStateFailed StatusState = "failed"
+	// StatusStateCanceled indicates that the work was canceled
+	StatusStateCanceled StatusState = "canceled"
+)
+
+// APIError is returned for unsuccessful GitLab API responses
+type APIError struct {
+	Method     string
+	Path       string
+	Message    string
+	StatusCode int
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gitlab: %s %s: %d %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// Unwrap makes errors.Is(err, ErrNotFound) true for 404 responses
+func (e *APIError) Unwrap() error {
+	if e.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -22,7 +22,8 @@
 //
 // # Key Features
 //
-//   - Automatic host generation (pr-{number}.{baseDomain})
+//   - Automatic host generation (pr-{number}-{repo hash}.{baseDomain}, see
+//     PreviewEnvironmentSpec.InstanceID)
 //   - TLS certificate management via cert-manager
 //   - DNS record creation via external-dns
 //   - Path-based routing to multiple services
@@ -52,6 +53,9 @@
 // The manager creates Ingress resources with the cert-manager.io/cluster-issuer annotation,
 // which triggers cert-manager to automatically provision TLS certificates. The certificate
 // is stored in a Kubernetes Secret referenced by the Ingress TLS configuration.
+// GetCertificateStatus reads the cert-manager Certificate created for that Secret and
+// reports whether it has been issued; it returns ErrCertManagerNotInstalled when the
+// cluster does not serve the Certificate API.
 //
 // # Integration with external-dns
 //
@@ -59,6 +63,9 @@
 // resources, which triggers external-dns to create DNS A records pointing to the Ingress
 // controller's LoadBalancer IP.
 //
+// With a Resolver set through WithResolver, ResolveHost looks up the preview host name,
+// showing whether external-dns has published its record.
+//
 // # Path-based Routing
 //
 // Services are exposed through path-based routing:
@@ -19,13 +19,21 @@ package ingress
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 
 	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -52,10 +60,42 @@ const (
 	managedByLabel = "previewd"
 )
 
+// CertificateGVK is the cert-manager Certificate kind. cert-manager's ingress-shim
+// creates a Certificate named after each TLS secret of an annotated Ingress.
+var CertificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+var (
+	// ErrCertManagerNotInstalled is returned by GetCertificateStatus when the
+	// cluster does not serve the cert-manager Certificate API
+	ErrCertManagerNotInstalled = errors.New("cert-manager is not installed")
+
+	// ErrNoResolver is returned by ResolveHost when the manager has no Resolver
+	ErrNoResolver = errors.New("no DNS resolver configured")
+)
+
+// Resolver looks up the addresses of a host name. *net.Resolver implements it.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// CertificateStatus describes whether cert-manager has issued the TLS
+// certificate of a preview environment
+type CertificateStatus struct {
+	// Name is the name of the Certificate and of the TLS secret it fills
+	Name string
+	// Issued is true once the Certificate is Ready
+	Issued bool
+	// Reason and Message are taken from the Certificate's Ready condition
+	Reason  string
+	Message string
+}
+
 // Manager handles Ingress lifecycle for preview environments
 type Manager struct {
 	client     client.Client
 	scheme     *runtime.Scheme
+	resolver   Resolver
+	recorder   record.EventRecorder
 	baseDomain string
 	certIssuer string
 }
@@ -70,6 +110,20 @@ func NewManager(c client.Client, scheme *runtime.Scheme, baseDomain, certIssuer
 	}
 }
 
+// WithResolver sets the resolver ResolveHost uses to check that the preview
+// host name has been published
+func (m *Manager) WithResolver(resolver Resolver) *Manager {
+	m.resolver = resolver
+	return m
+}
+
+// WithRecorder sets the recorder used to record Ingress creation as an Event on
+// the PreviewEnvironment
+func (m *Manager) WithRecorder(recorder record.EventRecorder) *Manager {
+	m.recorder = recorder
+	return m
+}
+
 // EnsureIngress creates or updates an Ingress resource for the preview environment
 // with TLS certificates (cert-manager) and DNS routing (external-dns).
 func (m *Manager) EnsureIngress(ctx context.Context, preview *previewv1alpha1.PreviewEnvironment, namespace string) error {
@@ -91,13 +145,14 @@ func (m *Manager) EnsureIngress(ctx context.Context, preview *previewv1alpha1.Pr
 		},
 	}
 
-	_, err := controllerutil.CreateOrUpdate(ctx, m.client, ingress, func() error {
+	result, err := controllerutil.CreateOrUpdate(ctx, m.client, ingress, func() error {
 		// Set labels
 		if ingress.Labels == nil {
 			ingress.Labels = make(map[string]string)
 		}
-		ingress.Labels["preview.previewd.io/pr"] = fmt.Sprintf("%d", preview.Spec.PRNumber)
-		ingress.Labels["preview.previewd.io/repository"] = preview.Spec.Repository
+		key, value := preview.Spec.IdentityLabel()
+		ingress.Labels[key] = value
+		ingress.Labels[previewv1alpha1.RepositoryLabel] = previewv1alpha1.RepositoryHash(preview.Spec.Repository)
 		ingress.Labels["preview.previewd.io/managed-by"] = managedByLabel
 
 		// Set annotations for cert-manager, external-dns, and nginx
@@ -115,11 +170,10 @@ func (m *Manager) EnsureIngress(ctx context.Context, preview *previewv1alpha1.Pr
 		ingress.Annotations["preview.previewd.io/owner-uid"] = string(preview.UID)
 
 		// Build TLS configuration
-		tlsSecretName := fmt.Sprintf("pr-%d-tls", preview.Spec.PRNumber)
 		ingress.Spec.TLS = []networkingv1.IngressTLS{
 			{
 				Hosts:      []string{host},
-				SecretName: tlsSecretName,
+				SecretName: tlsSecretName(preview),
 			},
 		}
 
@@ -127,16 +181,16 @@ func (m *Manager) EnsureIngress(ctx context.Context, preview *previewv1alpha1.Pr
 		pathType := networkingv1.PathTypePrefix
 		var paths []networkingv1.HTTPIngressPath
 
-		// Sort services: frontend (/) must come LAST for PathTypePrefix to work correctly.
+		// Sort services: the root path (/) must come LAST for PathTypePrefix to work correctly.
 		// With PathTypePrefix, "/" matches ALL requests, so specific paths like "/auth" must come first.
 		sortedServices := make([]string, len(preview.Spec.Services))
 		copy(sortedServices, preview.Spec.Services)
 		sort.Slice(sortedServices, func(i, j int) bool {
-			// frontend (/) always comes last
-			if sortedServices[i] == FrontendServiceName {
+			// The root path always comes last
+			if generatePathForService(preview, sortedServices[i]) == "/" {
 				return false
 			}
-			if sortedServices[j] == FrontendServiceName {
+			if generatePathForService(preview, sortedServices[j]) == "/" {
 				return true
 			}
 			// All other services sorted alphabetically
@@ -144,8 +198,8 @@ func (m *Manager) EnsureIngress(ctx context.Context, preview *previewv1alpha1.Pr
 		})
 
 		for _, service := range sortedServices {
-			serviceName := generateServiceName(preview.Spec.PRNumber, service)
-			path := generatePathForService(service)
+			serviceName := generateServiceName(preview.Spec.PreviewID(), service)
+			path := generatePathForService(preview, service)
 
 			paths = append(paths, networkingv1.HTTPIngressPath{
 				Path:     path,
@@ -176,30 +230,103 @@ func (m *Manager) EnsureIngress(ctx context.Context, preview *previewv1alpha1.Pr
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to ensure ingress for preview %s/%s (PR #%d): %w",
-			preview.Namespace, preview.Name, preview.Spec.PRNumber, err)
+		return fmt.Errorf("failed to ensure ingress for preview %s/%s (%s): %w",
+			preview.Namespace, preview.Name, preview.Spec.PreviewID(), err)
 	}
 
-	// TODO(#2): Enhance finalizer to explicitly cleanup cross-namespace resources.
-	// Currently, Ingress is cleaned up when the namespace is deleted during
-	// PreviewEnvironment removal. Consider adding explicit cleanup for safety.
+	if m.recorder != nil && result == controllerutil.OperationResultCreated {
+		m.recorder.Eventf(preview, corev1.EventTypeNormal, "IngressCreated",
+			"Created ingress %s/%s for %s", namespace, IngressName, m.GetIngressHost(preview))
+	}
+
+	// The Ingress lives in the preview namespace, so it is removed together with
+	// the namespace when the PreviewEnvironment finalizer runs.
 
 	return nil
 }
 
 // GetIngressHost returns the hostname for the preview environment ingress
 func (m *Manager) GetIngressHost(preview *previewv1alpha1.PreviewEnvironment) string {
-	return fmt.Sprintf("pr-%d.%s", preview.Spec.PRNumber, m.baseDomain)
+	return fmt.Sprintf("%s.%s", preview.Spec.InstanceID(), m.baseDomain)
+}
+
+// GetServiceURL returns the public URL a service is served at, using the same
+// path the ingress routes to it
+func (m *Manager) GetServiceURL(preview *previewv1alpha1.PreviewEnvironment, service string) string {
+	return fmt.Sprintf("https://%s%s", m.GetIngressHost(preview), generatePathForService(preview, service))
 }
 
-// generateServiceName generates the service name for a given PR number and service
-func generateServiceName(prNumber int, service string) string {
-	return fmt.Sprintf("preview-pr-%d-%s", prNumber, service)
+// GetCertificateStatus reports whether cert-manager has issued the TLS
+// certificate of the preview's Ingress in the given namespace. A Certificate
+// that has not been created yet is reported as not issued.
+func (m *Manager) GetCertificateStatus(ctx context.Context, preview *previewv1alpha1.PreviewEnvironment, namespace string) (*CertificateStatus, error) {
+	name := tlsSecretName(preview)
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(CertificateGVK)
+
+	err := m.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, certificate)
+	switch {
+	case meta.IsNoMatchError(err):
+		return nil, ErrCertManagerNotInstalled
+	case apierrors.IsNotFound(err):
+		return &CertificateStatus{
+			Name:    name,
+			Reason:  "CertificateNotCreated",
+			Message: fmt.Sprintf("cert-manager has not created Certificate %s yet", name),
+		}, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to get Certificate %s/%s: %w", namespace, name, err)
+	}
+
+	status := &CertificateStatus{
+		Name:    name,
+		Reason:  "Pending",
+		Message: fmt.Sprintf("cert-manager has not reported the status of Certificate %s yet", name),
+	}
+	conditions, _, _ := unstructured.NestedSlice(certificate.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Ready" {
+			continue
+		}
+		status.Issued = condition["status"] == string(metav1.ConditionTrue)
+		if reason, ok := condition["reason"].(string); ok && reason != "" {
+			status.Reason = reason
+		}
+		if message, ok := condition["message"].(string); ok && message != "" {
+			status.Message = message
+		}
+	}
+	return status, nil
 }
 
-// generatePathForService generates the path for a service
-// frontend gets "/", other services get "/<service-name>"
-func generatePathForService(service string) string {
+// ResolveHost looks up the addresses the preview host name resolves to, showing
+// whether external-dns has published its record
+func (m *Manager) ResolveHost(ctx context.Context, preview *previewv1alpha1.PreviewEnvironment) ([]string, error) {
+	if m.resolver == nil {
+		return nil, ErrNoResolver
+	}
+	return m.resolver.LookupHost(ctx, m.GetIngressHost(preview))
+}
+
+// tlsSecretName returns the name of the secret holding the preview's TLS certificate
+func tlsSecretName(preview *previewv1alpha1.PreviewEnvironment) string {
+	return preview.Spec.InstanceID() + "-tls"
+}
+
+// generateServiceName generates the service name for a given preview ID (see
+// PreviewEnvironmentSpec.PreviewID) and service
+func generateServiceName(previewID string, service string) string {
+	return fmt.Sprintf("preview-%s-%s", previewID, service)
+}
+
+// generatePathForService generates the path for a service.
+// Spec.IngressPaths takes precedence; otherwise frontend gets "/" and other
+// services get "/<service-name>".
+func generatePathForService(preview *previewv1alpha1.PreviewEnvironment, service string) string {
+	if path, ok := preview.Spec.IngressPaths[service]; ok && path != "" {
+		return path
+	}
 	if service == FrontendServiceName {
 		return "/"
 	}
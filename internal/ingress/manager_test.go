@@ -16,12 +16,14 @@ package ingress
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -113,7 +115,7 @@ func TestManager_EnsureIngress_HostAndTLS(t *testing.T) {
 	}
 
 	// Verify host
-	expectedHost := "pr-123.preview.example.com"
+	expectedHost := "pr-123-65e817ee.preview.example.com"
 	if len(ingress.Spec.Rules) == 0 {
 		t.Fatal("ingress has no rules")
 	}
@@ -125,8 +127,8 @@ func TestManager_EnsureIngress_HostAndTLS(t *testing.T) {
 	if len(ingress.Spec.TLS) == 0 {
 		t.Fatal("ingress has no TLS configuration")
 	}
-	if ingress.Spec.TLS[0].SecretName != "pr-123-tls" {
-		t.Errorf("TLS secret = %v, want %v", ingress.Spec.TLS[0].SecretName, "pr-123-tls")
+	if ingress.Spec.TLS[0].SecretName != "pr-123-65e817ee-tls" {
+		t.Errorf("TLS secret = %v, want %v", ingress.Spec.TLS[0].SecretName, "pr-123-65e817ee-tls")
 	}
 	if len(ingress.Spec.TLS[0].Hosts) == 0 || ingress.Spec.TLS[0].Hosts[0] != expectedHost {
 		t.Errorf("TLS host = %v, want %v", ingress.Spec.TLS[0].Hosts, []string{expectedHost})
@@ -206,7 +208,7 @@ func TestManager_EnsureIngress_ExternalDNSAnnotation(t *testing.T) {
 	}
 
 	// Verify external-dns annotation
-	expectedHost := "pr-789.preview.example.com"
+	expectedHost := "pr-789-65e817ee.preview.example.com"
 	dnsAnnotation := "external-dns.alpha.kubernetes.io/hostname"
 	if ingress.Annotations[dnsAnnotation] != expectedHost {
 		t.Errorf("external-dns annotation = %v, want %v",
@@ -442,19 +444,21 @@ func TestManager_GetIngressHost(t *testing.T) {
 			name: "generates correct host for PR 123",
 			preview: &previewv1alpha1.PreviewEnvironment{
 				Spec: previewv1alpha1.PreviewEnvironmentSpec{
-					PRNumber: 123,
+					Repository: "owner/repo",
+					PRNumber:   123,
 				},
 			},
-			wantHost: "pr-123.preview.example.com",
+			wantHost: "pr-123-65e817ee.preview.example.com",
 		},
 		{
 			name: "generates correct host for PR 9999",
 			preview: &previewv1alpha1.PreviewEnvironment{
 				Spec: previewv1alpha1.PreviewEnvironmentSpec{
-					PRNumber: 9999,
+					Repository: "owner/repo",
+					PRNumber:   9999,
 				},
 			},
-			wantHost: "pr-9999.preview.example.com",
+			wantHost: "pr-9999-65e817ee.preview.example.com",
 		},
 	}
 
@@ -472,6 +476,140 @@ func TestManager_GetIngressHost(t *testing.T) {
 	}
 }
 
+func TestManager_GetServiceURL(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository:   "owner/repo",
+			PRNumber:     42,
+			IngressPaths: map[string]string{"docs": "/documentation"},
+		},
+	}
+
+	tests := []struct {
+		service string
+		wantURL string
+	}{
+		{service: "frontend", wantURL: "https://pr-42-65e817ee.preview.example.com/"},
+		{service: "api", wantURL: "https://pr-42-65e817ee.preview.example.com/api"},
+		{service: "docs", wantURL: "https://pr-42-65e817ee.preview.example.com/documentation"},
+	}
+
+	scheme := runtime.NewScheme()
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	m := NewManager(c, scheme, "preview.example.com", "letsencrypt-prod")
+
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			if got := m.GetServiceURL(preview, tt.service); got != tt.wantURL {
+				t.Errorf("GetServiceURL() = %v, want %v", got, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestManager_GetCertificateStatus(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{Repository: "owner/repo", PRNumber: 42},
+	}
+	certificate := func(conditions ...interface{}) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"status": map[string]interface{}{"conditions": conditions},
+		}}
+		u.SetGroupVersionKind(CertificateGVK)
+		u.SetName("pr-42-65e817ee-tls")
+		u.SetNamespace("preview-ns")
+		return u
+	}
+
+	tests := []struct {
+		certificate *unstructured.Unstructured
+		name        string
+		wantReason  string
+		wantIssued  bool
+	}{
+		{
+			name:       "not created yet",
+			wantReason: "CertificateNotCreated",
+		},
+		{
+			name:        "no Ready condition yet",
+			certificate: certificate(),
+			wantReason:  "Pending",
+		},
+		{
+			name: "issuing",
+			certificate: certificate(map[string]interface{}{
+				"type": "Ready", "status": "False", "reason": "DoesNotExist", "message": "Issuing certificate",
+			}),
+			wantReason: "DoesNotExist",
+		},
+		{
+			name: "issued",
+			certificate: certificate(map[string]interface{}{
+				"type": "Ready", "status": "True", "reason": "Ready", "message": "Certificate is up to date",
+			}),
+			wantReason: "Ready",
+			wantIssued: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			if tt.certificate != nil {
+				builder = builder.WithObjects(tt.certificate)
+			}
+			m := NewManager(builder.Build(), scheme, "preview.example.com", "letsencrypt-prod")
+
+			status, err := m.GetCertificateStatus(context.Background(), preview, "preview-ns")
+			if err != nil {
+				t.Fatalf("GetCertificateStatus() error = %v", err)
+			}
+			if status.Name != "pr-42-65e817ee-tls" || status.Issued != tt.wantIssued || status.Reason != tt.wantReason {
+				t.Errorf("GetCertificateStatus() = %+v, want issued=%v reason=%s", status, tt.wantIssued, tt.wantReason)
+			}
+		})
+	}
+}
+
+// fakeResolver resolves every host to the same addresses
+type fakeResolver struct {
+	err   error
+	addrs []string
+	hosts []string
+}
+
+func (f *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	f.hosts = append(f.hosts, host)
+	return f.addrs, f.err
+}
+
+func TestManager_ResolveHost(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{Repository: "owner/repo", PRNumber: 42},
+	}
+	scheme := runtime.NewScheme()
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	m := NewManager(c, scheme, "preview.example.com", "letsencrypt-prod")
+	if _, err := m.ResolveHost(context.Background(), preview); !errors.Is(err, ErrNoResolver) {
+		t.Errorf("ResolveHost() without resolver error = %v, want ErrNoResolver", err)
+	}
+
+	resolver := &fakeResolver{addrs: []string{"203.0.113.10"}}
+	addrs, err := m.WithResolver(resolver).ResolveHost(context.Background(), preview)
+	if err != nil {
+		t.Fatalf("ResolveHost() error = %v", err)
+	}
+	if len(addrs) != 1 || addrs[0] != "203.0.113.10" {
+		t.Errorf("ResolveHost() = %v, want [203.0.113.10]", addrs)
+	}
+	if len(resolver.hosts) != 1 || resolver.hosts[0] != "pr-42-65e817ee.preview.example.com" {
+		t.Errorf("resolved hosts = %v, want [pr-42-65e817ee.preview.example.com]", resolver.hosts)
+	}
+}
+
 func TestManager_EnsureIngress_PathType(t *testing.T) {
 	preview := &previewv1alpha1.PreviewEnvironment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -602,30 +740,35 @@ func TestManager_EnsureIngress_ServicePort(t *testing.T) {
 
 func TestGenerateServiceName(t *testing.T) {
 	tests := []struct {
-		prNumber int
-		service  string
-		want     string
+		previewID string
+		service   string
+		want      string
 	}{
 		{
-			prNumber: 123,
-			service:  "auth",
-			want:     "preview-pr-123-auth",
+			previewID: "pr-123",
+			service:   "auth",
+			want:      "preview-pr-123-auth",
 		},
 		{
-			prNumber: 456,
-			service:  "api",
-			want:     "preview-pr-456-api",
+			previewID: "pr-456",
+			service:   "api",
+			want:      "preview-pr-456-api",
 		},
 		{
-			prNumber: 789,
-			service:  "frontend",
-			want:     "preview-pr-789-frontend",
+			previewID: "pr-789",
+			service:   "frontend",
+			want:      "preview-pr-789-frontend",
+		},
+		{
+			previewID: "br-main-0d6e40",
+			service:   "api",
+			want:      "preview-br-main-0d6e40-api",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.want, func(t *testing.T) {
-			got := generateServiceName(tt.prNumber, tt.service)
+			got := generateServiceName(tt.previewID, tt.service)
 			if got != tt.want {
 				t.Errorf("generateServiceName() = %v, want %v", got, tt.want)
 			}
@@ -635,6 +778,7 @@ func TestGenerateServiceName(t *testing.T) {
 
 func TestGeneratePathForService(t *testing.T) {
 	tests := []struct {
+		paths    map[string]string
 		service  string
 		name     string
 		wantPath string
@@ -659,11 +803,26 @@ func TestGeneratePathForService(t *testing.T) {
 			service:  "users",
 			wantPath: "/users",
 		},
+		{
+			name:     "configured path overrides default",
+			paths:    map[string]string{"api": "/v1/api"},
+			service:  "api",
+			wantPath: "/v1/api",
+		},
+		{
+			name:     "configured root path for non-frontend service",
+			paths:    map[string]string{"web": "/"},
+			service:  "web",
+			wantPath: "/",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := generatePathForService(tt.service)
+			preview := &previewv1alpha1.PreviewEnvironment{
+				Spec: previewv1alpha1.PreviewEnvironmentSpec{IngressPaths: tt.paths},
+			}
+			got := generatePathForService(preview, tt.service)
 			if got != tt.wantPath {
 				t.Errorf("generatePathForService() = %v, want %v", got, tt.wantPath)
 			}
@@ -18,9 +18,7 @@ package namespace
 
 import (
 	"context"
-	"crypto/sha256"
 	"fmt"
-	"strings"
 
 	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
@@ -31,6 +29,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -43,8 +42,9 @@ const (
 
 // Manager handles namespace lifecycle for preview environments
 type Manager struct {
-	client client.Client
-	scheme *runtime.Scheme
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
 }
 
 // NewManager creates a new namespace manager
@@ -55,11 +55,18 @@ func NewManager(c client.Client, scheme *runtime.Scheme) *Manager {
 	}
 }
 
+// WithRecorder sets the recorder used to record namespace creation and deletion
+// as Events on the PreviewEnvironment
+func (m *Manager) WithRecorder(recorder record.EventRecorder) *Manager {
+	m.recorder = recorder
+	return m
+}
+
 // EnsureNamespace creates or updates a namespace for the preview environment
 // with appropriate labels. Note: We don't set owner references on namespaces
 // as cross-namespace owner references are not allowed in Kubernetes.
 func (m *Manager) EnsureNamespace(ctx context.Context, preview *previewv1alpha1.PreviewEnvironment) error {
-	nsName := generateNamespaceName(preview.Spec.PRNumber, preview.Spec.Repository)
+	nsName := generateNamespaceName(preview.Spec.PreviewID(), preview.Spec.Repository)
 
 	ns := &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
@@ -67,13 +74,14 @@ func (m *Manager) EnsureNamespace(ctx context.Context, preview *previewv1alpha1.
 		},
 	}
 
-	_, err := controllerutil.CreateOrUpdate(ctx, m.client, ns, func() error {
+	result, err := controllerutil.CreateOrUpdate(ctx, m.client, ns, func() error {
 		// Set labels
 		if ns.Labels == nil {
 			ns.Labels = make(map[string]string)
 		}
-		ns.Labels["preview.previewd.io/pr"] = fmt.Sprintf("%d", preview.Spec.PRNumber)
-		ns.Labels["preview.previewd.io/repository"] = strings.ReplaceAll(preview.Spec.Repository, "/", "-")
+		key, value := preview.Spec.IdentityLabel()
+		ns.Labels[key] = value
+		ns.Labels[previewv1alpha1.RepositoryLabel] = previewv1alpha1.RepositoryHash(preview.Spec.Repository)
 		ns.Labels["preview.previewd.io/managed-by"] = managedByLabel
 
 		// Add annotations to track the owner (informational only)
@@ -91,6 +99,10 @@ func (m *Manager) EnsureNamespace(ctx context.Context, preview *previewv1alpha1.
 		return fmt.Errorf("failed to ensure namespace: %w", err)
 	}
 
+	if m.recorder != nil && result == controllerutil.OperationResultCreated {
+		m.recorder.Eventf(preview, corev1.EventTypeNormal, "NamespaceCreated", "Created namespace %s", nsName)
+	}
+
 	return nil
 }
 
@@ -122,7 +134,8 @@ func (m *Manager) EnsureResourceQuota(ctx context.Context, preview *previewv1alp
 		if quota.Labels == nil {
 			quota.Labels = make(map[string]string)
 		}
-		quota.Labels["preview.previewd.io/pr"] = fmt.Sprintf("%d", preview.Spec.PRNumber)
+		key, value := preview.Spec.IdentityLabel()
+		quota.Labels[key] = value
 		quota.Labels["preview.previewd.io/managed-by"] = managedByLabel
 
 		return nil
@@ -186,7 +199,8 @@ func (m *Manager) ensureDefaultDenyPolicy(ctx context.Context, preview *previewv
 		if policy.Labels == nil {
 			policy.Labels = make(map[string]string)
 		}
-		policy.Labels["preview.previewd.io/pr"] = fmt.Sprintf("%d", preview.Spec.PRNumber)
+		key, value := preview.Spec.IdentityLabel()
+		policy.Labels[key] = value
 		policy.Labels["preview.previewd.io/managed-by"] = managedByLabel
 
 		return nil
@@ -240,7 +254,8 @@ func (m *Manager) ensureAllowIngressPolicy(ctx context.Context, preview *preview
 		if policy.Labels == nil {
 			policy.Labels = make(map[string]string)
 		}
-		policy.Labels["preview.previewd.io/pr"] = fmt.Sprintf("%d", preview.Spec.PRNumber)
+		key, value := preview.Spec.IdentityLabel()
+		policy.Labels[key] = value
 		policy.Labels["preview.previewd.io/managed-by"] = managedByLabel
 
 		return nil
@@ -331,7 +346,8 @@ func (m *Manager) ensureAllowEgressPolicy(ctx context.Context, preview *previewv
 		if policy.Labels == nil {
 			policy.Labels = make(map[string]string)
 		}
-		policy.Labels["preview.previewd.io/pr"] = fmt.Sprintf("%d", preview.Spec.PRNumber)
+		key, value := preview.Spec.IdentityLabel()
+		policy.Labels[key] = value
 		policy.Labels["preview.previewd.io/managed-by"] = managedByLabel
 
 		return nil
@@ -343,7 +359,7 @@ func (m *Manager) ensureAllowEgressPolicy(ctx context.Context, preview *previewv
 // Cleanup removes namespace and associated resources when a preview environment is deleted.
 // The actual deletion is handled by Kubernetes garbage collection through owner references.
 func (m *Manager) Cleanup(ctx context.Context, preview *previewv1alpha1.PreviewEnvironment) error {
-	nsName := generateNamespaceName(preview.Spec.PRNumber, preview.Spec.Repository)
+	nsName := generateNamespaceName(preview.Spec.PreviewID(), preview.Spec.Repository)
 
 	ns := &corev1.Namespace{}
 	err := m.client.Get(ctx, types.NamespacedName{Name: nsName}, ns)
@@ -360,13 +376,35 @@ func (m *Manager) Cleanup(ctx context.Context, preview *previewv1alpha1.PreviewE
 		return fmt.Errorf("failed to delete namespace: %w", err)
 	}
 
+	// Cleanup is repeated until the namespace is gone; record the deletion once
+	if m.recorder != nil && ns.DeletionTimestamp.IsZero() {
+		m.recorder.Eventf(preview, corev1.EventTypeNormal, "DeletingNamespace", "Deleting namespace %s", nsName)
+	}
+
 	return nil
 }
 
+// IsNamespaceDeleted reports whether the preview environment's namespace is fully gone.
+// A namespace that still exists (for example while Terminating) is reported as not deleted.
+func (m *Manager) IsNamespaceDeleted(ctx context.Context, preview *previewv1alpha1.PreviewEnvironment) (bool, error) {
+	nsName := generateNamespaceName(preview.Spec.PreviewID(), preview.Spec.Repository)
+
+	ns := &corev1.Namespace{}
+	err := m.client.Get(ctx, types.NamespacedName{Name: nsName}, ns)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to get namespace: %w", err)
+	}
+
+	return false, nil
+}
+
 // GetNamespaceName returns the namespace name for a preview environment
 // Returns an error if the generated namespace name exceeds Kubernetes' 63-character limit
 func (m *Manager) GetNamespaceName(preview *previewv1alpha1.PreviewEnvironment) (string, error) {
-	nsName := generateNamespaceName(preview.Spec.PRNumber, preview.Spec.Repository)
+	nsName := generateNamespaceName(preview.Spec.PreviewID(), preview.Spec.Repository)
 
 	if len(nsName) > maxNamespaceLength {
 		return "", fmt.Errorf("generated namespace name %q exceeds maximum length of %d characters (got %d)",
@@ -376,16 +414,13 @@ func (m *Manager) GetNamespaceName(preview *previewv1alpha1.PreviewEnvironment)
 	return nsName, nil
 }
 
-// generateNamespaceName generates a deterministic namespace name from PR number and repository
-func generateNamespaceName(prNumber int, repository string) string {
-	// Create a hash of the repository to ensure uniqueness
-	h := sha256.New()
-	h.Write([]byte(repository))
-	hash := fmt.Sprintf("%x", h.Sum(nil))[:8]
-
-	// Format: preview-pr-{number}-{hash}
+// generateNamespaceName generates a deterministic namespace name from the
+// preview's pull request or branch ID (see PreviewEnvironmentSpec.PreviewID)
+// and repository
+func generateNamespaceName(previewID string, repository string) string {
+	// Format: preview-pr-{number}-{hash} or preview-br-{branch}-{hash}
 	// This ensures unique namespaces even if multiple repositories use same PR numbers
-	return fmt.Sprintf("preview-pr-%d-%s", prNumber, hash)
+	return fmt.Sprintf("preview-%s-%s", previewID, previewv1alpha1.RepositoryHash(repository))
 }
 
 // getIngressPort returns the ingress port from the preview environment spec,
@@ -53,7 +53,7 @@ func TestManager_EnsureNamespace(t *testing.T) {
 			},
 			validateFn: func(t *testing.T, c client.Client, preview *previewv1alpha1.PreviewEnvironment) {
 				ns := &corev1.Namespace{}
-				nsName := generateNamespaceName(preview.Spec.PRNumber, preview.Spec.Repository)
+				nsName := generateNamespaceName(preview.Spec.PreviewID(), preview.Spec.Repository)
 				err := c.Get(context.Background(), types.NamespacedName{Name: nsName}, ns)
 				if err != nil {
 					t.Errorf("failed to get namespace: %v", err)
@@ -64,8 +64,8 @@ func TestManager_EnsureNamespace(t *testing.T) {
 				if ns.Labels["preview.previewd.io/pr"] != "123" {
 					t.Errorf("expected PR label to be '123', got %s", ns.Labels["preview.previewd.io/pr"])
 				}
-				if ns.Labels["preview.previewd.io/repository"] != "owner-repo" {
-					t.Errorf("expected repository label to be 'owner-repo', got %s", ns.Labels["preview.previewd.io/repository"])
+				if ns.Labels["preview.previewd.io/repository"] != "65e817ee" {
+					t.Errorf("expected repository label to be the repository hash '65e817ee', got %s", ns.Labels["preview.previewd.io/repository"])
 				}
 				if ns.Labels["preview.previewd.io/managed-by"] != managedByLabel {
 					t.Errorf("expected managed-by label to be %q, got %s", managedByLabel, ns.Labels["preview.previewd.io/managed-by"])
@@ -98,7 +98,7 @@ func TestManager_EnsureNamespace(t *testing.T) {
 					Name: "preview-pr-456-65e817ee",
 					Labels: map[string]string{
 						"preview.previewd.io/pr":         "456",
-						"preview.previewd.io/repository": "owner-repo",
+						"preview.previewd.io/repository": "65e817ee",
 						"preview.previewd.io/managed-by": "previewd",
 					},
 				},
@@ -106,7 +106,7 @@ func TestManager_EnsureNamespace(t *testing.T) {
 			validateFn: func(t *testing.T, c client.Client, preview *previewv1alpha1.PreviewEnvironment) {
 				// Should not error and namespace should still exist
 				ns := &corev1.Namespace{}
-				nsName := generateNamespaceName(preview.Spec.PRNumber, preview.Spec.Repository)
+				nsName := generateNamespaceName(preview.Spec.PreviewID(), preview.Spec.Repository)
 				err := c.Get(context.Background(), types.NamespacedName{Name: nsName}, ns)
 				if err != nil {
 					t.Errorf("namespace should exist: %v", err)
@@ -453,7 +453,7 @@ func TestManager_Cleanup(t *testing.T) {
 				// Namespace should be deleted or have deletion timestamp
 				ns := &corev1.Namespace{}
 				err := c.Get(context.Background(), types.NamespacedName{
-					Name: generateNamespaceName(preview.Spec.PRNumber, preview.Spec.Repository),
+					Name: generateNamespaceName(preview.Spec.PreviewID(), preview.Spec.Repository),
 				}, ns)
 				if err == nil && ns.DeletionTimestamp == nil {
 					// In a real cluster, the namespace would be deleted by GC
@@ -499,6 +499,64 @@ func TestManager_Cleanup(t *testing.T) {
 	}
 }
 
+func TestManager_IsNamespaceDeleted(t *testing.T) {
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr-321",
+			Namespace: "previewd-system",
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			PRNumber:   321,
+			Repository: "owner/repo",
+		},
+	}
+
+	tests := []struct {
+		existingNS *corev1.Namespace
+		name       string
+		want       bool
+	}{
+		{
+			name: "returns true when namespace does not exist",
+			want: true,
+		},
+		{
+			name: "returns false while namespace is terminating",
+			existingNS: &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              generateNamespaceName("pr-321", "owner/repo"),
+					DeletionTimestamp: &metav1.Time{},
+					Finalizers:        []string{"kubernetes"},
+				},
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := corev1.AddToScheme(scheme); err != nil {
+				t.Fatalf("failed to add core scheme: %v", err)
+			}
+
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			if tt.existingNS != nil {
+				builder = builder.WithObjects(tt.existingNS)
+			}
+			m := NewManager(builder.Build(), scheme)
+
+			got, err := m.IsNamespaceDeleted(context.Background(), preview)
+			if err != nil {
+				t.Fatalf("IsNamespaceDeleted() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsNamespaceDeleted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 // Helper function to validate ingress port in network policy
 func validateIngressPort(t *testing.T, c client.Client, namespace string, expectedPort int32) {
 	t.Helper()
@@ -887,30 +945,35 @@ func TestManager_GetNamespaceName_LengthValidation(t *testing.T) {
 // Helper function tests
 func TestGenerateNamespaceName(t *testing.T) {
 	tests := []struct {
-		repo     string
-		want     string
-		prNumber int
+		previewID string
+		repo      string
+		want      string
 	}{
 		{
-			prNumber: 123,
-			repo:     "owner/repo",
-			want:     "preview-pr-123-65e817ee",
+			previewID: "pr-123",
+			repo:      "owner/repo",
+			want:      "preview-pr-123-65e817ee",
+		},
+		{
+			previewID: "pr-456",
+			repo:      "myorg/myrepo",
+			want:      "preview-pr-456-71b1f54a",
 		},
 		{
-			prNumber: 456,
-			repo:     "myorg/myrepo",
-			want:     "preview-pr-456-71b1f54a",
+			previewID: "pr-789",
+			repo:      "test/test-repo",
+			want:      "preview-pr-789-3741d56e",
 		},
 		{
-			prNumber: 789,
-			repo:     "test/test-repo",
-			want:     "preview-pr-789-3741d56e",
+			previewID: "br-release-1-2-f00d42",
+			repo:      "owner/repo",
+			want:      "preview-br-release-1-2-f00d42-65e817ee",
 		},
 	}
 
 	for _, tt := range tests {
-		t.Run(fmt.Sprintf("PR-%d-%s", tt.prNumber, tt.repo), func(t *testing.T) {
-			got := generateNamespaceName(tt.prNumber, tt.repo)
+		t.Run(fmt.Sprintf("%s-%s", tt.previewID, tt.repo), func(t *testing.T) {
+			got := generateNamespaceName(tt.previewID, tt.repo)
 			if got != tt.want {
 				t.Errorf("generateNamespaceName() = %v, want %v", got, tt.want)
 			}
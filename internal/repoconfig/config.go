@@ -0,0 +1,187 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package repoconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/github"
+	"github.com/mikelane/previewd/internal/gitlab"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// FileName is the configuration file read from the repository root
+const FileName = ".previewd.yaml"
+
+// ErrInvalidConfig is wrapped by every parse or validation error
+var ErrInvalidConfig = errors.New("invalid " + FileName)
+
+// Config is the schema of .previewd.yaml
+type Config struct {
+	// ResourceQuota defaults Spec.ResourceQuota
+	ResourceQuota *previewv1alpha1.ResourceQuotaSpec `json:"resourceQuota,omitempty"`
+
+	// Ingress configures ingress routing
+	Ingress IngressConfig `json:"ingress,omitempty"`
+
+	// ArgoCD configures how services are deployed
+	ArgoCD ArgoCDConfig `json:"argocd,omitempty"`
+
+	// TTL defaults Spec.TTL
+	TTL string `json:"ttl,omitempty"`
+
+	// Services defaults Spec.Services when no services were detected
+	Services []string `json:"services,omitempty"`
+}
+
+// IngressConfig configures ingress routing for the preview environment
+type IngressConfig struct {
+	// Paths maps a service name to its ingress path prefix
+	Paths map[string]string `json:"paths,omitempty"`
+}
+
+// ArgoCDConfig configures the ArgoCD source of each service
+type ArgoCDConfig struct {
+	// Path is the repository path template; "{{service}}" is replaced with the service name
+	Path string `json:"path,omitempty"`
+}
+
+// Parse decodes and validates a .previewd.yaml document
+func Parse(data []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Load fetches and parses .previewd.yaml from the repository at ref.
+// A repository without the file yields an empty Config.
+func Load(ctx context.Context, gh github.Client, owner, repo, ref string) (*Config, error) {
+	data, err := gh.GetFileContents(ctx, owner, repo, FileName, ref)
+	if errors.Is(err, github.ErrNotFound) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", FileName, err)
+	}
+	return Parse(data)
+}
+
+// LoadFromGitLab fetches and parses .previewd.yaml from a GitLab project at ref.
+// A project without the file yields an empty Config.
+func LoadFromGitLab(ctx context.Context, gl gitlab.Client, project, ref string) (*Config, error) {
+	data, err := gl.GetFileContents(ctx, project, FileName, ref)
+	if errors.Is(err, gitlab.ErrNotFound) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", FileName, err)
+	}
+	return Parse(data)
+}
+
+// Validate checks the configuration and reports every problem found
+func (c *Config) Validate() error {
+	var problems []string
+
+	for _, service := range c.Services {
+		for _, msg := range validation.IsDNS1123Label(service) {
+			problems = append(problems, fmt.Sprintf("services: %q: %s", service, msg))
+		}
+	}
+
+	if _, err := previewv1alpha1.ParseTTL(c.TTL); err != nil {
+		problems = append(problems, fmt.Sprintf("ttl: %q is not a duration such as 4h, 90m or 2d", c.TTL))
+	}
+
+	if q := c.ResourceQuota; q != nil {
+		for field, value := range map[string]string{
+			"requestsCpu":    q.RequestsCPU,
+			"limitsCpu":      q.LimitsCPU,
+			"requestsMemory": q.RequestsMemory,
+			"limitsMemory":   q.LimitsMemory,
+		} {
+			if value == "" {
+				continue
+			}
+			if _, err := resource.ParseQuantity(value); err != nil {
+				problems = append(problems, fmt.Sprintf("resourceQuota.%s: %q is not a valid quantity", field, value))
+			}
+		}
+	}
+
+	for service, path := range c.Ingress.Paths {
+		if !strings.HasPrefix(path, "/") {
+			problems = append(problems, fmt.Sprintf("ingress.paths.%s: %q must start with /", service, path))
+		}
+	}
+
+	if path := c.ArgoCD.Path; path != "" {
+		if strings.HasPrefix(path, "/") || strings.Contains(path, "..") {
+			problems = append(problems, fmt.Sprintf("argocd.path: %q must be a relative path inside the repository", path))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	// Map iteration order is random; keep error messages stable
+	sort.Strings(problems)
+	return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(problems, "; "))
+}
+
+// ApplyTo copies the configured values into spec. Services are only set when
+// spec has none, so services detected from the pull request take precedence.
+// The TTL, resource quota, ingress paths and source path belong to the file:
+// they are cleared when it does not set them, so removing a key takes effect
+// on the next push.
+func (c *Config) ApplyTo(spec *previewv1alpha1.PreviewEnvironmentSpec) {
+	if len(spec.Services) == 0 && len(c.Services) > 0 {
+		spec.Services = append([]string(nil), c.Services...)
+	}
+	spec.TTL = c.TTL
+	spec.ResourceQuota = nil
+	if c.ResourceQuota != nil {
+		quota := *c.ResourceQuota
+		spec.ResourceQuota = &quota
+	}
+	spec.IngressPaths = nil
+	if len(c.Ingress.Paths) > 0 {
+		spec.IngressPaths = make(map[string]string, len(c.Ingress.Paths))
+		for service, path := range c.Ingress.Paths {
+			spec.IngressPaths[service] = path
+		}
+	}
+	spec.SourcePath = c.ArgoCD.Path
+}
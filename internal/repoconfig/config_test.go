@@ -0,0 +1,222 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package repoconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/github"
+)
+
+// fakeContentsClient serves a single file for GetFileContents
+type fakeContentsClient struct {
+	github.Client
+	err  error
+	data []byte
+}
+
+func (f *fakeContentsClient) GetFileContents(_ context.Context, _, _, _, _ string) ([]byte, error) {
+	return f.data, f.err
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *Config
+		wantErr string
+	}{
+		{
+			name: "parses full configuration",
+			data: `
+services: [frontend, api]
+ttl: 2d
+resourceQuota:
+  requestsCpu: "1"
+  limitsMemory: 4Gi
+ingress:
+  paths:
+    api: /api/v1
+argocd:
+  path: deploy/{{service}}
+`,
+			want: &Config{
+				Services:      []string{"frontend", "api"},
+				TTL:           "2d",
+				ResourceQuota: &previewv1alpha1.ResourceQuotaSpec{RequestsCPU: "1", LimitsMemory: "4Gi"},
+				Ingress:       IngressConfig{Paths: map[string]string{"api": "/api/v1"}},
+				ArgoCD:        ArgoCDConfig{Path: "deploy/{{service}}"},
+			},
+		},
+		{
+			name: "parses empty document",
+			data: "",
+			want: &Config{},
+		},
+		{
+			name:    "rejects unknown fields",
+			data:    "servics: [api]\n",
+			wantErr: "servics",
+		},
+		{
+			name:    "rejects invalid TTL",
+			data:    "ttl: forever\n",
+			wantErr: "ttl",
+		},
+		{
+			name:    "rejects TTLs the CRD pattern rejects",
+			data:    "ttl: 1.5h\n",
+			wantErr: "ttl",
+		},
+		{
+			name:    "rejects invalid service names",
+			data:    "services: [My_Service]\n",
+			wantErr: "services",
+		},
+		{
+			name:    "rejects invalid quantities",
+			data:    "resourceQuota:\n  limitsCpu: lots\n",
+			wantErr: "resourceQuota.limitsCpu",
+		},
+		{
+			name:    "rejects relative ingress paths",
+			data:    "ingress:\n  paths:\n    api: api\n",
+			wantErr: "ingress.paths.api",
+		},
+		{
+			name:    "rejects source paths escaping the repository",
+			data:    "argocd:\n  path: ../other\n",
+			wantErr: "argocd.path",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data))
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrInvalidConfig) {
+					t.Fatalf("Parse() error = %v, want ErrInvalidConfig", err)
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Parse() error = %q, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  *fakeContentsClient
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name:   "returns empty config when file is missing",
+			client: &fakeContentsClient{err: fmt.Errorf("wrapped: %w", github.ErrNotFound)},
+			want:   &Config{},
+		},
+		{
+			name:   "parses file contents",
+			client: &fakeContentsClient{data: []byte("ttl: 8h\n")},
+			want:   &Config{TTL: "8h"},
+		},
+		{
+			name:    "returns fetch errors",
+			client:  &fakeContentsClient{err: errors.New("boom")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(context.Background(), tt.client, "owner", "repo", "abc123")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_ApplyTo(t *testing.T) {
+	config := &Config{
+		Services:      []string{"frontend"},
+		TTL:           "8h",
+		ResourceQuota: &previewv1alpha1.ResourceQuotaSpec{LimitsCPU: "2"},
+		Ingress:       IngressConfig{Paths: map[string]string{"api": "/v1"}},
+		ArgoCD:        ArgoCDConfig{Path: "deploy/{{service}}"},
+	}
+
+	t.Run("fills an empty spec", func(t *testing.T) {
+		spec := previewv1alpha1.PreviewEnvironmentSpec{}
+		config.ApplyTo(&spec)
+
+		want := previewv1alpha1.PreviewEnvironmentSpec{
+			Services:      []string{"frontend"},
+			TTL:           "8h",
+			ResourceQuota: &previewv1alpha1.ResourceQuotaSpec{LimitsCPU: "2"},
+			IngressPaths:  map[string]string{"api": "/v1"},
+			SourcePath:    "deploy/{{service}}",
+		}
+		if !reflect.DeepEqual(spec, want) {
+			t.Errorf("ApplyTo() spec = %+v, want %+v", spec, want)
+		}
+	})
+
+	t.Run("clears keys the file no longer sets", func(t *testing.T) {
+		spec := previewv1alpha1.PreviewEnvironmentSpec{}
+		config.ApplyTo(&spec)
+		(&Config{}).ApplyTo(&spec)
+
+		want := previewv1alpha1.PreviewEnvironmentSpec{Services: []string{"frontend"}}
+		if !reflect.DeepEqual(spec, want) {
+			t.Errorf("ApplyTo() spec = %+v, want %+v", spec, want)
+		}
+	})
+
+	t.Run("keeps detected services", func(t *testing.T) {
+		spec := previewv1alpha1.PreviewEnvironmentSpec{Services: []string{"api"}}
+		config.ApplyTo(&spec)
+
+		if !reflect.DeepEqual(spec.Services, []string{"api"}) {
+			t.Errorf("ApplyTo() Services = %v, want [api]", spec.Services)
+		}
+	})
+}
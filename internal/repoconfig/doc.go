@@ -0,0 +1,47 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package repoconfig loads the per-repository preview configuration file.
+//
+// Repositories can commit a .previewd.yaml at their root to configure their
+// preview environments. The file is read from the pull request head commit, so
+// changes to it are previewed like any other change.
+//
+// Example .previewd.yaml:
+//
+//	services: [frontend, api]
+//	ttl: 8h
+//	resourceQuota:
+//	  requestsCpu: "1"
+//	  limitsMemory: 4Gi
+//	ingress:
+//	  paths:
+//	    api: /api/v1
+//	argocd:
+//	  path: deploy/{{service}}/overlays/preview
+//
+// The argocd.path layout also tells service detection where services live:
+// with the example above, a change under deploy/api/ affects the api service.
+//
+// Unknown fields and invalid values are rejected with an error wrapping
+// ErrInvalidConfig, so a typo never silently falls back to the defaults.
+package repoconfig
@@ -0,0 +1,183 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// servicePlaceholder marks the service name in a repository layout
+	servicePlaceholder = "{{service}}"
+
+	// DefaultLayout is the repository layout used when .previewd.yaml sets no
+	// argocd.path. It matches the ArgoCD ApplicationSet's default source path.
+	DefaultLayout = "services/" + servicePlaceholder
+)
+
+// Detector maps changed file paths to the set of affected services
+type Detector struct {
+	// dependents maps a service to the services that depend on it directly
+	dependents map[string][]string
+}
+
+// NewDetector creates a Detector from a dependency graph mapping each service
+// to the services it depends on. A nil graph detects changed services only.
+func NewDetector(dependencies map[string][]string) *Detector {
+	dependents := make(map[string][]string)
+	for service, deps := range dependencies {
+		for _, dep := range deps {
+			dependents[dep] = append(dependents[dep], service)
+		}
+	}
+	return &Detector{dependents: dependents}
+}
+
+// Detect returns the sorted list of services affected by the changed files,
+// including every service that transitively depends on a changed service.
+// layout is the repository path template of a service, such as argocd.path
+// from .previewd.yaml; an empty layout is DefaultLayout. The directory named
+// by the "{{service}}" path segment holds the service, so a change anywhere
+// under it affects the service. A layout without that segment detects nothing.
+// Directories whose names are not valid DNS-1123 labels cannot name preview
+// resources, so they are logged and skipped.
+func (d *Detector) Detect(ctx context.Context, layout string, files []string) []string {
+	affected := make(map[string]bool)
+	invalid := make(map[string]bool)
+	queue := []string{}
+
+	prefix, ok := layoutPrefix(layout)
+	if !ok {
+		files = nil
+	}
+	for _, file := range files {
+		service := serviceForPath(prefix, file)
+		if service == "" || affected[service] || invalid[service] {
+			continue
+		}
+		if msgs := validation.IsDNS1123Label(service); len(msgs) > 0 {
+			invalid[service] = true
+			log.FromContext(ctx).Info("Skipping service directory with an invalid name",
+				"service", service, "reason", strings.Join(msgs, "; "))
+			continue
+		}
+		affected[service] = true
+		queue = append(queue, service)
+	}
+
+	// Walk the reverse dependency graph; the affected set doubles as the
+	// visited set so cycles terminate.
+	for len(queue) > 0 {
+		service := queue[0]
+		queue = queue[1:]
+		for _, dependent := range d.dependents[service] {
+			if !affected[dependent] {
+				affected[dependent] = true
+				queue = append(queue, dependent)
+			}
+		}
+	}
+
+	result := make([]string, 0, len(affected))
+	for service := range affected {
+		result = append(result, service)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// layoutPrefix returns the directories of layout that precede the service
+// directory. It reports false if no path segment of layout is exactly
+// "{{service}}".
+func layoutPrefix(layout string) ([]string, bool) {
+	if layout == "" {
+		layout = DefaultLayout
+	}
+	segments := strings.Split(path.Clean(layout), "/")
+	for i, segment := range segments {
+		if segment == servicePlaceholder {
+			return segments[:i], true
+		}
+	}
+	return nil, false
+}
+
+// serviceForPath returns the service owning file, or "" if the file is not
+// inside a service directory below prefix
+func serviceForPath(prefix []string, file string) string {
+	parts := strings.Split(path.Clean(file), "/")
+	if len(parts) < len(prefix)+2 {
+		return ""
+	}
+	for i, dir := range prefix {
+		if parts[i] != dir {
+			return ""
+		}
+	}
+	return parts[len(prefix)]
+}
+
+// ParseDependencies parses a dependency graph in the form
+// "service=dep1,dep2;other=dep3". An empty string yields an empty graph.
+func ParseDependencies(spec string) (map[string][]string, error) {
+	dependencies := make(map[string][]string)
+	if strings.TrimSpace(spec) == "" {
+		return dependencies, nil
+	}
+
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		service, deps, ok := strings.Cut(entry, "=")
+		service = strings.TrimSpace(service)
+		if !ok || service == "" {
+			return nil, fmt.Errorf("invalid service dependency %q: expected service=dep1,dep2", entry)
+		}
+		if msgs := validation.IsDNS1123Label(service); len(msgs) > 0 {
+			return nil, fmt.Errorf("invalid service name %q: %s", service, strings.Join(msgs, "; "))
+		}
+
+		for _, dep := range strings.Split(deps, ",") {
+			dep = strings.TrimSpace(dep)
+			if dep == "" {
+				continue
+			}
+			if msgs := validation.IsDNS1123Label(dep); len(msgs) > 0 {
+				return nil, fmt.Errorf("invalid service name %q: %s", dep, strings.Join(msgs, "; "))
+			}
+			dependencies[service] = append(dependencies[service], dep)
+		}
+	}
+
+	return dependencies, nil
+}
@@ -0,0 +1,166 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDetector_Detect(t *testing.T) {
+	dependencies := map[string][]string{
+		"web":    {"api"},
+		"api":    {"auth", "db"},
+		"worker": {"db"},
+	}
+
+	tests := []struct {
+		name   string
+		layout string
+		files  []string
+		want   []string
+	}{
+		{
+			name:  "detects services from changed paths",
+			files: []string{"services/web/src/index.ts", "services/web/package.json"},
+			want:  []string{"web"},
+		},
+		{
+			name:  "ignores files outside service directories",
+			files: []string{"README.md", "docs/services/api.md", "services/README.md"},
+			want:  []string{},
+		},
+		{
+			name:  "includes transitive dependents",
+			files: []string{"services/auth/main.go"},
+			want:  []string{"api", "auth", "web"},
+		},
+		{
+			name:  "includes all dependents of a shared service",
+			files: []string{"services/db/schema.sql"},
+			want:  []string{"api", "db", "web", "worker"},
+		},
+		{
+			name:  "returns empty list for no files",
+			files: nil,
+			want:  []string{},
+		},
+		{
+			name:   "follows the configured layout",
+			layout: "deploy/{{service}}/overlays/preview",
+			files:  []string{"deploy/worker/base/deployment.yaml", "services/web/index.ts"},
+			want:   []string{"worker"},
+		},
+		{
+			name:   "detects services at the repository root",
+			layout: "{{service}}",
+			files:  []string{"db/schema.sql", "README.md"},
+			want:   []string{"api", "db", "web", "worker"},
+		},
+		{
+			name:  "skips directories that are not valid service names",
+			files: []string{"services/My_Api/main.go", "services/-web/index.ts", "services/worker/main.go"},
+			want:  []string{"worker"},
+		},
+		{
+			name:   "detects nothing without a service directory in the layout",
+			layout: "deploy/all",
+			files:  []string{"deploy/all/web.yaml"},
+			want:   []string{},
+		},
+	}
+
+	detector := NewDetector(dependencies)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detector.Detect(context.Background(), tt.layout, tt.files)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Detect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetector_DetectHandlesCycles(t *testing.T) {
+	detector := NewDetector(map[string][]string{
+		"a": {"b"},
+		"b": {"a"},
+	})
+
+	got := detector.Detect(context.Background(), "", []string{"services/a/main.go"})
+	want := []string{"a", "b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Detect() = %v, want %v", got, want)
+	}
+}
+
+func TestParseDependencies(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name: "parses empty spec",
+			spec: "",
+			want: map[string][]string{},
+		},
+		{
+			name: "parses multiple services",
+			spec: "web=api; api=auth,db",
+			want: map[string][]string{
+				"web": {"api"},
+				"api": {"auth", "db"},
+			},
+		},
+		{
+			name:    "rejects entry without separator",
+			spec:    "web",
+			wantErr: true,
+		},
+		{
+			name:    "rejects entry without service name",
+			spec:    "=api",
+			wantErr: true,
+		},
+		{
+			name:    "rejects invalid service names",
+			spec:    "web=Auth_Service",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDependencies(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDependencies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDependencies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright (c) 2025 Mike Lane
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package services detects which preview services a pull request affects.
+//
+// Services follow the same repository layout as the ArgoCD ApplicationSet:
+// argocd.path from .previewd.yaml, or services/{{service}} by default. Every
+// directory in the place of {{service}} is a deployable service, so with the
+// default layout a file change under services/<name>/ marks <name> as
+// affected. Directory names that are not valid DNS-1123 labels are skipped,
+// since services name the preview's Applications, Services and Ingresses.
+//
+// Dependency Graph:
+//
+// Services can declare the services they depend on. When a dependency
+// changes, every service that depends on it (directly or transitively) is
+// also deployed, so the preview exercises the change end to end.
+//
+//	deps, err := services.ParseDependencies("web=api;api=auth,db")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	detector := services.NewDetector(deps)
+//	detector.Detect(ctx, services.DefaultLayout, []string{"services/auth/main.go"}) // [api auth web]
+package services
@@ -0,0 +1,133 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+)
+
+// bitbucketPullRequestPrefix prefixes the X-Event-Key of pull request events
+const bitbucketPullRequestPrefix = "pullrequest:"
+
+// BitbucketPullRequestEvent represents a Bitbucket Cloud pull request webhook event
+type BitbucketPullRequestEvent struct {
+	Repository  BitbucketRepository  `json:"repository"`
+	PullRequest BitbucketPullRequest `json:"pullrequest"`
+}
+
+// BitbucketPullRequest contains pull request metadata
+type BitbucketPullRequest struct {
+	UpdatedOn   time.Time         `json:"updated_on"`
+	Source      BitbucketEndpoint `json:"source"`
+	Destination BitbucketEndpoint `json:"destination"`
+	Title       string            `json:"title"`
+	State       string            `json:"state"` // OPEN, MERGED, DECLINED, SUPERSEDED
+	ID          int               `json:"id"`
+	Draft       bool              `json:"draft"`
+}
+
+// BitbucketEndpoint is the source or destination of a pull request
+type BitbucketEndpoint struct {
+	Branch struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+	Commit struct {
+		Hash string `json:"hash"` // abbreviated to 12 characters
+	} `json:"commit"`
+}
+
+// BitbucketRepository contains repository metadata
+type BitbucketRepository struct {
+	FullName string `json:"full_name"` // workspace/repo-slug
+	Name     string `json:"name"`
+}
+
+// bitbucketProvider handles Bitbucket Cloud webhooks, which are signed with
+// HMAC-SHA256 in X-Hub-Signature using the same format as GitHub. Only
+// pullrequest:* events are decoded. repo:push events are ignored: their
+// payloads list no changed files and Previewd has no Bitbucket API client to
+// read .previewd.yaml, so a branch preview would have no services to deploy.
+type bitbucketProvider struct {
+	keys *KeyRing
+}
+
+// NewBitbucketProvider returns the Provider for Bitbucket Cloud webhooks signed with any of keys
+func NewBitbucketProvider(keys *KeyRing) Provider {
+	return &bitbucketProvider{keys: keys}
+}
+
+func (p *bitbucketProvider) Name() string {
+	return previewv1alpha1.ProviderBitbucket
+}
+
+func (p *bitbucketProvider) Matches(header http.Header) bool {
+	return header.Get("X-Event-Key") != ""
+}
+
+func (p *bitbucketProvider) Verify(header http.Header, payload []byte) (string, bool) {
+	signature := header.Get("X-Hub-Signature")
+	return p.keys.match(func(secret string) bool {
+		return ValidateSignature(payload, signature, secret)
+	})
+}
+
+func (p *bitbucketProvider) DeliveryID(header http.Header) string {
+	return header.Get("X-Request-UUID")
+}
+
+func (p *bitbucketProvider) Decode(header http.Header, payload []byte, _ string) (*Event, error) {
+	eventKey := header.Get("X-Event-Key")
+	if !strings.HasPrefix(eventKey, bitbucketPullRequestPrefix) {
+		return nil, nil
+	}
+
+	var hook BitbucketPullRequestEvent
+	if err := json.Unmarshal(payload, &hook); err != nil {
+		return nil, err
+	}
+
+	pr := hook.PullRequest
+	event := &PullRequestEvent{
+		Number: pr.ID,
+		PullRequest: PullRequest{
+			UpdatedAt: pr.UpdatedOn,
+			Head:      Ref{Ref: pr.Source.Branch.Name, SHA: pr.Source.Commit.Hash},
+			Base:      Ref{Ref: pr.Destination.Branch.Name},
+			Title:     pr.Title,
+			State:     strings.ToLower(pr.State),
+			Draft:     pr.Draft,
+		},
+		Repository: Repository{FullName: hook.Repository.FullName, Name: hook.Repository.Name},
+	}
+
+	// Bitbucket has no pull request labels, and "updated" covers new commits
+	// as well as edits, so every update is treated as a synchronize
+	switch strings.TrimPrefix(eventKey, bitbucketPullRequestPrefix) {
+	case "created":
+		event.Action = "opened"
+	case "updated":
+		event.Action = "synchronize"
+	case "fulfilled", "rejected":
+		event.Action = "closed"
+	default:
+		return nil, nil
+	}
+	return &Event{PullRequest: event}, nil
+}
@@ -0,0 +1,354 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/github"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// commandPrefix starts every ChatOps command in a pull request comment
+	commandPrefix = "/preview"
+
+	// Reactions acknowledging a command
+	reactionSucceeded = "+1"
+	reactionFailed    = "confused"
+	reactionDenied    = "-1"
+)
+
+// commandUsage is appended to replies for malformed commands
+const commandUsage = "Usage: `/preview extend <duration>`, `/preview redeploy`, `/preview sleep`, " +
+	"`/preview destroy` or `/preview services <name>[,<name>...]`"
+
+// errNoPreview is returned when a command targets a pull request without a preview
+var errNoPreview = errors.New("no preview environment exists for this pull request")
+
+// CommandAction is a ChatOps command verb
+type CommandAction string
+
+const (
+	// CommandExtend adds to the preview environment TTL
+	CommandExtend CommandAction = "extend"
+	// CommandRedeploy wakes the preview environment and reconciles it again
+	CommandRedeploy CommandAction = "redeploy"
+	// CommandSleep undeploys the services but keeps the environment
+	CommandSleep CommandAction = "sleep"
+	// CommandDestroy deletes the preview environment
+	CommandDestroy CommandAction = "destroy"
+	// CommandServices replaces the list of deployed services
+	CommandServices CommandAction = "services"
+)
+
+// Command is a parsed /preview ChatOps command
+type Command struct {
+	Action   CommandAction
+	Services []string      // set for CommandServices
+	Duration time.Duration // set for CommandExtend
+}
+
+// ParseCommand finds a /preview command in a comment body. It returns nil and
+// no error when the comment contains no command.
+func ParseCommand(body string) (*Command, error) {
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != commandPrefix {
+			continue
+		}
+		return parseCommandArgs(fields[1:])
+	}
+	return nil, nil
+}
+
+// parseCommandArgs parses the words following /preview
+func parseCommandArgs(args []string) (*Command, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing command")
+	}
+
+	cmd := &Command{Action: CommandAction(strings.ToLower(args[0]))}
+	args = args[1:]
+
+	switch cmd.Action {
+	case CommandExtend:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("`extend` takes exactly one duration, e.g. `/preview extend 8h`")
+		}
+		duration, err := previewv1alpha1.ParseTTL(args[0])
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid duration %q", args[0])
+		}
+		cmd.Duration = duration
+
+	case CommandRedeploy, CommandSleep, CommandDestroy:
+		if len(args) != 0 {
+			return nil, fmt.Errorf("`%s` takes no arguments", cmd.Action)
+		}
+
+	case CommandServices:
+		for _, name := range strings.Split(strings.Join(args, ","), ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if msgs := validation.IsDNS1123Label(name); len(msgs) > 0 {
+				return nil, fmt.Errorf("invalid service name %q: %s", name, msgs[0])
+			}
+			cmd.Services = append(cmd.Services, name)
+		}
+		if len(cmd.Services) == 0 {
+			return nil, fmt.Errorf("`services` needs at least one service, e.g. `/preview services api,frontend`")
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown command %q", cmd.Action)
+	}
+
+	return cmd, nil
+}
+
+// acceptComment queues issue_comment webhook events carrying ChatOps commands
+func (s *Server) acceptComment(w http.ResponseWriter, r *http.Request, event *IssueCommentEvent, deliveryID string) {
+	if !s.allowRequest(w, r, event.Repository.FullName) {
+		return
+	}
+
+	if !isCommandComment(event) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if s.isDuplicateDelivery(r.Context(), deliveryID) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	s.respondQueued(w, r, deliveryID, s.enqueueComment(r.Context(), event, deliveryID))
+}
+
+// isCommandComment reports whether the event is a new pull request comment
+// containing a /preview command, valid or not
+func isCommandComment(event *IssueCommentEvent) bool {
+	if event.Action != "created" || event.Issue.PullRequest == nil {
+		return false
+	}
+	cmd, err := ParseCommand(event.Comment.Body)
+	return cmd != nil || err != nil
+}
+
+// handleIssueComment runs the /preview command in a new pull request comment.
+// Only users with write access may run commands; every command is acknowledged
+// with a reaction and a reply.
+func (s *Server) handleIssueComment(ctx context.Context, event *IssueCommentEvent) error {
+	logger := log.FromContext(ctx)
+
+	if !isCommandComment(event) {
+		return nil
+	}
+
+	cmd, parseErr := ParseCommand(event.Comment.Body)
+
+	if s.githubClient == nil {
+		logger.Info("Ignoring /preview command: GitHub client not configured", "pr", event.Issue.Number)
+		return nil
+	}
+
+	owner, repo, err := github.ParseRepository(event.Repository.FullName)
+	if err != nil {
+		return err
+	}
+
+	user := event.Comment.User.Login
+	allowed, err := s.canRunCommands(ctx, owner, repo, user)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		s.acknowledge(ctx, owner, repo, event, reactionDenied,
+			fmt.Sprintf("@%s only collaborators with write access can run `%s` commands.", user, commandPrefix))
+		return nil
+	}
+
+	if parseErr != nil {
+		s.acknowledge(ctx, owner, repo, event, reactionFailed,
+			fmt.Sprintf("@%s %v.\n\n%s", user, parseErr, commandUsage))
+		return nil
+	}
+
+	reply, err := s.runCommand(ctx, event.Repository.FullName, event.Issue.Number, cmd)
+	if err != nil {
+		s.acknowledge(ctx, owner, repo, event, reactionFailed,
+			fmt.Sprintf("@%s `%s %s` failed: %v", user, commandPrefix, cmd.Action, err))
+		// The failure has been reported on the pull request; retrying would
+		// repeat the reply, so the user reruns the command instead
+		if !errors.Is(err, errNoPreview) {
+			logger.Error(err, "Failed to run /preview command", "command", cmd.Action, "pr", event.Issue.Number)
+		}
+		return nil
+	}
+
+	logger.Info("Ran /preview command", "command", cmd.Action, "pr", event.Issue.Number, "user", user)
+	s.acknowledge(ctx, owner, repo, event, reactionSucceeded, fmt.Sprintf("@%s %s", user, reply))
+	return nil
+}
+
+// canRunCommands reports whether user has write access to the repository
+func (s *Server) canRunCommands(ctx context.Context, owner, repo, user string) (bool, error) {
+	permission, err := s.githubClient.GetPermissionLevel(ctx, owner, repo, user)
+	if err != nil {
+		return false, err
+	}
+	switch permission {
+	case "admin", "maintain", "write":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// runCommand applies cmd to the pull request's PreviewEnvironment and returns the reply text
+func (s *Server) runCommand(ctx context.Context, repository string, number int, cmd *Command) (string, error) {
+	preview, err := s.findPreview(ctx, repository, number)
+	if err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return "", errNoPreview
+		}
+		return "", err
+	}
+
+	if cmd.Action == CommandDestroy {
+		if err := s.client.Delete(ctx, preview); client.IgnoreNotFound(err) != nil {
+			return "", fmt.Errorf("failed to delete PreviewEnvironment: %w", err)
+		}
+		return "destroying the preview environment.", nil
+	}
+
+	// The controller updates the PreviewEnvironment too, so the command is
+	// applied to a fresh copy until the update does not conflict
+	key := client.ObjectKeyFromObject(preview)
+	var reply string
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := s.client.Get(ctx, key, preview); err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				return errNoPreview
+			}
+			return err
+		}
+		if reply, err = applyCommand(preview, cmd); err != nil {
+			return err
+		}
+		if err := s.client.Update(ctx, preview); err != nil {
+			return fmt.Errorf("failed to update PreviewEnvironment: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return reply, nil
+}
+
+// applyCommand applies cmd to preview and returns the reply text
+func applyCommand(preview *previewv1alpha1.PreviewEnvironment, cmd *Command) (string, error) {
+	if preview.Annotations == nil {
+		preview.Annotations = map[string]string{}
+	}
+
+	switch cmd.Action {
+	case CommandExtend:
+		current, err := previewv1alpha1.ParseTTL(preview.Spec.TTL)
+		if err != nil {
+			return "", err
+		}
+		// Recorded as an override so later pushes keep the extended TTL
+		preview.Spec.TTL = formatTTL(current + cmd.Duration)
+		preview.Annotations[previewv1alpha1.TTLOverrideAnnotation] = preview.Spec.TTL
+		return fmt.Sprintf("extended the preview environment by %s (TTL is now %s).",
+			formatDuration(cmd.Duration), preview.Spec.TTL), nil
+
+	case CommandRedeploy:
+		delete(preview.Annotations, previewv1alpha1.SleepAnnotation)
+		preview.Annotations[previewv1alpha1.RedeployAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		return "redeploying the preview environment.", nil
+
+	case CommandSleep:
+		preview.Annotations[previewv1alpha1.SleepAnnotation] = "true"
+		return fmt.Sprintf("putting the preview environment to sleep. Comment `%s redeploy` to wake it up.", commandPrefix), nil
+
+	case CommandServices:
+		// Recorded as an override so later pushes keep the chosen services
+		preview.Spec.Services = cmd.Services
+		preview.Annotations[previewv1alpha1.ServicesOverrideAnnotation] = strings.Join(cmd.Services, ",")
+		return fmt.Sprintf("deploying services %s.", strings.Join(cmd.Services, ", ")), nil
+	}
+	return "", fmt.Errorf("unknown command %q", cmd.Action)
+}
+
+// acknowledge reacts to the command comment and replies on the pull request.
+// Both are best-effort: failures are logged, not returned.
+func (s *Server) acknowledge(ctx context.Context, owner, repo string, event *IssueCommentEvent, reaction, reply string) {
+	logger := log.FromContext(ctx)
+
+	if err := s.githubClient.AddCommentReaction(ctx, owner, repo, event.Comment.ID, reaction); err != nil {
+		logger.Error(err, "Failed to react to /preview command", "comment", event.Comment.ID)
+	}
+	if err := s.githubClient.CreateComment(ctx, owner, repo, event.Issue.Number, reply); err != nil {
+		logger.Error(err, "Failed to reply to /preview command", "pr", event.Issue.Number)
+	}
+}
+
+// applyCommandOverrides restores the TTL and services set with ChatOps
+// commands, which take precedence over .previewd.yaml and detected services
+func applyCommandOverrides(preview *previewv1alpha1.PreviewEnvironment) {
+	if ttl := preview.Annotations[previewv1alpha1.TTLOverrideAnnotation]; ttl != "" {
+		preview.Spec.TTL = ttl
+	}
+	if services := preview.Annotations[previewv1alpha1.ServicesOverrideAnnotation]; services != "" {
+		preview.Spec.Services = strings.Split(services, ",")
+	}
+}
+
+// formatTTL renders d as a Spec.TTL, rounded up to whole minutes. Durations
+// with fractional seconds format as e.g. "1h0m0.5s" or "500µs", which the CRD
+// validation pattern rejects.
+func formatTTL(d time.Duration) string {
+	if rounded := d.Truncate(time.Minute); rounded < d {
+		d = rounded + time.Minute
+	}
+	return formatDuration(max(d, time.Minute))
+}
+
+// formatDuration renders d without trailing zero units ("12h" rather than "12h0m0s")
+func formatDuration(d time.Duration) string {
+	formatted := d.String()
+	if strings.HasSuffix(formatted, "m0s") {
+		formatted = strings.TrimSuffix(formatted, "0s")
+	}
+	if strings.HasSuffix(formatted, "h0m") {
+		formatted = strings.TrimSuffix(formatted, "0m")
+	}
+	return formatted
+}
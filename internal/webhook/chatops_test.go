@@ -0,0 +1,462 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/services"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		want    *Command
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{
+			name: "ignores comments without a command",
+			body: "Looks good to me!",
+		},
+		{
+			name: "ignores commands that are not at the start of a line",
+			body: "please run /preview destroy",
+		},
+		{
+			name: "parses extend with hours",
+			body: "/preview extend 8h",
+			want: &Command{Action: CommandExtend, Duration: 8 * time.Hour},
+		},
+		{
+			name: "parses extend with days",
+			body: "/preview extend 2d",
+			want: &Command{Action: CommandExtend, Duration: 48 * time.Hour},
+		},
+		{
+			name: "parses command on a later line",
+			body: "Thanks!\n/preview redeploy\n",
+			want: &Command{Action: CommandRedeploy},
+		},
+		{
+			name: "parses sleep",
+			body: "/preview sleep",
+			want: &Command{Action: CommandSleep},
+		},
+		{
+			name: "parses destroy",
+			body: "/preview destroy",
+			want: &Command{Action: CommandDestroy},
+		},
+		{
+			name: "parses services list",
+			body: "/preview services api, frontend",
+			want: &Command{Action: CommandServices, Services: []string{"api", "frontend"}},
+		},
+		{
+			name:    "rejects missing command",
+			body:    "/preview",
+			wantErr: true,
+		},
+		{
+			name:    "rejects unknown command",
+			body:    "/preview explode",
+			wantErr: true,
+		},
+		{
+			name:    "rejects invalid duration",
+			body:    "/preview extend soon",
+			wantErr: true,
+		},
+		{
+			name:    "rejects arguments to destroy",
+			body:    "/preview destroy now",
+			wantErr: true,
+		},
+		{
+			name:    "rejects invalid service names",
+			body:    "/preview services Not_Valid",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCommand(tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCommand() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		12 * time.Hour:   "12h",
+		90 * time.Minute: "1h30m",
+		30 * time.Minute: "30m",
+		45 * time.Second: "45s",
+	}
+	for d, want := range tests {
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestFormatTTL(t *testing.T) {
+	// The validation pattern of PreviewEnvironmentSpec.TTL
+	crdPattern := regexp.MustCompile(`^([0-9]+d|([0-9]+(ns|us|ms|s|m|h))+)$`)
+
+	tests := map[time.Duration]string{
+		12 * time.Hour:                             "12h",
+		90 * time.Minute:                           "1h30m",
+		time.Hour + 500*time.Millisecond:           "1h1m",
+		26*time.Hour + 30*time.Second:              "26h1m",
+		time.Microsecond:                           "1m",
+		4*time.Hour + 59*time.Minute + time.Second: "5h",
+	}
+	for d, want := range tests {
+		got := formatTTL(d)
+		if got != want {
+			t.Errorf("formatTTL(%v) = %q, want %q", d, got, want)
+		}
+		if !crdPattern.MatchString(got) {
+			t.Errorf("formatTTL(%v) = %q, which the CRD TTL pattern rejects", d, got)
+		}
+		if parsed, err := previewv1alpha1.ParseTTL(got); err != nil || parsed < d {
+			t.Errorf("ParseTTL(%q) = %v, %v; want at least %v", got, parsed, err, d)
+		}
+	}
+}
+
+// sendComment delivers an issue_comment event for PR 123 and returns the response code
+func sendComment(t *testing.T, server *Server, body string) int {
+	t.Helper()
+
+	event := IssueCommentEvent{
+		Action:     "created",
+		Issue:      Issue{Number: 123, PullRequest: &IssuePullRequest{URL: "https://api.github.com/repos/company/repo/pulls/123"}},
+		Comment:    Comment{ID: 99, Body: body, User: Owner{Login: "reviewer"}},
+		Repository: Repository{FullName: "company/repo"},
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal test event: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-GitHub-Event", "issue_comment")
+	req.Header.Set("X-Hub-Signature-256", computeSignature(payload, testSecret))
+	w := httptest.NewRecorder()
+
+	server.handleWebhook(w, req)
+	drainEvents(t, server)
+	return w.Code
+}
+
+func TestHandleIssueComment(t *testing.T) {
+	tests := []struct {
+		check        func(t *testing.T, preview *previewv1alpha1.PreviewEnvironment, exists bool)
+		name         string
+		body         string
+		permission   string
+		ttl          string
+		wantReaction string
+		wantReply    string
+		noPreview    bool
+	}{
+		{
+			name:         "extends the TTL",
+			body:         "/preview extend 8h",
+			permission:   "write",
+			wantReaction: reactionSucceeded,
+			wantReply:    "TTL is now 12h",
+			check: func(t *testing.T, preview *previewv1alpha1.PreviewEnvironment, _ bool) {
+				if preview.Spec.TTL != "12h" {
+					t.Errorf("TTL = %q, want 12h", preview.Spec.TTL)
+				}
+			},
+		},
+		{
+			name:         "extends an explicit TTL",
+			body:         "/preview extend 1d",
+			permission:   "admin",
+			ttl:          "2h",
+			wantReaction: reactionSucceeded,
+			check: func(t *testing.T, preview *previewv1alpha1.PreviewEnvironment, _ bool) {
+				if preview.Spec.TTL != "26h" {
+					t.Errorf("TTL = %q, want 26h", preview.Spec.TTL)
+				}
+			},
+		},
+		{
+			name:         "puts the preview to sleep",
+			body:         "/preview sleep",
+			permission:   "maintain",
+			wantReaction: reactionSucceeded,
+			check: func(t *testing.T, preview *previewv1alpha1.PreviewEnvironment, _ bool) {
+				if preview.Annotations[previewv1alpha1.SleepAnnotation] != "true" {
+					t.Errorf("annotations = %v, want sleep annotation", preview.Annotations)
+				}
+			},
+		},
+		{
+			name:         "redeploy records the request",
+			body:         "/preview redeploy",
+			permission:   "write",
+			wantReaction: reactionSucceeded,
+			check: func(t *testing.T, preview *previewv1alpha1.PreviewEnvironment, _ bool) {
+				if preview.Annotations[previewv1alpha1.RedeployAnnotation] == "" {
+					t.Errorf("annotations = %v, want redeploy annotation", preview.Annotations)
+				}
+			},
+		},
+		{
+			name:         "sets services",
+			body:         "/preview services api,frontend",
+			permission:   "write",
+			wantReaction: reactionSucceeded,
+			check: func(t *testing.T, preview *previewv1alpha1.PreviewEnvironment, _ bool) {
+				if !reflect.DeepEqual(preview.Spec.Services, []string{"api", "frontend"}) {
+					t.Errorf("Services = %v, want [api frontend]", preview.Spec.Services)
+				}
+			},
+		},
+		{
+			name:         "destroys the preview",
+			body:         "/preview destroy",
+			permission:   "write",
+			wantReaction: reactionSucceeded,
+			check: func(t *testing.T, _ *previewv1alpha1.PreviewEnvironment, exists bool) {
+				if exists {
+					t.Error("PreviewEnvironment still exists after destroy")
+				}
+			},
+		},
+		{
+			name:         "denies users without write access",
+			body:         "/preview destroy",
+			permission:   "read",
+			wantReaction: reactionDenied,
+			wantReply:    "only collaborators with write access",
+			check: func(t *testing.T, _ *previewv1alpha1.PreviewEnvironment, exists bool) {
+				if !exists {
+					t.Error("PreviewEnvironment was destroyed by a user without write access")
+				}
+			},
+		},
+		{
+			name:         "replies with usage for malformed commands",
+			body:         "/preview explode",
+			permission:   "write",
+			wantReaction: reactionFailed,
+			wantReply:    "Usage:",
+		},
+		{
+			name:         "reports missing preview environment",
+			body:         "/preview sleep",
+			permission:   "write",
+			noPreview:    true,
+			wantReaction: reactionFailed,
+			wantReply:    errNoPreview.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, k8sClient := setupTest(t)
+			gh := &fakeGitHubClient{permission: tt.permission}
+			server.WithGitHubClient(gh)
+
+			if !tt.noPreview {
+				preview := &previewv1alpha1.PreviewEnvironment{}
+				preview.Name = testPreviewName
+				preview.Namespace = DefaultPreviewNamespace
+				preview.Labels = previewLabels("company/repo", 123)
+				preview.Spec.Repository = "company/repo"
+				preview.Spec.PRNumber = 123
+				preview.Spec.TTL = tt.ttl
+				if err := k8sClient.Create(context.Background(), preview); err != nil {
+					t.Fatalf("Failed to create test PreviewEnvironment: %v", err)
+				}
+			}
+
+			if code := sendComment(t, server, tt.body); code != http.StatusAccepted {
+				t.Errorf("handleWebhook for issue comment returns %d, expected %d", code, http.StatusAccepted)
+			}
+
+			if len(gh.reactions) != 1 || gh.reactions[0] != tt.wantReaction {
+				t.Errorf("reactions = %v, want [%s]", gh.reactions, tt.wantReaction)
+			}
+			if len(gh.comments) != 1 || !strings.Contains(gh.comments[0], tt.wantReply) {
+				t.Errorf("replies = %v, want one containing %q", gh.comments, tt.wantReply)
+			}
+
+			if tt.check != nil {
+				preview := &previewv1alpha1.PreviewEnvironment{}
+				err := k8sClient.Get(context.Background(), types.NamespacedName{
+					Name:      testPreviewName,
+					Namespace: "previewd-system",
+				}, preview)
+				tt.check(t, preview, err == nil)
+			}
+		})
+	}
+}
+
+func TestHandleIssueComment_RetriesConflictingUpdate(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := previewv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add scheme: %v", err)
+	}
+
+	preview := &previewv1alpha1.PreviewEnvironment{}
+	preview.Name = testPreviewName
+	preview.Namespace = DefaultPreviewNamespace
+	preview.Labels = previewLabels("company/repo", 123)
+	preview.Spec.Repository = "company/repo"
+	preview.Spec.PRNumber = 123
+
+	updates := 0
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(preview).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				updates++
+				if updates == 1 {
+					// The controller writes the preview between the read and the update
+					current := &previewv1alpha1.PreviewEnvironment{}
+					if err := c.Get(ctx, client.ObjectKeyFromObject(obj), current); err != nil {
+						return err
+					}
+					current.Annotations = map[string]string{"controller": "wrote"}
+					if err := c.Update(ctx, current); err != nil {
+						return err
+					}
+				}
+				return c.Update(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	server := NewServer("localhost", 8080, k8sClient, testSecret)
+	gh := &fakeGitHubClient{permission: "write"}
+	server.WithGitHubClient(gh)
+
+	if code := sendComment(t, server, "/preview sleep"); code != http.StatusAccepted {
+		t.Errorf("handleWebhook for issue comment returns %d, expected %d", code, http.StatusAccepted)
+	}
+
+	if updates != 2 {
+		t.Errorf("PreviewEnvironment update attempted %d times, want 2", updates)
+	}
+	if len(gh.reactions) != 1 || gh.reactions[0] != reactionSucceeded {
+		t.Errorf("reactions = %v, want [%s]", gh.reactions, reactionSucceeded)
+	}
+	updated := &previewv1alpha1.PreviewEnvironment{}
+	if err := k8sClient.Get(context.Background(), client.ObjectKeyFromObject(preview), updated); err != nil {
+		t.Fatalf("Failed to get PreviewEnvironment: %v", err)
+	}
+	if updated.Annotations[previewv1alpha1.SleepAnnotation] != "true" || updated.Annotations["controller"] != "wrote" {
+		t.Errorf("annotations = %v, want the sleep annotation next to the controller's", updated.Annotations)
+	}
+}
+
+func TestHandleIssueComment_IgnoresNonCommands(t *testing.T) {
+	server, _ := setupTest(t)
+	gh := &fakeGitHubClient{permission: "write"}
+	server.WithGitHubClient(gh)
+
+	if code := sendComment(t, server, "Nice work!"); code != http.StatusOK {
+		t.Errorf("handleWebhook for issue comment returns %d, expected %d", code, http.StatusOK)
+	}
+	if len(gh.reactions) != 0 || len(gh.comments) != 0 {
+		t.Errorf("expected no acknowledgement, got reactions %v and replies %v", gh.reactions, gh.comments)
+	}
+}
+
+func TestHandleIssueComment_OverridesSurvivePush(t *testing.T) {
+	server, k8sClient := setupTest(t)
+	gh := &fakeGitHubClient{
+		permission: "write",
+		repoConfig: "ttl: 2h\n",
+		files:      []string{"services/api/handler.go"},
+	}
+	server.WithGitHubClient(gh).WithServiceDetection(services.NewDetector(nil))
+
+	preview := &previewv1alpha1.PreviewEnvironment{}
+	preview.Name = testPreviewName
+	preview.Namespace = DefaultPreviewNamespace
+	preview.Labels = previewLabels("company/repo", 123)
+	preview.Spec.Repository = "company/repo"
+	preview.Spec.PRNumber = 123
+	preview.Spec.HeadSHA = "oldsha"
+	preview.Spec.TTL = "2h"
+	preview.Spec.Services = []string{"api"}
+	if err := k8sClient.Create(context.Background(), preview); err != nil {
+		t.Fatalf("Failed to create test PreviewEnvironment: %v", err)
+	}
+
+	for _, body := range []string{"/preview extend 8h", "/preview services web"} {
+		if code := sendComment(t, server, body); code != http.StatusAccepted {
+			t.Fatalf("handleWebhook for %q returns %d, expected %d", body, code, http.StatusAccepted)
+		}
+	}
+
+	// A push re-reads .previewd.yaml and re-detects services
+	postPullRequest(t, server, PullRequestEvent{
+		Action:      "synchronize",
+		Number:      123,
+		PullRequest: PullRequest{Head: Ref{SHA: "newsha"}},
+		Repository:  Repository{FullName: "company/repo"},
+	})
+	drainEvents(t, server)
+
+	if err := k8sClient.Get(context.Background(), types.NamespacedName{
+		Name:      testPreviewName,
+		Namespace: "previewd-system",
+	}, preview); err != nil {
+		t.Fatalf("Failed to get PreviewEnvironment: %v", err)
+	}
+	if preview.Spec.HeadSHA != "newsha" {
+		t.Errorf("HeadSHA = %q, want newsha", preview.Spec.HeadSHA)
+	}
+	if preview.Spec.TTL != "10h" {
+		t.Errorf("TTL = %q, want the extended 10h", preview.Spec.TTL)
+	}
+	if !reflect.DeepEqual(preview.Spec.Services, []string{"web"}) {
+		t.Errorf("Services = %v, want the chosen [web]", preview.Spec.Services)
+	}
+}
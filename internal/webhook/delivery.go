@@ -0,0 +1,251 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The delivery ConfigMap lives in the manager's namespace only, and must be
+// named previewd-webhook-deliveries to match the default RBAC. Creating it is
+// allowed by the event store's RBAC marker.
+// +kubebuilder:rbac:groups="",namespace=system,resources=configmaps,resourceNames=previewd-webhook-deliveries,verbs=get;update
+
+const (
+	// DefaultDeliveryTTL is how long a delivery ID is remembered. GitHub only
+	// offers manual redelivery of recent deliveries, so a day covers retries.
+	DefaultDeliveryTTL = 24 * time.Hour
+
+	// defaultMaxDeliveries bounds the in-memory delivery cache
+	defaultMaxDeliveries = 10000
+
+	// maxConfigMapDeliveries bounds the ConfigMap-backed delivery cache, keeping
+	// the ConfigMap well below the 1 MiB object size limit
+	maxConfigMapDeliveries = 2000
+)
+
+// deliveryIDPattern matches delivery IDs usable as ConfigMap keys
+var deliveryIDPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// DeliveryStore remembers X-GitHub-Delivery IDs so redelivered webhooks are
+// processed only once.
+type DeliveryStore interface {
+	// MarkDelivered records id and reports whether it was already recorded
+	MarkDelivered(ctx context.Context, id string) (duplicate bool, err error)
+
+	// Forget removes id, so a redelivery of an event that was never processed
+	// is accepted again
+	Forget(ctx context.Context, id string) error
+}
+
+// MemoryDeliveryStore is a DeliveryStore that keeps delivery IDs in memory.
+// It is suitable for a single replica (or leader-only webhook serving).
+type MemoryDeliveryStore struct {
+	seen       map[string]time.Time
+	order      []string // delivery IDs, oldest first
+	ttl        time.Duration
+	mu         sync.Mutex
+	maxEntries int
+}
+
+// NewMemoryDeliveryStore creates an in-memory delivery store that remembers at
+// most maxEntries delivery IDs for ttl each
+func NewMemoryDeliveryStore(ttl time.Duration, maxEntries int) *MemoryDeliveryStore {
+	return &MemoryDeliveryStore{
+		seen:       make(map[string]time.Time),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+	}
+}
+
+// MarkDelivered implements DeliveryStore
+func (m *MemoryDeliveryStore) MarkDelivered(_ context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.prune(now)
+
+	if _, ok := m.seen[id]; ok {
+		return true, nil
+	}
+
+	m.seen[id] = now
+	m.order = append(m.order, id)
+	return false, nil
+}
+
+// Forget implements DeliveryStore
+func (m *MemoryDeliveryStore) Forget(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.seen[id]; !ok {
+		return nil
+	}
+	delete(m.seen, id)
+	for i, seen := range m.order {
+		if seen == id {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// prune drops expired delivery IDs and the oldest IDs beyond maxEntries.
+// Must be called with mu locked.
+func (m *MemoryDeliveryStore) prune(now time.Time) {
+	drop := 0
+	for drop < len(m.order) {
+		expired := now.Sub(m.seen[m.order[drop]]) > m.ttl
+		if !expired && len(m.order)-drop < m.maxEntries {
+			break
+		}
+		delete(m.seen, m.order[drop])
+		drop++
+	}
+	m.order = m.order[drop:]
+}
+
+// ConfigMapDeliveryStore is a DeliveryStore backed by a ConfigMap, so every
+// webhook replica shares the same delivery history. Each key of the ConfigMap
+// is a delivery ID and each value the RFC 3339 time it was received.
+type ConfigMapDeliveryStore struct {
+	client    client.Client
+	namespace string
+	name      string
+	ttl       time.Duration
+}
+
+// NewConfigMapDeliveryStore creates a delivery store persisted in the ConfigMap
+// namespace/name, which is created on first use. The client should read from
+// the API server directly rather than from a cache, so concurrent replicas see
+// each other's writes.
+func NewConfigMapDeliveryStore(c client.Client, namespace, name string, ttl time.Duration) *ConfigMapDeliveryStore {
+	return &ConfigMapDeliveryStore{
+		client:    c,
+		namespace: namespace,
+		name:      name,
+		ttl:       ttl,
+	}
+}
+
+// MarkDelivered implements DeliveryStore. Concurrent writers are serialized by
+// the ConfigMap resourceVersion: a conflicting update is retried from a fresh read.
+func (c *ConfigMapDeliveryStore) MarkDelivered(ctx context.Context, id string) (bool, error) {
+	if !deliveryIDPattern.MatchString(id) {
+		return false, fmt.Errorf("invalid delivery ID %q", id)
+	}
+
+	duplicate := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap := &corev1.ConfigMap{}
+		err := c.client.Get(ctx, client.ObjectKey{Namespace: c.namespace, Name: c.name}, configMap)
+		if apierrors.IsNotFound(err) {
+			configMap.Namespace = c.namespace
+			configMap.Name = c.name
+			configMap.Data = map[string]string{id: time.Now().UTC().Format(time.RFC3339)}
+			if err := c.client.Create(ctx, configMap); err != nil {
+				if apierrors.IsAlreadyExists(err) {
+					// Another replica created it first; retry against its copy
+					return apierrors.NewConflict(corev1.Resource("configmaps"), c.name, err)
+				}
+				return err
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		pruneDeliveries(configMap.Data, now, c.ttl, maxConfigMapDeliveries-1)
+		if _, ok := configMap.Data[id]; ok {
+			duplicate = true
+			return nil
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[id] = now.UTC().Format(time.RFC3339)
+		return c.client.Update(ctx, configMap)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to record delivery in ConfigMap %s/%s: %w", c.namespace, c.name, err)
+	}
+	return duplicate, nil
+}
+
+// Forget implements DeliveryStore
+func (c *ConfigMapDeliveryStore) Forget(ctx context.Context, id string) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap := &corev1.ConfigMap{}
+		err := c.client.Get(ctx, client.ObjectKey{Namespace: c.namespace, Name: c.name}, configMap)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, ok := configMap.Data[id]; !ok {
+			return nil
+		}
+		delete(configMap.Data, id)
+		return c.client.Update(ctx, configMap)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to forget delivery in ConfigMap %s/%s: %w", c.namespace, c.name, err)
+	}
+	return nil
+}
+
+// pruneDeliveries removes expired and unparsable entries from data and then
+// the oldest entries beyond maxEntries
+func pruneDeliveries(data map[string]string, now time.Time, ttl time.Duration, maxEntries int) {
+	type delivery struct {
+		at time.Time
+		id string
+	}
+
+	deliveries := make([]delivery, 0, len(data))
+	for id, value := range data {
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil || now.Sub(at) > ttl {
+			delete(data, id)
+			continue
+		}
+		deliveries = append(deliveries, delivery{at: at, id: id})
+	}
+
+	if len(deliveries) <= maxEntries {
+		return
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].at.Before(deliveries[j].at) })
+	for _, d := range deliveries[:len(deliveries)-maxEntries] {
+		delete(data, d.id)
+	}
+}
@@ -0,0 +1,334 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMemoryDeliveryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryDeliveryStore(time.Hour, 3)
+
+	if duplicate, _ := store.MarkDelivered(ctx, "a"); duplicate {
+		t.Error("first delivery of a reported as duplicate")
+	}
+	if duplicate, _ := store.MarkDelivered(ctx, "a"); !duplicate {
+		t.Error("second delivery of a not reported as duplicate")
+	}
+
+	// Filling the store evicts the oldest delivery
+	for _, id := range []string{"b", "c", "d"} {
+		if duplicate, _ := store.MarkDelivered(ctx, id); duplicate {
+			t.Errorf("first delivery of %s reported as duplicate", id)
+		}
+	}
+	if duplicate, _ := store.MarkDelivered(ctx, "a"); duplicate {
+		t.Error("evicted delivery a reported as duplicate")
+	}
+}
+
+func TestMemoryDeliveryStore_Forget(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryDeliveryStore(time.Hour, 3)
+
+	for _, id := range []string{"a", "b"} {
+		if _, err := store.MarkDelivered(ctx, id); err != nil {
+			t.Fatalf("MarkDelivered(%s) error = %v", id, err)
+		}
+	}
+	if err := store.Forget(ctx, "a"); err != nil {
+		t.Fatalf("Forget() error = %v", err)
+	}
+	if duplicate, _ := store.MarkDelivered(ctx, "a"); duplicate {
+		t.Error("forgotten delivery a reported as duplicate")
+	}
+	for _, id := range []string{"b", "a"} {
+		if duplicate, _ := store.MarkDelivered(ctx, id); !duplicate {
+			t.Errorf("delivery %s not reported as duplicate", id)
+		}
+	}
+}
+
+func TestMemoryDeliveryStore_Expires(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryDeliveryStore(10*time.Millisecond, 100)
+
+	if _, err := store.MarkDelivered(ctx, "a"); err != nil {
+		t.Fatalf("MarkDelivered() error = %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if duplicate, _ := store.MarkDelivered(ctx, "a"); duplicate {
+		t.Error("expired delivery reported as duplicate")
+	}
+}
+
+func TestConfigMapDeliveryStore(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add scheme: %v", err)
+	}
+
+	expired := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	existing := &corev1.ConfigMap{}
+	existing.Name = "deliveries"
+	existing.Namespace = "previewd-system"
+	existing.Data = map[string]string{"old": expired}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build()
+
+	// Two replicas share the ConfigMap
+	first := NewConfigMapDeliveryStore(k8sClient, "previewd-system", "deliveries", time.Hour)
+	second := NewConfigMapDeliveryStore(k8sClient, "previewd-system", "deliveries", time.Hour)
+
+	if duplicate, err := first.MarkDelivered(ctx, "72d3162e-cc78-11e3-81ab-4c9367dc0958"); err != nil || duplicate {
+		t.Fatalf("first MarkDelivered() = %v, %v; want false, nil", duplicate, err)
+	}
+	if duplicate, err := second.MarkDelivered(ctx, "72d3162e-cc78-11e3-81ab-4c9367dc0958"); err != nil || !duplicate {
+		t.Fatalf("second MarkDelivered() = %v, %v; want true, nil", duplicate, err)
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "previewd-system", Name: "deliveries"}, configMap); err != nil {
+		t.Fatalf("Failed to get ConfigMap: %v", err)
+	}
+	if _, ok := configMap.Data["old"]; ok {
+		t.Error("expired delivery was not pruned")
+	}
+	if len(configMap.Data) != 1 {
+		t.Errorf("ConfigMap holds %d deliveries, want 1", len(configMap.Data))
+	}
+
+	if _, err := first.MarkDelivered(ctx, "not a key"); err == nil {
+		t.Error("MarkDelivered() accepted an ID that is not a valid ConfigMap key")
+	}
+
+	// A delivery forgotten by one replica is accepted again by the other
+	if err := first.Forget(ctx, "72d3162e-cc78-11e3-81ab-4c9367dc0958"); err != nil {
+		t.Fatalf("Forget() error = %v", err)
+	}
+	if duplicate, err := second.MarkDelivered(ctx, "72d3162e-cc78-11e3-81ab-4c9367dc0958"); err != nil || duplicate {
+		t.Fatalf("MarkDelivered() after Forget() = %v, %v; want false, nil", duplicate, err)
+	}
+}
+
+func TestConfigMapDeliveryStore_CreatesConfigMap(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add scheme: %v", err)
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	store := NewConfigMapDeliveryStore(k8sClient, "previewd-system", "deliveries", time.Hour)
+
+	if duplicate, err := store.MarkDelivered(ctx, "a"); err != nil || duplicate {
+		t.Fatalf("MarkDelivered() = %v, %v; want false, nil", duplicate, err)
+	}
+	if duplicate, err := store.MarkDelivered(ctx, "a"); err != nil || !duplicate {
+		t.Fatalf("MarkDelivered() = %v, %v; want true, nil", duplicate, err)
+	}
+}
+
+func TestPruneDeliveries_KeepsNewest(t *testing.T) {
+	now := time.Now()
+	data := map[string]string{"bad": "yesterday"}
+	for i := 0; i < 5; i++ {
+		data[fmt.Sprintf("d%d", i)] = now.Add(time.Duration(i-5) * time.Minute).UTC().Format(time.RFC3339)
+	}
+
+	pruneDeliveries(data, now, time.Hour, 2)
+
+	if len(data) != 2 {
+		t.Fatalf("pruneDeliveries() left %v, want 2 entries", data)
+	}
+	for _, id := range []string{"d3", "d4"} {
+		if _, ok := data[id]; !ok {
+			t.Errorf("pruneDeliveries() dropped %s, one of the newest deliveries", id)
+		}
+	}
+}
+
+func TestHandleWebhook_DuplicateDelivery(t *testing.T) {
+	server, _ := setupTest(t)
+
+	event := PullRequestEvent{
+		Action:      "opened",
+		Number:      123,
+		PullRequest: PullRequest{Head: Ref{SHA: "abc123"}},
+		Repository:  Repository{FullName: "company/repo"},
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal test event: %v", err)
+	}
+
+	var codes []int
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+		req.Header.Set("X-GitHub-Event", "pull_request")
+		req.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+		req.Header.Set("X-Hub-Signature-256", computeSignature(payload, testSecret))
+		w := httptest.NewRecorder()
+
+		server.handleWebhook(w, req)
+		codes = append(codes, w.Code)
+	}
+
+	if codes[0] != http.StatusAccepted || codes[1] != http.StatusOK {
+		t.Errorf("delivery codes = %v, expected [%d %d]", codes, http.StatusAccepted, http.StatusOK)
+	}
+	if pending := server.events.take(eventKey("company/repo", 123)); len(pending) != 1 {
+		t.Errorf("%d events queued, expected 1", len(pending))
+	}
+}
+
+func TestHandleWebhook_RedeliveryOfDroppedEvent(t *testing.T) {
+	server, _ := setupTest(t)
+
+	// A synchronize event for a pull request without a PreviewEnvironment cannot succeed
+	payload, err := json.Marshal(PullRequestEvent{
+		Action:      "synchronize",
+		Number:      123,
+		PullRequest: PullRequest{Head: Ref{SHA: "abc123"}},
+		Repository:  Repository{FullName: "company/repo"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal test event: %v", err)
+	}
+	deliver := func() int {
+		req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+		req.Header.Set("X-GitHub-Event", "pull_request")
+		req.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+		req.Header.Set("X-Hub-Signature-256", computeSignature(payload, testSecret))
+		w := httptest.NewRecorder()
+		server.handleWebhook(w, req)
+		return w.Code
+	}
+
+	if code := deliver(); code != http.StatusAccepted {
+		t.Fatalf("first delivery returns %d, expected %d", code, http.StatusAccepted)
+	}
+	drainEvents(t, server)
+
+	if code := deliver(); code != http.StatusAccepted {
+		t.Errorf("redelivery of dropped event returns %d, expected %d", code, http.StatusAccepted)
+	}
+}
+
+func TestShutdown_ForgetsPendingDeliveries(t *testing.T) {
+	server, _ := setupTest(t)
+	ctx := context.Background()
+
+	if duplicate := server.isDuplicateDelivery(ctx, "pending"); duplicate {
+		t.Fatal("first delivery reported as duplicate")
+	}
+	if err := server.enqueuePullRequest(ctx, &PullRequestEvent{
+		Action:     "opened",
+		Number:     123,
+		Repository: Repository{FullName: "company/repo"},
+	}, "pending"); err != nil {
+		t.Fatalf("enqueuePullRequest() error = %v", err)
+	}
+
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if duplicate, _ := server.deliveries.MarkDelivered(ctx, "pending"); duplicate {
+		t.Error("delivery of an event dropped on shutdown is still recorded")
+	}
+}
+
+func TestHandlePRSynchronized_RejectsStaleEvents(t *testing.T) {
+	updatedAt := time.Date(2025, 11, 9, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		updatedAt time.Time
+		wantSHA   string
+	}{
+		{
+			name:      "newer event updates head SHA",
+			updatedAt: updatedAt.Add(time.Minute),
+			wantSHA:   "newsha",
+		},
+		{
+			name:      "older event is ignored",
+			updatedAt: updatedAt.Add(-time.Minute),
+			wantSHA:   "currentsha",
+		},
+		{
+			name:    "event without update time is applied",
+			wantSHA: "newsha",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, k8sClient := setupTest(t)
+
+			preview := &previewv1alpha1.PreviewEnvironment{}
+			preview.Name = testPreviewName
+			preview.Namespace = DefaultPreviewNamespace
+			preview.Labels = previewLabels("company/repo", 123)
+			preview.Spec.Repository = "company/repo"
+			preview.Annotations = map[string]string{
+				previewv1alpha1.HeadUpdatedAtAnnotation: updatedAt.Format(time.RFC3339),
+			}
+			preview.Spec.PRNumber = 123
+			preview.Spec.HeadSHA = "currentsha"
+			if err := k8sClient.Create(context.Background(), preview); err != nil {
+				t.Fatalf("Failed to create test PreviewEnvironment: %v", err)
+			}
+
+			postPullRequest(t, server, PullRequestEvent{
+				Action:      "synchronize",
+				Number:      123,
+				PullRequest: PullRequest{Head: Ref{SHA: "newsha"}, UpdatedAt: tt.updatedAt},
+				Repository:  Repository{FullName: "company/repo"},
+			})
+			drainEvents(t, server)
+
+			if err := k8sClient.Get(context.Background(), types.NamespacedName{
+				Name:      testPreviewName,
+				Namespace: "previewd-system",
+			}, preview); err != nil {
+				t.Fatalf("Failed to get PreviewEnvironment: %v", err)
+			}
+			if preview.Spec.HeadSHA != tt.wantSHA {
+				t.Errorf("PreviewEnvironment HeadSHA is %s, expected %s", preview.Spec.HeadSHA, tt.wantSHA)
+			}
+			if !tt.updatedAt.IsZero() && tt.wantSHA == "newsha" &&
+				preview.Annotations[previewv1alpha1.HeadUpdatedAtAnnotation] != tt.updatedAt.Format(time.RFC3339) {
+				t.Errorf("head-updated-at annotation is %q, expected %q",
+					preview.Annotations[previewv1alpha1.HeadUpdatedAtAnnotation], tt.updatedAt.Format(time.RFC3339))
+			}
+		})
+	}
+}
@@ -12,46 +12,202 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package webhook provides GitHub webhook handling for Previewd.
+// Package webhook provides Git provider webhook handling for Previewd.
 //
-// This package implements an HTTP server that receives GitHub pull request webhook
-// events and translates them into PreviewEnvironment Kubernetes resources.
+// This package implements an HTTP server that receives pull request and push
+// webhook events from GitHub, GitLab, Gitea/Forgejo and Bitbucket Cloud and
+// translates them into PreviewEnvironment Kubernetes resources.
 //
 // Key features:
-//   - Validates GitHub webhook signatures using HMAC-SHA256
+//   - Authenticates webhooks with each provider's signature or token scheme
 //   - Handles pull_request events (opened, synchronize, closed, reopened)
+//   - Runs /preview ChatOps commands from issue_comment events
+//   - Previews branches without pull requests from push events
 //   - Creates, updates, and deletes PreviewEnvironment resources
 //   - Provides per-repository rate limiting
 //   - Health check and readiness endpoints
 //
-// Webhook Security:
+// Git Providers:
 //
-// All webhook requests must include a valid X-Hub-Signature-256 header containing
-// an HMAC-SHA256 signature computed with the webhook secret. Requests with invalid
-// or missing signatures are rejected with HTTP 401.
+// Each Git hosting service is handled by a Provider, which recognises its
+// requests by their headers, authenticates them, and decodes the payload with
+// pull request actions normalized to GitHub's (see the list below). GitHub is
+// always enabled and handles every request no other provider claims;
+// WithGitLab and WithProvider enable the others:
+//   - GitHub: X-GitHub-Event, HMAC-SHA256 in X-Hub-Signature-256 ("sha256=<hex>")
+//   - GitLab: X-Gitlab-Event, the hook's secret token in X-Gitlab-Token
+//   - Gitea and Forgejo: X-Gitea-Event or X-Forgejo-Event, bare hex HMAC-SHA256
+//     in X-Gitea-Signature or X-Forgejo-Signature
+//   - Bitbucket Cloud: X-Event-Key, HMAC-SHA256 in X-Hub-Signature ("sha256=<hex>");
+//     pull request events only, so branch previews are not available
+//
+// Requests that fail authentication are rejected with HTTP 401.
+//
+// Each provider authenticates requests against a KeyRing, accepting any of its
+// keys, so a secret can be rotated without rejecting deliveries: add the new
+// secret, update the senders, then remove the old one. A SecretWatcher keeps a
+// KeyRing in sync with a Kubernetes Secret (for example its "webhook-secret"
+// and "previous-webhook-secret" data keys); with WithKeyRing, the same Secret
+// holds the secrets of other providers under their own data keys. Removing a
+// data key revokes its secret at once, and a provider none of whose data keys
+// holds a value rejects every delivery. The
+// previewd_webhook_signature_validations_total metric counts requests by
+// provider and by the name of the key that authenticated them ("invalid" if
+// none did), showing when a rotated-out key is no longer used.
+//
+// The provider
+// is recorded in Spec.Provider; only GitHub and GitLab previews read
+// .previewd.yaml, detect services and receive statuses and comments.
+// Bitbucket has no pull request labels and reports new commits and edits
+// alike as "updated", which is handled as synchronize; its webhooks carry
+// abbreviated commit hashes, which are used as the head SHA.
 //
 // Event Handling:
 //
+// The HTTP handler only validates, filters and queues events; it responds with
+// HTTP 202 Accepted as soon as an event is queued (HTTP 200 for events that
+// need no work). Workers then apply queued events with retries and exponential
+// backoff. Events for the same pull request are processed one at a time and in
+// order, and consecutive synchronize events are coalesced so only the latest
+// head SHA is deployed. At most 1000 events wait in the queue; further events
+// are refused with HTTP 503 until some have been processed.
+//
+// Pending events are held in memory. Providers do not redeliver events they
+// got a 202 for, so events still queued when the server stops are lost unless
+// WithEventStore is given a ConfigMapEventStore: stored events are kept until
+// processed and replayed when the server starts again.
+//
+// Providers also redeliver webhooks on their own, so each delivery ID
+// (X-GitHub-Delivery or the provider's equivalent) is processed only once;
+// redeliveries are acknowledged with HTTP 200. Delivery IDs are remembered for
+// a day in memory, or in a ConfigMap shared by all replicas when
+// WithDeliveryStore is given a ConfigMapDeliveryStore. The delivery ID of an
+// event that is dropped without being processed (after its retries, on an
+// error retrying cannot fix, or on shutdown without an event store) is
+// forgotten again, so a redelivery of it is processed.
+//
+// Synchronize events are checked against the pull request update time
+// recorded on the PreviewEnvironment (the previewd.io/head-updated-at
+// annotation): an event older than the last applied one is ignored, so an
+// out-of-order delivery cannot roll the preview back to an older head SHA.
+//
 // The webhook server processes the following pull_request actions:
 //   - opened: Creates a new PreviewEnvironment
 //   - synchronize: Updates the PreviewEnvironment with new head SHA
 //   - reopened: Recreates the PreviewEnvironment if deleted
 //   - closed: Deletes the PreviewEnvironment
+//   - labeled/unlabeled: Creates or deletes the PreviewEnvironment in label-gated mode
+//   - ready_for_review/converted_to_draft: Creates or deletes the PreviewEnvironment
+//     when draft pull requests are skipped
+//
+// GitLab Merge Requests:
+//
+// Merge Request Hook events are translated into the equivalent pull request
+// events and follow the same path: open, reopen, close and merge map to
+// opened, reopened and closed, and an update with new commits maps to
+// synchronize. Updates that toggle draft status or the preview label map to
+// the draft and label actions above. The PreviewEnvironment records
+// Spec.Provider "gitlab" and the full project path (which may include
+// subgroups) as Spec.Repository; .previewd.yaml, changed files and the config
+// status are read from and reported to GitLab. ChatOps commands are only
+// available on GitHub.
+//
+// PreviewEnvironment Placement:
+//
+// Each pull request gets a PreviewEnvironment named pr-<number>-<hash>, where
+// the hash is derived from the repository name, so pull requests with the same
+// number in different repositories never collide. PreviewEnvironments are
+// created in previewd-system unless WithNamespace or WithRepositoryNamespaces
+// choose another namespace. Later events find the PreviewEnvironment by its
+// preview.previewd.io/repository and preview.previewd.io/pr labels in any
+// namespace, the labels the controller also puts on every resource it creates
+// for the preview. PreviewEnvironments created by earlier versions (named
+// pr-<number> and labeled previewd.io/pr) are found by that label instead, so
+// their pull requests can still update and close them.
+//
+// Branch Previews:
+//
+// WithBranchPreviews previews branches that have no pull request. A push to a
+// branch matching one of the configured patterns (path.Match syntax, e.g.
+// "release/*") creates a PreviewEnvironment with Spec.Branch instead of
+// Spec.PRNumber, named br-<branch>-<hash>-<hash> and labeled
+// preview.previewd.io/branch; later pushes update its head SHA, and deleting the
+// branch deletes it. Consecutive pushes are coalesced like synchronize
+// events. Push and branch delete events are accepted from GitHub and
+// Gitea/Forgejo, and push events (including branch deletions) from GitLab.
+// Bitbucket repo:push events are ignored, since they list no changed files and
+// there is no Bitbucket client to read .previewd.yaml.
+//
+// A new branch preview deploys the services affected by the files its pushes
+// changed, as listed in the push payload (with WithServiceDetection), or else
+// the services listed in .previewd.yaml. A push that yields neither creates no
+// PreviewEnvironment, since it could only fail to provision; on GitHub and
+// GitLab this is reported as a failed "previewd/config" commit status. Later
+// pushes keep the services the preview was created with, unless they came
+// from .previewd.yaml.
+//
+// Opting In:
+//
+// By default every pull request is previewed. WithPreviewLabel restricts
+// previews to pull requests carrying a label, and WithSkipDrafts defers draft
+// pull requests until they are ready for review. Events for pull requests that
+// are not opted in are acknowledged and ignored.
+//
+// Service Detection:
+//
+// When configured with WithServiceDetection, opened and synchronize events list
+// the files changed in the pull request and fill Spec.Services with the affected
+// services (see package services). The first push of a branch preview does the
+// same with the files changed by the pushed commits.
+//
+// Repository Configuration:
+//
+// When a GitHub client is set with WithGitHubClient, opened and synchronize
+// events read .previewd.yaml from the pull request head commit and apply it to
+// the PreviewEnvironment spec (see package repoconfig). The file owns the TTL,
+// resource quota, ingress paths and source path, so removing a key clears the
+// field on the next push; services taken from the file are marked with the
+// previewd.io/services-from-config annotation and follow its changes too. An
+// invalid file is reported as a failed "previewd/config" commit status, the
+// PreviewEnvironment is left untouched and the event is not retried.
+//
+// ChatOps Commands:
+//
+// Pull request comments starting with /preview control the preview environment
+// of that pull request. Only collaborators with write access or above may run
+// commands; each command is acknowledged with a reaction and a reply.
+//   - /preview extend <duration>: Extends the TTL (e.g. 8h, 2d)
+//   - /preview redeploy: Wakes the preview, re-syncs its ArgoCD Applications and restarts its workloads
+//   - /preview sleep: Removes the deployed services but keeps the namespace
+//   - /preview destroy: Deletes the PreviewEnvironment
+//   - /preview services <a,b,...>: Replaces the deployed services
+//
+// The TTL and services set by commands are recorded in the
+// previewd.io/ttl-override and previewd.io/services-override annotations and
+// take precedence over .previewd.yaml and service detection on later pushes.
 //
 // Rate Limiting:
 //
-// Requests are rate-limited per repository using a token bucket algorithm.
-// The default limit is 10 requests per second per repository. Requests
-// exceeding the limit receive HTTP 429 Too Many Requests.
+// Requests are rate-limited per repository using a token bucket algorithm,
+// and optionally across all repositories with a global bucket (see
+// WithRateLimiter). The default limit is 10 requests per second per
+// repository. Requests exceeding a limit receive HTTP 429 Too Many Requests
+// with a Retry-After header, and the previewd_webhook_rate_limit_requests_total
+// metric counts allowed and throttled requests per repository.
 //
 // Example usage:
 //
-//	server := webhook.NewServer(
-//		k8sClient,
-//		"webhook-secret",
-//		8080,
-//	)
-//	if err := server.Start(ctx); err != nil {
+//	keys := webhook.NewKeyRing()
+//	watcher := webhook.NewSecretWatcher(watchClient, "previewd-system", "previewd-github-webhook",
+//		[]string{"webhook-secret", "previous-webhook-secret"}, keys)
+//	if err := watcher.Load(ctx); err != nil {
+//		log.Fatal(err)
+//	}
+//	server := webhook.NewServer("", 8082, mgr.GetClient(), "").WithGitHubKeys(keys).WithLeaderElection(true)
+//	if err := mgr.Add(watcher); err != nil {
+//		log.Fatal(err)
+//	}
+//	if err := mgr.Add(server); err != nil {
 //		log.Fatal(err)
 //	}
 package webhook
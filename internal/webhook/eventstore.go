@@ -0,0 +1,261 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// The event ConfigMap lives in the manager's namespace only, and must be named
+// previewd-webhook-events to match the default RBAC. Creation cannot be
+// restricted to a name, so it is allowed for any ConfigMap in that namespace.
+// +kubebuilder:rbac:groups="",namespace=system,resources=configmaps,verbs=create
+// +kubebuilder:rbac:groups="",namespace=system,resources=configmaps,resourceNames=previewd-webhook-events,verbs=get;update
+
+// maxConfigMapEvents bounds the ConfigMap-backed event store. Stored events
+// are a few hundred bytes each, which keeps the ConfigMap well below the
+// 1 MiB object size limit.
+const maxConfigMapEvents = 500
+
+// errEventStoreFull is returned when the event store cannot take more events
+var errEventStoreFull = errors.New("event store is full")
+
+// EventStore persists accepted webhook events until they have been processed,
+// so events acknowledged with HTTP 202 survive a restart and are replayed when
+// the server starts again.
+type EventStore interface {
+	// Save stores the encoded event under id
+	Save(ctx context.Context, id string, event []byte) error
+
+	// Delete removes the event stored under id, if any
+	Delete(ctx context.Context, id string) error
+
+	// List returns every stored event by id
+	List(ctx context.Context) (map[string][]byte, error)
+}
+
+// storedEvent is the encoding of a queuedEvent in an EventStore
+type storedEvent struct {
+	ReceivedAt  time.Time          `json:"receivedAt"`
+	PullRequest *PullRequestEvent  `json:"pullRequest,omitempty"`
+	Comment     *IssueCommentEvent `json:"comment,omitempty"`
+	Push        *PushEvent         `json:"push,omitempty"`
+	Provider    string             `json:"provider,omitempty"` // PullRequestEvent.Provider is not encoded
+	DeliveryID  string             `json:"deliveryID,omitempty"`
+}
+
+// ConfigMapEventStore is an EventStore backed by a ConfigMap. Each key of the
+// ConfigMap is an event ID and each value the JSON-encoded event.
+type ConfigMapEventStore struct {
+	client    client.Client
+	namespace string
+	name      string
+}
+
+// NewConfigMapEventStore creates an event store persisted in the ConfigMap
+// namespace/name, which is created on first use
+func NewConfigMapEventStore(c client.Client, namespace, name string) *ConfigMapEventStore {
+	return &ConfigMapEventStore{
+		client:    c,
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+// Save implements EventStore. It fails with errEventStoreFull once the
+// ConfigMap holds maxConfigMapEvents events.
+func (c *ConfigMapEventStore) Save(ctx context.Context, id string, event []byte) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap := &corev1.ConfigMap{}
+		err := c.client.Get(ctx, client.ObjectKey{Namespace: c.namespace, Name: c.name}, configMap)
+		if apierrors.IsNotFound(err) {
+			configMap.Namespace = c.namespace
+			configMap.Name = c.name
+			configMap.Data = map[string]string{id: string(event)}
+			if err := c.client.Create(ctx, configMap); err != nil {
+				if apierrors.IsAlreadyExists(err) {
+					// Another writer created it first; retry against its copy
+					return apierrors.NewConflict(corev1.Resource("configmaps"), c.name, err)
+				}
+				return err
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(configMap.Data) >= maxConfigMapEvents {
+			return errEventStoreFull
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[id] = string(event)
+		return c.client.Update(ctx, configMap)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store event in ConfigMap %s/%s: %w", c.namespace, c.name, err)
+	}
+	return nil
+}
+
+// Delete implements EventStore
+func (c *ConfigMapEventStore) Delete(ctx context.Context, id string) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap := &corev1.ConfigMap{}
+		err := c.client.Get(ctx, client.ObjectKey{Namespace: c.namespace, Name: c.name}, configMap)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, ok := configMap.Data[id]; !ok {
+			return nil
+		}
+		delete(configMap.Data, id)
+		return c.client.Update(ctx, configMap)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete event from ConfigMap %s/%s: %w", c.namespace, c.name, err)
+	}
+	return nil
+}
+
+// List implements EventStore
+func (c *ConfigMapEventStore) List(ctx context.Context) (map[string][]byte, error) {
+	configMap := &corev1.ConfigMap{}
+	err := c.client.Get(ctx, client.ObjectKey{Namespace: c.namespace, Name: c.name}, configMap)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events from ConfigMap %s/%s: %w", c.namespace, c.name, err)
+	}
+
+	events := make(map[string][]byte, len(configMap.Data))
+	for id, event := range configMap.Data {
+		events[id] = []byte(event)
+	}
+	return events, nil
+}
+
+// storeEvent saves event to the event store, if one is configured
+func (s *Server) storeEvent(ctx context.Context, event *queuedEvent) error {
+	if s.eventStore == nil {
+		return nil
+	}
+
+	stored := storedEvent{
+		ReceivedAt:  time.Now().UTC(),
+		PullRequest: event.pullRequest,
+		Comment:     event.comment,
+		Push:        event.push,
+		DeliveryID:  event.deliveryID,
+	}
+	if event.pullRequest != nil {
+		stored.Provider = event.pullRequest.Provider
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	return s.eventStore.Save(ctx, event.id, data)
+}
+
+// unstoreEvents removes events that have been processed, dropped or
+// superseded from the event store, if one is configured. Like forgetDelivery,
+// it runs with its own timeout so events finished during shutdown are removed.
+func (s *Server) unstoreEvents(ctx context.Context, events ...*queuedEvent) {
+	if s.eventStore == nil || len(events) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+	defer cancel()
+	for _, event := range events {
+		if err := s.eventStore.Delete(ctx, event.id); err != nil {
+			// The event is replayed on the next start; handlers are idempotent
+			log.FromContext(ctx).Error(err, "Failed to remove webhook event from the event store", "event", event.id)
+		}
+	}
+}
+
+// replayStoredEvents queues the events left in the event store by a previous
+// run, oldest first
+func (s *Server) replayStoredEvents(ctx context.Context) error {
+	if s.eventStore == nil {
+		return nil
+	}
+	logger := log.FromContext(ctx)
+
+	stored, err := s.eventStore.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	type replayed struct {
+		event      *queuedEvent
+		receivedAt time.Time
+	}
+	events := make([]replayed, 0, len(stored))
+	for id, data := range stored {
+		var decoded storedEvent
+		err := json.Unmarshal(data, &decoded)
+		if err == nil && decoded.PullRequest == nil && decoded.Comment == nil && decoded.Push == nil {
+			err = errors.New("stored event holds no event")
+		}
+		if err != nil {
+			logger.Error(err, "Discarding undecodable stored webhook event", "event", id)
+			s.unstoreEvents(ctx, &queuedEvent{id: id})
+			continue
+		}
+		if decoded.PullRequest != nil {
+			decoded.PullRequest.Provider = decoded.Provider
+		}
+		events = append(events, replayed{
+			event: &queuedEvent{
+				pullRequest: decoded.PullRequest,
+				comment:     decoded.Comment,
+				push:        decoded.Push,
+				deliveryID:  decoded.DeliveryID,
+				id:          id,
+			},
+			receivedAt: decoded.ReceivedAt,
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].receivedAt.Before(events[j].receivedAt) })
+
+	for _, r := range events {
+		s.unstoreEvents(ctx, s.events.add(r.event.key(), r.event)...)
+	}
+	if len(events) > 0 {
+		logger.Info("Replayed stored webhook events", "count", len(events))
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// setupEventStoreTest returns a client serving both PreviewEnvironments and
+// ConfigMaps, and an event store backed by it
+func setupEventStoreTest(t *testing.T) (client.Client, *ConfigMapEventStore) {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := previewv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add scheme: %v", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add scheme: %v", err)
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	return k8sClient, NewConfigMapEventStore(k8sClient, "previewd-system", "events")
+}
+
+// deliverPullRequest posts a pull_request event with a delivery ID and returns the status code
+func deliverPullRequest(t *testing.T, server *Server, event PullRequestEvent, deliveryID string) int {
+	t.Helper()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal test event: %v", err)
+	}
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	req.Header.Set("X-GitHub-Delivery", deliveryID)
+	req.Header.Set("X-Hub-Signature-256", computeSignature(payload, testSecret))
+	w := httptest.NewRecorder()
+
+	server.handleWebhook(w, req)
+	return w.Code
+}
+
+func TestConfigMapEventStore(t *testing.T) {
+	ctx := context.Background()
+	_, store := setupEventStoreTest(t)
+
+	if err := store.Save(ctx, "a", []byte(`{"push":{}}`)); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := store.Save(ctx, "b", []byte(`{"comment":{}}`)); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := store.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	events, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(events) != 1 || string(events["b"]) != `{"comment":{}}` {
+		t.Errorf("List() = %v, want only b", events)
+	}
+}
+
+func TestConfigMapEventStore_Full(t *testing.T) {
+	ctx := context.Background()
+	_, store := setupEventStoreTest(t)
+
+	for i := 0; i < maxConfigMapEvents; i++ {
+		if err := store.Save(ctx, fmt.Sprintf("e%d", i), []byte(`{}`)); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	if err := store.Save(ctx, "overflow", []byte(`{}`)); !errors.Is(err, errEventStoreFull) {
+		t.Errorf("Save() error = %v, want %v", err, errEventStoreFull)
+	}
+}
+
+func TestServer_ReplaysStoredEvents(t *testing.T) {
+	ctx := context.Background()
+	k8sClient, store := setupEventStoreTest(t)
+
+	server := NewServer("localhost", 8080, k8sClient, testSecret).WithEventStore(store)
+	code := deliverPullRequest(t, server, PullRequestEvent{
+		Action:      "opened",
+		Number:      123,
+		PullRequest: PullRequest{Head: Ref{SHA: "abc123"}},
+		Repository:  Repository{FullName: "company/repo"},
+	}, "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	if code != http.StatusAccepted {
+		t.Fatalf("handleWebhook returns %d, expected %d", code, http.StatusAccepted)
+	}
+
+	// The server stops before processing the event
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if events, _ := store.List(ctx); len(events) != 1 {
+		t.Fatalf("event store holds %d events after shutdown, want 1", len(events))
+	}
+
+	restarted := NewServer("localhost", 8080, k8sClient, testSecret).WithEventStore(store)
+	if err := restarted.replayStoredEvents(ctx); err != nil {
+		t.Fatalf("replayStoredEvents() error = %v", err)
+	}
+	drainEvents(t, restarted)
+
+	previews := &previewv1alpha1.PreviewEnvironmentList{}
+	if err := k8sClient.List(ctx, previews); err != nil {
+		t.Fatalf("Failed to list PreviewEnvironments: %v", err)
+	}
+	if len(previews.Items) != 1 || previews.Items[0].Spec.HeadSHA != "abc123" {
+		t.Errorf("PreviewEnvironments after replay = %v, want one at abc123", previews.Items)
+	}
+	if events, _ := store.List(ctx); len(events) != 0 {
+		t.Errorf("event store holds %d events after processing, want 0", len(events))
+	}
+}
+
+func TestServer_SupersededEventsLeaveTheEventStore(t *testing.T) {
+	ctx := context.Background()
+	k8sClient, store := setupEventStoreTest(t)
+	server := NewServer("localhost", 8080, k8sClient, testSecret).WithEventStore(store)
+
+	for i, sha := range []string{"sha1", "sha2"} {
+		code := deliverPullRequest(t, server, PullRequestEvent{
+			Action:      "synchronize",
+			Number:      123,
+			PullRequest: PullRequest{Head: Ref{SHA: sha}},
+			Repository:  Repository{FullName: "company/repo"},
+		}, fmt.Sprintf("delivery-%d", i))
+		if code != http.StatusAccepted {
+			t.Fatalf("handleWebhook returns %d, expected %d", code, http.StatusAccepted)
+		}
+	}
+
+	// The first synchronize event was coalesced into the second
+	if events, _ := store.List(ctx); len(events) != 1 {
+		t.Errorf("event store holds %d events, want 1", len(events))
+	}
+}
+
+func TestHandleWebhook_RefusesEventsWhenQueueFull(t *testing.T) {
+	server, _ := setupTest(t)
+	server.events.maxPending = 1
+
+	opened := func(number int) PullRequestEvent {
+		return PullRequestEvent{
+			Action:      "opened",
+			Number:      number,
+			PullRequest: PullRequest{Head: Ref{SHA: "abc123"}},
+			Repository:  Repository{FullName: "company/repo"},
+		}
+	}
+
+	if code := deliverPullRequest(t, server, opened(1), "first"); code != http.StatusAccepted {
+		t.Fatalf("first delivery returns %d, expected %d", code, http.StatusAccepted)
+	}
+	if code := deliverPullRequest(t, server, opened(2), "second"); code != http.StatusServiceUnavailable {
+		t.Fatalf("delivery to a full queue returns %d, expected %d", code, http.StatusServiceUnavailable)
+	}
+
+	// Once the queue has room, the refused delivery is accepted
+	drainEvents(t, server)
+	if code := deliverPullRequest(t, server, opened(2), "second"); code != http.StatusAccepted {
+		t.Errorf("redelivery returns %d, expected %d", code, http.StatusAccepted)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+)
+
+// giteaProvider handles Gitea and Forgejo webhooks. Their pull request
+// payloads mirror GitHub's, and they are signed with a bare hex-encoded
+// HMAC-SHA256 of the payload. Forgejo sends X-Forgejo-* headers alongside the
+// X-Gitea-* ones.
+type giteaProvider struct {
+	keys *KeyRing
+}
+
+// NewGiteaProvider returns the Provider for Gitea and Forgejo webhooks signed with any of keys
+func NewGiteaProvider(keys *KeyRing) Provider {
+	return &giteaProvider{keys: keys}
+}
+
+func (p *giteaProvider) Name() string {
+	return previewv1alpha1.ProviderGitea
+}
+
+func (p *giteaProvider) Matches(header http.Header) bool {
+	return giteaHeader(header, "Event") != ""
+}
+
+func (p *giteaProvider) Verify(header http.Header, payload []byte) (string, bool) {
+	signature := giteaHeader(header, "Signature")
+	return p.keys.match(func(secret string) bool {
+		return validHMAC(payload, signature, secret)
+	})
+}
+
+func (p *giteaProvider) DeliveryID(header http.Header) string {
+	return giteaHeader(header, "Delivery")
+}
+
+func (p *giteaProvider) Decode(header http.Header, payload []byte, previewLabel string) (*Event, error) {
+	eventType := giteaHeader(header, "Event")
+	if eventType == "push" || eventType == "delete" {
+		return decodeBranchEvent(eventType, payload)
+	}
+
+	// Label and synchronize events share the pull_request event; the
+	// X-Gitea-Event-Type header and the action tell them apart
+	if eventType != "pull_request" {
+		return nil, nil
+	}
+
+	var event PullRequestEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+
+	switch event.Action {
+	case "synchronized":
+		event.Action = "synchronize"
+	case "label_updated", "label_cleared":
+		// Gitea reports the new label set, not which label changed
+		event.Action = "edited"
+		if previewLabel != "" {
+			event.Action = "unlabeled"
+			if hasLabel(event.PullRequest.Labels, previewLabel) {
+				event.Action = "labeled"
+			}
+			event.Label = &Label{Name: previewLabel}
+		}
+	}
+	return &Event{PullRequest: &event}, nil
+}
+
+// giteaHeader returns the X-Forgejo-<name> header, or X-Gitea-<name> if unset
+func giteaHeader(header http.Header, name string) string {
+	if value := header.Get("X-Forgejo-" + name); value != "" {
+		return value
+	}
+	return header.Get("X-Gitea-" + name)
+}
+
+// hasLabel reports whether labels include name, ignoring case
+func hasLabel(labels []Label, name string) bool {
+	for _, label := range labels {
+		if strings.EqualFold(label.Name, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,259 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/repoconfig"
+)
+
+const (
+	// gitlabMergeRequestHook is the X-Gitlab-Event value of merge request events
+	gitlabMergeRequestHook = "Merge Request Hook"
+
+	// gitlabPushHook is the X-Gitlab-Event value of push events, which GitLab
+	// also sends when a branch is deleted
+	gitlabPushHook = "Push Hook"
+)
+
+// gitlabTimeLayouts are the timestamp formats found in GitLab webhook payloads
+var gitlabTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05 MST", "2006-01-02 15:04:05 -0700"}
+
+// MergeRequestHook represents a GitLab merge request webhook event
+type MergeRequestHook struct {
+	ObjectAttributes MergeRequestAttributes `json:"object_attributes"`
+	Project          GitLabProject          `json:"project"`
+	Changes          MergeRequestChanges    `json:"changes"`
+	ObjectKind       string                 `json:"object_kind"`
+	Labels           []GitLabLabel          `json:"labels"`
+}
+
+// MergeRequestAttributes contains merge request metadata
+type MergeRequestAttributes struct {
+	LastCommit     GitLabCommit `json:"last_commit"`
+	Action         string       `json:"action"` // open, reopen, update, close, merge, approved, ...
+	State          string       `json:"state"`
+	Title          string       `json:"title"`
+	SourceBranch   string       `json:"source_branch"`
+	TargetBranch   string       `json:"target_branch"`
+	OldRev         string       `json:"oldrev"` // set on update when new commits were pushed
+	UpdatedAt      string       `json:"updated_at"`
+	IID            int          `json:"iid"`
+	Draft          bool         `json:"draft"`
+	WorkInProgress bool         `json:"work_in_progress"`
+}
+
+// MergeRequestChanges lists the attributes an update event changed
+type MergeRequestChanges struct {
+	Labels *struct {
+		Previous []GitLabLabel `json:"previous"`
+		Current  []GitLabLabel `json:"current"`
+	} `json:"labels"`
+	Draft *struct {
+		Previous bool `json:"previous"`
+		Current  bool `json:"current"`
+	} `json:"draft"`
+}
+
+// PushHook represents a GitLab push webhook event
+type PushHook struct {
+	Project GitLabProject `json:"project"`
+	Ref     string        `json:"ref"` // refs/heads/<branch> for branch pushes
+	After   string        `json:"after"`
+	Commits []pushCommit  `json:"commits"`
+}
+
+// GitLabProject represents the project a merge request belongs to
+type GitLabProject struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+	WebURL            string `json:"web_url"`
+	ID                int    `json:"id"`
+}
+
+// GitLabCommit represents a commit in a GitLab webhook payload
+type GitLabCommit struct {
+	ID string `json:"id"`
+}
+
+// GitLabLabel represents a label in a GitLab webhook payload
+type GitLabLabel struct {
+	Title string `json:"title"`
+}
+
+// gitlabProvider handles GitLab webhooks. GitLab sends the hook's secret
+// token verbatim in X-Gitlab-Token rather than signing the payload.
+type gitlabProvider struct {
+	tokens *KeyRing
+}
+
+// NewGitLabProvider returns the Provider for GitLab webhooks carrying any of tokens
+func NewGitLabProvider(tokens *KeyRing) Provider {
+	return &gitlabProvider{tokens: tokens}
+}
+
+func (p *gitlabProvider) Name() string {
+	return previewv1alpha1.ProviderGitLab
+}
+
+func (p *gitlabProvider) Matches(header http.Header) bool {
+	return header.Get("X-Gitlab-Event") != ""
+}
+
+func (p *gitlabProvider) Verify(header http.Header, _ []byte) (string, bool) {
+	return p.tokens.matchToken(header.Get("X-Gitlab-Token"))
+}
+
+func (p *gitlabProvider) DeliveryID(header http.Header) string {
+	return header.Get("X-Gitlab-Event-UUID")
+}
+
+func (p *gitlabProvider) Decode(header http.Header, payload []byte, previewLabel string) (*Event, error) {
+	switch header.Get("X-Gitlab-Event") {
+	case gitlabMergeRequestHook:
+		var hook MergeRequestHook
+		if err := json.Unmarshal(payload, &hook); err != nil {
+			return nil, err
+		}
+		return &Event{PullRequest: pullRequestFromMergeRequest(&hook, previewLabel)}, nil
+	case gitlabPushHook:
+		return decodeGitLabPush(payload)
+	default:
+		return nil, nil
+	}
+}
+
+// decodeGitLabPush parses a GitLab push event. A push whose new head is the
+// zero SHA deletes the branch; tag pushes are sent as a separate event.
+func decodeGitLabPush(payload []byte) (*Event, error) {
+	var hook PushHook
+	if err := json.Unmarshal(payload, &hook); err != nil {
+		return nil, err
+	}
+	branch, ok := strings.CutPrefix(hook.Ref, "refs/heads/")
+	if !ok {
+		return nil, nil
+	}
+
+	push := &PushEvent{
+		Repository: Repository{FullName: hook.Project.PathWithNamespace},
+		Branch:     branch,
+		SHA:        hook.After,
+		Files:      changedFiles(hook.Commits),
+	}
+	if hook.After == zeroSHA {
+		push.Deleted = true
+		push.SHA = ""
+		push.Files = nil
+	}
+	return &Event{Push: push}, nil
+}
+
+// pullRequestFromMergeRequest maps a GitLab merge request event onto the
+// pull request event lifecycle shared by every provider
+func pullRequestFromMergeRequest(hook *MergeRequestHook, previewLabel string) *PullRequestEvent {
+	attrs := hook.ObjectAttributes
+
+	event := &PullRequestEvent{
+		Number: attrs.IID,
+		PullRequest: PullRequest{
+			Head:   Ref{Ref: attrs.SourceBranch, SHA: attrs.LastCommit.ID},
+			Base:   Ref{Ref: attrs.TargetBranch},
+			Title:  attrs.Title,
+			State:  attrs.State,
+			Labels: make([]Label, 0, len(hook.Labels)),
+			Draft:  attrs.Draft || attrs.WorkInProgress,
+		},
+		Repository: Repository{FullName: hook.Project.PathWithNamespace},
+	}
+	for _, label := range hook.Labels {
+		event.PullRequest.Labels = append(event.PullRequest.Labels, Label{Name: label.Title})
+	}
+	for _, layout := range gitlabTimeLayouts {
+		if updatedAt, err := time.Parse(layout, attrs.UpdatedAt); err == nil {
+			event.PullRequest.UpdatedAt = updatedAt
+			break
+		}
+	}
+
+	switch attrs.Action {
+	case "open":
+		event.Action = "opened"
+	case "reopen":
+		event.Action = "reopened"
+	case "close", "merge":
+		event.Action = "closed"
+	case "update":
+		event.Action, event.Label = mergeRequestUpdateAction(hook, previewLabel)
+	default:
+		event.Action = attrs.Action
+	}
+	return event
+}
+
+// mergeRequestUpdateAction classifies a GitLab "update" event: new commits,
+// a draft status change or a change of the preview label
+func mergeRequestUpdateAction(hook *MergeRequestHook, previewLabel string) (string, *Label) {
+	changes := hook.Changes
+	switch {
+	case hook.ObjectAttributes.OldRev != "":
+		return "synchronize", nil
+	case changes.Draft != nil && changes.Draft.Current:
+		return "converted_to_draft", nil
+	case changes.Draft != nil:
+		return "ready_for_review", nil
+	case changes.Labels != nil:
+		before := hasGitLabLabel(changes.Labels.Previous, previewLabel)
+		after := hasGitLabLabel(changes.Labels.Current, previewLabel)
+		if after && !before {
+			return "labeled", &Label{Name: previewLabel}
+		}
+		if before && !after {
+			return "unlabeled", &Label{Name: previewLabel}
+		}
+	}
+	return "edited", nil
+}
+
+// hasGitLabLabel reports whether labels include name, ignoring case
+func hasGitLabLabel(labels []GitLabLabel, name string) bool {
+	for _, label := range labels {
+		if name != "" && strings.EqualFold(label.Title, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadGitLabRepoConfig reads .previewd.yaml from the project's commit sha.
+// An invalid file is reported on the commit as a failed status and returned as
+// an error wrapping repoconfig.ErrInvalidConfig.
+func (s *Server) loadGitLabRepoConfig(ctx context.Context, project, sha string) (*repoconfig.Config, error) {
+	if s.gitlabClient == nil {
+		return &repoconfig.Config{}, nil
+	}
+
+	config, err := repoconfig.LoadFromGitLab(ctx, s.gitlabClient, project, sha)
+	if errors.Is(err, repoconfig.ErrInvalidConfig) {
+		s.reportConfigFailure(ctx, previewv1alpha1.ProviderGitLab, project, sha, err.Error())
+	}
+	return config, err
+}
@@ -0,0 +1,250 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/gitlab"
+	"github.com/mikelane/previewd/internal/services"
+)
+
+// fakeGitLabClient serves merge request files and .previewd.yaml
+type fakeGitLabClient struct {
+	gitlab.Client
+	repoConfig string
+	files      []string
+	statuses   []*gitlab.Status
+}
+
+func (f *fakeGitLabClient) GetMRFiles(_ context.Context, _ string, _ int) ([]string, error) {
+	return f.files, nil
+}
+
+func (f *fakeGitLabClient) GetFileContents(_ context.Context, _, _, _ string) ([]byte, error) {
+	if f.repoConfig == "" {
+		return nil, gitlab.ErrNotFound
+	}
+	return []byte(f.repoConfig), nil
+}
+
+func (f *fakeGitLabClient) UpdateCommitStatus(_ context.Context, _, _ string, status *gitlab.Status) error {
+	f.statuses = append(f.statuses, status)
+	return nil
+}
+
+//nolint:gosec // Test token, not a real credential
+const testGitLabToken = "test-gitlab-token"
+
+// sendMergeRequestHook delivers a GitLab merge request event and returns the response code
+func sendMergeRequestHook(t *testing.T, server *Server, token string, hook *MergeRequestHook) int {
+	t.Helper()
+
+	payload, err := json.Marshal(hook)
+	if err != nil {
+		t.Fatalf("Failed to marshal test event: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-Gitlab-Event", gitlabMergeRequestHook)
+	req.Header.Set("X-Gitlab-Token", token)
+	w := httptest.NewRecorder()
+
+	server.handleWebhook(w, req)
+	drainEvents(t, server)
+	return w.Code
+}
+
+func newMergeRequestHook(action string) *MergeRequestHook {
+	return &MergeRequestHook{
+		ObjectKind: "merge_request",
+		Project:    GitLabProject{PathWithNamespace: "group/sub/project"},
+		ObjectAttributes: MergeRequestAttributes{
+			Action:       action,
+			IID:          7,
+			SourceBranch: "feature",
+			TargetBranch: "main",
+			LastCommit:   GitLabCommit{ID: "abc123"},
+			UpdatedAt:    "2025-11-09 12:00:00 UTC",
+		},
+	}
+}
+
+func TestHandleGitLabWebhook_Token(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		sent       string
+		wantCode   int
+	}{
+		{name: "accepts matching token", configured: testGitLabToken, sent: testGitLabToken, wantCode: http.StatusAccepted},
+		{name: "rejects wrong token", configured: testGitLabToken, sent: "wrong", wantCode: http.StatusUnauthorized},
+		{name: "rejects missing token", configured: testGitLabToken, wantCode: http.StatusUnauthorized},
+		{name: "rejects when GitLab is not configured", sent: "", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := setupTest(t)
+			server.WithGitLab(testKeys(tt.configured), nil)
+
+			if code := sendMergeRequestHook(t, server, tt.sent, newMergeRequestHook("open")); code != tt.wantCode {
+				t.Errorf("handleWebhook returns %d, expected %d", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestPullRequestFromMergeRequest(t *testing.T) {
+	withChanges := func(action string, edit func(*MergeRequestHook)) *MergeRequestHook {
+		hook := newMergeRequestHook(action)
+		edit(hook)
+		return hook
+	}
+
+	tests := []struct {
+		hook       *MergeRequestHook
+		name       string
+		wantAction string
+		wantLabel  bool
+	}{
+		{name: "open", hook: newMergeRequestHook("open"), wantAction: "opened"},
+		{name: "reopen", hook: newMergeRequestHook("reopen"), wantAction: "reopened"},
+		{name: "close", hook: newMergeRequestHook("close"), wantAction: "closed"},
+		{name: "merge", hook: newMergeRequestHook("merge"), wantAction: "closed"},
+		{name: "approved is passed through", hook: newMergeRequestHook("approved"), wantAction: "approved"},
+		{
+			name:       "update with new commits",
+			hook:       withChanges("update", func(h *MergeRequestHook) { h.ObjectAttributes.OldRev = "def456" }),
+			wantAction: "synchronize",
+		},
+		{
+			name: "marked as draft",
+			hook: withChanges("update", func(h *MergeRequestHook) {
+				h.Changes.Draft = &struct {
+					Previous bool `json:"previous"`
+					Current  bool `json:"current"`
+				}{Previous: false, Current: true}
+			}),
+			wantAction: "converted_to_draft",
+		},
+		{
+			name: "marked as ready",
+			hook: withChanges("update", func(h *MergeRequestHook) {
+				h.Changes.Draft = &struct {
+					Previous bool `json:"previous"`
+					Current  bool `json:"current"`
+				}{Previous: true, Current: false}
+			}),
+			wantAction: "ready_for_review",
+		},
+		{
+			name: "preview label added",
+			hook: withChanges("update", func(h *MergeRequestHook) {
+				h.Changes.Labels = &struct {
+					Previous []GitLabLabel `json:"previous"`
+					Current  []GitLabLabel `json:"current"`
+				}{Current: []GitLabLabel{{Title: "Preview"}}}
+			}),
+			wantAction: "labeled",
+			wantLabel:  true,
+		},
+		{
+			name: "preview label removed",
+			hook: withChanges("update", func(h *MergeRequestHook) {
+				h.Changes.Labels = &struct {
+					Previous []GitLabLabel `json:"previous"`
+					Current  []GitLabLabel `json:"current"`
+				}{Previous: []GitLabLabel{{Title: "preview"}, {Title: "bug"}}, Current: []GitLabLabel{{Title: "bug"}}}
+			}),
+			wantAction: "unlabeled",
+			wantLabel:  true,
+		},
+		{name: "other updates", hook: newMergeRequestHook("update"), wantAction: "edited"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := pullRequestFromMergeRequest(tt.hook, "preview")
+			if event.Action != tt.wantAction {
+				t.Errorf("Action = %q, want %q", event.Action, tt.wantAction)
+			}
+			if (event.Label != nil) != tt.wantLabel {
+				t.Errorf("Label = %v, want label: %v", event.Label, tt.wantLabel)
+			}
+			if event.Number != 7 ||
+				event.Repository.FullName != "group/sub/project" || event.PullRequest.Head.SHA != "abc123" {
+				t.Errorf("event = %+v, want GitLab MR !7 of group/sub/project at abc123", event)
+			}
+			if want := time.Date(2025, 11, 9, 12, 0, 0, 0, time.UTC); !event.PullRequest.UpdatedAt.Equal(want) {
+				t.Errorf("UpdatedAt = %v, want %v", event.PullRequest.UpdatedAt, want)
+			}
+		})
+	}
+}
+
+func TestHandleGitLabWebhook_Lifecycle(t *testing.T) {
+	server, _ := setupTest(t)
+	gl := &fakeGitLabClient{
+		repoConfig: "ttl: 8h\n",
+		files:      []string{"services/api/main.go"},
+	}
+	server.WithGitLab(testKeys(testGitLabToken), gl).WithServiceDetection(services.NewDetector(nil))
+
+	if code := sendMergeRequestHook(t, server, testGitLabToken, newMergeRequestHook("open")); code != http.StatusAccepted {
+		t.Fatalf("handleWebhook for MR opened returns %d, expected %d", code, http.StatusAccepted)
+	}
+
+	preview, err := server.findPreview(context.Background(), "group/sub/project", 7)
+	if err != nil {
+		t.Fatalf("Failed to find PreviewEnvironment: %v", err)
+	}
+	if preview.Spec.Provider != previewv1alpha1.ProviderGitLab || preview.Spec.HeadSHA != "abc123" {
+		t.Errorf("PreviewEnvironment spec = %+v, expected GitLab provider at abc123", preview.Spec)
+	}
+	if preview.Spec.TTL != "8h" || !reflect.DeepEqual(preview.Spec.Services, []string{"api"}) {
+		t.Errorf("PreviewEnvironment TTL = %q, Services = %v; expected 8h and [api]", preview.Spec.TTL, preview.Spec.Services)
+	}
+
+	if code := sendMergeRequestHook(t, server, testGitLabToken, newMergeRequestHook("merge")); code != http.StatusAccepted {
+		t.Fatalf("handleWebhook for MR merged returns %d, expected %d", code, http.StatusAccepted)
+	}
+	if _, err := server.findPreview(context.Background(), "group/sub/project", 7); err == nil {
+		t.Error("PreviewEnvironment still exists after the merge request was merged")
+	}
+}
+
+func TestHandleGitLabWebhook_InvalidRepoConfig(t *testing.T) {
+	server, _ := setupTest(t)
+	gl := &fakeGitLabClient{repoConfig: "ttl: forever\n"}
+	server.WithGitLab(testKeys(testGitLabToken), gl)
+
+	sendMergeRequestHook(t, server, testGitLabToken, newMergeRequestHook("open"))
+
+	if len(gl.statuses) != 1 || gl.statuses[0].State != gitlab.StatusStateFailed || gl.statuses[0].Name != configStatusContext {
+		t.Errorf("commit statuses = %v, expected one failed %s status", gl.statuses, configStatusContext)
+	}
+	if _, err := server.findPreview(context.Background(), "group/sub/project", 7); err == nil {
+		t.Error("PreviewEnvironment was created despite invalid config")
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/subtle"
+	"sync"
+)
+
+// DefaultKeyName names a webhook secret that was given as a plain value
+// rather than read from a Kubernetes Secret
+const DefaultKeyName = "default"
+
+// Key is a named webhook secret. The name identifies the secret in logs and
+// metrics; for secrets read from a Kubernetes Secret it is the data key.
+type Key struct {
+	Name  string
+	Value string
+}
+
+// KeyRing holds the webhook secrets a provider currently accepts. Requests
+// authenticated by any key in the ring are accepted, so a secret can be
+// rotated without rejecting deliveries signed with the previous one. Keys can
+// be replaced while the server runs; a KeyRing is safe for concurrent use.
+type KeyRing struct {
+	keys []Key
+	mu   sync.RWMutex
+}
+
+// NewKeyRing returns a KeyRing holding keys, in order of preference
+func NewKeyRing(keys ...Key) *KeyRing {
+	ring := &KeyRing{}
+	ring.Set(keys)
+	return ring
+}
+
+// Set replaces the keys in the ring. Keys with empty values are dropped.
+func (r *KeyRing) Set(keys []Key) {
+	valid := make([]Key, 0, len(keys))
+	for _, key := range keys {
+		if key.Value != "" {
+			valid = append(valid, key)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = valid
+}
+
+// Names returns the names of the keys in the ring, in order of preference
+func (r *KeyRing) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.keys))
+	for _, key := range r.keys {
+		names = append(names, key.Name)
+	}
+	return names
+}
+
+// Len returns the number of keys in the ring
+func (r *KeyRing) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.keys)
+}
+
+// match returns the name of the first key whose secret valid accepts
+func (r *KeyRing) match(valid func(secret string) bool) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if valid(key.Value) {
+			return key.Name, true
+		}
+	}
+	return "", false
+}
+
+// matchToken returns the name of the key equal to token, for providers that
+// send the secret itself rather than a signature
+func (r *KeyRing) matchToken(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	return r.match(func(secret string) bool {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+	})
+}
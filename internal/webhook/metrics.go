@@ -0,0 +1,65 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// invalidKeyLabel is the key label of requests no key authenticated
+const invalidKeyLabel = "invalid"
+
+// signatureValidations counts webhook requests by provider and by the name of
+// the key that authenticated them, so a rotated-out key can be removed once
+// nothing is signed with it any more
+var signatureValidations = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "previewd_webhook_signature_validations_total",
+		Help: "Webhook requests by provider and the secret key that authenticated them (\"invalid\" if none did)",
+	},
+	[]string{"provider", "key"},
+)
+
+// rateLimitedRequests counts webhook requests allowed and throttled by the
+// rate limiter, per repository
+var rateLimitedRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "previewd_webhook_rate_limit_requests_total",
+		Help: "Webhook requests checked against the rate limits, by repository and result (allowed or throttled)",
+	},
+	[]string{"repository", "result"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(signatureValidations, rateLimitedRequests)
+}
+
+// recordSignatureValidation counts a webhook request's authentication result
+func recordSignatureValidation(provider, key string, ok bool) {
+	if !ok {
+		key = invalidKeyLabel
+	}
+	signatureValidations.WithLabelValues(provider, key).Inc()
+}
+
+// recordRateLimit counts a rate limiting decision for repo
+func recordRateLimit(repo string, allowed bool) {
+	result := "throttled"
+	if allowed {
+		result = "allowed"
+	}
+	rateLimitedRequests.WithLabelValues(repo, result).Inc()
+}
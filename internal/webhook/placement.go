@@ -0,0 +1,167 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultPreviewNamespace is where PreviewEnvironments are created unless
+	// configured otherwise
+	DefaultPreviewNamespace = "previewd-system"
+
+	// legacyPRLabel records the pull request number on PreviewEnvironments
+	// created before the preview.previewd.io labels and repository-hashed
+	// names. Those are named pr-<number> and carry no repository hash.
+	legacyPRLabel = "previewd.io/pr"
+)
+
+// previewName returns the PreviewEnvironment name for a pull request. Like the
+// preview namespace name, it includes a hash of the repository so pull
+// requests with the same number in different repositories do not collide.
+func previewName(repository string, number int) string {
+	spec := previewv1alpha1.PreviewEnvironmentSpec{Repository: repository, PRNumber: number}
+	return spec.InstanceID()
+}
+
+// previewLabels returns the labels identifying the pull request's
+// PreviewEnvironment: the same identity labels the controller puts on the
+// resources it creates for the preview
+func previewLabels(repository string, number int) map[string]string {
+	spec := previewv1alpha1.PreviewEnvironmentSpec{Repository: repository, PRNumber: number}
+	return spec.IdentityLabels()
+}
+
+// branchPreviewName returns the PreviewEnvironment name for a branch:
+// the branch's PreviewID followed by the repository hash used by previewName
+func branchPreviewName(repository, branch string) string {
+	spec := previewv1alpha1.PreviewEnvironmentSpec{Repository: repository, Branch: branch}
+	return spec.InstanceID()
+}
+
+// branchPreviewLabels returns the labels identifying the branch's
+// PreviewEnvironment, like previewLabels
+func branchPreviewLabels(repository, branch string) map[string]string {
+	spec := previewv1alpha1.PreviewEnvironmentSpec{Repository: repository, Branch: branch}
+	return spec.IdentityLabels()
+}
+
+// namespaceFor returns the namespace PreviewEnvironments of repository are created in
+func (s *Server) namespaceFor(repository string) string {
+	if namespace, ok := s.repoNamespaces[strings.ToLower(repository)]; ok {
+		return namespace
+	}
+	return s.namespace
+}
+
+// findPreviews returns the PreviewEnvironments of a pull request in any
+// namespace. They are looked up by label rather than by name, so they are
+// found even if the namespace mapping changed after they were created.
+// PreviewEnvironments created before the current labels are found by the
+// legacy previewd.io/pr label, so they are still updated and deleted.
+func (s *Server) findPreviews(ctx context.Context, repository string, number int) ([]previewv1alpha1.PreviewEnvironment, error) {
+	previews, err := s.listPreviews(ctx, repository, number, previewLabels(repository, number))
+	if err != nil || len(previews) > 0 {
+		return previews, err
+	}
+	return s.listPreviews(ctx, repository, number, map[string]string{legacyPRLabel: strconv.Itoa(number)})
+}
+
+// listPreviews returns the PreviewEnvironments of a pull request carrying labels
+func (s *Server) listPreviews(ctx context.Context, repository string, number int,
+	labels map[string]string) ([]previewv1alpha1.PreviewEnvironment, error) {
+	list := &previewv1alpha1.PreviewEnvironmentList{}
+	if err := s.client.List(ctx, list, client.MatchingLabels(labels)); err != nil {
+		return nil, fmt.Errorf("failed to list PreviewEnvironments: %w", err)
+	}
+
+	// Repository hashes can collide, and legacy labels hold no repository at
+	// all; the spec is authoritative
+	previews := make([]previewv1alpha1.PreviewEnvironment, 0, len(list.Items))
+	for _, preview := range list.Items {
+		if preview.Spec.PRNumber == number && !preview.Spec.IsBranchPreview() &&
+			strings.EqualFold(preview.Spec.Repository, repository) {
+			previews = append(previews, preview)
+		}
+	}
+	return previews, nil
+}
+
+// findPreview returns the PreviewEnvironment of a pull request, or a NotFound
+// error if there is none
+func (s *Server) findPreview(ctx context.Context, repository string, number int) (*previewv1alpha1.PreviewEnvironment, error) {
+	previews, err := s.findPreviews(ctx, repository, number)
+	if err != nil {
+		return nil, err
+	}
+	if len(previews) == 0 {
+		resource := previewv1alpha1.GroupVersion.WithResource("previewenvironments").GroupResource()
+		return nil, apierrors.NewNotFound(resource, previewName(repository, number))
+	}
+	return &previews[0], nil
+}
+
+// findBranchPreviews returns the PreviewEnvironments of a branch in any
+// namespace, looked up by label like findPreviews. Branch previews have always
+// carried the current labels, so there is no legacy lookup.
+func (s *Server) findBranchPreviews(ctx context.Context, repository, branch string) ([]previewv1alpha1.PreviewEnvironment, error) {
+	list := &previewv1alpha1.PreviewEnvironmentList{}
+	if err := s.client.List(ctx, list, client.MatchingLabels(branchPreviewLabels(repository, branch))); err != nil {
+		return nil, fmt.Errorf("failed to list PreviewEnvironments: %w", err)
+	}
+
+	// Branch IDs and repository hashes can collide; the spec is authoritative
+	previews := make([]previewv1alpha1.PreviewEnvironment, 0, len(list.Items))
+	for _, preview := range list.Items {
+		if preview.Spec.Branch == branch && strings.EqualFold(preview.Spec.Repository, repository) {
+			previews = append(previews, preview)
+		}
+	}
+	return previews, nil
+}
+
+// ParseNamespaceMapping parses a per-repository namespace mapping of the form
+// "owner/repo=namespace;other/repo=namespace". Repository names are matched
+// case-insensitively.
+func ParseNamespaceMapping(spec string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		repository, namespace, found := strings.Cut(entry, "=")
+		repository = strings.TrimSpace(repository)
+		namespace = strings.TrimSpace(namespace)
+		if !found || !strings.Contains(repository, "/") {
+			return nil, fmt.Errorf("invalid namespace mapping %q: expected owner/repo=namespace", entry)
+		}
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid namespace %q for %s: %s", namespace, repository, strings.Join(errs, ", "))
+		}
+		mapping[strings.ToLower(repository)] = namespace
+	}
+	return mapping, nil
+}
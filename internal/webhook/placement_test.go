@@ -0,0 +1,188 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestPreviewName(t *testing.T) {
+	first := previewName("company/repo", 123)
+	second := previewName("company/other", 123)
+
+	if first == second {
+		t.Errorf("previewName() = %q for two repositories, expected distinct names", first)
+	}
+	if first != previewName("company/repo", 123) {
+		t.Error("previewName() is not deterministic")
+	}
+	if mixedCase := previewName("Company/Repo", 123); mixedCase != first {
+		t.Errorf("previewName() = %q for Company/Repo and %q for company/repo, expected the same name", mixedCase, first)
+	}
+	if labels := previewLabels("Company/Repo", 123); !reflect.DeepEqual(labels, previewLabels("company/repo", 123)) {
+		t.Errorf("previewLabels() = %v for Company/Repo, expected the labels of company/repo", labels)
+	}
+	if errs := validation.IsDNS1123Subdomain(first); len(errs) > 0 {
+		t.Errorf("previewName() = %q is not a valid object name: %v", first, errs)
+	}
+}
+
+func TestParseNamespaceMapping(t *testing.T) {
+	tests := []struct {
+		want    map[string]string
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{
+			name: "parses empty spec",
+			want: map[string]string{},
+		},
+		{
+			name: "parses multiple repositories",
+			spec: "Company/Web=team-web; company/api=team-api",
+			want: map[string]string{
+				"company/web": "team-web",
+				"company/api": "team-api",
+			},
+		},
+		{
+			name:    "rejects entry without namespace",
+			spec:    "company/web",
+			wantErr: true,
+		},
+		{
+			name:    "rejects entry without owner",
+			spec:    "web=team-web",
+			wantErr: true,
+		},
+		{
+			name:    "rejects invalid namespace",
+			spec:    "company/web=Team_Web",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNamespaceMapping(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNamespaceMapping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseNamespaceMapping() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleWebhook_SamePRNumberInTwoRepositories(t *testing.T) {
+	server, k8sClient := setupTest(t)
+	server.WithNamespace("previews").WithRepositoryNamespaces(map[string]string{"company/api": "team-api"})
+
+	for _, repository := range []string{"company/repo", "company/api"} {
+		postPullRequest(t, server, PullRequestEvent{
+			Action:      "opened",
+			Number:      123,
+			PullRequest: PullRequest{Head: Ref{SHA: "abc123"}},
+			Repository:  Repository{FullName: repository},
+		})
+	}
+	drainEvents(t, server)
+
+	list := &previewv1alpha1.PreviewEnvironmentList{}
+	if err := k8sClient.List(context.Background(), list); err != nil {
+		t.Fatalf("Failed to list PreviewEnvironments: %v", err)
+	}
+	got := map[string]string{}
+	for _, preview := range list.Items {
+		got[preview.Spec.Repository] = preview.Namespace + "/" + preview.Name
+	}
+	want := map[string]string{
+		"company/repo": "previews/" + previewName("company/repo", 123),
+		"company/api":  "team-api/" + previewName("company/api", 123),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("PreviewEnvironments = %v, want %v", got, want)
+	}
+	for _, preview := range list.Items {
+		// The controller selects the preview's resources by the same labels
+		if labels := preview.Spec.IdentityLabels(); !reflect.DeepEqual(preview.Labels, labels) {
+			t.Errorf("%s labels = %v, want %v", preview.Name, preview.Labels, labels)
+		}
+	}
+
+	// Closing one pull request leaves the other repository's preview alone
+	postPullRequest(t, server, PullRequestEvent{
+		Action:     "closed",
+		Number:     123,
+		Repository: Repository{FullName: "company/api"},
+	})
+	drainEvents(t, server)
+
+	if err := k8sClient.List(context.Background(), list); err != nil {
+		t.Fatalf("Failed to list PreviewEnvironments: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Spec.Repository != "company/repo" {
+		t.Errorf("PreviewEnvironments after close = %v, want only company/repo", list.Items)
+	}
+}
+
+func TestHandleWebhook_ClosesLegacyPreview(t *testing.T) {
+	server, k8sClient := setupTest(t)
+	ctx := context.Background()
+
+	// PreviewEnvironments created before repository-hashed names and the
+	// preview.previewd.io labels
+	for _, repository := range []string{"company/repo", "company/other"} {
+		legacy := &previewv1alpha1.PreviewEnvironment{}
+		legacy.Name = "pr-123"
+		legacy.Namespace = DefaultPreviewNamespace
+		if repository != "company/repo" {
+			legacy.Namespace = "other"
+		}
+		legacy.Labels = map[string]string{legacyPRLabel: "123", "previewd.io/repository": "company-repo"}
+		legacy.Spec.Repository = repository
+		legacy.Spec.PRNumber = 123
+		if err := k8sClient.Create(ctx, legacy); err != nil {
+			t.Fatalf("Failed to create legacy PreviewEnvironment: %v", err)
+		}
+	}
+
+	previews, err := server.findPreviews(ctx, "Company/Repo", 123)
+	if err != nil || len(previews) != 1 || previews[0].Namespace != DefaultPreviewNamespace {
+		t.Fatalf("findPreviews() = %v, %v; want the legacy preview of company/repo", previews, err)
+	}
+
+	postPullRequest(t, server, PullRequestEvent{
+		Action:     "closed",
+		Number:     123,
+		Repository: Repository{FullName: "company/repo"},
+	})
+	drainEvents(t, server)
+
+	list := &previewv1alpha1.PreviewEnvironmentList{}
+	if err := k8sClient.List(ctx, list); err != nil {
+		t.Fatalf("Failed to list PreviewEnvironments: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Spec.Repository != "company/other" {
+		t.Errorf("PreviewEnvironments after close = %v, want only company/other", list.Items)
+	}
+}
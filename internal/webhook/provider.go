@@ -0,0 +1,159 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+)
+
+// Provider adapts the webhooks of one Git hosting service. It recognises the
+// service's requests by their headers, authenticates them and decodes their
+// payloads into the events the server processes, with pull request actions
+// normalized to GitHub's pull_request lifecycle (opened, synchronize, closed,
+// reopened, labeled, unlabeled, ready_for_review, converted_to_draft).
+type Provider interface {
+	// Name returns the provider recorded in PreviewEnvironment Spec.Provider
+	Name() string
+	// Matches reports whether a request was sent by this provider
+	Matches(header http.Header) bool
+	// Verify reports whether the request is authentic, and the name of the
+	// key that authenticated it
+	Verify(header http.Header, payload []byte) (key string, ok bool)
+	// DeliveryID returns the unique ID of a delivery, or "" if the provider sends none
+	DeliveryID(header http.Header) string
+	// Decode parses a verified payload. previewLabel is the label opting pull
+	// requests into previews ("" if previews are not label-gated); providers
+	// that report label changes as a whole use it to derive labeled and
+	// unlabeled actions. Decode returns a nil event for events previewd does
+	// not handle.
+	Decode(header http.Header, payload []byte, previewLabel string) (*Event, error)
+}
+
+// Event is a decoded webhook event. Exactly one field is set.
+type Event struct {
+	PullRequest *PullRequestEvent
+	Comment     *IssueCommentEvent
+	Push        *PushEvent
+}
+
+// zeroSHA is the commit SHA providers report for the missing side of a
+// created or deleted branch
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// githubProvider handles GitHub webhooks, which are signed with HMAC-SHA256
+// in X-Hub-Signature-256
+type githubProvider struct {
+	keys *KeyRing
+}
+
+// NewGitHubProvider returns the Provider for GitHub webhooks signed with any of keys
+func NewGitHubProvider(keys *KeyRing) Provider {
+	return &githubProvider{keys: keys}
+}
+
+func (p *githubProvider) Name() string {
+	return previewv1alpha1.ProviderGitHub
+}
+
+func (p *githubProvider) Matches(header http.Header) bool {
+	return header.Get("X-GitHub-Event") != ""
+}
+
+func (p *githubProvider) Verify(header http.Header, payload []byte) (string, bool) {
+	signature := header.Get("X-Hub-Signature-256")
+	return p.keys.match(func(secret string) bool {
+		return ValidateSignature(payload, signature, secret)
+	})
+}
+
+func (p *githubProvider) DeliveryID(header http.Header) string {
+	return header.Get("X-GitHub-Delivery")
+}
+
+func (p *githubProvider) Decode(header http.Header, payload []byte, _ string) (*Event, error) {
+	switch header.Get("X-GitHub-Event") {
+	case "pull_request":
+		var event PullRequestEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		return &Event{PullRequest: &event}, nil
+	case "issue_comment":
+		var event IssueCommentEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		return &Event{Comment: &event}, nil
+	case "push", "delete":
+		return decodeBranchEvent(header.Get("X-GitHub-Event"), payload)
+	default:
+		return nil, nil
+	}
+}
+
+// decodeBranchEvent parses a GitHub-style push or delete event, as sent by
+// GitHub and Gitea. Pushes and deletions of tags return a nil event.
+func decodeBranchEvent(eventType string, payload []byte) (*Event, error) {
+	if eventType == "delete" {
+		var event deletePayload
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		if event.RefType != "branch" {
+			return nil, nil
+		}
+		return &Event{Push: &PushEvent{Repository: event.Repository, Branch: event.Ref, Deleted: true}}, nil
+	}
+
+	var event pushPayload
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	branch, ok := strings.CutPrefix(event.Ref, "refs/heads/")
+	if !ok {
+		return nil, nil
+	}
+	push := &PushEvent{Repository: event.Repository, Branch: branch, SHA: event.After, Files: changedFiles(event.Commits)}
+	if event.Deleted || event.After == zeroSHA {
+		push.Deleted = true
+		push.SHA = ""
+		push.Files = nil
+	}
+	return &Event{Push: push}, nil
+}
+
+// WithProvider accepts webhooks from another Git hosting service, such as
+// NewGiteaProvider or NewBitbucketProvider. Requests are matched against the
+// added providers by their headers before falling back to GitHub.
+func (s *Server) WithProvider(provider Provider) *Server {
+	s.providers = append(s.providers, provider)
+	return s
+}
+
+// providerFor returns the provider that sent a request. Requests no other
+// provider claims are treated as GitHub webhooks, so they are rejected unless
+// they carry a valid GitHub signature.
+func (s *Server) providerFor(header http.Header) Provider {
+	for _, provider := range s.providers {
+		if provider.Matches(header) {
+			return provider
+		}
+	}
+	return s.github
+}
@@ -0,0 +1,314 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+)
+
+//nolint:gosec // Test secrets, not real credentials
+const (
+	testGiteaSecret     = "test-gitea-secret"
+	testBitbucketSecret = "test-bitbucket-secret"
+)
+
+// testKeys returns a KeyRing holding secret under DefaultKeyName
+func testKeys(secret string) *KeyRing {
+	return NewKeyRing(Key{Name: DefaultKeyName, Value: secret})
+}
+
+// giteaSignature computes the bare hex HMAC Gitea sends in X-Gitea-Signature
+func giteaSignature(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func headerOf(pairs ...string) http.Header {
+	header := http.Header{}
+	for i := 0; i < len(pairs); i += 2 {
+		header.Set(pairs[i], pairs[i+1])
+	}
+	return header
+}
+
+func TestProviderFor(t *testing.T) {
+	server, _ := setupTest(t)
+	server.WithGitLab(testKeys(testGitLabToken), nil).
+		WithProvider(NewGiteaProvider(testKeys(testGiteaSecret))).
+		WithProvider(NewBitbucketProvider(testKeys(testBitbucketSecret)))
+
+	tests := []struct {
+		header http.Header
+		name   string
+		want   string
+	}{
+		{name: "GitHub", header: headerOf("X-GitHub-Event", "pull_request"), want: previewv1alpha1.ProviderGitHub},
+		{name: "GitLab", header: headerOf("X-Gitlab-Event", "Merge Request Hook"), want: previewv1alpha1.ProviderGitLab},
+		{
+			name:   "Gitea also sends X-GitHub-Event",
+			header: headerOf("X-GitHub-Event", "pull_request", "X-Gitea-Event", "pull_request"),
+			want:   previewv1alpha1.ProviderGitea,
+		},
+		{name: "Forgejo", header: headerOf("X-Forgejo-Event", "pull_request"), want: previewv1alpha1.ProviderGitea},
+		{name: "Bitbucket", header: headerOf("X-Event-Key", "pullrequest:created"), want: previewv1alpha1.ProviderBitbucket},
+		{name: "unknown requests fall back to GitHub", header: http.Header{}, want: previewv1alpha1.ProviderGitHub},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := server.providerFor(tt.header).Name(); got != tt.want {
+				t.Errorf("providerFor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGiteaProvider_Verify(t *testing.T) {
+	payload := []byte(`{"action":"opened"}`)
+	provider := NewGiteaProvider(testKeys(testGiteaSecret))
+
+	tests := []struct {
+		header http.Header
+		name   string
+		want   bool
+	}{
+		{name: "Gitea signature", header: headerOf("X-Gitea-Signature", giteaSignature(payload, testGiteaSecret)), want: true},
+		{name: "Forgejo signature", header: headerOf("X-Forgejo-Signature", giteaSignature(payload, testGiteaSecret)), want: true},
+		{name: "wrong secret", header: headerOf("X-Gitea-Signature", giteaSignature(payload, "wrong")), want: false},
+		{name: "GitHub format", header: headerOf("X-Gitea-Signature", computeSignature(payload, testGiteaSecret)), want: false},
+		{name: "missing signature", header: http.Header{}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := provider.Verify(tt.header, payload); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := NewGiteaProvider(testKeys("")).Verify(headerOf("X-Gitea-Signature", giteaSignature(payload, "")), payload); ok {
+		t.Error("Verify() accepted a request without a configured secret")
+	}
+}
+
+func TestGiteaProvider_Decode(t *testing.T) {
+	tests := []struct {
+		name         string
+		event        string
+		payload      string
+		previewLabel string
+		wantAction   string
+		wantNil      bool
+	}{
+		{name: "opened", event: "pull_request", payload: `{"action":"opened"}`, wantAction: "opened"},
+		{name: "synchronized", event: "pull_request", payload: `{"action":"synchronized"}`, wantAction: "synchronize"},
+		{
+			name:         "preview label added",
+			event:        "pull_request",
+			payload:      `{"action":"label_updated","pull_request":{"labels":[{"name":"Preview"}]}}`,
+			previewLabel: "preview",
+			wantAction:   "labeled",
+		},
+		{
+			name:         "preview label removed",
+			event:        "pull_request",
+			payload:      `{"action":"label_updated","pull_request":{"labels":[{"name":"bug"}]}}`,
+			previewLabel: "preview",
+			wantAction:   "unlabeled",
+		},
+		{
+			name:         "labels cleared",
+			event:        "pull_request",
+			payload:      `{"action":"label_cleared","pull_request":{"labels":[]}}`,
+			previewLabel: "preview",
+			wantAction:   "unlabeled",
+		},
+		{name: "labels without gating", event: "pull_request", payload: `{"action":"label_updated"}`, wantAction: "edited"},
+		{name: "other events", event: "issues", payload: `{}`, wantNil: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := NewGiteaProvider(testKeys(testGiteaSecret)).Decode(headerOf("X-Gitea-Event", tt.event), []byte(tt.payload), tt.previewLabel)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if tt.wantNil {
+				if event != nil {
+					t.Errorf("Decode() = %+v, want nil", event)
+				}
+				return
+			}
+			if event == nil || event.PullRequest == nil {
+				t.Fatalf("Decode() = %+v, want a pull request event", event)
+			}
+			if event.PullRequest.Action != tt.wantAction {
+				t.Errorf("Action = %q, want %q", event.PullRequest.Action, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestBitbucketProvider_Decode(t *testing.T) {
+	payload := `{
+		"repository": {"full_name": "workspace/repo", "name": "repo"},
+		"pullrequest": {
+			"id": 9,
+			"state": "OPEN",
+			"updated_on": "2025-11-09T12:00:00.123456+00:00",
+			"source": {"branch": {"name": "feature"}, "commit": {"hash": "abcdef123456"}},
+			"destination": {"branch": {"name": "main"}, "commit": {"hash": "123456abcdef"}}
+		}
+	}`
+
+	tests := []struct {
+		eventKey   string
+		wantAction string
+	}{
+		{eventKey: "pullrequest:created", wantAction: "opened"},
+		{eventKey: "pullrequest:updated", wantAction: "synchronize"},
+		{eventKey: "pullrequest:fulfilled", wantAction: "closed"},
+		{eventKey: "pullrequest:rejected", wantAction: "closed"},
+		{eventKey: "pullrequest:approved"},
+		{eventKey: "repo:push"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.eventKey, func(t *testing.T) {
+			event, err := NewBitbucketProvider(testKeys(testBitbucketSecret)).Decode(headerOf("X-Event-Key", tt.eventKey), []byte(payload), "")
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if tt.wantAction == "" {
+				if event != nil {
+					t.Errorf("Decode() = %+v, want nil", event)
+				}
+				return
+			}
+			if event == nil || event.PullRequest == nil {
+				t.Fatalf("Decode() = %+v, want a pull request event", event)
+			}
+
+			pr := event.PullRequest
+			if pr.Action != tt.wantAction {
+				t.Errorf("Action = %q, want %q", pr.Action, tt.wantAction)
+			}
+			if pr.Number != 9 || pr.Repository.FullName != "workspace/repo" ||
+				pr.PullRequest.Head.SHA != "abcdef123456" || pr.PullRequest.Head.Ref != "feature" {
+				t.Errorf("event = %+v, want PR 9 of workspace/repo at feature/abcdef123456", pr)
+			}
+			if pr.PullRequest.UpdatedAt.IsZero() {
+				t.Error("UpdatedAt was not decoded")
+			}
+		})
+	}
+}
+
+func TestHandleWebhook_Providers(t *testing.T) {
+	giteaPayload := []byte(`{
+		"action": "opened",
+		"number": 5,
+		"pull_request": {"head": {"ref": "feature", "sha": "0123456789abcdef0123456789abcdef01234567"}},
+		"repository": {"full_name": "team/app"}
+	}`)
+	bitbucketPayload := []byte(`{
+		"repository": {"full_name": "workspace/app"},
+		"pullrequest": {"id": 5, "source": {"branch": {"name": "feature"}, "commit": {"hash": "abcdef123456"}}}
+	}`)
+
+	tests := []struct {
+		header     http.Header
+		name       string
+		repository string
+		provider   string
+		payload    []byte
+		wantCode   int
+	}{
+		{
+			name: "Gitea",
+			header: headerOf("X-Gitea-Event", "pull_request", "X-Gitea-Delivery", "gitea-1",
+				"X-Gitea-Signature", giteaSignature(giteaPayload, testGiteaSecret)),
+			payload:    giteaPayload,
+			repository: "team/app",
+			provider:   previewv1alpha1.ProviderGitea,
+			wantCode:   http.StatusAccepted,
+		},
+		{
+			name: "Bitbucket",
+			header: headerOf("X-Event-Key", "pullrequest:created", "X-Request-UUID", "bitbucket-1",
+				"X-Hub-Signature", computeSignature(bitbucketPayload, testBitbucketSecret)),
+			payload:    bitbucketPayload,
+			repository: "workspace/app",
+			provider:   previewv1alpha1.ProviderBitbucket,
+			wantCode:   http.StatusAccepted,
+		},
+		{
+			name: "Gitea signed with the GitHub secret",
+			header: headerOf("X-Gitea-Event", "pull_request",
+				"X-Gitea-Signature", giteaSignature(giteaPayload, testSecret)),
+			payload:  giteaPayload,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "Bitbucket signed with the Gitea secret",
+			header: headerOf("X-Event-Key", "pullrequest:created",
+				"X-Hub-Signature", computeSignature(bitbucketPayload, testGiteaSecret)),
+			payload:  bitbucketPayload,
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := setupTest(t)
+			server.WithProvider(NewGiteaProvider(testKeys(testGiteaSecret))).
+				WithProvider(NewBitbucketProvider(testKeys(testBitbucketSecret)))
+
+			req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(tt.payload))
+			req.Header = tt.header
+			w := httptest.NewRecorder()
+
+			server.handleWebhook(w, req)
+			drainEvents(t, server)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("handleWebhook returns %d, expected %d: %s", w.Code, tt.wantCode, strings.TrimSpace(w.Body.String()))
+			}
+			if tt.wantCode != http.StatusAccepted {
+				return
+			}
+
+			preview, err := server.findPreview(context.Background(), tt.repository, 5)
+			if err != nil {
+				t.Fatalf("Failed to find PreviewEnvironment: %v", err)
+			}
+			if preview.Spec.Provider != tt.provider {
+				t.Errorf("PreviewEnvironment Provider = %q, want %q", preview.Spec.Provider, tt.provider)
+			}
+		})
+	}
+}
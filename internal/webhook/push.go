@@ -0,0 +1,194 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// noBranchServicesDescription is the commit status description of a branch
+// push that found no services to preview
+const noBranchServicesDescription = "No preview: the push changed no service and .previewd.yaml lists no services"
+
+// WithBranchPreviews enables branch previews: pushes to branches whose name
+// matches one of patterns (path.Match syntax, e.g. "release/*") create or
+// update a PreviewEnvironment for the branch, and deleting the branch deletes
+// it. A branch preview deploys the services affected by the files the pushes
+// changed (with WithServiceDetection) or else those listed in the branch's
+// .previewd.yaml. A push that yields no services creates no preview and is
+// reported as a failed "previewd/config" commit status.
+func (s *Server) WithBranchPreviews(patterns []string) *Server {
+	s.branchPatterns = patterns
+	return s
+}
+
+// ParseBranchPatterns parses a comma-separated list of branch name patterns
+// in path.Match syntax, such as "main,release/*"
+func ParseBranchPatterns(spec string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(spec, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid branch pattern %q: %w", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// isPreviewBranch reports whether branch matches one of the branch preview patterns
+func (s *Server) isPreviewBranch(branch string) bool {
+	for _, pattern := range s.branchPatterns {
+		if matched, _ := path.Match(pattern, branch); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptPush filters a decoded push or branch deletion and queues it for
+// processing
+func (s *Server) acceptPush(w http.ResponseWriter, r *http.Request, event *PushEvent, deliveryID string) {
+	logger := log.FromContext(r.Context())
+
+	if !s.allowRequest(w, r, event.Repository.FullName) {
+		return
+	}
+
+	if !s.isPreviewBranch(event.Branch) {
+		logger.V(1).Info("Ignoring push to branch without previews", "branch", event.Branch)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if s.isDuplicateDelivery(r.Context(), deliveryID) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	s.respondQueued(w, r, deliveryID, s.enqueuePush(r.Context(), event, deliveryID))
+}
+
+// handleBranchPushed creates the branch's PreviewEnvironment on the first
+// push and updates its head SHA on later ones. Later pushes keep the services
+// the preview was created with, unless they came from .previewd.yaml: those
+// follow the branch's current file.
+func (s *Server) handleBranchPushed(ctx context.Context, event *PushEvent) error {
+	logger := log.FromContext(ctx)
+
+	repository := event.Repository.FullName
+	previews, err := s.findBranchPreviews(ctx, repository, event.Branch)
+	if err != nil {
+		return err
+	}
+
+	repoConfig, err := s.loadRepoConfig(ctx, event.Provider, repository, event.SHA)
+	if err != nil {
+		return err
+	}
+
+	if len(previews) > 0 {
+		preview := &previews[0]
+		preview.Spec.HeadSHA = event.SHA
+		if preview.Annotations[previewv1alpha1.ConfigServicesAnnotation] == "true" {
+			preview.Spec.Services = nil
+		}
+		applyRepoConfig(preview, repoConfig)
+
+		if err := s.client.Update(ctx, preview); err != nil {
+			return fmt.Errorf("failed to update PreviewEnvironment: %w", err)
+		}
+
+		logger.Info("Updated PreviewEnvironment", "name", preview.Name, "branch", event.Branch, "newSHA", event.SHA)
+		return nil
+	}
+
+	preview := &previewv1alpha1.PreviewEnvironment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      branchPreviewName(repository, event.Branch),
+			Namespace: s.namespaceFor(repository),
+			Labels:    branchPreviewLabels(repository, event.Branch),
+		},
+		Spec: previewv1alpha1.PreviewEnvironmentSpec{
+			Repository: repository,
+			Provider:   event.Provider,
+			Branch:     event.Branch,
+			HeadSHA:    event.SHA,
+			Services:   s.detectPushServices(ctx, event, repoConfig.ArgoCD.Path),
+		},
+	}
+	applyRepoConfig(preview, repoConfig)
+
+	// Without services the preview could only fail to provision
+	if len(preview.Spec.Services) == 0 {
+		logger.Info("Not previewing branch: no services to deploy", "branch", event.Branch, "sha", event.SHA)
+		s.reportConfigFailure(ctx, event.Provider, repository, event.SHA, noBranchServicesDescription)
+		return nil
+	}
+
+	if err := s.client.Create(ctx, preview); err != nil {
+		// A concurrent create is retried as an update
+		return fmt.Errorf("failed to create PreviewEnvironment: %w", err)
+	}
+
+	logger.Info("Created PreviewEnvironment", "name", preview.Name, "namespace", preview.Namespace, "branch", event.Branch)
+	return nil
+}
+
+// detectPushServices returns the services affected by the files the push
+// changed, with services laid out in the repository as layout describes (see
+// services.Detector.Detect). It returns nil when service detection is not
+// configured.
+func (s *Server) detectPushServices(ctx context.Context, event *PushEvent, layout string) []string {
+	if s.detector == nil {
+		return nil
+	}
+	return s.detector.Detect(ctx, layout, event.Files)
+}
+
+// handleBranchDeleted deletes the PreviewEnvironment of a deleted branch
+func (s *Server) handleBranchDeleted(ctx context.Context, event *PushEvent) error {
+	logger := log.FromContext(ctx)
+
+	previews, err := s.findBranchPreviews(ctx, event.Repository.FullName, event.Branch)
+	if err != nil {
+		return err
+	}
+	if len(previews) == 0 {
+		logger.Info("PreviewEnvironment not found (already deleted)", "branch", event.Branch)
+		return nil
+	}
+
+	for i := range previews {
+		preview := &previews[i]
+		if err := s.client.Delete(ctx, preview); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete PreviewEnvironment: %w", err)
+		}
+		logger.Info("Deleted PreviewEnvironment", "name", preview.Name, "namespace", preview.Namespace, "branch", event.Branch)
+	}
+	return nil
+}
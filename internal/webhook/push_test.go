@@ -0,0 +1,311 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/mikelane/previewd/internal/github"
+	"github.com/mikelane/previewd/internal/services"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	testBranchSHA = "1111111111111111111111111111111111111111"
+	testNewerSHA  = "2222222222222222222222222222222222222222"
+)
+
+// sendGitHubEvent delivers a signed GitHub event and processes the queue
+func sendGitHubEvent(t *testing.T, server *Server, eventType, payload string) int {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader([]byte(payload)))
+	req.Header.Set("X-GitHub-Event", eventType)
+	req.Header.Set("X-Hub-Signature-256", computeSignature([]byte(payload), testSecret))
+	w := httptest.NewRecorder()
+
+	server.handleWebhook(w, req)
+	drainEvents(t, server)
+	return w.Code
+}
+
+func TestParseBranchPatterns(t *testing.T) {
+	patterns, err := ParseBranchPatterns(" main, release/* ,,")
+	if err != nil {
+		t.Fatalf("ParseBranchPatterns() error = %v", err)
+	}
+	if want := []string{"main", "release/*"}; !reflect.DeepEqual(patterns, want) {
+		t.Errorf("ParseBranchPatterns() = %v, want %v", patterns, want)
+	}
+
+	if _, err := ParseBranchPatterns("release/[0-9"); err == nil {
+		t.Error("ParseBranchPatterns() accepted a malformed pattern")
+	}
+}
+
+func TestDecodeBranchEvents(t *testing.T) {
+	tests := []struct {
+		provider    Provider
+		header      http.Header
+		name        string
+		payload     string
+		wantBranch  string
+		wantSHA     string
+		wantFiles   []string
+		wantDeleted bool
+		wantNil     bool
+	}{
+		{
+			name:     "GitHub push",
+			provider: NewGitHubProvider(testKeys(testSecret)),
+			header:   headerOf("X-GitHub-Event", "push"),
+			payload: `{"ref":"refs/heads/release/1.0","after":"` + testBranchSHA + `","commits":[` +
+				`{"added":["services/api/new.go"],"modified":["README.md"]},` +
+				`{"modified":["services/api/new.go"],"removed":["services/web/old.go"]}]}`,
+			wantBranch: "release/1.0",
+			wantSHA:    testBranchSHA,
+			wantFiles:  []string{"services/api/new.go", "README.md", "services/web/old.go"},
+		},
+		{
+			name:        "GitHub push deleting the branch",
+			provider:    NewGitHubProvider(testKeys(testSecret)),
+			header:      headerOf("X-GitHub-Event", "push"),
+			payload:     `{"ref":"refs/heads/release/1.0","after":"` + zeroSHA + `","deleted":true}`,
+			wantBranch:  "release/1.0",
+			wantDeleted: true,
+		},
+		{
+			name:     "GitHub tag push",
+			provider: NewGitHubProvider(testKeys(testSecret)),
+			header:   headerOf("X-GitHub-Event", "push"),
+			payload:  `{"ref":"refs/tags/v1.0","after":"` + testBranchSHA + `"}`,
+			wantNil:  true,
+		},
+		{
+			name:        "GitHub branch delete",
+			provider:    NewGitHubProvider(testKeys(testSecret)),
+			header:      headerOf("X-GitHub-Event", "delete"),
+			payload:     `{"ref":"release/1.0","ref_type":"branch"}`,
+			wantBranch:  "release/1.0",
+			wantDeleted: true,
+		},
+		{
+			name:     "GitHub tag delete",
+			provider: NewGitHubProvider(testKeys(testSecret)),
+			header:   headerOf("X-GitHub-Event", "delete"),
+			payload:  `{"ref":"v1.0","ref_type":"tag"}`,
+			wantNil:  true,
+		},
+		{
+			name:       "Gitea push",
+			provider:   NewGiteaProvider(testKeys(testGiteaSecret)),
+			header:     headerOf("X-Gitea-Event", "push"),
+			payload:    `{"ref":"refs/heads/main","after":"` + testBranchSHA + `"}`,
+			wantBranch: "main",
+			wantSHA:    testBranchSHA,
+		},
+		{
+			name:        "Forgejo branch delete",
+			provider:    NewGiteaProvider(testKeys(testGiteaSecret)),
+			header:      headerOf("X-Forgejo-Event", "delete"),
+			payload:     `{"ref":"main","ref_type":"branch"}`,
+			wantBranch:  "main",
+			wantDeleted: true,
+		},
+		{
+			name:     "GitLab push",
+			provider: NewGitLabProvider(testKeys(testGitLabToken)),
+			header:   headerOf("X-Gitlab-Event", "Push Hook"),
+			payload: `{"ref":"refs/heads/main","after":"` + testBranchSHA + `","project":{"path_with_namespace":"group/project"},` +
+				`"commits":[{"added":[],"modified":["services/api/main.go"],"removed":[]}]}`,
+			wantBranch: "main",
+			wantSHA:    testBranchSHA,
+			wantFiles:  []string{"services/api/main.go"},
+		},
+		{
+			name:        "GitLab branch delete",
+			provider:    NewGitLabProvider(testKeys(testGitLabToken)),
+			header:      headerOf("X-Gitlab-Event", "Push Hook"),
+			payload:     `{"ref":"refs/heads/main","after":"` + zeroSHA + `","project":{"path_with_namespace":"group/project"}}`,
+			wantBranch:  "main",
+			wantDeleted: true,
+		},
+		{
+			name:     "Bitbucket push",
+			provider: NewBitbucketProvider(testKeys(testBitbucketSecret)),
+			header:   headerOf("X-Event-Key", "repo:push"),
+			payload:  `{"push":{"changes":[{"new":{"type":"branch","name":"main","target":{"hash":"1111111"}}}]}}`,
+			wantNil:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := tt.provider.Decode(tt.header, []byte(tt.payload), "")
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if tt.wantNil {
+				if event != nil {
+					t.Errorf("Decode() = %+v, want nil", event)
+				}
+				return
+			}
+			if event == nil || event.Push == nil {
+				t.Fatalf("Decode() = %+v, want a push event", event)
+			}
+			push := event.Push
+			if push.Branch != tt.wantBranch || push.SHA != tt.wantSHA || push.Deleted != tt.wantDeleted {
+				t.Errorf("Decode() = %+v, want branch %q, SHA %q, deleted %v", push, tt.wantBranch, tt.wantSHA, tt.wantDeleted)
+			}
+			if !reflect.DeepEqual(push.Files, tt.wantFiles) {
+				t.Errorf("Decode() files = %v, want %v", push.Files, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestBranchPreviewName(t *testing.T) {
+	name := branchPreviewName("company/repo", "release/2025.10-hotfix-candidate")
+
+	if name == branchPreviewName("company/other", "release/2025.10-hotfix-candidate") {
+		t.Errorf("branchPreviewName() = %q for two repositories, expected distinct names", name)
+	}
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		t.Errorf("branchPreviewName() = %q is not a valid DNS label: %v", name, errs)
+	}
+}
+
+func TestCoalesce_Pushes(t *testing.T) {
+	push := func(sha string, deleted bool) *queuedEvent {
+		return &queuedEvent{push: &PushEvent{Branch: "main", SHA: sha, Deleted: deleted}}
+	}
+
+	events := coalesce([]*queuedEvent{push("a", false), push("b", false), push("", true), push("c", false)})
+
+	want := []string{"b", "", "c"}
+	if len(events) != len(want) {
+		t.Fatalf("coalesce() returned %d events, want %d", len(events), len(want))
+	}
+	for i, event := range events {
+		if event.push.SHA != want[i] {
+			t.Errorf("event %d SHA = %q, want %q", i, event.push.SHA, want[i])
+		}
+	}
+}
+
+func TestCoalesce_PushesKeepChangedFiles(t *testing.T) {
+	push := func(sha string, files ...string) *queuedEvent {
+		return &queuedEvent{push: &PushEvent{Branch: "main", SHA: sha, Files: files}}
+	}
+
+	events := coalesce([]*queuedEvent{push("a", "services/api/main.go"), push("b", "README.md", "services/api/main.go")})
+
+	if len(events) != 1 || events[0].push.SHA != "b" {
+		t.Fatalf("coalesce() = %d events, want the push of b only", len(events))
+	}
+	if want := []string{"services/api/main.go", "README.md"}; !reflect.DeepEqual(events[0].push.Files, want) {
+		t.Errorf("coalesced push files = %v, want %v", events[0].push.Files, want)
+	}
+}
+
+func TestHandleWebhook_BranchPreviewLifecycle(t *testing.T) {
+	server, _ := setupTest(t)
+	gh := &fakeGitHubClient{repoConfig: "services: [api]\n"}
+	server.WithGitHubClient(gh).WithBranchPreviews([]string{"release/*"})
+	ctx := context.Background()
+
+	pushTo := func(branch, sha string) string {
+		return `{"ref":"refs/heads/` + branch + `","after":"` + sha + `","repository":{"full_name":"company/repo"}}`
+	}
+
+	if code := sendGitHubEvent(t, server, "push", pushTo("feature/x", testBranchSHA)); code != http.StatusOK {
+		t.Errorf("handleWebhook for unmatched branch returns %d, expected %d", code, http.StatusOK)
+	}
+	if previews, _ := server.findBranchPreviews(ctx, "company/repo", "feature/x"); len(previews) != 0 {
+		t.Error("PreviewEnvironment was created for a branch not matching any pattern")
+	}
+
+	if code := sendGitHubEvent(t, server, "push", pushTo("release/1.0", testBranchSHA)); code != http.StatusAccepted {
+		t.Fatalf("handleWebhook for first push returns %d, expected %d", code, http.StatusAccepted)
+	}
+	previews, err := server.findBranchPreviews(ctx, "company/repo", "release/1.0")
+	if err != nil || len(previews) != 1 {
+		t.Fatalf("findBranchPreviews() = %d previews, %v; expected 1", len(previews), err)
+	}
+	preview := previews[0]
+	if preview.Name != branchPreviewName("company/repo", "release/1.0") || preview.Namespace != DefaultPreviewNamespace {
+		t.Errorf("PreviewEnvironment is %s/%s, expected %s/%s", preview.Namespace, preview.Name,
+			DefaultPreviewNamespace, branchPreviewName("company/repo", "release/1.0"))
+	}
+	if preview.Spec.PRNumber != 0 || preview.Spec.HeadSHA != testBranchSHA || !reflect.DeepEqual(preview.Spec.Services, []string{"api"}) {
+		t.Errorf("PreviewEnvironment spec = %+v, expected branch preview of %s with services [api]", preview.Spec, testBranchSHA)
+	}
+
+	if code := sendGitHubEvent(t, server, "push", pushTo("release/1.0", testNewerSHA)); code != http.StatusAccepted {
+		t.Fatalf("handleWebhook for second push returns %d, expected %d", code, http.StatusAccepted)
+	}
+	previews, _ = server.findBranchPreviews(ctx, "company/repo", "release/1.0")
+	if len(previews) != 1 || previews[0].Spec.HeadSHA != testNewerSHA {
+		t.Fatalf("PreviewEnvironments after second push = %+v, expected one at %s", previews, testNewerSHA)
+	}
+
+	deleteEvent := `{"ref":"release/1.0","ref_type":"branch","repository":{"full_name":"company/repo"}}`
+	if code := sendGitHubEvent(t, server, "delete", deleteEvent); code != http.StatusAccepted {
+		t.Fatalf("handleWebhook for branch delete returns %d, expected %d", code, http.StatusAccepted)
+	}
+	if previews, _ := server.findBranchPreviews(ctx, "company/repo", "release/1.0"); len(previews) != 0 {
+		t.Error("PreviewEnvironment still exists after the branch was deleted")
+	}
+}
+
+func TestHandleWebhook_BranchPreviewServices(t *testing.T) {
+	server, _ := setupTest(t)
+	gh := &fakeGitHubClient{}
+	server.WithGitHubClient(gh).WithServiceDetection(services.NewDetector(nil)).WithBranchPreviews([]string{"release/*"})
+	ctx := context.Background()
+
+	pushTo := func(branch, file string) string {
+		return `{"ref":"refs/heads/` + branch + `","after":"` + testBranchSHA + `",` +
+			`"repository":{"full_name":"company/repo"},"commits":[{"modified":["` + file + `"]}]}`
+	}
+
+	if code := sendGitHubEvent(t, server, "push", pushTo("release/1.0", "services/web/index.html")); code != http.StatusAccepted {
+		t.Fatalf("handleWebhook returns %d, expected %d", code, http.StatusAccepted)
+	}
+	previews, err := server.findBranchPreviews(ctx, "company/repo", "release/1.0")
+	if err != nil || len(previews) != 1 {
+		t.Fatalf("findBranchPreviews() = %d previews, %v; expected 1", len(previews), err)
+	}
+	if want := []string{"web"}; !reflect.DeepEqual(previews[0].Spec.Services, want) {
+		t.Errorf("Spec.Services = %v, expected the detected services %v", previews[0].Spec.Services, want)
+	}
+
+	// Neither the changed files nor .previewd.yaml name a service
+	if code := sendGitHubEvent(t, server, "push", pushTo("release/2.0", "README.md")); code != http.StatusAccepted {
+		t.Fatalf("handleWebhook returns %d, expected %d", code, http.StatusAccepted)
+	}
+	if previews, _ := server.findBranchPreviews(ctx, "company/repo", "release/2.0"); len(previews) != 0 {
+		t.Errorf("PreviewEnvironment was created for a push without services: %+v", previews[0].Spec)
+	}
+	if len(gh.statuses) != 1 || gh.statuses[0].State != github.StatusStateFailure || gh.statuses[0].Context != configStatusContext {
+		t.Errorf("commit statuses = %+v, expected one failed %s status", gh.statuses, configStatusContext)
+	}
+}
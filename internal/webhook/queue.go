@@ -0,0 +1,373 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikelane/previewd/internal/repoconfig"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// eventQueueName names the work queue in the workqueue_* metrics
+	eventQueueName = "webhook-events"
+
+	// defaultWorkers is the number of goroutines processing queued events
+	defaultWorkers = 4
+
+	// maxEventRetries is how often a failing event is retried before it is dropped
+	maxEventRetries = 5
+
+	// eventTimeout bounds the Kubernetes and GitHub calls made for a single event
+	eventTimeout = 30 * time.Second
+
+	// cleanupTimeout bounds forgetting the delivery ID of a dropped event and
+	// removing a finished event from the event store
+	cleanupTimeout = 5 * time.Second
+
+	// defaultMaxPendingEvents bounds the events waiting in the queue. Further
+	// events are refused until some have been processed.
+	defaultMaxPendingEvents = 1000
+)
+
+// errQueueFull is returned when the event queue cannot take more events
+var errQueueFull = errors.New("event queue is full")
+
+// queuedEvent is a validated webhook event waiting to be processed.
+// Exactly one of pullRequest, comment and push is set.
+type queuedEvent struct {
+	pullRequest *PullRequestEvent
+	comment     *IssueCommentEvent
+	push        *PushEvent
+	deliveryID  string // recorded by isDuplicateDelivery; empty if the provider sent none
+	id          string // identifies the event in the event store
+}
+
+// key identifies the pull request or branch the event belongs to
+func (e *queuedEvent) key() string {
+	switch {
+	case e.comment != nil:
+		// Comments share the pull request key so commands observe earlier events
+		return eventKey(e.comment.Repository.FullName, e.comment.Issue.Number)
+	case e.push != nil:
+		return branchEventKey(e.push.Repository.FullName, e.push.Branch)
+	default:
+		return eventKey(e.pullRequest.Repository.FullName, e.pullRequest.Number)
+	}
+}
+
+// isSynchronize reports whether the event is a pull_request synchronize event
+func (e *queuedEvent) isSynchronize() bool {
+	return e.pullRequest != nil && strings.EqualFold(e.pullRequest.Action, "synchronize")
+}
+
+// isPush reports whether the event is a push to a branch (not a deletion)
+func (e *queuedEvent) isPush() bool {
+	return e.push != nil && !e.push.Deleted
+}
+
+// eventQueue holds accepted webhook events until a worker processes them.
+//
+// Events are grouped by pull request (or branch, for branch previews): the
+// work queue only ever holds the pull request key, and the events themselves
+// wait in pending in arrival order. The work queue never hands the same key to
+// two workers at once, so events for one pull request are processed one at a
+// time and in order, while different pull requests are processed concurrently.
+type eventQueue struct {
+	queue      workqueue.TypedRateLimitingInterface[string]
+	pending    map[string][]*queuedEvent
+	mu         sync.Mutex
+	maxPending int
+}
+
+// newEventQueue creates an empty event queue with per-key exponential backoff
+func newEventQueue() *eventQueue {
+	return &eventQueue{
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: eventQueueName},
+		),
+		pending:    make(map[string][]*queuedEvent),
+		maxPending: defaultMaxPendingEvents,
+	}
+}
+
+// full reports whether maxPending events are waiting to be processed
+func (q *eventQueue) full() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	count := 0
+	for _, events := range q.pending {
+		count += len(events)
+	}
+	return count >= q.maxPending
+}
+
+// add queues event for the pull request identified by key and returns the
+// pending events it superseded
+func (q *eventQueue) add(key string, event *queuedEvent) []*queuedEvent {
+	q.mu.Lock()
+	superseded := q.coalesce(key, append(q.pending[key], event))
+	q.mu.Unlock()
+
+	q.queue.Add(key)
+	return superseded
+}
+
+// take removes and returns the pending events for key
+func (q *eventQueue) take(key string) []*queuedEvent {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	events := q.pending[key]
+	delete(q.pending, key)
+	return events
+}
+
+// drain removes and returns every pending event
+func (q *eventQueue) drain() []*queuedEvent {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var events []*queuedEvent
+	for key, pending := range q.pending {
+		events = append(events, pending...)
+		delete(q.pending, key)
+	}
+	return events
+}
+
+// retry puts events back in front of any events that arrived since they were
+// taken, re-adds key after its backoff and returns the events superseded
+func (q *eventQueue) retry(key string, events []*queuedEvent) []*queuedEvent {
+	q.mu.Lock()
+	superseded := q.coalesce(key, append(events, q.pending[key]...))
+	q.mu.Unlock()
+
+	q.queue.AddRateLimited(key)
+	return superseded
+}
+
+// coalesce stores the coalesced events as the pending events of key and
+// returns the events coalescing dropped. Must be called with mu locked.
+func (q *eventQueue) coalesce(key string, events []*queuedEvent) []*queuedEvent {
+	all := append([]*queuedEvent(nil), events...)
+	q.pending[key] = coalesce(events)
+
+	kept := make(map[*queuedEvent]bool, len(q.pending[key]))
+	for _, event := range q.pending[key] {
+		kept[event] = true
+	}
+	var superseded []*queuedEvent
+	for _, event := range all {
+		if !kept[event] {
+			superseded = append(superseded, event)
+		}
+	}
+	return superseded
+}
+
+// coalesce collapses runs of synchronize events, and runs of branch pushes,
+// into the most recent one. Each of these events carries the complete head
+// state of the pull request or branch, so only the latest head SHA needs to be
+// deployed. Synchronize events that arrive out of order are ranked by the pull
+// request's updated_at time; pushes carry no such time and are ranked by
+// arrival. A push only lists the files its own commits changed, so the
+// coalesced push keeps the files of the pushes it replaces.
+func coalesce(events []*queuedEvent) []*queuedEvent {
+	result := events[:0]
+	for _, event := range events {
+		n := len(result)
+		switch {
+		case n > 0 && event.isSynchronize() && result[n-1].isSynchronize():
+			if !event.pullRequest.PullRequest.UpdatedAt.Before(result[n-1].pullRequest.PullRequest.UpdatedAt) {
+				result[n-1] = event
+			}
+		case n > 0 && event.isPush() && result[n-1].isPush():
+			event.push.Files = mergeFiles(result[n-1].push.Files, event.push.Files)
+			result[n-1] = event
+		default:
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+// mergeFiles returns the files listed in either a or b, each once
+func mergeFiles(a, b []string) []string {
+	return changedFiles([]pushCommit{{Modified: a}, {Modified: b}})
+}
+
+// eventKey identifies the pull request an event belongs to
+func eventKey(repository string, number int) string {
+	return fmt.Sprintf("%s#%d", strings.ToLower(repository), number)
+}
+
+// branchEventKey identifies the branch a push event belongs to. Unlike
+// repository names, branch names are case-sensitive.
+func branchEventKey(repository, branch string) string {
+	return fmt.Sprintf("%s@%s", strings.ToLower(repository), branch)
+}
+
+// enqueuePullRequest queues a pull_request event for asynchronous processing
+func (s *Server) enqueuePullRequest(ctx context.Context, event *PullRequestEvent, deliveryID string) error {
+	return s.enqueue(ctx, &queuedEvent{pullRequest: event, deliveryID: deliveryID})
+}
+
+// enqueueComment queues an issue_comment event for asynchronous processing
+func (s *Server) enqueueComment(ctx context.Context, event *IssueCommentEvent, deliveryID string) error {
+	return s.enqueue(ctx, &queuedEvent{comment: event, deliveryID: deliveryID})
+}
+
+// enqueuePush queues a push or branch deletion for asynchronous processing
+func (s *Server) enqueuePush(ctx context.Context, event *PushEvent, deliveryID string) error {
+	return s.enqueue(ctx, &queuedEvent{push: event, deliveryID: deliveryID})
+}
+
+// enqueue saves event to the event store, if one is configured, and queues it.
+// It fails if the queue or the event store is full, or the event cannot be
+// stored.
+func (s *Server) enqueue(ctx context.Context, event *queuedEvent) error {
+	if s.events.full() {
+		return errQueueFull
+	}
+
+	event.id = string(uuid.NewUUID())
+	if err := s.storeEvent(ctx, event); err != nil {
+		return err
+	}
+	s.unstoreEvents(ctx, s.events.add(event.key(), event)...)
+	return nil
+}
+
+// dropPendingEvents shuts down the event queue. Without an event store the
+// events that were never processed are lost, so their delivery IDs are
+// forgotten; with one they are replayed on the next start.
+func (s *Server) dropPendingEvents(ctx context.Context) {
+	s.events.queue.ShutDown()
+	if s.eventStore != nil {
+		return
+	}
+	for _, event := range s.events.drain() {
+		s.forgetDelivery(ctx, event.deliveryID)
+	}
+}
+
+// runWorker processes queued events until the queue is shut down
+func (s *Server) runWorker(ctx context.Context) {
+	for s.processNextEvent(ctx) {
+	}
+}
+
+// processNextEvent processes the pending events of the next pull request in
+// the queue. It returns false once the queue has been shut down.
+func (s *Server) processNextEvent(ctx context.Context) bool {
+	key, shutdown := s.events.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer s.events.queue.Done(key)
+
+	logger := log.FromContext(ctx).WithValues("key", key)
+	events := s.events.take(key)
+	for i, event := range events {
+		err := s.processEvent(ctx, event)
+		if err == nil {
+			s.unstoreEvents(ctx, event)
+			continue
+		}
+
+		// A dropped event's delivery is forgotten so a redelivery is processed
+		if !isRetryableEventError(err) {
+			logger.Error(err, "Dropping webhook event that cannot succeed")
+			s.dropEvent(ctx, event)
+			continue
+		}
+		if retries := s.events.queue.NumRequeues(key); retries >= maxEventRetries {
+			logger.Error(err, "Dropping webhook event after retries", "retries", retries)
+			s.events.queue.Forget(key)
+			s.dropEvent(ctx, event)
+			continue
+		}
+		if s.events.queue.ShuttingDown() {
+			if s.eventStore != nil {
+				// Left in the event store, the event is replayed on the next start
+				logger.Error(err, "Failed to process webhook event on shutdown")
+				continue
+			}
+			logger.Error(err, "Dropping webhook event on shutdown")
+			s.forgetDelivery(ctx, event.deliveryID)
+			continue
+		}
+
+		logger.Error(err, "Failed to process webhook event, retrying")
+		s.unstoreEvents(ctx, s.events.retry(key, events[i:])...)
+		return true
+	}
+
+	s.events.queue.Forget(key)
+	return true
+}
+
+// dropEvent gives up on an event that could not be processed
+func (s *Server) dropEvent(ctx context.Context, event *queuedEvent) {
+	s.unstoreEvents(ctx, event)
+	s.forgetDelivery(ctx, event.deliveryID)
+}
+
+// processEvent applies a single queued event
+func (s *Server) processEvent(ctx context.Context, event *queuedEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, eventTimeout)
+	defer cancel()
+
+	if event.comment != nil {
+		return s.handleIssueComment(ctx, event.comment)
+	}
+	if event.push != nil {
+		if event.push.Deleted {
+			return s.handleBranchDeleted(ctx, event.push)
+		}
+		return s.handleBranchPushed(ctx, event.push)
+	}
+
+	pr := event.pullRequest
+	switch strings.ToLower(pr.Action) {
+	case "opened", "reopened", "ready_for_review", "labeled":
+		return s.handlePROpened(ctx, pr)
+	case "closed", "unlabeled", "converted_to_draft":
+		return s.handlePRClosed(ctx, pr)
+	case "synchronize":
+		return s.handlePRSynchronized(ctx, pr)
+	default:
+		return nil
+	}
+}
+
+// isRetryableEventError reports whether processing an event again could succeed.
+// An invalid .previewd.yaml has already been reported on the commit, and a
+// missing PreviewEnvironment will not appear by retrying.
+func isRetryableEventError(err error) bool {
+	return !errors.Is(err, repoconfig.ErrInvalidConfig) && !apierrors.IsNotFound(err)
+}
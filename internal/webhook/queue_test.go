@@ -0,0 +1,211 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func prEvent(action, sha string) *queuedEvent {
+	return &queuedEvent{pullRequest: &PullRequestEvent{Action: action, PullRequest: PullRequest{Head: Ref{SHA: sha}}}}
+}
+
+func TestCoalesce(t *testing.T) {
+	events := coalesce([]*queuedEvent{
+		prEvent("opened", "a"),
+		prEvent("synchronize", "b"),
+		prEvent("synchronize", "c"),
+		prEvent("closed", "c"),
+		prEvent("reopened", "c"),
+		prEvent("synchronize", "d"),
+		prEvent("synchronize", "e"),
+	})
+
+	want := []string{"opened/a", "synchronize/c", "closed/c", "reopened/c", "synchronize/e"}
+	if len(events) != len(want) {
+		t.Fatalf("coalesce() returned %d events, want %d", len(events), len(want))
+	}
+	for i, event := range events {
+		if got := event.pullRequest.Action + "/" + event.pullRequest.PullRequest.Head.SHA; got != want[i] {
+			t.Errorf("event %d = %s, want %s", i, got, want[i])
+		}
+	}
+}
+
+func TestCoalesce_KeepsMostRecentlyUpdated(t *testing.T) {
+	older := prEvent("synchronize", "old")
+	older.pullRequest.PullRequest.UpdatedAt = time.Date(2025, 11, 9, 12, 0, 0, 0, time.UTC)
+	newer := prEvent("synchronize", "new")
+	newer.pullRequest.PullRequest.UpdatedAt = older.pullRequest.PullRequest.UpdatedAt.Add(time.Minute)
+
+	// The newer event is delivered first
+	events := coalesce([]*queuedEvent{newer, older})
+	if len(events) != 1 || events[0] != newer {
+		t.Errorf("coalesce() kept %s, want new", events[0].pullRequest.PullRequest.Head.SHA)
+	}
+}
+
+// postPullRequest delivers a pull_request event without processing the queue
+func postPullRequest(t *testing.T, server *Server, event PullRequestEvent) {
+	t.Helper()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal test event: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	req.Header.Set("X-Hub-Signature-256", computeSignature(payload, testSecret))
+	w := httptest.NewRecorder()
+
+	server.handleWebhook(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("handleWebhook returns %d, expected %d", w.Code, http.StatusAccepted)
+	}
+}
+
+func TestEventQueue_CoalescesSynchronize(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := previewv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add scheme: %v", err)
+	}
+
+	preview := &previewv1alpha1.PreviewEnvironment{}
+	preview.Name = testPreviewName
+	preview.Namespace = DefaultPreviewNamespace
+	preview.Labels = previewLabels("company/repo", 123)
+	preview.Spec.Repository = "company/repo"
+	preview.Spec.PRNumber = 123
+
+	updates := 0
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(preview).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				updates++
+				return c.Update(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	server := NewServer("localhost", 8080, k8sClient, testSecret)
+
+	for _, sha := range []string{"sha1", "sha2", "sha3"} {
+		postPullRequest(t, server, PullRequestEvent{
+			Action:      "synchronize",
+			Number:      123,
+			PullRequest: PullRequest{Head: Ref{SHA: sha}},
+			Repository:  Repository{FullName: "company/repo"},
+		})
+	}
+
+	if got := server.events.queue.Len(); got != 1 {
+		t.Errorf("queue length = %d, want 1", got)
+	}
+	drainEvents(t, server)
+
+	if updates != 1 {
+		t.Errorf("PreviewEnvironment updated %d times, want 1", updates)
+	}
+	if err := k8sClient.Get(context.Background(), types.NamespacedName{
+		Name:      testPreviewName,
+		Namespace: "previewd-system",
+	}, preview); err != nil {
+		t.Fatalf("Failed to get PreviewEnvironment: %v", err)
+	}
+	if preview.Spec.HeadSHA != "sha3" {
+		t.Errorf("PreviewEnvironment HeadSHA is %s, expected sha3", preview.Spec.HeadSHA)
+	}
+}
+
+func TestEventQueue_RetriesInOrder(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := previewv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add scheme: %v", err)
+	}
+
+	failures := 1
+	var calls []string
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				calls = append(calls, "create")
+				if failures > 0 {
+					failures--
+					return errors.New("apiserver unavailable")
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+			Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+				calls = append(calls, "delete")
+				return c.Delete(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	server := NewServer("localhost", 8080, k8sClient, testSecret)
+
+	postPullRequest(t, server, PullRequestEvent{
+		Action:      "opened",
+		Number:      123,
+		PullRequest: PullRequest{Head: Ref{SHA: "sha1"}},
+		Repository:  Repository{FullName: "company/repo"},
+	})
+	drainEvents(t, server)
+
+	// The closed event arrives while the failed opened event waits for its retry
+	postPullRequest(t, server, PullRequestEvent{
+		Action:     "closed",
+		Number:     123,
+		Repository: Repository{FullName: "company/repo"},
+	})
+
+	key := eventKey("company/repo", 123)
+	if got := server.events.queue.NumRequeues(key); got != 1 {
+		t.Errorf("NumRequeues = %d, want 1", got)
+	}
+
+	// Blocks until the backoff expires
+	server.processNextEvent(context.Background())
+
+	want := []string{"create", "create", "delete"}
+	if len(calls) != len(want) {
+		t.Fatalf("client calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("client calls = %v, want %v", calls, want)
+			break
+		}
+	}
+	if got := server.events.queue.NumRequeues(key); got != 0 {
+		t.Errorf("NumRequeues = %d after success, want 0", got)
+	}
+}
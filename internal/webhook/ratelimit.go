@@ -0,0 +1,171 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// defaultRepositoryRateLimit is the default number of requests per second per repository
+	defaultRepositoryRateLimit = 10
+
+	// rateLimiterJanitorInterval is how often idle repository buckets are removed
+	rateLimiterJanitorInterval = time.Minute
+)
+
+// RateLimiter limits webhook requests with token buckets: one per repository
+// and an optional global bucket shared by every repository. A request is
+// allowed only if both buckets have a token. Buckets that have refilled
+// completely are indistinguishable from new ones, so a janitor removes them
+// in the background.
+type RateLimiter struct {
+	repos           map[string]*rate.Limiter
+	global          *rate.Limiter // nil when there is no global limit
+	mu              sync.Mutex
+	repoLimit       rate.Limit
+	repoBurst       int
+	janitorInterval time.Duration
+}
+
+// NewRateLimiter creates a rate limiter allowing bursts of limit requests per
+// repository, refilled at limit requests per window
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		repos:           make(map[string]*rate.Limiter),
+		repoLimit:       perWindow(limit, window),
+		repoBurst:       limit,
+		janitorInterval: rateLimiterJanitorInterval,
+	}
+}
+
+// WithGlobalLimit additionally limits all repositories together to bursts of
+// limit requests, refilled at limit requests per window. A limit of zero or
+// less removes the global limit.
+func (rl *RateLimiter) WithGlobalLimit(limit int, window time.Duration) *RateLimiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.global = nil
+	if limit > 0 {
+		rl.global = rate.NewLimiter(perWindow(limit, window), limit)
+	}
+	return rl
+}
+
+// perWindow converts limit requests per window to a rate
+func perWindow(limit int, window time.Duration) rate.Limit {
+	return rate.Limit(float64(limit) / window.Seconds())
+}
+
+// Allow checks if a request from the given repository should be allowed
+func (rl *RateLimiter) Allow(repo string) bool {
+	ok, _ := rl.Take(repo)
+	return ok
+}
+
+// Take takes a token for repo from the repository's bucket and the global
+// bucket. If either is empty, it takes nothing and returns how long until a
+// request for repo could be allowed.
+func (rl *RateLimiter) Take(repo string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	limiter, ok := rl.repos[repo]
+	if !ok {
+		limiter = rate.NewLimiter(rl.repoLimit, rl.repoBurst)
+		rl.repos[repo] = limiter
+	}
+
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); !reservation.OK() || delay > 0 {
+		reservation.CancelAt(now)
+		return false, retryDelay(reservation, delay)
+	}
+
+	if rl.global != nil {
+		global := rl.global.ReserveN(now, 1)
+		if delay := global.DelayFrom(now); !global.OK() || delay > 0 {
+			global.CancelAt(now)
+			reservation.CancelAt(now)
+			return false, retryDelay(global, delay)
+		}
+	}
+	return true, 0
+}
+
+// retryDelay returns the delay of a reservation that could not be granted now.
+// A reservation that can never be granted (a zero burst) is retried after a second.
+func retryDelay(reservation *rate.Reservation, delay time.Duration) time.Duration {
+	if !reservation.OK() || delay == rate.InfDuration {
+		return time.Second
+	}
+	return delay
+}
+
+// runJanitor removes idle repository buckets every interval until ctx is done
+func (rl *RateLimiter) runJanitor(ctx context.Context) {
+	ticker := time.NewTicker(rl.janitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			rl.prune(now)
+		}
+	}
+}
+
+// prune removes repository buckets that have refilled completely
+func (rl *RateLimiter) prune(now time.Time) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for repo, limiter := range rl.repos {
+		if limiter.TokensAt(now) >= float64(rl.repoBurst) {
+			delete(rl.repos, repo)
+		}
+	}
+}
+
+// allowRequest applies the rate limits to a request for repo. Throttled
+// requests are answered with HTTP 429 and a Retry-After header.
+func (s *Server) allowRequest(w http.ResponseWriter, r *http.Request, repo string) bool {
+	ok, retryAfter := s.rateLimiter.Take(repo)
+	recordRateLimit(repo, ok)
+	if ok {
+		return true
+	}
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	log.FromContext(r.Context()).Info("Rate limit exceeded", "repository", repo, "retryAfter", seconds)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, "Too many requests", http.StatusTooManyRequests)
+	return false
+}
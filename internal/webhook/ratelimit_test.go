@@ -0,0 +1,186 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRateLimiter(t *testing.T) {
+	rl := NewRateLimiter(3, 100*time.Millisecond)
+
+	// First 3 requests should succeed
+	for i := 0; i < 3; i++ {
+		if !rl.Allow("test-repo") {
+			t.Errorf("Request %d was rate limited, expected to be allowed", i+1)
+		}
+	}
+
+	// 4th request should be rate limited
+	if rl.Allow("test-repo") {
+		t.Error("Request 4 was allowed, expected to be rate limited")
+	}
+
+	// Wait for window to reset
+	time.Sleep(110 * time.Millisecond)
+
+	// Should allow again after reset
+	if !rl.Allow("test-repo") {
+		t.Error("Request after reset was rate limited, expected to be allowed")
+	}
+}
+
+// TestRateLimiter_Concurrency verifies thread-safe concurrent access
+func TestRateLimiter_Concurrency(t *testing.T) {
+	rl := NewRateLimiter(100, time.Second)
+	repo := "test/repo"
+
+	// Run 50 goroutines making requests concurrently
+	done := make(chan bool)
+	for i := 0; i < 50; i++ {
+		go func() {
+			for j := 0; j < 10; j++ {
+				_ = rl.Allow(repo)
+			}
+			done <- true
+		}()
+	}
+
+	// Wait for all goroutines to finish
+	for i := 0; i < 50; i++ {
+		<-done
+	}
+
+	// Verify rate limiter still works correctly
+	// After 500 requests (50*10), we should be at limit (100 per second)
+	if rl.Allow(repo) {
+		t.Error("Rate limiter allowed request after exceeding limit")
+	}
+}
+
+func TestRateLimiter_DifferentRepos(t *testing.T) {
+	rl := NewRateLimiter(2, time.Second)
+
+	// Repo A: 2 requests (at limit)
+	if !rl.Allow("repo-a") {
+		t.Error("repo-a request 1 was rate limited")
+	}
+	if !rl.Allow("repo-a") {
+		t.Error("repo-a request 2 was rate limited")
+	}
+
+	// Repo B: should still be allowed (different bucket)
+	if !rl.Allow("repo-b") {
+		t.Error("repo-b request 1 was rate limited")
+	}
+
+	// Repo A: should be rate limited
+	if rl.Allow("repo-a") {
+		t.Error("repo-a request 3 was allowed, expected rate limit")
+	}
+}
+
+func TestRateLimiter_GlobalLimit(t *testing.T) {
+	rl := NewRateLimiter(2, time.Minute).WithGlobalLimit(3, time.Minute)
+
+	for _, repo := range []string{"repo-a", "repo-a", "repo-b"} {
+		if !rl.Allow(repo) {
+			t.Errorf("%s request was rate limited, expected to be allowed", repo)
+		}
+	}
+
+	// repo-b still has a token of its own, but the global bucket is empty
+	ok, retryAfter := rl.Take("repo-b")
+	if ok {
+		t.Fatal("request over the global limit was allowed")
+	}
+	if retryAfter <= 0 || retryAfter > 20*time.Second {
+		t.Errorf("retryAfter = %v, want about one global token (20s)", retryAfter)
+	}
+
+	// The throttled request must not have used repo-b's token
+	rl.WithGlobalLimit(0, time.Minute)
+	if !rl.Allow("repo-b") {
+		t.Error("repo-b lost its token to a request throttled by the global limit")
+	}
+}
+
+func TestRateLimiter_Prune(t *testing.T) {
+	rl := NewRateLimiter(2, 100*time.Millisecond)
+	rl.Allow("busy")
+	rl.Allow("busy")
+	rl.Allow("idle")
+
+	rl.prune(time.Now())
+	if len(rl.repos) != 2 {
+		t.Fatalf("buckets after prune = %d, want 2 (neither has refilled)", len(rl.repos))
+	}
+
+	rl.prune(time.Now().Add(time.Second))
+	if len(rl.repos) != 0 {
+		t.Errorf("buckets after refill = %d, want 0", len(rl.repos))
+	}
+}
+
+func TestHandleWebhook_RateLimitedRetryAfter(t *testing.T) {
+	server, _ := setupTest(t)
+	server.WithRateLimiter(NewRateLimiter(1, 10*time.Second))
+
+	payload, err := json.Marshal(PullRequestEvent{
+		Action:      "opened",
+		Number:      1,
+		Repository:  Repository{FullName: "retry/repo"},
+		PullRequest: PullRequest{Head: Ref{SHA: "test"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal test event: %v", err)
+	}
+
+	allowed := rateLimitedRequests.WithLabelValues("retry/repo", "allowed")
+	throttled := rateLimitedRequests.WithLabelValues("retry/repo", "throttled")
+	allowedBefore, throttledBefore := testutil.ToFloat64(allowed), testutil.ToFloat64(throttled)
+
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+		req.Header.Set("X-GitHub-Event", "pull_request")
+		req.Header.Set("X-Hub-Signature-256", computeSignature(payload, testSecret))
+		w = httptest.NewRecorder()
+		server.handleWebhook(w, req)
+	}
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request returned %d, expected %d", w.Code, http.StatusTooManyRequests)
+	}
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 10 {
+		t.Errorf("Retry-After = %q, want 1-10 seconds", w.Header().Get("Retry-After"))
+	}
+
+	if got := testutil.ToFloat64(allowed) - allowedBefore; got != 1 {
+		t.Errorf("allowed requests counted = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(throttled) - throttledBefore; got != 1 {
+		t.Errorf("throttled requests counted = %v, want 1", got)
+	}
+}
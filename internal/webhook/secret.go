@@ -0,0 +1,216 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// secretWatchRetryDelay is how long SecretWatcher waits before re-establishing a failed watch
+const secretWatchRetryDelay = 10 * time.Second
+
+// The webhook Secret is read from the manager's namespace only, and must be
+// named previewd-github-webhook to match the default RBAC.
+// +kubebuilder:rbac:groups="",namespace=system,resources=secrets,resourceNames=previewd-github-webhook,verbs=get;list;watch
+
+// SecretWatcher keeps KeyRings in sync with a Kubernetes Secret. Each of the
+// listed data keys that holds a value becomes a key in its ring, in the listed
+// order. To rotate a webhook secret, move the current value to the second data
+// key and write the new one to the first: both are accepted until the old
+// value is removed, so no delivery is rejected while senders are updated.
+//
+// One Secret can hold the webhook secrets of several providers, each under its
+// own data keys (see WithKeyRing).
+//
+// SecretWatcher implements manager.Runnable and runs on every replica.
+type SecretWatcher struct {
+	client    client.WithWatch
+	namespace string
+	name      string
+	rings     []watchedKeyRing
+}
+
+// watchedKeyRing is a KeyRing loaded from data keys of the watched Secret
+type watchedKeyRing struct {
+	keys     *KeyRing
+	dataKeys []string
+}
+
+// NewSecretWatcher returns a SecretWatcher loading dataKeys of the Secret
+// namespace/name into keys
+func NewSecretWatcher(c client.WithWatch, namespace, name string, dataKeys []string, keys *KeyRing) *SecretWatcher {
+	w := &SecretWatcher{
+		client:    c,
+		namespace: namespace,
+		name:      name,
+	}
+	return w.WithKeyRing(dataKeys, keys)
+}
+
+// WithKeyRing also loads dataKeys of the Secret into keys. A ring none of
+// whose data keys holds a value is emptied, rejecting every delivery.
+func (w *SecretWatcher) WithKeyRing(dataKeys []string, keys *KeyRing) *SecretWatcher {
+	w.rings = append(w.rings, watchedKeyRing{keys: keys, dataKeys: dataKeys})
+	return w
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every replica
+// serving webhooks needs the current secrets.
+func (w *SecretWatcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Load reads the Secret and replaces the keys in the rings. It returns an
+// error if no namespace is set, the Secret does not exist or none of the data
+// keys holds a value.
+func (w *SecretWatcher) Load(ctx context.Context) error {
+	_, err := w.load(ctx, true)
+	return err
+}
+
+// Start watches the Secret until ctx is cancelled, updating the rings on every
+// change. A ring whose data keys are all removed from the Secret, or whose
+// Secret is deleted, is emptied, so a leaked secret is revoked as soon as its
+// data key is removed: the provider's deliveries are rejected until a key is
+// added again.
+func (w *SecretWatcher) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithValues("secret", w.namespace+"/"+w.name)
+
+	for {
+		if err := w.watch(ctx); err != nil {
+			logger.Error(err, "Webhook secret watch failed, retrying", "retryAfter", secretWatchRetryDelay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(secretWatchRetryDelay):
+		}
+	}
+}
+
+// watch loads the Secret and applies changes until the watch ends
+func (w *SecretWatcher) watch(ctx context.Context) error {
+	resourceVersion, err := w.load(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	watcher, err := w.client.Watch(ctx, &corev1.SecretList{},
+		client.InNamespace(w.namespace),
+		client.MatchingFields{"metadata.name": w.name},
+		&client.ListOptions{Raw: &metav1.ListOptions{ResourceVersion: resourceVersion}})
+	if err != nil {
+		return fmt.Errorf("failed to watch webhook secret %s/%s: %w", w.namespace, w.name, err)
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				// The API server ends watches periodically; start a new one
+				return nil
+			}
+			if err := w.handle(ctx, event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handle applies a watch event for the Secret
+func (w *SecretWatcher) handle(ctx context.Context, event watch.Event) error {
+	logger := log.FromContext(ctx).WithValues("secret", w.namespace+"/"+w.name)
+
+	switch event.Type {
+	case watch.Added, watch.Modified:
+		secret, ok := event.Object.(*corev1.Secret)
+		if !ok || secret.Name != w.name {
+			return nil
+		}
+		if err := w.apply(ctx, secret, false); err != nil {
+			return err
+		}
+	case watch.Deleted:
+		if secret, ok := event.Object.(*corev1.Secret); ok && secret.Name == w.name {
+			logger.Info("Webhook secret deleted; rejecting all webhook deliveries until it is recreated")
+			return w.apply(ctx, &corev1.Secret{}, false)
+		}
+	case watch.Error:
+		return apierrors.FromObject(event.Object)
+	}
+	return nil
+}
+
+// load reads the Secret into the rings and returns its resource version. With
+// requireKeys, a Secret without any value is an error and leaves the rings
+// unchanged.
+func (w *SecretWatcher) load(ctx context.Context, requireKeys bool) (string, error) {
+	if w.namespace == "" {
+		return "", fmt.Errorf("webhook secret %s has no namespace", w.name)
+	}
+	secret := &corev1.Secret{}
+	if err := w.client.Get(ctx, client.ObjectKey{Namespace: w.namespace, Name: w.name}, secret); err != nil {
+		return "", fmt.Errorf("failed to get webhook secret %s/%s: %w", w.namespace, w.name, err)
+	}
+	if err := w.apply(ctx, secret, requireKeys); err != nil {
+		return "", err
+	}
+	return secret.ResourceVersion, nil
+}
+
+// apply replaces the keys in each ring with the values of its data keys in
+// the Secret, emptying rings none of whose data keys holds a value. With
+// requireKeys, it returns an error instead if no data key holds a value.
+func (w *SecretWatcher) apply(ctx context.Context, secret *corev1.Secret, requireKeys bool) error {
+	logger := log.FromContext(ctx).WithValues("secret", w.namespace+"/"+w.name)
+
+	loaded := make([][]Key, len(w.rings))
+	var names, dataKeys []string
+	for i, ring := range w.rings {
+		for _, dataKey := range ring.dataKeys {
+			if value := secret.Data[dataKey]; len(value) > 0 {
+				loaded[i] = append(loaded[i], Key{Name: dataKey, Value: string(value)})
+				names = append(names, dataKey)
+			}
+		}
+		dataKeys = append(dataKeys, ring.dataKeys...)
+	}
+	if len(names) == 0 && requireKeys {
+		return fmt.Errorf("webhook secret %s/%s has no value for keys %q", w.namespace, w.name, dataKeys)
+	}
+
+	for i, ring := range w.rings {
+		if len(loaded[i]) == 0 && ring.keys.Len() > 0 {
+			logger.Info("Webhook secret holds none of a provider's keys; rejecting its deliveries",
+				"dataKeys", ring.dataKeys)
+		}
+		ring.keys.Set(loaded[i])
+	}
+	logger.Info("Loaded webhook secret", "keys", names)
+	return nil
+}
@@ -0,0 +1,285 @@
+// Copyright 2025 The Previewd Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newSecretClient returns a fake client holding the previewd-system/github-webhook Secret with data
+func newSecretClient(t *testing.T, data map[string][]byte) client.WithWatch {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add scheme: %v", err)
+	}
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github-webhook", Namespace: "previewd-system"},
+		Data:       data,
+	}).Build()
+}
+
+func TestSecretWatcher_Load(t *testing.T) {
+	dataKeys := []string{"webhook-secret", "previous-webhook-secret"}
+
+	tests := []struct {
+		data    map[string][]byte
+		name    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "loads current and previous secrets in order",
+			data: map[string][]byte{
+				"previous-webhook-secret": []byte("old"),
+				"webhook-secret":          []byte("new"),
+			},
+			want: []string{"webhook-secret", "previous-webhook-secret"},
+		},
+		{
+			name: "skips missing and empty keys",
+			data: map[string][]byte{"webhook-secret": []byte("new"), "previous-webhook-secret": {}},
+			want: []string{"webhook-secret"},
+		},
+		{
+			name:    "errors when no key has a value",
+			data:    map[string][]byte{"other": []byte("value")},
+			want:    []string{DefaultKeyName},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := testKeys("initial")
+			watcher := NewSecretWatcher(newSecretClient(t, tt.data), "previewd-system", "github-webhook", dataKeys, keys)
+
+			err := watcher.Load(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := keys.Names(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("key names = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretWatcher_RequiresNamespace(t *testing.T) {
+	keys := NewKeyRing()
+	watcher := NewSecretWatcher(newSecretClient(t, map[string][]byte{"webhook-secret": []byte("new")}),
+		"", "github-webhook", []string{"webhook-secret"}, keys)
+
+	if err := watcher.Load(context.Background()); err == nil {
+		t.Error("Load() without a namespace succeeded, want an error")
+	}
+	if keys.Len() != 0 {
+		t.Errorf("key names = %v, want none", keys.Names())
+	}
+}
+
+func TestSecretWatcher_LoadsSeveralKeyRings(t *testing.T) {
+	githubKeys := NewKeyRing()
+	gitlabKeys := testKeys("from-environment")
+	watcher := NewSecretWatcher(newSecretClient(t, map[string][]byte{"webhook-secret": []byte("github")}),
+		"previewd-system", "github-webhook", []string{"webhook-secret"}, githubKeys).
+		WithKeyRing([]string{"gitlab-webhook-token", "previous-gitlab-webhook-token"}, gitlabKeys)
+
+	if err := watcher.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := githubKeys.Names(); !reflect.DeepEqual(got, []string{"webhook-secret"}) {
+		t.Errorf("GitHub key names = %v, want [webhook-secret]", got)
+	}
+	// The Secret holds no GitLab token, so GitLab deliveries are rejected
+	if gitlabKeys.Len() != 0 {
+		t.Errorf("GitLab key names = %v, want none", gitlabKeys.Names())
+	}
+
+	watcher = NewSecretWatcher(newSecretClient(t, map[string][]byte{"gitlab-webhook-token": []byte("gitlab")}),
+		"previewd-system", "github-webhook", []string{"webhook-secret"}, githubKeys).
+		WithKeyRing([]string{"gitlab-webhook-token", "previous-gitlab-webhook-token"}, gitlabKeys)
+	if err := watcher.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v, want GitLab keys alone to be enough", err)
+	}
+	if name, ok := gitlabKeys.matchToken("gitlab"); !ok || name != "gitlab-webhook-token" {
+		t.Errorf("matchToken(gitlab) = %q, %v; want gitlab-webhook-token", name, ok)
+	}
+}
+
+func TestSecretWatcher_FollowsUpdates(t *testing.T) {
+	c := newSecretClient(t, map[string][]byte{"webhook-secret": []byte("first")})
+	keys := NewKeyRing()
+	watcher := NewSecretWatcher(c, "previewd-system", "github-webhook",
+		[]string{"webhook-secret", "previous-webhook-secret"}, keys)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- watcher.Start(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Start() error = %v", err)
+		}
+	}()
+
+	waitForKeys := func(want ...string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if slices.Equal(keys.Names(), want) {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("key names = %v, want %v", keys.Names(), want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForKeys("webhook-secret")
+
+	// Rotate: the old value moves to the previous key and both are accepted
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "previewd-system", Name: "github-webhook"}, secret); err != nil {
+		t.Fatalf("Failed to get Secret: %v", err)
+	}
+	secret.Data = map[string][]byte{"webhook-secret": []byte("second"), "previous-webhook-secret": []byte("first")}
+	if err := c.Update(ctx, secret); err != nil {
+		t.Fatalf("Failed to update Secret: %v", err)
+	}
+	waitForKeys("webhook-secret", "previous-webhook-secret")
+
+	if name, ok := keys.matchToken("first"); !ok || name != "previous-webhook-secret" {
+		t.Errorf("matchToken(first) = %q, %v; want previous-webhook-secret", name, ok)
+	}
+
+	// Removing the previous key revokes the old secret
+	secret.Data = map[string][]byte{"webhook-secret": []byte("second")}
+	if err := c.Update(ctx, secret); err != nil {
+		t.Fatalf("Failed to update Secret: %v", err)
+	}
+	waitForKeys("webhook-secret")
+	if _, ok := keys.matchToken("first"); ok {
+		t.Error("matchToken(first) succeeded after its key was removed")
+	}
+
+	// Deleting the Secret revokes every key
+	if err := c.Delete(ctx, secret); err != nil {
+		t.Fatalf("Failed to delete Secret: %v", err)
+	}
+	waitForKeys()
+}
+
+func TestSecretWatcher_RevokesRemovedKeys(t *testing.T) {
+	c := newSecretClient(t, map[string][]byte{
+		"webhook-secret":       []byte("github"),
+		"gitlab-webhook-token": []byte("leaked"),
+	})
+	githubKeys, gitlabKeys := NewKeyRing(), NewKeyRing()
+	watcher := NewSecretWatcher(c, "previewd-system", "github-webhook", []string{"webhook-secret"}, githubKeys).
+		WithKeyRing([]string{"gitlab-webhook-token"}, gitlabKeys)
+	if err := watcher.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	server, _ := setupTest(t)
+	server.WithGitHubKeys(githubKeys).WithGitLab(gitlabKeys, nil)
+	send := func() int {
+		req := httptest.NewRequest("POST", "/webhook", strings.NewReader(`{"object_kind":"merge_request"}`))
+		req.Header.Set("X-Gitlab-Event", gitlabMergeRequestHook)
+		req.Header.Set("X-Gitlab-Token", "leaked")
+		w := httptest.NewRecorder()
+		server.handleWebhook(w, req)
+		return w.Code
+	}
+	if code := send(); code == http.StatusUnauthorized {
+		t.Fatalf("handleWebhook with the loaded token returns %d", code)
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "previewd-system", Name: "github-webhook"}, secret); err != nil {
+		t.Fatalf("Failed to get Secret: %v", err)
+	}
+	delete(secret.Data, "gitlab-webhook-token")
+	if err := watcher.handle(context.Background(), watch.Event{Type: watch.Modified, Object: secret}); err != nil {
+		t.Fatalf("handle() error = %v", err)
+	}
+
+	if code := send(); code != http.StatusUnauthorized {
+		t.Errorf("handleWebhook with the removed token returns %d, want %d", code, http.StatusUnauthorized)
+	}
+	if githubKeys.Len() != 1 {
+		t.Errorf("GitHub key names = %v, want [webhook-secret]", githubKeys.Names())
+	}
+}
+
+func TestHandleWebhook_AcceptsRotatedSecrets(t *testing.T) {
+	server, _ := setupTest(t)
+	server.WithGitHubKeys(NewKeyRing(
+		Key{Name: "webhook-secret", Value: "new-secret"},
+		Key{Name: "previous-webhook-secret", Value: testSecret},
+	))
+
+	tests := []struct {
+		name     string
+		secret   string
+		key      string
+		wantCode int
+	}{
+		{name: "current secret", secret: "new-secret", key: "webhook-secret", wantCode: http.StatusOK},
+		{name: "previous secret", secret: testSecret, key: "previous-webhook-secret", wantCode: http.StatusOK},
+		{name: "unknown secret", secret: "other", key: invalidKeyLabel, wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := signatureValidations.WithLabelValues("github", tt.key)
+			before := testutil.ToFloat64(counter)
+
+			payload := []byte(`{"zen":"Keep it logically awesome."}`)
+			req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+			req.Header.Set("X-GitHub-Event", "ping")
+			req.Header.Set("X-Hub-Signature-256", computeSignature(payload, tt.secret))
+			w := httptest.NewRecorder()
+
+			server.handleWebhook(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("handleWebhook returns %d, expected %d", w.Code, tt.wantCode)
+			}
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Errorf("validations counted for key %q = %v, want 1", tt.key, got)
+			}
+		})
+	}
+}
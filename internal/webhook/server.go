@@ -16,110 +16,181 @@ package webhook
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/github"
+	"github.com/mikelane/previewd/internal/gitlab"
+	"github.com/mikelane/previewd/internal/repoconfig"
+	"github.com/mikelane/previewd/internal/services"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-// Server handles GitHub webhook requests
-type Server struct {
-	client        client.Client
-	server        *http.Server
-	rateLimiter   *RateLimiter
-	addr          string
-	webhookSecret string
-	port          int
-}
+const (
+	// configStatusContext is the commit status context used to report an invalid
+	// .previewd.yaml, or a branch preview with no services to deploy
+	configStatusContext = "previewd/config"
 
-// RateLimiter provides per-repository rate limiting
-type RateLimiter struct {
-	limiters map[string]*bucket
-	window   time.Duration
-	maxAge   time.Duration // Max age before cleaning up inactive buckets
-	mu       sync.Mutex
-	limit    int
-}
+	// maxStatusDescriptionLength is the maximum commit status description GitHub accepts
+	maxStatusDescriptionLength = 140
+)
 
-type bucket struct {
-	lastReset time.Time
-	lastUsed  time.Time
-	tokens    int
+// Server handles GitHub webhook requests.
+// It implements manager.Runnable and manager.LeaderElectionRunnable so it can be
+// registered with a controller-runtime manager.
+type Server struct {
+	client         client.Client
+	github         Provider
+	githubClient   github.Client
+	gitlabClient   gitlab.Client
+	detector       *services.Detector
+	server         *http.Server
+	events         *eventQueue
+	deliveries     DeliveryStore
+	eventStore     EventStore        // nil keeps accepted events in memory only
+	providers      []Provider        // tried before GitHub, in the order added
+	branchPatterns []string          // path.Match patterns of branches previewed on push
+	repoNamespaces map[string]string // lower-cased repository name to namespace
+	rateLimiter    *RateLimiter
+	addr           string
+	namespace      string
+	previewLabel   string
+	port           int
+	workers        int
+	leaderOnly     bool
+	skipDrafts     bool
 }
 
 // NewServer creates a new webhook server
 func NewServer(addr string, port int, k8sClient client.Client, webhookSecret string) *Server {
 	return &Server{
-		addr:          addr,
-		port:          port,
-		client:        k8sClient,
-		webhookSecret: webhookSecret,
-		rateLimiter:   NewRateLimiter(10, time.Second), // 10 requests per second per repo
+		addr:        addr,
+		port:        port,
+		client:      k8sClient,
+		github:      NewGitHubProvider(NewKeyRing(Key{Name: DefaultKeyName, Value: webhookSecret})),
+		rateLimiter: NewRateLimiter(defaultRepositoryRateLimit, time.Second),
+		events:      newEventQueue(),
+		deliveries:  NewMemoryDeliveryStore(DefaultDeliveryTTL, defaultMaxDeliveries),
+		workers:     defaultWorkers,
+		namespace:   DefaultPreviewNamespace,
 	}
 }
 
-// NewRateLimiter creates a new rate limiter
-func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
-	return &RateLimiter{
-		limiters: make(map[string]*bucket),
-		limit:    limit,
-		window:   window,
-		maxAge:   1 * time.Hour, // Clean up buckets inactive for 1 hour
-	}
+// WithLeaderElection configures whether the server only runs on the elected leader.
+// When enabled, a single replica receives webhooks and writes PreviewEnvironments,
+// so route the webhook Service to the leader (or run a single replica).
+func (s *Server) WithLeaderElection(leaderOnly bool) *Server {
+	s.leaderOnly = leaderOnly
+	return s
 }
 
-// Allow checks if a request from the given repository should be allowed
-func (rl *RateLimiter) Allow(repo string) bool {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+// WithGitHubClient sets the GitHub client used to read pull request files and
+// the repository's .previewd.yaml. Without it, PreviewEnvironments are created
+// from the webhook payload alone.
+func (s *Server) WithGitHubClient(githubClient github.Client) *Server {
+	s.githubClient = githubClient
+	return s
+}
 
-	// Cleanup inactive buckets to prevent memory leak
-	rl.cleanup()
+// WithGitHubKeys replaces the webhook secret given to NewServer with a KeyRing,
+// such as one kept up to date by a SecretWatcher, so the secret can be rotated
+// while the server runs
+func (s *Server) WithGitHubKeys(keys *KeyRing) *Server {
+	s.github = NewGitHubProvider(keys)
+	return s
+}
 
-	b, exists := rl.limiters[repo]
-	if !exists {
-		b = &bucket{
-			tokens:    rl.limit,
-			lastReset: time.Now(),
-			lastUsed:  time.Now(),
-		}
-		rl.limiters[repo] = b
+// WithGitLab enables GitLab merge request webhooks authenticated with any of
+// tokens (the hook's secret token, sent as X-Gitlab-Token). The optional client
+// reads merge request files and .previewd.yaml and reports configuration errors.
+func (s *Server) WithGitLab(tokens *KeyRing, gitlabClient gitlab.Client) *Server {
+	s.gitlabClient = gitlabClient
+	return s.WithProvider(NewGitLabProvider(tokens))
+}
+
+// WithRateLimiter replaces the default limit of 10 requests per second per repository
+func (s *Server) WithRateLimiter(rateLimiter *RateLimiter) *Server {
+	s.rateLimiter = rateLimiter
+	return s
+}
+
+// WithServiceDetection enables filling Spec.Services from the files changed in
+// the pull request. It requires a client for the pull request's provider (see
+// WithGitHubClient and WithGitLab).
+func (s *Server) WithServiceDetection(detector *services.Detector) *Server {
+	s.detector = detector
+	return s
+}
+
+// WithPreviewLabel enables label-gated mode: only pull requests carrying label
+// get a PreviewEnvironment. Adding the label creates it and removing the label
+// tears it down. An empty label previews every pull request.
+func (s *Server) WithPreviewLabel(label string) *Server {
+	s.previewLabel = label
+	return s
+}
+
+// WithSkipDrafts configures whether draft pull requests are skipped until they
+// are marked ready for review. Converting a PR back to draft tears it down.
+func (s *Server) WithSkipDrafts(skip bool) *Server {
+	s.skipDrafts = skip
+	return s
+}
+
+// WithWorkers sets the number of workers processing queued webhook events.
+// Events for the same pull request are always processed one at a time.
+func (s *Server) WithWorkers(workers int) *Server {
+	if workers > 0 {
+		s.workers = workers
 	}
+	return s
+}
 
-	// Reset bucket if window has passed
-	if time.Since(b.lastReset) >= rl.window {
-		b.tokens = rl.limit
-		b.lastReset = time.Now()
+// WithNamespace sets the namespace PreviewEnvironments are created in. The
+// namespace must exist.
+func (s *Server) WithNamespace(namespace string) *Server {
+	if namespace != "" {
+		s.namespace = namespace
 	}
+	return s
+}
 
-	// Update last used time
-	b.lastUsed = time.Now()
+// WithRepositoryNamespaces places the PreviewEnvironments of specific
+// repositories in their own namespaces (see ParseNamespaceMapping).
+// Repositories without an entry use the namespace set by WithNamespace.
+func (s *Server) WithRepositoryNamespaces(mapping map[string]string) *Server {
+	s.repoNamespaces = mapping
+	return s
+}
 
-	if b.tokens > 0 {
-		b.tokens--
-		return true
-	}
+// WithDeliveryStore sets where X-GitHub-Delivery IDs are remembered. The
+// default in-memory store only deduplicates deliveries within one replica; use
+// a ConfigMapDeliveryStore when several replicas receive webhooks.
+func (s *Server) WithDeliveryStore(store DeliveryStore) *Server {
+	s.deliveries = store
+	return s
+}
 
-	return false
+// WithEventStore sets where accepted events are persisted until they have been
+// processed. Without one, events still waiting in the queue are lost when the
+// server stops. Stored events are replayed when the server starts, so with
+// several replicas sharing a store an event may be processed more than once;
+// the event handlers are idempotent.
+func (s *Server) WithEventStore(store EventStore) *Server {
+	s.eventStore = store
+	return s
 }
 
-// cleanup removes buckets that haven't been used recently
-// Must be called with mu locked
-func (rl *RateLimiter) cleanup() {
-	now := time.Now()
-	for repo, b := range rl.limiters {
-		if now.Sub(b.lastUsed) > rl.maxAge {
-			delete(rl.limiters, repo)
-		}
-	}
+// NeedLeaderElection implements manager.LeaderElectionRunnable
+func (s *Server) NeedLeaderElection() bool {
+	return s.leaderOnly
 }
 
 // Start starts the webhook server
@@ -137,6 +208,19 @@ func (s *Server) Start(ctx context.Context) error {
 		IdleTimeout:       120 * time.Second,
 	}
 
+	// Queue the events a previous run accepted but did not process
+	if err := s.replayStoredEvents(ctx); err != nil {
+		return fmt.Errorf("failed to replay stored webhook events: %w", err)
+	}
+
+	// Remove idle rate limiter buckets in the background
+	go s.rateLimiter.runJanitor(ctx)
+
+	// Start event workers; they exit when the queue is shut down
+	for i := 0; i < s.workers; i++ {
+		go s.runWorker(ctx)
+	}
+
 	// Start server in goroutine
 	errChan := make(chan error, 1)
 	go func() {
@@ -151,12 +235,18 @@ func (s *Server) Start(ctx context.Context) error {
 	case <-ctx.Done():
 		return s.Shutdown(context.Background())
 	case err := <-errChan:
+		s.dropPendingEvents(context.Background())
 		return err
 	}
 }
 
-// Shutdown gracefully stops the server
+// Shutdown gracefully stops the server and its event workers. Events still
+// waiting in the queue stay in the event store and are replayed on the next
+// start. Without an event store they are lost: providers do not redeliver
+// acknowledged events on their own, so their delivery IDs are forgotten and a
+// manual redelivery from the provider's webhook settings is processed.
 func (s *Server) Shutdown(ctx context.Context) error {
+	defer s.dropPendingEvents(ctx)
 	if s.server == nil {
 		return nil
 	}
@@ -172,7 +262,7 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleWebhook handles GitHub webhook requests
+// handleWebhook handles webhook requests from every configured Git provider
 func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
 	logger := log.FromContext(r.Context())
 
@@ -195,89 +285,198 @@ func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	// Validate signature
-	signature := r.Header.Get("X-Hub-Signature-256")
-	if !ValidateSignature(payload, signature, s.webhookSecret) {
-		logger.Info("Invalid webhook signature")
+	// Authenticate and decode the request with the adapter of the Git provider
+	// that sent it. Everything up to queueing the event happens inside the
+	// request; the Kubernetes and Git provider API calls happen later on an
+	// event worker, so the providers' delivery timeouts are never at risk.
+	provider := s.providerFor(r.Header)
+	key, ok := provider.Verify(r.Header, payload)
+	recordSignatureValidation(provider.Name(), key, ok)
+	if !ok {
+		logger.Info("Invalid webhook signature", "provider", provider.Name())
 		http.Error(w, "Invalid signature", http.StatusUnauthorized)
 		return
 	}
+	logger.V(1).Info("Verified webhook signature", "provider", provider.Name(), "key", key)
 
-	// Check event type
-	eventType := r.Header.Get("X-GitHub-Event")
-	if eventType != "pull_request" {
-		logger.V(1).Info("Ignoring non-PR event", "event", eventType)
-		w.WriteHeader(http.StatusOK)
+	event, err := provider.Decode(r.Header, payload, s.previewLabel)
+	if err != nil {
+		logger.Error(err, "Failed to parse JSON payload", "provider", provider.Name())
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	// Parse event
-	var event PullRequestEvent
-	if err := json.Unmarshal(payload, &event); err != nil {
-		logger.Error(err, "Failed to parse JSON payload")
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
-		return
+	deliveryID := provider.DeliveryID(r.Header)
+	switch {
+	case event == nil:
+		logger.V(1).Info("Ignoring unsupported event", "provider", provider.Name())
+		w.WriteHeader(http.StatusOK)
+	case event.PullRequest != nil:
+		event.PullRequest.Provider = provider.Name()
+		s.acceptPullRequest(w, r, event.PullRequest, deliveryID)
+	case event.Push != nil:
+		event.Push.Provider = provider.Name()
+		s.acceptPush(w, r, event.Push, deliveryID)
+	default:
+		s.acceptComment(w, r, event.Comment, deliveryID)
 	}
+}
+
+// acceptPullRequest filters a decoded pull request event and queues it for
+// processing
+func (s *Server) acceptPullRequest(w http.ResponseWriter, r *http.Request, event *PullRequestEvent, deliveryID string) {
+	logger := log.FromContext(r.Context())
 
-	// Rate limiting check
-	if !s.rateLimiter.Allow(event.Repository.FullName) {
-		logger.Info("Rate limit exceeded", "repository", event.Repository.FullName)
-		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+	if !s.allowRequest(w, r, event.Repository.FullName) {
 		return
 	}
 
-	// Handle event
-	ctx := r.Context()
-	switch strings.ToLower(event.Action) {
-	case "opened", "reopened":
-		if err := s.handlePROpened(ctx, &event); err != nil {
-			logger.Error(err, "Failed to handle PR opened")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	// Filter out events that need no work, then queue the rest
+	switch action := strings.ToLower(event.Action); action {
+	case "opened", "reopened", "ready_for_review", "labeled", "synchronize":
+		if !s.wantsPreview(event) || (action == "labeled" && !s.isPreviewLabel(event.Label)) {
+			logger.V(1).Info("Ignoring PR without preview opt-in", "action", event.Action, "pr", event.Number)
+			w.WriteHeader(http.StatusOK)
 			return
 		}
-		w.WriteHeader(http.StatusCreated)
 
-	case "closed":
-		if err := s.handlePRClosed(ctx, &event); err != nil {
-			logger.Error(err, "Failed to handle PR closed")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	case "closed", "unlabeled", "converted_to_draft":
+		if (action == "unlabeled" && !s.isPreviewLabel(event.Label)) ||
+			(action == "converted_to_draft" && !s.skipDrafts) {
+			logger.V(1).Info("Ignoring PR action", "action", event.Action)
+			w.WriteHeader(http.StatusOK)
 			return
 		}
-		w.WriteHeader(http.StatusOK)
-
-	case "synchronize":
-		if err := s.handlePRSynchronized(ctx, &event); err != nil {
-			logger.Error(err, "Failed to handle PR synchronized")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.WriteHeader(http.StatusOK)
 
 	default:
 		logger.V(1).Info("Ignoring PR action", "action", event.Action)
 		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if s.isDuplicateDelivery(r.Context(), deliveryID) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	s.respondQueued(w, r, deliveryID, s.enqueuePullRequest(r.Context(), event, deliveryID))
+}
+
+// respondQueued acknowledges an event once queued. An event that could not be
+// queued is refused with HTTP 503 and its delivery ID forgotten, so the
+// provider's redelivery is processed.
+func (s *Server) respondQueued(w http.ResponseWriter, r *http.Request, deliveryID string, err error) {
+	if err != nil {
+		log.FromContext(r.Context()).Error(err, "Failed to queue webhook event", "delivery", deliveryID)
+		s.forgetDelivery(r.Context(), deliveryID)
+		http.Error(w, "Event queue unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// isDuplicateDelivery records a delivery ID (X-GitHub-Delivery or its provider
+// equivalent) and reports whether it was processed before. Deliveries are
+// recorded only once they are about to be queued, so a rejected delivery can
+// still be redelivered, and are forgotten again if their event is dropped
+// without being processed (see forgetDelivery). If the store fails, the
+// delivery is processed rather than lost.
+func (s *Server) isDuplicateDelivery(ctx context.Context, deliveryID string) bool {
+	logger := log.FromContext(ctx)
+
+	if deliveryID == "" {
+		return false
+	}
+
+	duplicate, err := s.deliveries.MarkDelivered(ctx, deliveryID)
+	if err != nil {
+		logger.Error(err, "Failed to record webhook delivery", "delivery", deliveryID)
+		return false
+	}
+	if duplicate {
+		logger.Info("Ignoring duplicate webhook delivery", "delivery", deliveryID)
+	}
+	return duplicate
+}
+
+// forgetDelivery removes a delivery ID recorded by isDuplicateDelivery once its
+// event has been dropped, so the provider's redelivery is processed. It runs
+// with its own timeout because events are also dropped on shutdown, after ctx
+// is cancelled.
+func (s *Server) forgetDelivery(ctx context.Context, deliveryID string) {
+	if deliveryID == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+	defer cancel()
+	if err := s.deliveries.Forget(ctx, deliveryID); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to forget dropped webhook delivery", "delivery", deliveryID)
+	}
+}
+
+// wantsPreview reports whether the pull request should have a PreviewEnvironment
+// given the configured preview label and draft handling
+func (s *Server) wantsPreview(event *PullRequestEvent) bool {
+	if s.skipDrafts && event.PullRequest.Draft {
+		return false
+	}
+	if s.previewLabel == "" {
+		return true
+	}
+	for _, label := range event.PullRequest.Labels {
+		if s.isPreviewLabel(&label) {
+			return true
+		}
 	}
+	return false
+}
+
+// isPreviewLabel reports whether label is the configured preview label
+func (s *Server) isPreviewLabel(label *Label) bool {
+	return s.previewLabel != "" && label != nil && strings.EqualFold(label.Name, s.previewLabel)
 }
 
 // handlePROpened creates a PreviewEnvironment CR when a PR is opened
 func (s *Server) handlePROpened(ctx context.Context, event *PullRequestEvent) error {
 	logger := log.FromContext(ctx)
 
+	repository := event.Repository.FullName
+	existing, err := s.findPreviews(ctx, repository, event.Number)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		logger.Info("PreviewEnvironment already exists", "name", existing[0].Name, "namespace", existing[0].Namespace)
+		return nil
+	}
+
+	repoConfig, err := s.loadRepoConfig(ctx, event.Provider, repository, event.PullRequest.Head.SHA)
+	if err != nil {
+		return err
+	}
+
+	affectedServices, err := s.detectServices(ctx, event, repoConfig.ArgoCD.Path)
+	if err != nil {
+		return err
+	}
+
 	preview := &previewv1alpha1.PreviewEnvironment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("pr-%d", event.Number),
-			Namespace: "previewd-system",
-			Labels: map[string]string{
-				"previewd.io/pr":         fmt.Sprintf("%d", event.Number),
-				"previewd.io/repository": sanitizeLabel(event.Repository.FullName),
-			},
+			Name:      previewName(repository, event.Number),
+			Namespace: s.namespaceFor(repository),
+			Labels:    previewLabels(repository, event.Number),
 		},
 		Spec: previewv1alpha1.PreviewEnvironmentSpec{
-			Repository: event.Repository.FullName,
+			Repository: repository,
+			Provider:   event.Provider,
 			PRNumber:   event.Number,
 			HeadSHA:    event.PullRequest.Head.SHA,
+			Services:   affectedServices,
 		},
 	}
+	applyRepoConfig(preview, repoConfig)
+	recordHeadUpdatedAt(preview, event)
 
 	if err := s.client.Create(ctx, preview); err != nil {
 		if client.IgnoreAlreadyExists(err) == nil {
@@ -287,30 +486,30 @@ func (s *Server) handlePROpened(ctx context.Context, event *PullRequestEvent) er
 		return fmt.Errorf("failed to create PreviewEnvironment: %w", err)
 	}
 
-	logger.Info("Created PreviewEnvironment", "name", preview.Name, "pr", event.Number)
+	logger.Info("Created PreviewEnvironment", "name", preview.Name, "namespace", preview.Namespace, "pr", event.Number)
 	return nil
 }
 
-// handlePRClosed deletes a PreviewEnvironment CR when a PR is closed
+// handlePRClosed deletes the PreviewEnvironment CR when a PR is closed
 func (s *Server) handlePRClosed(ctx context.Context, event *PullRequestEvent) error {
 	logger := log.FromContext(ctx)
 
-	preview := &previewv1alpha1.PreviewEnvironment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("pr-%d", event.Number),
-			Namespace: "previewd-system",
-		},
+	previews, err := s.findPreviews(ctx, event.Repository.FullName, event.Number)
+	if err != nil {
+		return err
+	}
+	if len(previews) == 0 {
+		logger.Info("PreviewEnvironment not found (already deleted)", "pr", event.Number)
+		return nil
 	}
 
-	if err := s.client.Delete(ctx, preview); err != nil {
-		if client.IgnoreNotFound(err) == nil {
-			logger.Info("PreviewEnvironment not found (already deleted)", "name", preview.Name)
-			return nil // Not found, not an error
+	for i := range previews {
+		preview := &previews[i]
+		if err := s.client.Delete(ctx, preview); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete PreviewEnvironment: %w", err)
 		}
-		return fmt.Errorf("failed to delete PreviewEnvironment: %w", err)
+		logger.Info("Deleted PreviewEnvironment", "name", preview.Name, "namespace", preview.Namespace, "pr", event.Number)
 	}
-
-	logger.Info("Deleted PreviewEnvironment", "name", preview.Name, "pr", event.Number)
 	return nil
 }
 
@@ -318,15 +517,45 @@ func (s *Server) handlePRClosed(ctx context.Context, event *PullRequestEvent) er
 func (s *Server) handlePRSynchronized(ctx context.Context, event *PullRequestEvent) error {
 	logger := log.FromContext(ctx)
 
-	preview := &previewv1alpha1.PreviewEnvironment{}
-	name := fmt.Sprintf("pr-%d", event.Number)
-	if err := s.client.Get(ctx, client.ObjectKey{Name: name, Namespace: "previewd-system"}, preview); err != nil {
-		return fmt.Errorf("failed to get PreviewEnvironment: %w", err)
+	preview, err := s.findPreview(ctx, event.Repository.FullName, event.Number)
+	if err != nil {
+		return err
+	}
+
+	if isStale(preview, event) {
+		logger.Info("Ignoring stale synchronize event", "name", preview.Name,
+			"sha", event.PullRequest.Head.SHA, "currentSHA", preview.Spec.HeadSHA)
+		return nil
+	}
+
+	repoConfig, err := s.loadRepoConfig(ctx, event.Provider, event.Repository.FullName, event.PullRequest.Head.SHA)
+	if err != nil {
+		return err
 	}
 
 	// Update HeadSHA
 	preview.Spec.HeadSHA = event.PullRequest.Head.SHA
 
+	// The PR file list is cumulative, so the detected services replace the old
+	// list, unless services were chosen with /preview services. A pull request
+	// touching no service keeps the services it has, unless they came from
+	// .previewd.yaml: those are taken from the current file again.
+	if preview.Annotations[previewv1alpha1.ServicesOverrideAnnotation] == "" {
+		affectedServices, err := s.detectServices(ctx, event, repoConfig.ArgoCD.Path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case len(affectedServices) > 0:
+			preview.Spec.Services = affectedServices
+		case preview.Annotations[previewv1alpha1.ConfigServicesAnnotation] == "true":
+			preview.Spec.Services = nil
+		}
+	}
+	applyRepoConfig(preview, repoConfig)
+	applyCommandOverrides(preview)
+	recordHeadUpdatedAt(preview, event)
+
 	if err := s.client.Update(ctx, preview); err != nil {
 		return fmt.Errorf("failed to update PreviewEnvironment: %w", err)
 	}
@@ -335,14 +564,148 @@ func (s *Server) handlePRSynchronized(ctx context.Context, event *PullRequestEve
 	return nil
 }
 
-// sanitizeLabel converts a repository name to a valid Kubernetes label value
-// Labels must be 63 characters or less and match [a-z0-9]([-a-z0-9]*[a-z0-9])?
-func sanitizeLabel(s string) string {
-	s = strings.ToLower(s)
-	s = strings.ReplaceAll(s, "/", "-")
-	s = strings.ReplaceAll(s, "_", "-")
-	if len(s) > 63 {
-		s = s[:63]
+// isStale reports whether event describes an older state of the pull request
+// than the one the PreviewEnvironment was last updated from. Redelivered or
+// out-of-order events must not roll the preview back to an older head SHA.
+func isStale(preview *previewv1alpha1.PreviewEnvironment, event *PullRequestEvent) bool {
+	if event.PullRequest.UpdatedAt.IsZero() {
+		return false
 	}
-	return s
+	recorded, err := time.Parse(time.RFC3339, preview.Annotations[previewv1alpha1.HeadUpdatedAtAnnotation])
+	if err != nil {
+		return false
+	}
+	return event.PullRequest.UpdatedAt.Before(recorded)
+}
+
+// recordHeadUpdatedAt stores the event's pull request update time on the
+// PreviewEnvironment for later staleness checks
+func recordHeadUpdatedAt(preview *previewv1alpha1.PreviewEnvironment, event *PullRequestEvent) {
+	if event.PullRequest.UpdatedAt.IsZero() {
+		return
+	}
+	if preview.Annotations == nil {
+		preview.Annotations = map[string]string{}
+	}
+	preview.Annotations[previewv1alpha1.HeadUpdatedAtAnnotation] = event.PullRequest.UpdatedAt.UTC().Format(time.RFC3339)
+}
+
+// loadRepoConfig reads .previewd.yaml from the head commit sha of a pull
+// request or branch. Repositories on providers without an API client use the
+// defaults. An invalid file is reported on the commit as a failed status and
+// returned as an error wrapping repoconfig.ErrInvalidConfig.
+func (s *Server) loadRepoConfig(ctx context.Context, provider, repository, sha string) (*repoconfig.Config, error) {
+	switch {
+	case provider == previewv1alpha1.ProviderGitLab:
+		return s.loadGitLabRepoConfig(ctx, repository, sha)
+	case provider != previewv1alpha1.ProviderGitHub || s.githubClient == nil:
+		return &repoconfig.Config{}, nil
+	}
+
+	owner, repo, err := github.ParseRepository(repository)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := repoconfig.Load(ctx, s.githubClient, owner, repo, sha)
+	if errors.Is(err, repoconfig.ErrInvalidConfig) {
+		s.reportConfigFailure(ctx, provider, repository, sha, err.Error())
+	}
+	return config, err
+}
+
+// applyRepoConfig applies .previewd.yaml to the preview's spec (see
+// repoconfig.Config.ApplyTo) and records in the ConfigServicesAnnotation
+// whether its services came from the file
+func applyRepoConfig(preview *previewv1alpha1.PreviewEnvironment, config *repoconfig.Config) {
+	fromConfig := len(preview.Spec.Services) == 0 && len(config.Services) > 0
+	config.ApplyTo(&preview.Spec)
+	if !fromConfig {
+		delete(preview.Annotations, previewv1alpha1.ConfigServicesAnnotation)
+		return
+	}
+	if preview.Annotations == nil {
+		preview.Annotations = map[string]string{}
+	}
+	preview.Annotations[previewv1alpha1.ConfigServicesAnnotation] = "true"
+}
+
+// reportConfigFailure reports a problem with the repository configuration of
+// sha as a failed "previewd/config" commit status. Providers without an API
+// client get no status. Failures to post the status are logged, not returned.
+func (s *Server) reportConfigFailure(ctx context.Context, provider, repository, sha, description string) {
+	var err error
+	switch {
+	case provider == previewv1alpha1.ProviderGitLab && s.gitlabClient != nil:
+		status := &gitlab.Status{
+			State:       gitlab.StatusStateFailed,
+			Description: truncateDescription(description),
+			Name:        configStatusContext,
+		}
+		err = s.gitlabClient.UpdateCommitStatus(ctx, repository, sha, status)
+	case provider == previewv1alpha1.ProviderGitHub && s.githubClient != nil:
+		owner, repo, parseErr := github.ParseRepository(repository)
+		if parseErr != nil {
+			err = parseErr
+			break
+		}
+		status := &github.Status{
+			State:       github.StatusStateFailure,
+			Description: truncateDescription(description),
+			Context:     configStatusContext,
+		}
+		err = s.githubClient.UpdateCommitStatus(ctx, owner, repo, sha, status)
+	default:
+		return
+	}
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to report repository config problem", "sha", sha)
+	}
+}
+
+// detectServices returns the services affected by the pull request's changed
+// files, with services laid out in the repository as layout describes (see
+// services.Detector.Detect). It returns nil when service detection is not
+// configured or the provider has no API client.
+func (s *Server) detectServices(ctx context.Context, event *PullRequestEvent, layout string) ([]string, error) {
+	if s.detector == nil {
+		return nil, nil
+	}
+	if event.Provider == previewv1alpha1.ProviderGitLab {
+		if s.gitlabClient == nil {
+			return nil, nil
+		}
+		paths, err := s.gitlabClient.GetMRFiles(ctx, event.Repository.FullName, event.Number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect services: %w", err)
+		}
+		return s.detector.Detect(ctx, layout, paths), nil
+	}
+	if event.Provider != previewv1alpha1.ProviderGitHub || s.githubClient == nil {
+		return nil, nil
+	}
+
+	owner, repo, err := github.ParseRepository(event.Repository.FullName)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := s.githubClient.GetPRFiles(ctx, owner, repo, event.Number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect services: %w", err)
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, file.Filename)
+	}
+	return s.detector.Detect(ctx, layout, paths), nil
+}
+
+// truncateDescription shortens s to fit the GitHub commit status description limit
+func truncateDescription(s string) string {
+	if len(s) <= maxStatusDescriptionLength {
+		return s
+	}
+	return s[:maxStatusDescriptionLength-3] + "..."
 }
@@ -24,10 +24,12 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
-	"time"
 
 	previewv1alpha1 "github.com/mikelane/previewd/api/v1alpha1"
+	"github.com/mikelane/previewd/internal/github"
+	"github.com/mikelane/previewd/internal/services"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -37,6 +39,9 @@ import (
 //nolint:gosec // Test secret, not a real credential
 const testSecret = "test-webhook-secret"
 
+// testPreviewName is the PreviewEnvironment name for company/repo PR 123
+var testPreviewName = previewName("company/repo", 123)
+
 func setupTest(t *testing.T) (*Server, client.Client) {
 	t.Helper()
 
@@ -53,13 +58,31 @@ func setupTest(t *testing.T) (*Server, client.Client) {
 	return server, fakeClient
 }
 
-//nolint:unparam // secret is always testSecret in tests, but keeping parameter for clarity
+// drainEvents processes every queued webhook event that is ready
+func drainEvents(t *testing.T, server *Server) {
+	t.Helper()
+	for server.events.queue.Len() > 0 {
+		server.processNextEvent(context.Background())
+	}
+}
+
 func computeSignature(payload []byte, secret string) string {
 	mac := hmac.New(sha256.New, []byte(secret))
 	mac.Write(payload)
 	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
 }
 
+func TestServer_NeedLeaderElection(t *testing.T) {
+	server, _ := setupTest(t)
+	if server.NeedLeaderElection() {
+		t.Error("NeedLeaderElection() = true, want false by default")
+	}
+
+	if !server.WithLeaderElection(true).NeedLeaderElection() {
+		t.Error("NeedLeaderElection() = false, want true after WithLeaderElection(true)")
+	}
+}
+
 func TestHandleHealth(t *testing.T) {
 	server, _ := setupTest(t)
 
@@ -176,15 +199,16 @@ func TestHandlePROpened(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	server.handleWebhook(w, req)
+	drainEvents(t, server)
 
-	if w.Code != http.StatusCreated {
-		t.Errorf("handleWebhook for PR opened returns %d, expected %d", w.Code, http.StatusCreated)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("handleWebhook for PR opened returns %d, expected %d", w.Code, http.StatusAccepted)
 	}
 
 	// Verify PreviewEnvironment was created
 	preview := &previewv1alpha1.PreviewEnvironment{}
 	getErr := k8sClient.Get(context.Background(), types.NamespacedName{
-		Name:      "pr-123",
+		Name:      testPreviewName,
 		Namespace: "previewd-system",
 	}, preview)
 
@@ -210,10 +234,10 @@ func TestHandlePROpened_AlreadyExists(t *testing.T) {
 
 	// Create existing PreviewEnvironment first
 	existingPreview := &previewv1alpha1.PreviewEnvironment{}
-	//nolint:goconst // Test data, not a meaningful constant
-	existingPreview.Name = "pr-123"
-	//nolint:goconst // Test data, not a meaningful constant
-	existingPreview.Namespace = "previewd-system"
+	existingPreview.Name = testPreviewName
+	existingPreview.Namespace = DefaultPreviewNamespace
+	existingPreview.Labels = previewLabels("company/repo", 123)
+	existingPreview.Spec.Repository = "company/repo"
 	existingPreview.Spec.PRNumber = 123
 	existingPreview.Spec.HeadSHA = "oldsha"
 	if err := k8sClient.Create(context.Background(), existingPreview); err != nil {
@@ -250,10 +274,11 @@ func TestHandlePROpened_AlreadyExists(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	server.handleWebhook(w, req)
+	drainEvents(t, server)
 
 	// Should return Created even though PreviewEnvironment already exists
-	if w.Code != http.StatusCreated {
-		t.Errorf("handleWebhook for PR opened (already exists) returns %d, expected %d", w.Code, http.StatusCreated)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("handleWebhook for PR opened (already exists) returns %d, expected %d", w.Code, http.StatusAccepted)
 	}
 }
 
@@ -262,8 +287,10 @@ func TestHandlePRClosed(t *testing.T) {
 
 	// Create existing PreviewEnvironment
 	preview := &previewv1alpha1.PreviewEnvironment{}
-	preview.Name = "pr-123"
-	preview.Namespace = "previewd-system"
+	preview.Name = testPreviewName
+	preview.Namespace = DefaultPreviewNamespace
+	preview.Labels = previewLabels("company/repo", 123)
+	preview.Spec.Repository = "company/repo"
 	preview.Spec.PRNumber = 123
 	if err := k8sClient.Create(context.Background(), preview); err != nil {
 		t.Fatalf("Failed to create test PreviewEnvironment: %v", err)
@@ -289,14 +316,15 @@ func TestHandlePRClosed(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	server.handleWebhook(w, req)
+	drainEvents(t, server)
 
-	if w.Code != http.StatusOK {
-		t.Errorf("handleWebhook for PR closed returns %d, expected %d", w.Code, http.StatusOK)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("handleWebhook for PR closed returns %d, expected %d", w.Code, http.StatusAccepted)
 	}
 
 	// Verify PreviewEnvironment was deleted
 	getErr := k8sClient.Get(context.Background(), types.NamespacedName{
-		Name:      "pr-123",
+		Name:      testPreviewName,
 		Namespace: "previewd-system",
 	}, preview)
 
@@ -330,10 +358,11 @@ func TestHandlePRClosed_NotFound(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	server.handleWebhook(w, req)
+	drainEvents(t, server)
 
 	// Should return OK even though PreviewEnvironment doesn't exist
-	if w.Code != http.StatusOK {
-		t.Errorf("handleWebhook for PR closed (not found) returns %d, expected %d", w.Code, http.StatusOK)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("handleWebhook for PR closed (not found) returns %d, expected %d", w.Code, http.StatusAccepted)
 	}
 }
 
@@ -342,8 +371,10 @@ func TestHandlePRSynchronized(t *testing.T) {
 
 	// Create existing PreviewEnvironment
 	preview := &previewv1alpha1.PreviewEnvironment{}
-	preview.Name = "pr-123"
-	preview.Namespace = "previewd-system"
+	preview.Name = testPreviewName
+	preview.Namespace = DefaultPreviewNamespace
+	preview.Labels = previewLabels("company/repo", 123)
+	preview.Spec.Repository = "company/repo"
 	preview.Spec.PRNumber = 123
 	preview.Spec.HeadSHA = "oldsha"
 	if err := k8sClient.Create(context.Background(), preview); err != nil {
@@ -375,15 +406,16 @@ func TestHandlePRSynchronized(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	server.handleWebhook(w, req)
+	drainEvents(t, server)
 
-	if w.Code != http.StatusOK {
-		t.Errorf("handleWebhook for PR synchronized returns %d, expected %d", w.Code, http.StatusOK)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("handleWebhook for PR synchronized returns %d, expected %d", w.Code, http.StatusAccepted)
 	}
 
 	// Verify PreviewEnvironment was updated
 	updated := &previewv1alpha1.PreviewEnvironment{}
 	getErr := k8sClient.Get(context.Background(), types.NamespacedName{
-		Name:      "pr-123",
+		Name:      testPreviewName,
 		Namespace: "previewd-system",
 	}, updated)
 
@@ -396,77 +428,520 @@ func TestHandlePRSynchronized(t *testing.T) {
 	}
 }
 
-func TestRateLimiter(t *testing.T) {
-	rl := NewRateLimiter(3, 100*time.Millisecond)
+// fakeGitHubClient serves a fixed list of PR files and an optional .previewd.yaml
+type fakeGitHubClient struct {
+	github.Client
+	repoConfig string
+	permission string
+	files      []string
+	statuses   []*github.Status
+	comments   []string
+	reactions  []string
+}
 
-	// First 3 requests should succeed
-	for i := 0; i < 3; i++ {
-		if !rl.Allow("test-repo") {
-			t.Errorf("Request %d was rate limited, expected to be allowed", i+1)
-		}
+func (f *fakeGitHubClient) GetPermissionLevel(_ context.Context, _, _, _ string) (string, error) {
+	return f.permission, nil
+}
+
+func (f *fakeGitHubClient) CreateComment(_ context.Context, _, _ string, _ int, body string) error {
+	f.comments = append(f.comments, body)
+	return nil
+}
+
+func (f *fakeGitHubClient) AddCommentReaction(_ context.Context, _, _ string, _ int64, reaction string) error {
+	f.reactions = append(f.reactions, reaction)
+	return nil
+}
+
+func (f *fakeGitHubClient) GetFileContents(_ context.Context, _, _, _, _ string) ([]byte, error) {
+	if f.repoConfig == "" {
+		return nil, github.ErrNotFound
 	}
+	return []byte(f.repoConfig), nil
+}
 
-	// 4th request should be rate limited
-	if rl.Allow("test-repo") {
-		t.Error("Request 4 was allowed, expected to be rate limited")
+func (f *fakeGitHubClient) UpdateCommitStatus(_ context.Context, _, _, _ string, status *github.Status) error {
+	f.statuses = append(f.statuses, status)
+	return nil
+}
+
+func (f *fakeGitHubClient) GetPRFiles(_ context.Context, _, _ string, _ int) ([]*github.File, error) {
+	files := make([]*github.File, 0, len(f.files))
+	for _, name := range f.files {
+		files = append(files, &github.File{Filename: name})
 	}
+	return files, nil
+}
 
-	// Wait for window to reset
-	time.Sleep(110 * time.Millisecond)
+func TestHandlePROpened_DetectsServices(t *testing.T) {
+	server, k8sClient := setupTest(t)
+	gh := &fakeGitHubClient{files: []string{"services/auth/main.go", "README.md"}}
+	server.WithGitHubClient(gh).WithServiceDetection(services.NewDetector(map[string][]string{"web": {"auth"}}))
+
+	event := PullRequestEvent{
+		Action:      "opened",
+		Number:      123,
+		PullRequest: PullRequest{Head: Ref{SHA: "abc123"}},
+		Repository:  Repository{FullName: "company/repo"},
+	}
 
-	// Should allow again after reset
-	if !rl.Allow("test-repo") {
-		t.Error("Request after reset was rate limited, expected to be allowed")
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal test event: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	req.Header.Set("X-Hub-Signature-256", computeSignature(payload, testSecret))
+	w := httptest.NewRecorder()
+
+	server.handleWebhook(w, req)
+	drainEvents(t, server)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("handleWebhook for PR opened returns %d, expected %d", w.Code, http.StatusAccepted)
+	}
+
+	preview := &previewv1alpha1.PreviewEnvironment{}
+	if err := k8sClient.Get(context.Background(), types.NamespacedName{
+		Name:      testPreviewName,
+		Namespace: "previewd-system",
+	}, preview); err != nil {
+		t.Fatalf("Failed to get PreviewEnvironment: %v", err)
+	}
+
+	if want := []string{"auth", "web"}; !reflect.DeepEqual(preview.Spec.Services, want) {
+		t.Errorf("PreviewEnvironment Services is %v, expected %v", preview.Spec.Services, want)
 	}
 }
 
-// TestRateLimiter_Concurrency verifies thread-safe concurrent access
-func TestRateLimiter_Concurrency(t *testing.T) {
-	rl := NewRateLimiter(100, time.Second)
-	repo := "test/repo"
+func TestHandlePRSynchronized_UpdatesServices(t *testing.T) {
+	server, k8sClient := setupTest(t)
+	gh := &fakeGitHubClient{files: []string{"services/api/handler.go", "services/web/app.ts"}}
+	server.WithGitHubClient(gh).WithServiceDetection(services.NewDetector(nil))
 
-	// Run 50 goroutines making requests concurrently
-	done := make(chan bool)
-	for i := 0; i < 50; i++ {
-		go func() {
-			for j := 0; j < 10; j++ {
-				_ = rl.Allow(repo)
-			}
-			done <- true
-		}()
+	preview := &previewv1alpha1.PreviewEnvironment{}
+	preview.Name = testPreviewName
+	preview.Namespace = DefaultPreviewNamespace
+	preview.Labels = previewLabels("company/repo", 123)
+	preview.Spec.Repository = "company/repo"
+	preview.Spec.PRNumber = 123
+	preview.Spec.HeadSHA = "oldsha"
+	preview.Spec.Services = []string{"web"}
+	if err := k8sClient.Create(context.Background(), preview); err != nil {
+		t.Fatalf("Failed to create test PreviewEnvironment: %v", err)
 	}
 
-	// Wait for all goroutines to finish
-	for i := 0; i < 50; i++ {
-		<-done
+	event := PullRequestEvent{
+		Action:      "synchronize",
+		Number:      123,
+		PullRequest: PullRequest{Head: Ref{SHA: "newsha123"}},
+		Repository:  Repository{FullName: "company/repo"},
 	}
 
-	// Verify rate limiter still works correctly
-	// After 500 requests (50*10), we should be at limit (100 per second)
-	if rl.Allow(repo) {
-		t.Error("Rate limiter allowed request after exceeding limit")
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal test event: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	req.Header.Set("X-Hub-Signature-256", computeSignature(payload, testSecret))
+	w := httptest.NewRecorder()
+
+	server.handleWebhook(w, req)
+	drainEvents(t, server)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("handleWebhook for PR synchronized returns %d, expected %d", w.Code, http.StatusAccepted)
+	}
+
+	updated := &previewv1alpha1.PreviewEnvironment{}
+	if err := k8sClient.Get(context.Background(), types.NamespacedName{
+		Name:      testPreviewName,
+		Namespace: "previewd-system",
+	}, updated); err != nil {
+		t.Fatalf("Failed to get updated PreviewEnvironment: %v", err)
+	}
+
+	if want := []string{"api", "web"}; !reflect.DeepEqual(updated.Spec.Services, want) {
+		t.Errorf("PreviewEnvironment Services is %v, expected %v", updated.Spec.Services, want)
 	}
 }
 
-func TestRateLimiter_DifferentRepos(t *testing.T) {
-	rl := NewRateLimiter(2, time.Second)
+func TestHandlePRSynchronized_KeepsServicesWhenNoneDetected(t *testing.T) {
+	server, k8sClient := setupTest(t)
+	gh := &fakeGitHubClient{files: []string{"README.md", "docs/setup.md"}}
+	server.WithGitHubClient(gh).WithServiceDetection(services.NewDetector(nil))
+
+	preview := &previewv1alpha1.PreviewEnvironment{}
+	preview.Name = testPreviewName
+	preview.Namespace = DefaultPreviewNamespace
+	preview.Labels = previewLabels("company/repo", 123)
+	preview.Spec.Repository = "company/repo"
+	preview.Spec.PRNumber = 123
+	preview.Spec.HeadSHA = "oldsha"
+	preview.Spec.Services = []string{"web"}
+	if err := k8sClient.Create(context.Background(), preview); err != nil {
+		t.Fatalf("Failed to create test PreviewEnvironment: %v", err)
+	}
+
+	postPullRequest(t, server, PullRequestEvent{
+		Action:      "synchronize",
+		Number:      123,
+		PullRequest: PullRequest{Head: Ref{SHA: "newsha123"}},
+		Repository:  Repository{FullName: "company/repo"},
+	})
+	drainEvents(t, server)
 
-	// Repo A: 2 requests (at limit)
-	if !rl.Allow("repo-a") {
-		t.Error("repo-a request 1 was rate limited")
+	if err := k8sClient.Get(context.Background(), types.NamespacedName{
+		Name:      testPreviewName,
+		Namespace: "previewd-system",
+	}, preview); err != nil {
+		t.Fatalf("Failed to get updated PreviewEnvironment: %v", err)
+	}
+	if preview.Spec.HeadSHA != "newsha123" {
+		t.Errorf("PreviewEnvironment HeadSHA is %s, expected newsha123", preview.Spec.HeadSHA)
 	}
-	if !rl.Allow("repo-a") {
-		t.Error("repo-a request 2 was rate limited")
+	if want := []string{"web"}; !reflect.DeepEqual(preview.Spec.Services, want) {
+		t.Errorf("PreviewEnvironment Services is %v, expected %v", preview.Spec.Services, want)
 	}
+}
 
-	// Repo B: should still be allowed (different bucket)
-	if !rl.Allow("repo-b") {
-		t.Error("repo-b request 1 was rate limited")
+func TestHandlePROpened_DetectsServicesInConfiguredLayout(t *testing.T) {
+	server, k8sClient := setupTest(t)
+	gh := &fakeGitHubClient{
+		repoConfig: "argocd:\n  path: apps/{{service}}/overlays/preview\n",
+		files:      []string{"apps/billing/base/deployment.yaml", "services/web/app.ts"},
 	}
+	server.WithGitHubClient(gh).WithServiceDetection(services.NewDetector(nil))
+
+	postPullRequest(t, server, PullRequestEvent{
+		Action:      "opened",
+		Number:      123,
+		PullRequest: PullRequest{Head: Ref{SHA: "abc123"}},
+		Repository:  Repository{FullName: "company/repo"},
+	})
+	drainEvents(t, server)
 
-	// Repo A: should be rate limited
-	if rl.Allow("repo-a") {
-		t.Error("repo-a request 3 was allowed, expected rate limit")
+	preview := &previewv1alpha1.PreviewEnvironment{}
+	if err := k8sClient.Get(context.Background(), types.NamespacedName{
+		Name:      testPreviewName,
+		Namespace: "previewd-system",
+	}, preview); err != nil {
+		t.Fatalf("Failed to get PreviewEnvironment: %v", err)
+	}
+	if want := []string{"billing"}; !reflect.DeepEqual(preview.Spec.Services, want) {
+		t.Errorf("PreviewEnvironment Services is %v, expected %v", preview.Spec.Services, want)
+	}
+}
+
+func TestHandlePROpened_AppliesRepoConfig(t *testing.T) {
+	server, k8sClient := setupTest(t)
+	gh := &fakeGitHubClient{repoConfig: "services: [frontend]\nttl: 8h\nargocd:\n  path: deploy/{{service}}\n"}
+	server.WithGitHubClient(gh)
+
+	event := PullRequestEvent{
+		Action:      "opened",
+		Number:      123,
+		PullRequest: PullRequest{Head: Ref{SHA: "abc123"}},
+		Repository:  Repository{FullName: "company/repo"},
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal test event: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	req.Header.Set("X-Hub-Signature-256", computeSignature(payload, testSecret))
+	w := httptest.NewRecorder()
+
+	server.handleWebhook(w, req)
+	drainEvents(t, server)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("handleWebhook for PR opened returns %d, expected %d", w.Code, http.StatusAccepted)
+	}
+
+	preview := &previewv1alpha1.PreviewEnvironment{}
+	if err := k8sClient.Get(context.Background(), types.NamespacedName{
+		Name:      testPreviewName,
+		Namespace: "previewd-system",
+	}, preview); err != nil {
+		t.Fatalf("Failed to get PreviewEnvironment: %v", err)
+	}
+
+	if !reflect.DeepEqual(preview.Spec.Services, []string{"frontend"}) {
+		t.Errorf("PreviewEnvironment Services is %v, expected [frontend]", preview.Spec.Services)
+	}
+	if preview.Spec.TTL != "8h" {
+		t.Errorf("PreviewEnvironment TTL is %q, expected 8h", preview.Spec.TTL)
+	}
+	if preview.Spec.SourcePath != "deploy/{{service}}" {
+		t.Errorf("PreviewEnvironment SourcePath is %q, expected deploy/{{service}}", preview.Spec.SourcePath)
+	}
+}
+
+func TestHandlePRSynchronized_RemovedRepoConfigKeys(t *testing.T) {
+	server, k8sClient := setupTest(t)
+	gh := &fakeGitHubClient{repoConfig: "services: [frontend]\nttl: 8h\nargocd:\n  path: deploy/{{service}}\n"}
+	server.WithGitHubClient(gh)
+
+	postPullRequest(t, server, PullRequestEvent{
+		Action:      "opened",
+		Number:      123,
+		PullRequest: PullRequest{Head: Ref{SHA: "abc123"}},
+		Repository:  Repository{FullName: "company/repo"},
+	})
+	drainEvents(t, server)
+
+	// The next commit removes every key but the services, which change
+	gh.repoConfig = "services: [backend]\n"
+	postPullRequest(t, server, PullRequestEvent{
+		Action:      "synchronize",
+		Number:      123,
+		PullRequest: PullRequest{Head: Ref{SHA: "def456"}},
+		Repository:  Repository{FullName: "company/repo"},
+	})
+	drainEvents(t, server)
+
+	preview := &previewv1alpha1.PreviewEnvironment{}
+	if err := k8sClient.Get(context.Background(), types.NamespacedName{
+		Name:      testPreviewName,
+		Namespace: "previewd-system",
+	}, preview); err != nil {
+		t.Fatalf("Failed to get PreviewEnvironment: %v", err)
+	}
+
+	if preview.Spec.HeadSHA != "def456" {
+		t.Fatalf("PreviewEnvironment HeadSHA is %q, expected def456", preview.Spec.HeadSHA)
+	}
+	if preview.Spec.TTL != "" || preview.Spec.SourcePath != "" {
+		t.Errorf("PreviewEnvironment TTL is %q and SourcePath %q, expected both cleared",
+			preview.Spec.TTL, preview.Spec.SourcePath)
+	}
+	if !reflect.DeepEqual(preview.Spec.Services, []string{"backend"}) {
+		t.Errorf("PreviewEnvironment Services is %v, expected [backend] from the updated file", preview.Spec.Services)
+	}
+}
+
+func TestHandlePROpened_InvalidRepoConfig(t *testing.T) {
+	server, k8sClient := setupTest(t)
+	gh := &fakeGitHubClient{repoConfig: "ttl: forever\n"}
+	server.WithGitHubClient(gh)
+
+	event := PullRequestEvent{
+		Action:      "opened",
+		Number:      123,
+		PullRequest: PullRequest{Head: Ref{SHA: "abc123"}},
+		Repository:  Repository{FullName: "company/repo"},
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal test event: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	req.Header.Set("X-Hub-Signature-256", computeSignature(payload, testSecret))
+	w := httptest.NewRecorder()
+
+	server.handleWebhook(w, req)
+	drainEvents(t, server)
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("handleWebhook for invalid config returns %d, expected %d", w.Code, http.StatusAccepted)
+	}
+
+	if len(gh.statuses) != 1 {
+		t.Fatalf("expected 1 commit status, got %d", len(gh.statuses))
+	}
+	status := gh.statuses[0]
+	if status.State != github.StatusStateFailure || status.Context != configStatusContext {
+		t.Errorf("commit status = %+v, expected failure with context %s", status, configStatusContext)
+	}
+	if len(status.Description) > maxStatusDescriptionLength {
+		t.Errorf("commit status description is %d characters, expected at most %d",
+			len(status.Description), maxStatusDescriptionLength)
+	}
+
+	preview := &previewv1alpha1.PreviewEnvironment{}
+	err = k8sClient.Get(context.Background(), types.NamespacedName{
+		Name:      testPreviewName,
+		Namespace: "previewd-system",
+	}, preview)
+	if err == nil {
+		t.Error("PreviewEnvironment was created despite invalid config")
+	}
+}
+
+func TestHandleWebhook_PreviewOptIn(t *testing.T) {
+	previewLabel := []Label{{Name: "preview"}}
+
+	tests := []struct {
+		event       PullRequestEvent
+		name        string
+		label       string
+		existing    bool
+		skipDrafts  bool
+		wantCode    int
+		wantPreview bool
+	}{
+		{
+			name:        "label-gated mode ignores opened PR without label",
+			label:       "preview",
+			event:       PullRequestEvent{Action: "opened"},
+			wantCode:    http.StatusOK,
+			wantPreview: false,
+		},
+		{
+			name:        "label-gated mode creates preview for opened PR with label",
+			label:       "preview",
+			event:       PullRequestEvent{Action: "opened", PullRequest: PullRequest{Labels: previewLabel}},
+			wantCode:    http.StatusAccepted,
+			wantPreview: true,
+		},
+		{
+			name:  "adding the preview label creates preview",
+			label: "preview",
+			event: PullRequestEvent{
+				Action:      "labeled",
+				Label:       &Label{Name: "preview"},
+				PullRequest: PullRequest{Labels: previewLabel},
+			},
+			wantCode:    http.StatusAccepted,
+			wantPreview: true,
+		},
+		{
+			name:  "adding another label is ignored",
+			label: "preview",
+			event: PullRequestEvent{
+				Action:      "labeled",
+				Label:       &Label{Name: "bug"},
+				PullRequest: PullRequest{Labels: []Label{{Name: "bug"}}},
+			},
+			wantCode:    http.StatusOK,
+			wantPreview: false,
+		},
+		{
+			name:        "removing the preview label tears down preview",
+			label:       "preview",
+			existing:    true,
+			event:       PullRequestEvent{Action: "unlabeled", Label: &Label{Name: "preview"}},
+			wantCode:    http.StatusAccepted,
+			wantPreview: false,
+		},
+		{
+			name:        "removing another label keeps preview",
+			label:       "preview",
+			existing:    true,
+			event:       PullRequestEvent{Action: "unlabeled", Label: &Label{Name: "bug"}},
+			wantCode:    http.StatusOK,
+			wantPreview: true,
+		},
+		{
+			name:        "labels are ignored when label-gated mode is disabled",
+			existing:    true,
+			event:       PullRequestEvent{Action: "unlabeled", Label: &Label{Name: "preview"}},
+			wantCode:    http.StatusOK,
+			wantPreview: true,
+		},
+		{
+			name:        "draft PR is skipped",
+			skipDrafts:  true,
+			event:       PullRequestEvent{Action: "opened", PullRequest: PullRequest{Draft: true}},
+			wantCode:    http.StatusOK,
+			wantPreview: false,
+		},
+		{
+			name:        "draft PR is previewed when drafts are not skipped",
+			event:       PullRequestEvent{Action: "opened", PullRequest: PullRequest{Draft: true}},
+			wantCode:    http.StatusAccepted,
+			wantPreview: true,
+		},
+		{
+			name:        "ready for review creates preview",
+			skipDrafts:  true,
+			event:       PullRequestEvent{Action: "ready_for_review"},
+			wantCode:    http.StatusAccepted,
+			wantPreview: true,
+		},
+		{
+			name:        "ready for review still requires the preview label",
+			label:       "preview",
+			skipDrafts:  true,
+			event:       PullRequestEvent{Action: "ready_for_review"},
+			wantCode:    http.StatusOK,
+			wantPreview: false,
+		},
+		{
+			name:        "converting to draft tears down preview",
+			skipDrafts:  true,
+			existing:    true,
+			event:       PullRequestEvent{Action: "converted_to_draft", PullRequest: PullRequest{Draft: true}},
+			wantCode:    http.StatusAccepted,
+			wantPreview: false,
+		},
+		{
+			name:        "synchronize is ignored without the preview label",
+			label:       "preview",
+			event:       PullRequestEvent{Action: "synchronize"},
+			wantCode:    http.StatusOK,
+			wantPreview: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, k8sClient := setupTest(t)
+			server.WithPreviewLabel(tt.label).WithSkipDrafts(tt.skipDrafts)
+
+			if tt.existing {
+				preview := &previewv1alpha1.PreviewEnvironment{}
+				preview.Name = testPreviewName
+				preview.Namespace = DefaultPreviewNamespace
+				preview.Labels = previewLabels("company/repo", 123)
+				preview.Spec.Repository = "company/repo"
+				preview.Spec.PRNumber = 123
+				if err := k8sClient.Create(context.Background(), preview); err != nil {
+					t.Fatalf("Failed to create test PreviewEnvironment: %v", err)
+				}
+			}
+
+			event := tt.event
+			event.Number = 123
+			event.PullRequest.Head = Ref{SHA: "abc123"}
+			event.Repository = Repository{FullName: "company/repo"}
+
+			payload, err := json.Marshal(event)
+			if err != nil {
+				t.Fatalf("Failed to marshal test event: %v", err)
+			}
+
+			req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+			req.Header.Set("X-GitHub-Event", "pull_request")
+			req.Header.Set("X-Hub-Signature-256", computeSignature(payload, testSecret))
+			w := httptest.NewRecorder()
+
+			server.handleWebhook(w, req)
+			drainEvents(t, server)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("handleWebhook returns %d, expected %d", w.Code, tt.wantCode)
+			}
+
+			preview := &previewv1alpha1.PreviewEnvironment{}
+			getErr := k8sClient.Get(context.Background(), types.NamespacedName{
+				Name:      testPreviewName,
+				Namespace: "previewd-system",
+			}, preview)
+			if exists := getErr == nil; exists != tt.wantPreview {
+				t.Errorf("PreviewEnvironment exists = %v, expected %v", exists, tt.wantPreview)
+			}
+		})
 	}
 }
 
@@ -503,8 +978,8 @@ func TestHandleWebhook_RateLimited(t *testing.T) {
 
 		if i < 10 {
 			// First 10 should succeed (or 201 Created)
-			if w.Code != http.StatusCreated {
-				t.Errorf("Request %d returned %d, expected %d", i+1, w.Code, http.StatusCreated)
+			if w.Code != http.StatusAccepted {
+				t.Errorf("Request %d returned %d, expected %d", i+1, w.Code, http.StatusAccepted)
 			}
 		} else {
 			// 11th should be rate limited
@@ -537,27 +1012,3 @@ type errorReader struct{}
 func (e *errorReader) Read(p []byte) (n int, err error) {
 	return 0, io.ErrUnexpectedEOF
 }
-
-func TestSanitizeLabel(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"company/repo", "company-repo"},
-		{"Company/Repo", "company-repo"},
-		{"company_name/repo_name", "company-name-repo-name"},
-		{"a" + string(make([]byte, 70)), "a" + string(make([]byte, 62))}, // Truncate to 63
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			result := sanitizeLabel(tt.input)
-			if result != tt.expected {
-				t.Errorf("sanitizeLabel(%q) = %q, expected %q", tt.input, result, tt.expected)
-			}
-			if len(result) > 63 {
-				t.Errorf("sanitizeLabel(%q) = %q (len=%d), exceeds 63 characters", tt.input, result, len(result))
-			}
-		})
-	}
-}
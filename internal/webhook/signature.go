@@ -37,8 +37,16 @@ func ValidateSignature(payload []byte, signature string, secret string) bool {
 		return false
 	}
 
-	// Extract hex-encoded HMAC from signature
-	receivedMAC := strings.TrimPrefix(signature, "sha256=")
+	// Verify the hex-encoded HMAC that follows the prefix
+	return validHMAC(payload, strings.TrimPrefix(signature, "sha256="), secret)
+}
+
+// validHMAC reports whether receivedMAC is the hex-encoded HMAC-SHA256 of
+// payload keyed with secret. Both must be non-empty.
+func validHMAC(payload []byte, receivedMAC string, secret string) bool {
+	if receivedMAC == "" || secret == "" {
+		return false
+	}
 
 	// Compute expected HMAC
 	mac := hmac.New(sha256.New, []byte(secret))
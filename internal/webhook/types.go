@@ -14,20 +14,32 @@
 
 package webhook
 
+import "time"
+
 // PullRequestEvent represents a GitHub pull_request webhook event
 type PullRequestEvent struct {
+	Label       *Label      `json:"label,omitempty"` // set for labeled/unlabeled actions
 	PullRequest PullRequest `json:"pull_request"`
 	Repository  Repository  `json:"repository"`
 	Action      string      `json:"action"`
+	Provider    string      `json:"-"` // Git provider the event came from (see previewv1alpha1.Provider*)
 	Number      int         `json:"number"`
 }
 
 // PullRequest contains PR metadata
 type PullRequest struct {
-	Head  Ref    `json:"head"`
-	Base  Ref    `json:"base"`
-	Title string `json:"title"`
-	State string `json:"state"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Head      Ref       `json:"head"`
+	Base      Ref       `json:"base"`
+	Title     string    `json:"title"`
+	State     string    `json:"state"`
+	Labels    []Label   `json:"labels"`
+	Draft     bool      `json:"draft"`
+}
+
+// Label represents a label applied to a pull request
+type Label struct {
+	Name string `json:"name"`
 }
 
 // Ref represents a git reference (branch)
@@ -47,3 +59,81 @@ type Repository struct {
 type Owner struct {
 	Login string `json:"login"`
 }
+
+// IssueCommentEvent represents a GitHub issue_comment webhook event.
+// Pull request conversation comments are delivered as issue comments.
+type IssueCommentEvent struct {
+	Issue      Issue      `json:"issue"`
+	Repository Repository `json:"repository"`
+	Action     string     `json:"action"`
+	Comment    Comment    `json:"comment"`
+}
+
+// Issue contains the issue (or pull request) a comment was made on
+type Issue struct {
+	PullRequest *IssuePullRequest `json:"pull_request,omitempty"` // set only for pull requests
+	Number      int               `json:"number"`
+}
+
+// IssuePullRequest marks an issue as a pull request
+type IssuePullRequest struct {
+	URL string `json:"url"`
+}
+
+// Comment contains issue comment metadata
+type Comment struct {
+	User Owner  `json:"user"`
+	Body string `json:"body"`
+	ID   int64  `json:"id"`
+}
+
+// PushEvent is a push to a branch, or the deletion of a branch, normalized
+// from the provider's push and branch delete events
+type PushEvent struct {
+	Repository Repository
+	Provider   string // Git provider the event came from (see previewv1alpha1.Provider*)
+	Branch     string
+	SHA        string   // new head of the branch; empty if Deleted
+	Files      []string // files added, modified or removed by the pushed commits
+	Deleted    bool
+}
+
+// pushPayload represents a GitHub or Gitea push webhook event
+type pushPayload struct {
+	Repository Repository   `json:"repository"`
+	Ref        string       `json:"ref"` // refs/heads/<branch> for branch pushes
+	After      string       `json:"after"`
+	Commits    []pushCommit `json:"commits"`
+	Deleted    bool         `json:"deleted"`
+}
+
+// pushCommit represents a commit in a GitHub, Gitea or GitLab push webhook event
+type pushCommit struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
+}
+
+// changedFiles lists the files changed by commits, each once
+func changedFiles(commits []pushCommit) []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, commit := range commits {
+		for _, list := range [][]string{commit.Added, commit.Modified, commit.Removed} {
+			for _, file := range list {
+				if !seen[file] {
+					seen[file] = true
+					files = append(files, file)
+				}
+			}
+		}
+	}
+	return files
+}
+
+// deletePayload represents a GitHub or Gitea delete webhook event
+type deletePayload struct {
+	Repository Repository `json:"repository"`
+	Ref        string     `json:"ref"` // the bare branch or tag name
+	RefType    string     `json:"ref_type"`
+}